@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,25 +11,56 @@ import (
 	"syscall"
 	"time"
 
+	"fuel-monitor-api/internal/alerts"
 	"fuel-monitor-api/internal/config"
 	"fuel-monitor-api/internal/database"
 	"fuel-monitor-api/internal/handlers"
+	"fuel-monitor-api/internal/logger"
+	"fuel-monitor-api/internal/mail"
 	"fuel-monitor-api/internal/middleware"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
 	"fuel-monitor-api/internal/ssh"
+	"fuel-monitor-api/internal/watchdog"
+	"fuel-monitor-api/internal/webhooks"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
+// version identifies the build; release builds set it with
+// -ldflags "-X main.version=<version>"
+var version = "dev"
+
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+		logger.Infof("No .env file found, using environment variables")
 	}
 
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Pagination.Validate(); err != nil {
+		log.Fatalf("Invalid pagination configuration: %v", err)
+	}
+	if err := cfg.Closing.Validate(); err != nil {
+		log.Fatalf("Invalid daily closing configuration: %v", err)
+	}
+	if err := cfg.RateLimit.Validate(); err != nil {
+		log.Fatalf("Invalid rate limit configuration: %v", err)
+	}
+
+	// Per-site and per-step detail is debug level; LOG_LEVEL=debug shows it
+	logLevel, err := logger.ParseLevel(cfg.Server.LogLevel)
+	if err != nil {
+		logger.Warnf("%v, using info", err)
+	}
+	logger.SetLevel(logLevel)
+
+	// Apply the configured generator/zesa "on" representations
+	models.SetOnStateValues(cfg.Sensors.OnStateValues)
+	models.SetTemperatureSensors(cfg.Sensors.TemperatureSensors)
 
 	// Setup SSH tunnel
 	sshClient, localPort, err := ssh.SetupTunnel(cfg)
@@ -48,32 +80,41 @@ func main() {
 	}
 	defer db.Close()
 
-	// Test database connection
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Database ping failed: %v", err)
+	// Load runtime settings overrides once initialization runs; env/default values apply until then
+	settingsStore := settings.NewStore(db, cfg)
+	// Sustained low fuel escalation is shared by the dashboard and webhook delivery
+	escalation := alerts.NewFuelEscalation(cfg.Dashboard.CriticalFuelDuration)
+	// Worker pool liveness is shared by the request handlers, the nightly job and db-stats
+	wd := watchdog.New()
+
+	// Setup Gin router. Data routes answer 503 until initialization completes.
+	readiness := &middleware.Readiness{}
+	router := setupRouter(cfg, db, settingsStore, escalation, wd, readiness)
+
+	initCtx, cancelInit := context.WithCancel(context.Background())
+	defer cancelInit()
+	// Alert webhooks are delivered once ready, unless alerting is switched off
+	webhookInterval := cfg.Webhooks.PollInterval
+	if !cfg.Features.Alerting {
+		webhookInterval = 0
 	}
-	log.Println("Database connected successfully")
-
-	// Fast auto-create sites from sensor_readings
-	if err := db.FastAutoCreateSites(); err != nil {
-		log.Printf("Warning: Failed to auto-create sites: %v", err)
-	}
-
-	// Setup Gin router
-	router := setupRouter(cfg, db)
+	notifier := webhooks.NewNotifier(db, settingsStore, webhooks.NewSender(cfg.Webhooks), escalation, webhookInterval)
+	// Nightly cumulative processing also starts once ready
+	cumulativeJob := handlers.NewCumulativeHandler(db, cfg, settingsStore, wd)
+	go initialize(initCtx, db, settingsStore, cfg.Sites, readiness, notifier, cumulativeJob)
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:      router,
 		ReadTimeout:  60 * time.Second,
-		WriteTimeout: 0,
+		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  300 * time.Second,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Server starting on port %d", cfg.Server.Port)
+		logger.Infof("Server starting on port %d", cfg.Server.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
@@ -83,7 +124,8 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	logger.Infof("Shutting down server...")
+	cancelInit()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -93,10 +135,63 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	log.Println("Server exited")
+	logger.Infof("Server exited")
+}
+
+// initialize runs the startup work that must finish before the API serves data:
+// schema, runtime settings and the initial sites sync. The database has already
+// been pinged by database.Connect. The sites sync is retried until it succeeds,
+// then the service is marked ready and the nightly cumulative processing and
+// webhook delivery start.
+func initialize(ctx context.Context, db *database.DB, settingsStore *settings.Store, sitesConfig config.SitesConfig, readiness *middleware.Readiness, notifier *webhooks.Notifier, cumulativeJob *handlers.CumulativeHandler) {
+	const retryDelay = 10 * time.Second
+
+	retry := func(step string, fn func() error) bool {
+		for {
+			err := fn()
+			if err == nil {
+				return true
+			}
+			logger.Warnf("%s failed, retrying in %v: %v", step, retryDelay, err)
+
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(retryDelay):
+			}
+		}
+	}
+
+	// Apply the schema this API owns
+	if err := db.EnsureSchema(); err != nil {
+		log.Fatalf("Failed to apply database schema: %v", err)
+	}
+
+	if err := settingsStore.Refresh(); err != nil {
+		logger.Warnf("Failed to load runtime settings: %v", err)
+	}
+
+	// Fast auto-create sites from sensor_readings
+	if !retry("Auto-creating sites", func() error {
+		_, err := db.FastAutoCreateSites(sitesConfig)
+		if errors.Is(err, database.ErrSiteDeviceIDNotUnique) {
+			// Retrying cannot help; the duplicates must be merged by hand
+			logger.Errorf("Not auto-creating sites: %v", err)
+			return nil
+		}
+		return err
+	}) {
+		return
+	}
+
+	readiness.SetReady()
+	logger.Infof("Initialization complete, service is ready")
+
+	go cumulativeJob.RunDailySchedule(ctx)
+	notifier.Run(ctx)
 }
 
-func setupRouter(cfg *config.Config, db *database.DB) *gin.Engine {
+func setupRouter(cfg *config.Config, db *database.DB, settingsStore *settings.Store, escalation *alerts.FuelEscalation, wd *watchdog.Watchdog, readiness *middleware.Readiness) *gin.Engine {
 	// Set Gin mode based on environment
 	if cfg.Server.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -104,8 +199,13 @@ func setupRouter(cfg *config.Config, db *database.DB) *gin.Engine {
 
 	router := gin.New()
 
-	// Add middleware
-	router.Use(gin.Logger())
+	// Known paths requested with the wrong method get 405 rather than 404
+	router.HandleMethodNotAllowed = true
+	router.NoMethod(middleware.MethodNotAllowed(router))
+
+	// Add middleware. The request ID comes first so every response carries it.
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestLogger())
 	router.Use(gin.Recovery())
 
 	// CORS configuration
@@ -115,76 +215,273 @@ func setupRouter(cfg *config.Config, db *database.DB) *gin.Engine {
 			"http://154.119.80.28:4173",
 			"http://127.0.0.1:4173",
 		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Content-Type", "Authorization", "If-None-Match", "X-API-Key", "X-Request-Id"},
+		ExposeHeaders:    []string{"ETag", "X-Total-Count", "X-Page", "X-Page-Size", "Link", "X-Request-Id"},
 		AllowCredentials: true,
 	}
 	router.Use(cors.New(corsConfig))
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db, cfg)
-	userHandler := handlers.NewUserHandler(db)
-	sitesHandler := handlers.NewSitesHandler(db)
-	dashboardHandler := handlers.NewDashboardHandler(db)
-	cumulativeHandler := handlers.NewCumulativeHandler(db)
+	userHandler := handlers.NewUserHandler(db, cfg)
+	sitesHandler := handlers.NewSitesHandler(db, cfg, settingsStore)
+	dashboardHandler := handlers.NewDashboardHandler(db, cfg, settingsStore, escalation, wd)
+	cumulativeHandler := handlers.NewCumulativeHandler(db, cfg, settingsStore, wd)
+	closingHandler := handlers.NewClosingHandler(db, cfg)
+	adminHandler := handlers.NewAdminHandler(db, cfg, settingsStore, wd)
+	webhookHandler := handlers.NewWebhookHandler(db, cfg, webhooks.NewSender(cfg.Webhooks))
+	reportHandler := handlers.NewReportHandler(cumulativeHandler, mail.NewSender(cfg.SMTP))
 
 	// Routes
-	setupRoutes(router, authHandler, userHandler, sitesHandler, dashboardHandler, cumulativeHandler)
+	setupRoutes(router, readiness, authHandler, userHandler, sitesHandler, dashboardHandler, cumulativeHandler, closingHandler, adminHandler, webhookHandler, reportHandler)
 
 	return router
 }
 
-func setupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, sitesHandler *handlers.SitesHandler, dashboardHandler *handlers.DashboardHandler, cumulativeHandler *handlers.CumulativeHandler) {
-	// Health check
-	router.GET("/api/health", func(c *gin.Context) {
+func setupRoutes(router *gin.Engine, readiness *middleware.Readiness, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, sitesHandler *handlers.SitesHandler, dashboardHandler *handlers.DashboardHandler, cumulativeHandler *handlers.CumulativeHandler, closingHandler *handlers.ClosingHandler, adminHandler *handlers.AdminHandler, webhookHandler *handlers.WebhookHandler, reportHandler *handlers.ReportHandler) {
+	features := authHandler.Config.Features
+	jwtSecret := authHandler.Config.JWT.Secret
+
+	// authRequired validates the bearer token; adminOnly additionally requires the
+	// admin role. AUTH_RECHECK_USER decides which tokens authRequired also
+	// re-checks against the database: with "admin", those claiming the admin
+	// role, so admin scope on data routes and admin-only routes alike follows
+	// the user's current role.
+	authRequired := []gin.HandlerFunc{middleware.AuthRequired(jwtSecret)}
+	adminOnly := []gin.HandlerFunc{middleware.RequireAdmin()}
+	recheck := middleware.NewUserRecheck(authHandler.DB, authHandler.Config.JWT.RecheckTTL)
+	switch authHandler.Config.JWT.RecheckUser {
+	case "all":
+		authRequired = append(authRequired, recheck.Middleware())
+	case "admin":
+		authRequired = append(authRequired, recheck.AdminMiddleware())
+	}
+	withAuth := func(handler gin.HandlerFunc) []gin.HandlerFunc {
+		return append(authRequired[:len(authRequired):len(authRequired)], handler)
+	}
+
+	// Per-user limits on the expensive routes (RATE_LIMIT_*). Routes sharing a
+	// limiter share each user's budget.
+	rateLimits := middleware.NewMemoryRateLimitStore()
+	limited := func(name string, perMinute int) gin.HandlerFunc {
+		adminPerMinute := int(float64(perMinute) * authHandler.Config.RateLimit.AdminMultiplier)
+		return middleware.RateLimitPerUser(rateLimits, name, middleware.RateLimit{PerMinute: perMinute}, middleware.RateLimit{PerMinute: adminPerMinute})
+	}
+	dashboardLimit := limited("dashboard", authHandler.Config.RateLimit.Dashboard)
+	cumulativeLimit := limited("cumulative", authHandler.Config.RateLimit.Cumulative)
+
+	// Time limits on the routes expected to answer quickly (REQUEST_TIMEOUT,
+	// DASHBOARD_REQUEST_TIMEOUT). Everything else is bounded by the server's
+	// write timeout, which exports and long-running rebuilds lift with NoTimeout.
+	requestTimeout := middleware.Timeout(authHandler.Config.Server.RequestTimeout)
+	dashboardTimeout := middleware.Timeout(authHandler.Config.Dashboard.RequestTimeout)
+
+	// Every route lives under the configurable base path (API_BASE_PATH)
+	base := router.Group(authHandler.Config.Server.BasePath)
+
+	// Health check (liveness) and readiness, available while starting up
+	base.GET("/health", func(c *gin.Context) {
+		middleware.SetNoStore(c)
 		c.JSON(http.StatusOK, gin.H{
 			"status":    "healthy",
 			"timestamp": time.Now().Format(time.RFC3339),
 		})
 	})
+	base.GET("/health/ready", readiness.Handler)
+
+	// Build version, the same for every caller
+	base.GET("/version", func(c *gin.Context) {
+		middleware.SetPublicCache(c, authHandler.Config.Server.MetadataCacheMaxAge)
+		c.JSON(http.StatusOK, gin.H{
+			"version":     version,
+			"calcVersion": models.CumulativeCalcVersion,
+		})
+	})
+
+	// All other routes wait for startup initialization
+	api := base.Group("")
+	api.Use(readiness.RequireReady())
 
 	// Auth routes
-	auth := router.Group("/api/auth")
+	auth := api.Group("/auth")
+	auth.Use(middleware.NoStore())
+	auth.Use(requestTimeout)
 	{
 		auth.POST("/login", authHandler.Login)
-		auth.POST("/logout", middleware.AuthRequired(authHandler.Config.JWT.Secret), authHandler.Logout)
-		auth.GET("/validate", middleware.AuthRequired(authHandler.Config.JWT.Secret), authHandler.ValidateToken)
+		auth.POST("/logout", withAuth(authHandler.Logout)...)
+		auth.GET("/validate", withAuth(authHandler.ValidateToken)...)
+		if features.Introspection {
+			auth.POST("/introspect", middleware.RequireAPIKeyOrAdmin(authHandler.Config.JWT.IntrospectionAPIKey, jwtSecret, recheck), authHandler.Introspect)
+		}
 	}
 
 	// Dashboard route (authenticated users)
-	router.GET("/api/dashboard", middleware.AuthRequired(authHandler.Config.JWT.Secret), dashboardHandler.GetDashboard)
+	api.GET("/dashboard", append(authRequired[:len(authRequired):len(authRequired)], dashboardLimit, dashboardTimeout, dashboardHandler.GetDashboard)...)
 
 	// Cumulative readings route (authenticated users) - ADD THIS LINE
-	router.POST("/api/cumulative-readings", middleware.AuthRequired(authHandler.Config.JWT.Secret), cumulativeHandler.GetCumulativeReadings)
+	api.POST("/cumulative-readings", append(authRequired[:len(authRequired):len(authRequired)], cumulativeLimit, middleware.NoTimeout(), cumulativeHandler.GetCumulativeReadings)...)
 
 	// Register the new GET endpoint for cumulative readings by date range
-	router.GET("/api/cumulative-readings", middleware.AuthRequired(authHandler.Config.JWT.Secret), cumulativeHandler.GetCumulativeReadingsByDateRange)
+	api.GET("/cumulative-readings", append(authRequired[:len(authRequired):len(authRequired)], cumulativeLimit, cumulativeHandler.GetCumulativeReadingsByDateRange)...)
+
+	// Stored cumulative readings, served without recomputation
+	api.GET("/cumulative-readings/stored", withAuth(cumulativeHandler.GetStoredCumulativeReadings)...)
+
+	// Read-only views over stored cumulative readings (authenticated users)
+	cumulative := api.Group("/cumulative")
+	cumulative.Use(authRequired...)
+	cumulative.Use(cumulativeLimit)
+	{
+		if features.Leaderboard {
+			cumulative.GET("/leaderboard", cumulativeHandler.GetLeaderboard)
+		}
+		cumulative.GET("/status", cumulativeHandler.GetProcessingStatus)
+		cumulative.GET("/by-date", cumulativeHandler.GetCumulativeByDate)
+		cumulative.GET("/by-location", cumulativeHandler.GetCumulativeByLocation)
+		cumulative.GET("/matrix", cumulativeHandler.GetCumulativeMatrix)
+		cumulative.GET("/available-dates", cumulativeHandler.GetAvailableDates)
+		cumulative.GET("/offline-ranking", cumulativeHandler.GetOfflineRanking)
+		cumulative.GET("/efficiency-ranking", cumulativeHandler.GetEfficiencyRanking)
+		cumulative.GET("/outdated", append(adminOnly[:len(adminOnly):len(adminOnly)], cumulativeHandler.GetOutdatedReadings)...)
+		cumulative.GET("/range/export", middleware.NoTimeout(), cumulativeHandler.ExportCumulativeRange)
+	}
+
+	// Emailed reports (authenticated users)
+	reports := api.Group("/reports")
+	reports.Use(authRequired...)
+	reports.Use(cumulativeLimit)
+	{
+		reports.POST("/email", middleware.NoTimeout(), reportHandler.EmailReport)
+	}
+
+	// A disabled feature's routes do not exist. The ones a /sites/:id route
+	// under another method would match are answered with 404 explicitly, so
+	// they are not reported as 405.
+	notFound := func(c *gin.Context) {
+		c.String(http.StatusNotFound, "404 page not found")
+	}
+	if !features.SensorQuality {
+		api.GET("/sites/frozen-sensors", notFound)
+	}
+	if !features.Alerting {
+		api.GET("/sites/alert-preview", notFound)
+	}
 
 	// Sites routes (authenticated users)
-	sites := router.Group("/api/sites")
-	sites.Use(middleware.AuthRequired(authHandler.Config.JWT.Secret))
+	sites := api.Group("/sites")
+	sites.Use(authRequired...)
+	sites.Use(requestTimeout)
 	{
 		sites.GET("", sitesHandler.GetSites)
+		sites.GET("/search", sitesHandler.SearchSites)
+		if features.SensorQuality {
+			sites.GET("/frozen-sensors", sitesHandler.GetFrozenSensors)
+		}
+		if features.Alerting {
+			sites.GET("/alerts/long-runtime", sitesHandler.GetLongRuntimeAlerts)
+			sites.GET("/alert-preview", append(adminOnly[:len(adminOnly):len(adminOnly)], dashboardTimeout, dashboardHandler.PreviewLowFuelAlerts)...)
+		}
+		sites.GET("/:id/sensors", sitesHandler.GetDeviceSensors)
+		sites.GET("/:id/sensor/:name/latest", sitesHandler.GetLatestSensorValue)
+		sites.GET("/:id/runtime-forecast", sitesHandler.GetRuntimeForecast)
+		sites.GET("/:id/daily-deltas", sitesHandler.GetDailyDeltas)
+		sites.GET("/:id/low-fuel-threshold", sitesHandler.GetSiteLowFuelThreshold)
+		sites.PATCH("/:id", append(adminOnly[:len(adminOnly):len(adminOnly)], sitesHandler.PatchSite)...)
+		sites.PUT("/:id/maintenance", append(adminOnly[:len(adminOnly):len(adminOnly)], sitesHandler.SetSiteMaintenance)...)
+		sites.PUT("/:id/alerts", append(adminOnly[:len(adminOnly):len(adminOnly)], sitesHandler.SetSiteAlerts)...)
+		sites.PUT("/:id/low-fuel", append(adminOnly[:len(adminOnly):len(adminOnly)], sitesHandler.SetSiteLowFuel)...)
+		sites.PUT("/thresholds/bulk", append(adminOnly[:len(adminOnly):len(adminOnly)], sitesHandler.BulkSetLowFuelThreshold)...)
+		sites.POST("/:id/cumulative/rebuild", append(adminOnly[:len(adminOnly):len(adminOnly)], middleware.NoTimeout(), cumulativeHandler.RebuildSiteHistory)...)
+		if features.RawReadings {
+			sites.GET("/:id/level-at", sitesHandler.GetFuelLevelAt)
+			sites.GET("/:id/readings", sitesHandler.GetSensorReadings)
+			sites.GET("/:id/volume-series", sitesHandler.GetFuelVolumeSeries)
+			sites.GET("/:id/sensor/:name/recent", append(adminOnly[:len(adminOnly):len(adminOnly)], sitesHandler.GetRecentSensorReadings)...)
+		}
+	}
+
+	// Roles users hold (authenticated users)
+	api.GET("/roles", append(authRequired[:len(authRequired):len(authRequired)], requestTimeout, userHandler.GetRoles)...)
+
+	// The authenticated user's own data (any role)
+	me := api.Group("/me")
+	me.Use(authRequired...)
+	me.Use(middleware.NoStore())
+	{
+		me.GET("/logins", userHandler.GetMyLogins)
+		me.GET("/preferences", userHandler.GetMyPreferences)
+		me.PUT("/preferences", userHandler.UpdateMyPreferences)
 	}
 
 	// User management routes (admin only)
-	users := router.Group("/api/users")
-	users.Use(middleware.AuthRequired(authHandler.Config.JWT.Secret))
-	users.Use(middleware.RequireAdmin())
+	users := api.Group("/users")
+	users.Use(authRequired...)
+	users.Use(adminOnly...)
+	users.Use(middleware.NoStore())
 	{
 		users.GET("", userHandler.GetUsers)
+		users.GET("/inactive", userHandler.GetInactiveUsers)
+		users.GET("/export", middleware.NoTimeout(), userHandler.ExportUsers)
+		users.GET("/assignment-counts", userHandler.GetAssignmentCounts)
 		users.GET("/:id", userHandler.GetUserByID)
+		users.GET("/:id/logins", userHandler.GetUserLogins)
 		users.POST("", userHandler.CreateUser)
 		users.PUT("/:id", userHandler.UpdateUser)
 		users.DELETE("/:id", userHandler.DeleteUser)
 	}
 
 	// User-Site assignment routes (admin only) - different base path to avoid conflicts
-	assignments := router.Group("/api/assignments")
-	assignments.Use(middleware.AuthRequired(authHandler.Config.JWT.Secret))
-	assignments.Use(middleware.RequireAdmin())
+	assignments := api.Group("/assignments")
+	assignments.Use(authRequired...)
+	assignments.Use(adminOnly...)
 	{
 		assignments.POST("/user/:userId/sites", sitesHandler.AssignSitesToUser)
 		assignments.GET("/user/:userId/sites", sitesHandler.GetUserSiteAssignments)
+		assignments.POST("/bulk", sitesHandler.BulkAssignSites)
+		assignments.POST("/clone", sitesHandler.CloneAssignments)
+	}
+
+	// Active alerts and acknowledgements (authenticated users)
+	if features.Alerting {
+		alertRoutes := api.Group("/alerts")
+		alertRoutes.Use(authRequired...)
+		alertRoutes.Use(middleware.NoStore())
+		{
+			alertRoutes.GET("/active", dashboardLimit, dashboardTimeout, dashboardHandler.GetActiveAlerts)
+			alertRoutes.POST("/ack", dashboardHandler.AcknowledgeAlert)
+			alertRoutes.POST("/ack/bulk", dashboardLimit, dashboardTimeout, dashboardHandler.BulkAcknowledgeAlerts)
+		}
+	}
+
+	// Alert webhooks (admin only)
+	if features.Alerting {
+		hooks := api.Group("/webhooks")
+		hooks.Use(authRequired...)
+		hooks.Use(adminOnly...)
+		hooks.Use(middleware.NoStore())
+		{
+			hooks.GET("", webhookHandler.GetWebhooks)
+			hooks.POST("", webhookHandler.CreateWebhook)
+			hooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+			hooks.POST("/:id/test", webhookHandler.TestWebhook)
+		}
+	}
+
+	// Admin maintenance routes (admin only)
+	if features.AdminTools {
+		admin := api.Group("/admin")
+		admin.Use(authRequired...)
+		admin.Use(adminOnly...)
+		admin.Use(middleware.NoStore())
+		{
+			admin.POST("/closing/rebuild", middleware.NoTimeout(), closingHandler.RebuildDailyClosing)
+			admin.GET("/db-stats", adminHandler.GetDBStats)
+			admin.GET("/diagnostics", adminHandler.GetDiagnostics)
+			admin.POST("/devices/rename", middleware.NoTimeout(), adminHandler.RenameDevice)
+			admin.GET("/config", adminHandler.GetConfig)
+			admin.GET("/settings", adminHandler.GetSettings)
+			admin.PUT("/settings", adminHandler.UpdateSettings)
+		}
 	}
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/alerts"
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/middleware"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter builds the application router for cfg over a database that is
+// never reached, marked ready
+func newTestRouter(cfg *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	if cfg.JWT.Secret == "" {
+		cfg.JWT.Secret = "test-secret"
+	}
+	db := &database.DB{}
+	readiness := &middleware.Readiness{}
+	readiness.SetReady()
+	return setupRouter(cfg, db, settings.NewStore(db, cfg), alerts.NewFuelEscalation(time.Hour), watchdog.New(), readiness)
+}
+
+func TestSetupRouterBasePath(t *testing.T) {
+	router := newTestRouter(&config.Config{Server: config.ServerConfig{BasePath: "/fuel/api"}})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/fuel/api/health", http.StatusOK},
+		// Authenticated routes are mounted too, and reach their middleware
+		{"/fuel/api/dashboard", http.StatusUnauthorized},
+		{"/api/health", http.StatusNotFound},
+		{"/health", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("GET %s = %d, want %d", tt.path, recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSetupRouterCORSPreflight(t *testing.T) {
+	router := newTestRouter(&config.Config{Server: config.ServerConfig{BasePath: "/api"}})
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/api/sites/3", nil)
+			req.Header.Set("Origin", "http://localhost:4173")
+			req.Header.Set("Access-Control-Request-Method", method)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusNoContent {
+				t.Fatalf("preflight status = %d, want 204", recorder.Code)
+			}
+			if allowed := recorder.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(allowed, method) {
+				t.Errorf("Access-Control-Allow-Methods = %q, want it to include %s", allowed, method)
+			}
+		})
+	}
+}
+
+func TestSetupRouterVersion(t *testing.T) {
+	router := newTestRouter(&config.Config{Server: config.ServerConfig{BasePath: "/api", MetadataCacheMaxAge: time.Hour}})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	if got := recorder.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("Cache-Control = %q, want public, max-age=3600", got)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, `"version":"dev"`) {
+		t.Errorf("body = %s, want the dev version", body)
+	}
+
+	// Roles are only for authenticated users
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/roles", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("roles status = %d, want 401", recorder.Code)
+	}
+	if got := recorder.Header().Get("Cache-Control"); strings.Contains(got, "public") {
+		t.Errorf("unauthenticated roles Cache-Control = %q, want it not public", got)
+	}
+}
+
+func TestSetupRouterFeatures(t *testing.T) {
+	routes := []struct {
+		feature string
+		method  string
+		path    string
+		enable  func(*config.FeaturesConfig)
+	}{
+		{"introspection", http.MethodPost, "/api/auth/introspect", func(f *config.FeaturesConfig) { f.Introspection = true }},
+		{"leaderboard", http.MethodGet, "/api/cumulative/leaderboard", func(f *config.FeaturesConfig) { f.Leaderboard = true }},
+		{"sensor quality", http.MethodGet, "/api/sites/frozen-sensors", func(f *config.FeaturesConfig) { f.SensorQuality = true }},
+		{"raw readings", http.MethodGet, "/api/sites/3/readings", func(f *config.FeaturesConfig) { f.RawReadings = true }},
+		{"raw readings", http.MethodGet, "/api/sites/3/level-at", func(f *config.FeaturesConfig) { f.RawReadings = true }},
+		{"admin tools", http.MethodGet, "/api/admin/db-stats", func(f *config.FeaturesConfig) { f.AdminTools = true }},
+		{"admin tools", http.MethodPost, "/api/admin/closing/rebuild", func(f *config.FeaturesConfig) { f.AdminTools = true }},
+		{"alerting", http.MethodGet, "/api/alerts/active", func(f *config.FeaturesConfig) { f.Alerting = true }},
+		{"alerting", http.MethodGet, "/api/webhooks", func(f *config.FeaturesConfig) { f.Alerting = true }},
+		{"alerting", http.MethodGet, "/api/sites/alerts/long-runtime", func(f *config.FeaturesConfig) { f.Alerting = true }},
+		{"alerting", http.MethodGet, "/api/sites/alert-preview", func(f *config.FeaturesConfig) { f.Alerting = true }},
+	}
+
+	for _, route := range routes {
+		t.Run(route.feature+" "+route.path, func(t *testing.T) {
+			for _, enabled := range []bool{true, false} {
+				cfg := &config.Config{Server: config.ServerConfig{BasePath: "/api"}}
+				if enabled {
+					route.enable(&cfg.Features)
+				}
+				router := newTestRouter(cfg)
+
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, httptest.NewRequest(route.method, route.path, nil))
+
+				// An enabled route reaches its authentication; a disabled one does not exist
+				want := http.StatusUnauthorized
+				if !enabled {
+					want = http.StatusNotFound
+				}
+				if recorder.Code != want {
+					t.Errorf("%s %s with the feature enabled=%t = %d, want %d", route.method, route.path, enabled, recorder.Code, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package alerts
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Escalation describes a site that has just become critical
+type Escalation struct {
+	SiteID int
+	// Level and At are the fuel level (percent) and time of the site's latest reading
+	Level float64
+	At    time.Time
+	// Since is when the fuel level first fell to the critical threshold
+	Since time.Time
+}
+
+// fuelState is the low-fuel history of one site
+type fuelState struct {
+	lastSeen   time.Time
+	belowSince time.Time // zero while the level is above the critical threshold
+	level      float64
+	critical   bool
+	notified   bool
+}
+
+// FuelEscalation tracks how long each site's fuel level has stayed at or below
+// the critical threshold. A site becomes critical once it has stayed there for
+// the configured duration and stays critical until a reading above the
+// threshold is observed. Readings are judged by their own timestamps, so a site
+// whose sensor stops reporting does not escalate.
+type FuelEscalation struct {
+	mu       sync.Mutex
+	duration time.Duration
+	sites    map[int]*fuelState
+}
+
+// NewFuelEscalation creates a tracker that escalates sites after duration
+// below the critical threshold. A duration of 0 escalates immediately.
+func NewFuelEscalation(duration time.Duration) *FuelEscalation {
+	return &FuelEscalation{
+		duration: duration,
+		sites:    make(map[int]*fuelState),
+	}
+}
+
+// Observe records a site's fuel level reading taken at the given time and
+// reports whether the site is critical. Readings older than the newest one
+// already observed for the site do not change its state.
+func (e *FuelEscalation) Observe(siteID int, level, threshold float64, at time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, ok := e.sites[siteID]
+	if !ok {
+		state = &fuelState{}
+		e.sites[siteID] = state
+	}
+	if at.Before(state.lastSeen) {
+		return state.critical
+	}
+	state.lastSeen = at
+	state.level = level
+
+	if level > threshold {
+		state.belowSince = time.Time{}
+		state.critical = false
+		state.notified = false
+		return false
+	}
+
+	if state.belowSince.IsZero() {
+		state.belowSince = at
+	}
+	if !state.critical && at.Sub(state.belowSince) >= e.duration {
+		state.critical = true
+	}
+	return state.critical
+}
+
+// Critical reports whether the site is currently critical
+func (e *FuelEscalation) Critical(siteID int) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, ok := e.sites[siteID]
+	return ok && state.critical
+}
+
+// Escalations returns the sites that have become critical and not yet been
+// marked notified, ordered by site ID. A site keeps being returned until
+// MarkNotified is called for it; it escalates again only after a reading above
+// the threshold has cleared it.
+func (e *FuelEscalation) Escalations() []Escalation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	escalations := []Escalation{}
+	for siteID, state := range e.sites {
+		if !state.critical || state.notified {
+			continue
+		}
+		escalations = append(escalations, Escalation{
+			SiteID: siteID,
+			Level:  state.level,
+			Since:  state.belowSince,
+			At:     state.lastSeen,
+		})
+	}
+
+	sort.Slice(escalations, func(i, j int) bool {
+		return escalations[i].SiteID < escalations[j].SiteID
+	})
+	return escalations
+}
+
+// MarkNotified records that the site's escalation has been delivered, so
+// Escalations stops returning it. It has no effect unless the site is critical.
+func (e *FuelEscalation) MarkNotified(siteID int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if state, ok := e.sites[siteID]; ok && state.critical {
+		state.notified = true
+	}
+}
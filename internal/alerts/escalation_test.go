@@ -0,0 +1,82 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFuelEscalationObserve(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	type reading struct {
+		level float64
+		at    time.Time
+		want  bool
+	}
+
+	tests := []struct {
+		name     string
+		duration time.Duration
+		readings []reading
+	}{
+		{"above threshold", 30 * time.Minute, []reading{{50, at(0), false}, {40, at(60), false}}},
+		{"escalates after duration", 30 * time.Minute, []reading{{8, at(0), false}, {7, at(29), false}, {7, at(30), true}, {6, at(45), true}}},
+		{"zero duration escalates at once", 0, []reading{{5, at(0), true}}},
+		{"threshold is inclusive", 0, []reading{{10.1, at(0), false}, {10, at(1), true}}},
+		{"recovery clears", 30 * time.Minute, []reading{{5, at(0), false}, {5, at(30), true}, {20, at(40), false}, {5, at(50), false}}},
+		{"dip resets the clock", 30 * time.Minute, []reading{{5, at(0), false}, {15, at(20), false}, {5, at(25), false}, {5, at(50), false}, {5, at(55), true}}},
+		{"older reading ignored", 30 * time.Minute, []reading{{5, at(0), false}, {5, at(30), true}, {50, at(10), true}}},
+		{"silent sensor does not escalate", 30 * time.Minute, []reading{{5, at(0), false}, {5, at(0), false}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewFuelEscalation(tt.duration)
+			for i, r := range tt.readings {
+				if got := e.Observe(1, r.level, 10, r.at); got != r.want {
+					t.Fatalf("reading %d (%.0f%% at %v): critical = %v, want %v", i, r.level, r.at.Sub(start), got, r.want)
+				}
+			}
+			want := tt.readings[len(tt.readings)-1].want
+			if got := e.Critical(1); got != want {
+				t.Errorf("Critical() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFuelEscalationNotified(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	e := NewFuelEscalation(0)
+	e.Observe(2, 5, 10, start)
+	e.Observe(1, 5, 10, start)
+
+	// Pending until marked notified
+	for i := 0; i < 2; i++ {
+		escalations := e.Escalations()
+		if len(escalations) != 2 || escalations[0].SiteID != 1 || escalations[1].SiteID != 2 {
+			t.Fatalf("call %d: escalations = %+v, want sites 1 and 2", i, escalations)
+		}
+	}
+
+	e.MarkNotified(1)
+	if escalations := e.Escalations(); len(escalations) != 1 || escalations[0].SiteID != 2 {
+		t.Fatalf("after marking site 1: escalations = %+v, want site 2", escalations)
+	}
+
+	// Recovering and dropping again escalates anew
+	e.Observe(1, 50, 10, start.Add(time.Minute))
+	e.Observe(1, 5, 10, start.Add(2*time.Minute))
+	if escalations := e.Escalations(); len(escalations) != 2 {
+		t.Fatalf("after recurring: escalations = %+v, want sites 1 and 2", escalations)
+	}
+
+	// Marking a site that is not critical does nothing
+	e.Observe(3, 50, 10, start)
+	e.MarkNotified(3)
+	e.Observe(3, 5, 10, start.Add(time.Minute))
+	if escalations := e.Escalations(); len(escalations) != 3 {
+		t.Errorf("escalations = %+v, want sites 1, 2 and 3", escalations)
+	}
+}
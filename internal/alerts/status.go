@@ -0,0 +1,67 @@
+package alerts
+
+import (
+	"time"
+
+	"fuel-monitor-api/internal/models"
+)
+
+// Status returns a site's alert status for its latest reading: "critical_fuel"
+// when criticalFuel is set, otherwise "low_fuel", "generator_off" or "normal".
+// Sites in maintenance are "maintenance" instead, as planned downtime is not an alert.
+func Status(site *models.Site, reading *models.SensorReading, siteType *models.SiteType, lowFuelThreshold float64, criticalFuel bool, now time.Time) string {
+	if site.InMaintenance(now) {
+		return "maintenance"
+	}
+
+	generatorExpected := siteType.Expects("generator_state")
+	fuelLevelPercentage := reading.FuelLevelPercentage()
+
+	switch {
+	case criticalFuel:
+		return models.AlertCriticalFuel
+	case isLowFuel(site, reading, fuelLevelPercentage, lowFuelThreshold):
+		return models.AlertLowFuel
+	case generatorExpected && !reading.GeneratorOn && fuelLevelPercentage > 0:
+		return models.AlertGeneratorOff
+	default:
+		return "normal"
+	}
+}
+
+// LowFuel reports whether a site's reading is low on fuel under lowFuelThreshold,
+// the way Status decides low_fuel
+func LowFuel(site *models.Site, reading *models.SensorReading, lowFuelThreshold float64) bool {
+	return isLowFuel(site, reading, reading.FuelLevelPercentage(), lowFuelThreshold)
+}
+
+// isLowFuel reports whether a site's fuel is low under its low fuel mode. The
+// site's own percent threshold, when set, replaces lowFuelThreshold. Liters
+// remaining come from the volume reading; without one the percent threshold applies.
+func isLowFuel(site *models.Site, reading *models.SensorReading, fuelLevelPercentage, lowFuelThreshold float64) bool {
+	if site.LowFuelThreshold != nil {
+		lowFuelThreshold = *site.LowFuelThreshold
+	}
+	percentLow := fuelLevelPercentage <= lowFuelThreshold
+	if site.LowFuelMode == models.LowFuelModePercent || site.LowFuelLiters == nil || !reading.FuelVolumeParsed {
+		return percentLow
+	}
+
+	litersLow := reading.FuelVolumeFloat <= *site.LowFuelLiters
+	if site.LowFuelMode == models.LowFuelModeEither {
+		return percentLow || litersLow
+	}
+	return litersLow
+}
+
+// CurrentStatus is Status for a site's latest realtime reading, or "offline"
+// when the device reports no fuel level
+func CurrentStatus(site *models.Site, reading *models.SensorReading, siteType *models.SiteType, lowFuelThreshold float64, criticalFuel bool, now time.Time) string {
+	if reading == nil || reading.FuelLevel == "" {
+		if site.InMaintenance(now) {
+			return "maintenance"
+		}
+		return models.AlertOffline
+	}
+	return Status(site, reading, siteType, lowFuelThreshold, criticalFuel, now)
+}
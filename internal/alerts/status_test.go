@@ -0,0 +1,50 @@
+package alerts
+
+import (
+	"testing"
+
+	"fuel-monitor-api/internal/models"
+)
+
+func TestLowFuel(t *testing.T) {
+	liters := func(l float64) *float64 { return &l }
+
+	tests := []struct {
+		name       string
+		mode       string
+		liters     *float64
+		threshold  *float64
+		fuelLevel  string
+		fuelVolume string
+		want       bool
+	}{
+		{"percent below threshold", models.LowFuelModePercent, nil, nil, "20", "900", true},
+		{"percent above threshold", models.LowFuelModePercent, nil, nil, "30", "900", false},
+		{"percent ignores liters", models.LowFuelModePercent, liters(100), nil, "30", "50", false},
+		{"percent with site threshold", models.LowFuelModePercent, nil, liters(10), "20", "900", false},
+
+		{"liters below threshold", models.LowFuelModeLiters, liters(100), nil, "80", "90", true},
+		{"liters at threshold", models.LowFuelModeLiters, liters(100), nil, "80", "100", true},
+		{"liters above threshold ignores percent", models.LowFuelModeLiters, liters(100), nil, "10", "150", false},
+		// Without a volume reading, or a liters threshold, percent decides
+		{"liters without volume reading", models.LowFuelModeLiters, liters(100), nil, "20", "", true},
+		{"liters with unparsable volume", models.LowFuelModeLiters, liters(100), nil, "30", "n/a", false},
+		{"liters without threshold", models.LowFuelModeLiters, nil, nil, "20", "900", true},
+
+		{"either low by percent", models.LowFuelModeEither, liters(100), nil, "20", "500", true},
+		{"either low by liters", models.LowFuelModeEither, liters(100), nil, "80", "90", true},
+		{"either not low", models.LowFuelModeEither, liters(100), nil, "80", "500", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := &models.Site{LowFuelMode: tt.mode, LowFuelLiters: tt.liters, LowFuelThreshold: tt.threshold}
+			reading := &models.SensorReading{FuelLevel: tt.fuelLevel, FuelVolume: tt.fuelVolume}
+			reading.ParseValues()
+
+			if got := LowFuel(site, reading, 25); got != tt.want {
+				t.Errorf("LowFuel = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,28 +1,65 @@
 package config
 
 import (
+	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	SSH      SSHConfig
-	JWT      JWTConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	SSH        SSHConfig
+	JWT        JWTConfig
+	Sites      SitesConfig
+	Closing    ClosingConfig
+	Cumulative CumulativeConfig
+	Sensors    SensorsConfig
+	Dashboard  DashboardConfig
+	Features   FeaturesConfig
+	Audit      AuditConfig
+	Webhooks   WebhooksConfig
+	Pagination PaginationConfig
+	SMTP       SMTPConfig
+	RateLimit  RateLimitConfig
 }
 
 type ServerConfig struct {
 	Port        int
 	Environment string
+	// BasePath is the prefix every route is mounted under, e.g. "/api" or "/fuel/api"
+	BasePath string
+	// LogLevel is the minimum level logged: debug, info, warn or error
+	LogLevel string
+	// WriteTimeout bounds how long any response may take to write. File exports
+	// opt out of it. 0 disables it.
+	WriteTimeout time.Duration
+	// RequestTimeout bounds the fast routes (auth and sites). 0 disables it.
+	RequestTimeout time.Duration
+	// MetadataCacheMaxAge is how long clients may cache the roles and version responses
+	MetadataCacheMaxAge time.Duration
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	Name     string
-	User     string
-	Password string
+	Host            string
+	Port            int
+	Name            string
+	User            string
+	Password        string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// ConnectAttempts is how many times the initial ping is tried before Connect
+	// gives up; ConnectBackoff is the first wait between tries, doubled each retry
+	ConnectAttempts int
+	ConnectBackoff  time.Duration
+	// SlowQueryThreshold is how long the dashboard and cumulative queries may take
+	// before they are logged as slow; zero disables the logging
+	SlowQueryThreshold time.Duration
 }
 
 type SSHConfig struct {
@@ -31,18 +68,213 @@ type SSHConfig struct {
 	Password       string
 	RemoteBindHost string
 	RemoteBindPort int
+	// LocalPort pins the local tunnel port; 0 picks a random free port
+	LocalPort int
 }
 
 type JWTConfig struct {
 	Secret    string
 	ExpiresIn string
+	// IntrospectionAPIKey lets gateways call token introspection without an
+	// admin token. Empty disables API key access.
+	IntrospectionAPIKey string
+	// RecheckUser re-validates token claims against the database on every
+	// authenticated route: "off", "admin" (tokens claiming the admin role) or
+	// "all" (every token). Lookups are cached per user for RecheckTTL.
+	RecheckUser string
+	RecheckTTL  time.Duration
+	// ReportDisabledAccounts answers a correct password for a deactivated
+	// account with 403 "account disabled" rather than "invalid credentials"
+	ReportDisabledAccounts bool
+}
+
+// SitesConfig controls how sites auto-created for new devices are named
+type SitesConfig struct {
+	// NameStyle is "title" (the device ID without its prefix, hyphens as spaces,
+	// each word capitalized: simbisa-avondale becomes "Avondale") or "device"
+	// (the device ID itself)
+	NameStyle string
+	// LocationTemplate fills in the location, with {name} and {device} replaced
+	// by the site name and device ID. Empty leaves the location blank.
+	LocationTemplate string
+}
+
+type ClosingConfig struct {
+	// Cutoff is the local "HH:MM" time at which a business day closes.
+	// Empty means the latest daily closing row is used.
+	Cutoff string
+}
+
+type CumulativeConfig struct {
+	// RangeGroupedQuery aggregates range queries for all sites in one grouped
+	// query instead of one round trip per site
+	RangeGroupedQuery bool
+	// RangeBatchSize is the number of sites per goroutine on the per-site path
+	RangeBatchSize int
+	// NoGeneratorNoiseThreshold is the fuel change (percent) below which changes
+	// are treated as sensor noise at sites whose type has no generator.
+	// 0 disables the noise filter for those sites.
+	NoGeneratorNoiseThreshold float64
+	// ConsistencyTolerance is how far (percent) a day's net fuel level and volume
+	// changes may go in opposite directions before the metrics are flagged as
+	// inconsistent. 0 disables the check.
+	ConsistencyTolerance float64
+	// EfficiencyMinGeneratorHours is the generator runtime (hours) a site needs
+	// over a range to appear in the efficiency ranking
+	EfficiencyMinGeneratorHours float64
+	// RangeCacheMaxAge is how long clients may cache range responses that end before today
+	RangeCacheMaxAge time.Duration
+	// TransactionalBatches saves each batch of daily upserts in one transaction,
+	// rolling the whole batch back when any site in it fails
+	TransactionalBatches bool
+	// RangeMaxRows caps the site-day rows (sites × days) a range query may cover.
+	// 0 disables the limit.
+	RangeMaxRows int
+	// RebuildMaxDays caps the days one site history rebuild may recompute.
+	// 0 disables the limit.
+	RebuildMaxDays int
+	// ScheduleEnabled runs the previous day's processing for every active site
+	// daily at ScheduleTime (HH:MM, local time)
+	ScheduleEnabled bool
+	ScheduleTime    string
+	// ScheduleBackfillDays caps how many missed days after the latest stored
+	// one are caught up at startup, e.g. after downtime over the scheduled
+	// time. 0 disables catching up.
+	ScheduleBackfillDays int
+}
+
+type SensorsConfig struct {
+	// FrozenWindow is how long a fuel level must stay byte-identical before
+	// the sensor is reported as frozen
+	FrozenWindow time.Duration
+	// OnStateValues are the raw generator/zesa values that mean "on"
+	OnStateValues []string
+	// TemperatureSensors are the sensor names the fuel temperature may be
+	// reported under, most preferred first
+	TemperatureSensors []string
+	// LongRuntimeThreshold is how long a generator may run continuously before it is alerted on
+	LongRuntimeThreshold time.Duration
+	// DailyDropThreshold is the day-over-day closing fuel drop (percent) flagged as
+	// suspicious when the generator logged no runtime that day
+	DailyDropThreshold float64
+}
+
+type DashboardConfig struct {
+	// RealtimeWorkers and ClosingWorkers cap the concurrent per-site queries
+	// issued by the realtime and daily closing dashboard views
+	RealtimeWorkers int
+	ClosingWorkers  int
+	// CollectTimeout bounds how long a dashboard view waits for its workers' results
+	CollectTimeout time.Duration
+	// SoftDeadline is when the dashboard stops waiting and returns the sites read
+	// so far, marking the rest timed out. 0 waits up to CollectTimeout.
+	SoftDeadline time.Duration
+	// RequestTimeout bounds the dashboard and active alert routes. 0 disables it.
+	RequestTimeout time.Duration
+	// LowFuelThreshold is the fuel level (percent) at or below which a site is flagged low_fuel
+	LowFuelThreshold float64
+	// CriticalFuelThreshold is the lower fuel level (percent) that escalates a site to
+	// critical_fuel once the level has stayed at or below it for CriticalFuelDuration
+	CriticalFuelThreshold float64
+	CriticalFuelDuration  time.Duration
+	// ActivityLimit and ActivityWindow bound the state transitions listed as recent activity
+	ActivityLimit  int
+	ActivityWindow time.Duration
+}
+
+type AuditConfig struct {
+	// LogFailedLogins also records rejected login attempts in login_events
+	LogFailedLogins bool
+}
+
+// WebhooksConfig controls alert webhook delivery
+type WebhooksConfig struct {
+	// PollInterval is how often new state transitions are checked for and
+	// delivered. 0 disables delivery (test-fire still works).
+	PollInterval time.Duration
+	// Timeout bounds each delivery attempt
+	Timeout time.Duration
+	// MaxAttempts and RetryBackoff control retries of failed deliveries;
+	// the backoff doubles after each failed attempt
+	MaxAttempts  int
+	RetryBackoff time.Duration
+}
+
+// SMTPConfig configures outgoing email. Email is disabled when Host is empty.
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with the server; no authentication
+	// is attempted when Username is empty
+	Username string
+	Password string
+	// From is the sender address of outgoing email
+	From string
+	// Timeout bounds connecting to the server and the whole SMTP conversation
+	Timeout time.Duration
+}
+
+// RateLimitConfig sets the per-user request allowances of the expensive
+// routes, in requests per minute. 0 disables a limit.
+type RateLimitConfig struct {
+	// Dashboard covers the dashboard and active alerts
+	Dashboard int
+	// Cumulative covers the cumulative readings, views, exports and emailed reports
+	Cumulative int
+	// AdminMultiplier scales both limits for admins
+	AdminMultiplier float64
+}
+
+// Validate checks that admins get at least the users' budget; below 1 a small
+// limit could round down to 0, which would lift it for admins entirely
+func (r RateLimitConfig) Validate() error {
+	if r.AdminMultiplier < 1 || math.IsNaN(r.AdminMultiplier) || math.IsInf(r.AdminMultiplier, 0) {
+		return fmt.Errorf("admin multiplier %g must be a finite number of at least 1", r.AdminMultiplier)
+	}
+	return nil
+}
+
+// PaginationConfig sets the page sizes of the paginated list endpoints
+type PaginationConfig struct {
+	// DefaultPageSize applies when ?page= is given without ?pageSize=
+	DefaultPageSize int
+	// MaxPageSize caps the ?pageSize= a client may request
+	MaxPageSize int
+}
+
+// Validate checks that the page sizes are positive and the default does not exceed the maximum
+func (p PaginationConfig) Validate() error {
+	if p.DefaultPageSize < 1 || p.MaxPageSize < 1 {
+		return fmt.Errorf("page sizes must be positive (default %d, max %d)", p.DefaultPageSize, p.MaxPageSize)
+	}
+	if p.DefaultPageSize > p.MaxPageSize {
+		return fmt.Errorf("default page size %d exceeds max page size %d", p.DefaultPageSize, p.MaxPageSize)
+	}
+	return nil
+}
+
+// FeaturesConfig toggles optional endpoints. Disabled features do not
+// register their routes.
+type FeaturesConfig struct {
+	Introspection bool
+	Leaderboard   bool
+	SensorQuality bool
+	RawReadings   bool
+	AdminTools    bool
+	Alerting      bool
 }
 
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port:        getIntEnv("PORT", 4174),
-			Environment: getEnv("GIN_MODE", "debug"),
+			Port:           getIntEnv("PORT", 4174),
+			Environment:    getEnv("GIN_MODE", "debug"),
+			BasePath:       normalizeBasePath(getEnv("API_BASE_PATH", "/api")),
+			LogLevel:       getEnv("LOG_LEVEL", "info"),
+			WriteTimeout:   getDurationEnv("SERVER_WRITE_TIMEOUT", 5*time.Minute),
+			RequestTimeout: getDurationEnv("REQUEST_TIMEOUT", 30*time.Second),
+
+			MetadataCacheMaxAge: getDurationEnv("METADATA_CACHE_MAX_AGE", time.Hour),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "127.0.0.1"),
@@ -50,6 +282,15 @@ func Load() *Config {
 			Name:     getEnv("DB_NAME", "sensorsdb"),
 			User:     getEnv("DB_USER", "sa"),
 			Password: getEnv("DB_PASSWORD", "s3rv3r5mxdb"),
+
+			MaxOpenConns:    getIntEnv("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    getIntEnv("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: getDurationEnv("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			ConnMaxIdleTime: getDurationEnv("DB_CONN_MAX_IDLE_TIME", 1*time.Minute),
+			ConnectAttempts: getIntEnv("DB_CONNECT_ATTEMPTS", 5),
+			ConnectBackoff:  getDurationEnv("DB_CONNECT_BACKOFF", 500*time.Millisecond),
+
+			SlowQueryThreshold: getDurationEnv("DB_SLOW_QUERY_THRESHOLD", 500*time.Millisecond),
 		},
 		SSH: SSHConfig{
 			Host:           getEnv("SSH_HOST", "41.191.232.15"),
@@ -57,14 +298,169 @@ func Load() *Config {
 			Password:       getEnv("SSH_PASSWORD", "s3rv3r5mx$"),
 			RemoteBindHost: getEnv("REMOTE_BIND_HOST", "127.0.0.1"),
 			RemoteBindPort: getIntEnv("REMOTE_BIND_PORT", 5437),
+			LocalPort:      getIntEnv("SSH_LOCAL_PORT", 0),
 		},
 		JWT: JWTConfig{
 			Secret:    getEnv("JWT_SECRET", "fuel-monitor-secret-key-2024"),
 			ExpiresIn: getEnv("JWT_EXPIRES_IN", "24h"),
+
+			IntrospectionAPIKey: getEnv("INTROSPECTION_API_KEY", ""),
+			RecheckUser:         normalizeRecheckMode(getEnv("AUTH_RECHECK_USER", "admin")),
+			RecheckTTL:          getDurationEnv("AUTH_RECHECK_TTL", 30*time.Second),
+
+			ReportDisabledAccounts: getBoolEnv("LOGIN_REPORT_DISABLED", true),
+		},
+		Sites: SitesConfig{
+			NameStyle:        normalizeSiteNameStyle(getEnv("SITE_NAME_STYLE", "title")),
+			LocationTemplate: getEnv("SITE_LOCATION_TEMPLATE", ""),
+		},
+		Closing: ClosingConfig{
+			Cutoff: getEnv("DAILY_CLOSING_CUTOFF", ""),
+		},
+		Cumulative: CumulativeConfig{
+			RangeGroupedQuery: getBoolEnv("CUMULATIVE_RANGE_GROUPED_QUERY", true),
+			RangeBatchSize:    getIntEnv("CUMULATIVE_RANGE_BATCH_SIZE", 20),
+
+			NoGeneratorNoiseThreshold:   getFloatEnv("NO_GENERATOR_NOISE_THRESHOLD", 2.0),
+			ConsistencyTolerance:        getFloatEnv("FUEL_CONSISTENCY_TOLERANCE", 5.0),
+			EfficiencyMinGeneratorHours: getFloatEnv("EFFICIENCY_MIN_GENERATOR_HOURS", 1.0),
+			RangeCacheMaxAge:            getDurationEnv("CUMULATIVE_RANGE_CACHE_MAX_AGE", 24*time.Hour),
+			TransactionalBatches:        getBoolEnv("CUMULATIVE_TRANSACTIONAL_BATCHES", false),
+			RangeMaxRows:                getIntEnv("CUMULATIVE_RANGE_MAX_ROWS", 50000),
+			RebuildMaxDays:              getIntEnv("CUMULATIVE_REBUILD_MAX_DAYS", 92),
+			ScheduleEnabled:             getBoolEnv("CUMULATIVE_SCHEDULE_ENABLED", true),
+			ScheduleTime:                getEnv("CUMULATIVE_SCHEDULE_TIME", "01:00"),
+			ScheduleBackfillDays:        getIntEnv("CUMULATIVE_SCHEDULE_BACKFILL_DAYS", 7),
+		},
+		Sensors: SensorsConfig{
+			FrozenWindow:       getDurationEnv("FROZEN_SENSOR_WINDOW", 12*time.Hour),
+			OnStateValues:      getListEnv("STATE_ON_VALUES", []string{"1", "1.0", "on", "true"}),
+			TemperatureSensors: getListEnv("TEMPERATURE_SENSORS", []string{"fuel_sensor_temp", "fuel_sensor_temperature"}),
+
+			LongRuntimeThreshold: getDurationEnv("LONG_RUNTIME_THRESHOLD", 24*time.Hour),
+			DailyDropThreshold:   getFloatEnv("DAILY_DROP_THRESHOLD", 5.0),
+		},
+		Dashboard: DashboardConfig{
+			RealtimeWorkers: getIntEnv("DASHBOARD_REALTIME_WORKERS", 15),
+			ClosingWorkers:  getIntEnv("DASHBOARD_CLOSING_WORKERS", 12),
+			CollectTimeout:  getDurationEnv("DASHBOARD_COLLECT_TIMEOUT", 60*time.Second),
+			RequestTimeout:  getDurationEnv("DASHBOARD_REQUEST_TIMEOUT", 90*time.Second),
+			SoftDeadline:    getDurationEnv("DASHBOARD_SOFT_DEADLINE", 10*time.Second),
+
+			LowFuelThreshold:      getFloatEnv("LOW_FUEL_THRESHOLD", 25.0),
+			CriticalFuelThreshold: getFloatEnv("CRITICAL_FUEL_THRESHOLD", 10.0),
+			CriticalFuelDuration:  getDurationEnv("CRITICAL_FUEL_DURATION", 2*time.Hour),
+			ActivityLimit:         getIntEnv("DASHBOARD_ACTIVITY_LIMIT", 10),
+			ActivityWindow:        getDurationEnv("DASHBOARD_ACTIVITY_WINDOW", 24*time.Hour),
+		},
+		Features: FeaturesConfig{
+			Introspection: getBoolEnv("FEATURE_INTROSPECTION", true),
+			Leaderboard:   getBoolEnv("FEATURE_LEADERBOARD", true),
+			SensorQuality: getBoolEnv("FEATURE_SENSOR_QUALITY", true),
+			RawReadings:   getBoolEnv("FEATURE_RAW_READINGS", true),
+			AdminTools:    getBoolEnv("FEATURE_ADMIN_TOOLS", true),
+			Alerting:      getBoolEnv("FEATURE_ALERTING", true),
+		},
+		Audit: AuditConfig{
+			LogFailedLogins: getBoolEnv("LOG_FAILED_LOGINS", false),
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval: getDurationEnv("WEBHOOK_POLL_INTERVAL", time.Minute),
+			Timeout:      getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:  getIntEnv("WEBHOOK_MAX_ATTEMPTS", 3),
+			RetryBackoff: getDurationEnv("WEBHOOK_RETRY_BACKOFF", 2*time.Second),
+		},
+		Pagination: PaginationConfig{
+			DefaultPageSize: getIntEnv("PAGE_SIZE_DEFAULT", 50),
+			MaxPageSize:     getIntEnv("PAGE_SIZE_MAX", 500),
+		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getIntEnv("SMTP_PORT", 587),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", "fuel-monitor@localhost"),
+			Timeout:  getDurationEnv("SMTP_TIMEOUT", 30*time.Second),
+		},
+		RateLimit: RateLimitConfig{
+			Dashboard:       getIntEnv("RATE_LIMIT_DASHBOARD", 30),
+			Cumulative:      getIntEnv("RATE_LIMIT_CUMULATIVE", 20),
+			AdminMultiplier: getFloatEnv("RATE_LIMIT_ADMIN_MULTIPLIER", 4),
 		},
 	}
 }
 
+// Validate checks that the cutoff, when set, is a valid "HH:MM" time
+func (c ClosingConfig) Validate() error {
+	if c.Cutoff == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", c.Cutoff); err != nil {
+		return fmt.Errorf("cutoff %q is not a valid HH:MM time", c.Cutoff)
+	}
+	return nil
+}
+
+// LastCutoff returns the most recent closing cutoff at or before now, in
+// now's location. ok is false when no cutoff is configured or it is invalid.
+func (c ClosingConfig) LastCutoff(now time.Time) (cutoff time.Time, ok bool) {
+	if c.Cutoff == "" {
+		return time.Time{}, false
+	}
+
+	clock, err := time.Parse("15:04", c.Cutoff)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	cutoff = time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if cutoff.After(now) {
+		cutoff = cutoff.AddDate(0, 0, -1)
+	}
+	return cutoff, true
+}
+
+// CutoffOn returns the closing cutoff for the given calendar day in local
+// time, falling back to the end of the day when no valid cutoff is set.
+func (c ClosingConfig) CutoffOn(date time.Time) time.Time {
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+
+	if clock, err := time.Parse("15:04", c.Cutoff); err == nil {
+		return dayStart.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+	}
+	return dayStart.Add(24 * time.Hour).Add(-1 * time.Nanosecond)
+}
+
+// normalizeBasePath ensures a leading slash and drops trailing slashes, so
+// "fuel/api/" becomes "/fuel/api". An empty path mounts routes at the root.
+func normalizeBasePath(path string) string {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// normalizeRecheckMode accepts "off", "admin" or "all" in any case and falls
+// back to "admin" for anything else.
+func normalizeRecheckMode(mode string) string {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "off", "all":
+		return mode
+	default:
+		return "admin"
+	}
+}
+
+// normalizeSiteNameStyle accepts "title" or "device" in any case and falls
+// back to "title" for anything else.
+func normalizeSiteNameStyle(style string) string {
+	if style = strings.ToLower(strings.TrimSpace(style)); style == "device" {
+		return style
+	}
+	return "title"
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -79,4 +475,46 @@ func getIntEnv(key string, defaultValue int) int {
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getListEnv(key string, defaultValue []string) []string {
+	if value, exists := os.LookupEnv(key); exists {
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		if len(values) > 0 {
+			return values
+		}
+	}
+	return defaultValue
+}
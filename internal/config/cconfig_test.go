@@ -0,0 +1,95 @@
+package config
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"/", ""},
+		{"  ", ""},
+		{"api", "/api"},
+		{"/api", "/api"},
+		{"fuel/api/", "/fuel/api"},
+		{" /fuel/api// ", "/fuel/api"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeBasePath(tt.path); got != tt.want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPaginationConfigValidate(t *testing.T) {
+	tests := []struct {
+		defaultSize int
+		maxSize     int
+		wantErr     bool
+	}{
+		{20, 100, false},
+		{100, 100, false},
+		{1, 1, false},
+		{0, 100, true},
+		{20, 0, true},
+		{-1, 100, true},
+		{200, 100, true},
+	}
+
+	for _, tt := range tests {
+		err := PaginationConfig{DefaultPageSize: tt.defaultSize, MaxPageSize: tt.maxSize}.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(default %d, max %d) error = %v, want error %t", tt.defaultSize, tt.maxSize, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClosingConfigValidate(t *testing.T) {
+	tests := []struct {
+		cutoff  string
+		wantErr bool
+	}{
+		{"", false},
+		{"23:00", false},
+		{"00:30", false},
+		{"24:00", true},
+		{"23:60", true},
+		{"11pm", true},
+		{"23", true},
+	}
+
+	for _, tt := range tests {
+		err := ClosingConfig{Cutoff: tt.cutoff}.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) error = %v, want error %t", tt.cutoff, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	tests := []struct {
+		multiplier float64
+		wantErr    bool
+	}{
+		{1, false},
+		{4, false},
+		{1.5, false},
+		{0, true},
+		{0.5, true},
+		{-2, true},
+		{math.NaN(), true},
+		{math.Inf(1), true},
+	}
+
+	for _, tt := range tests {
+		err := RateLimitConfig{AdminMultiplier: tt.multiplier}.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%g) error = %v, want error %t", tt.multiplier, err, tt.wantErr)
+		}
+	}
+}
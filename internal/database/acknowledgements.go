@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// CreateAlertAcknowledgement acknowledges a site's alert. Returns nil when the
+// alert already has an active acknowledgement.
+func (db *DB) CreateAlertAcknowledgement(siteID int, alertType, note string, userID int) (*models.AlertAcknowledgement, error) {
+	query := `
+		INSERT INTO alert_acknowledgements (site_id, alert_type, note, acknowledged_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (site_id, alert_type) WHERE cleared_at IS NULL DO NOTHING
+		RETURNING id, site_id, alert_type, note, acknowledged_by, acknowledged_at, cleared_at
+	`
+
+	var ack models.AlertAcknowledgement
+	err := db.QueryRow(query, siteID, alertType, note, userID).Scan(
+		&ack.ID,
+		&ack.SiteID,
+		&ack.AlertType,
+		&ack.Note,
+		&ack.AcknowledgedBy,
+		&ack.AcknowledgedAt,
+		&ack.ClearedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Already acknowledged
+		}
+		return nil, fmt.Errorf("failed to acknowledge alert: %w", err)
+	}
+
+	return &ack, nil
+}
+
+// CreateAlertAcknowledgements acknowledges the alertType alert of several sites
+// in a single statement, so either all of them are recorded or none. Sites
+// whose alert already has an active acknowledgement are skipped. Returns the
+// IDs of the sites acknowledged.
+func (db *DB) CreateAlertAcknowledgements(siteIDs []int, alertType, note string, userID int) ([]int, error) {
+	acknowledged := []int{}
+	if len(siteIDs) == 0 {
+		return acknowledged, nil
+	}
+
+	ids := make([]int64, len(siteIDs))
+	for i, siteID := range siteIDs {
+		ids[i] = int64(siteID)
+	}
+
+	query := `
+		INSERT INTO alert_acknowledgements (site_id, alert_type, note, acknowledged_by)
+		SELECT ids.site_id, $2, $3, $4 FROM unnest($1::int[]) AS ids(site_id)
+		ON CONFLICT (site_id, alert_type) WHERE cleared_at IS NULL DO NOTHING
+		RETURNING site_id
+	`
+
+	rows, err := db.Query(query, pq.Array(ids), alertType, note, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acknowledge alerts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var siteID int
+		if err := rows.Scan(&siteID); err != nil {
+			return nil, fmt.Errorf("failed to scan acknowledged site: %w", err)
+		}
+		acknowledged = append(acknowledged, siteID)
+	}
+
+	return acknowledged, rows.Err()
+}
+
+// GetActiveAlertAcknowledgements retrieves the acknowledgements of alerts that
+// have not cleared yet, with the acknowledging user's name
+func (db *DB) GetActiveAlertAcknowledgements() ([]*models.AlertAcknowledgement, error) {
+	query := `
+		SELECT a.id, a.site_id, a.alert_type, a.note, a.acknowledged_by,
+		       COALESCE(u.username, ''), a.acknowledged_at, a.cleared_at
+		FROM alert_acknowledgements a
+		LEFT JOIN users u ON u.id = a.acknowledged_by
+		WHERE a.cleared_at IS NULL
+		ORDER BY a.acknowledged_at
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert acknowledgements: %w", err)
+	}
+	defer rows.Close()
+
+	acks := []*models.AlertAcknowledgement{}
+	for rows.Next() {
+		var ack models.AlertAcknowledgement
+		err := rows.Scan(
+			&ack.ID,
+			&ack.SiteID,
+			&ack.AlertType,
+			&ack.Note,
+			&ack.AcknowledgedBy,
+			&ack.Username,
+			&ack.AcknowledgedAt,
+			&ack.ClearedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert acknowledgement: %w", err)
+		}
+		acks = append(acks, &ack)
+	}
+
+	return acks, rows.Err()
+}
+
+// ClearResolvedAlertAcknowledgements clears the active acknowledgements of the
+// given sites whose alert is no longer current. current maps site IDs to their
+// current alert status; sites not in it are left alone. Returns how many
+// acknowledgements were cleared.
+func (db *DB) ClearResolvedAlertAcknowledgements(current map[int]string) (int, error) {
+	if len(current) == 0 {
+		return 0, nil
+	}
+
+	siteIDs := make([]int64, 0, len(current))
+	statuses := make([]string, 0, len(current))
+	for siteID, status := range current {
+		siteIDs = append(siteIDs, int64(siteID))
+		statuses = append(statuses, status)
+	}
+
+	query := `
+		UPDATE alert_acknowledgements a
+		SET cleared_at = NOW()
+		FROM unnest($1::int[], $2::text[]) AS current_alerts(site_id, alert_type)
+		WHERE a.cleared_at IS NULL
+		  AND a.site_id = current_alerts.site_id
+		  AND a.alert_type <> current_alerts.alert_type
+	`
+
+	result, err := db.Exec(query, pq.Array(siteIDs), pq.Array(statuses))
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear alert acknowledgements: %w", err)
+	}
+
+	cleared, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count cleared alert acknowledgements: %w", err)
+	}
+	return int(cleared), nil
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// GetRecentTransitions returns the newest state transitions for the given devices
+// since a point in time, newest first: generator and zesa switching on or off
+// (using the configured "on" values) and fuel level dropping to or below the
+// site's own low fuel threshold, or lowFuelThreshold without one. names maps
+// lowercase device IDs to the sensor names they report under; transitions carry
+// the API's sensor names. expected maps lowercase device IDs to the sensors
+// their site type expects (DefaultExpectedSensors when missing); sensors a
+// device is not expected to report give no transitions. Device IDs are matched
+// ignoring case and returned in lowercase. At most limit transitions are returned.
+func (db *DB) GetRecentTransitions(deviceIDs []string, names map[string]models.SensorNames, expected map[string][]string, since time.Time, lowFuelThreshold float64, limit int) ([]*models.StateTransition, error) {
+	// Reading IDs are positive, so this cursor includes every reading at since
+	return db.getTransitions(deviceIDs, names, expected, models.TransitionCursor{Time: since, ReadingID: -1}, lowFuelThreshold, limit, false)
+}
+
+// GetTransitionsAfter returns the transitions GetRecentTransitions finds, oldest
+// first, starting strictly after the cursor. Passing the last transition's
+// Cursor pages forward through them without skipping or repeating any.
+func (db *DB) GetTransitionsAfter(deviceIDs []string, names map[string]models.SensorNames, expected map[string][]string, after models.TransitionCursor, lowFuelThreshold float64, limit int) ([]*models.StateTransition, error) {
+	return db.getTransitions(deviceIDs, names, expected, after, lowFuelThreshold, limit, true)
+}
+
+// getTransitions finds the transitions after the cursor, in ascending or
+// descending (time, reading ID) order
+func (db *DB) getTransitions(deviceIDs []string, names map[string]models.SensorNames, expected map[string][]string, after models.TransitionCursor, lowFuelThreshold float64, limit int, ascending bool) ([]*models.StateTransition, error) {
+	if len(deviceIDs) == 0 || limit < 1 {
+		return []*models.StateTransition{}, nil
+	}
+
+	// Each device with the names its generator, zesa and fuel level sensors
+	// report under. Unexpected sensors get an empty name, which matches no
+	// readings, so they are left out before the limit applies.
+	devices := make([]string, len(deviceIDs))
+	generatorNames := make([]string, len(deviceIDs))
+	zesaNames := make([]string, len(deviceIDs))
+	levelNames := make([]string, len(deviceIDs))
+	for i, deviceID := range deviceIDs {
+		key := strings.ToLower(deviceID)
+		sensors, ok := expected[key]
+		if !ok {
+			sensors = models.DefaultExpectedSensors
+		}
+		devices[i] = deviceID
+		generatorNames[i] = expectedDeviceName(names[key], sensors, "generator_state")
+		zesaNames[i] = expectedDeviceName(names[key], sensors, "zesa_state")
+		levelNames[i] = expectedDeviceName(names[key], sensors, "fuel_sensor_level")
+	}
+
+	args := []interface{}{after.Time, pq.Array(models.OnStateValues()), lowFuelThreshold, limit, after.ReadingID,
+		pq.Array(devices), pq.Array(generatorNames), pq.Array(zesaNames), pq.Array(levelNames)}
+
+	order := "time DESC, id DESC"
+	if ascending {
+		order = "time ASC, id ASC"
+	}
+
+	// Readings from before the window seed LAG so the first in-window reading can be a transition
+	query := fmt.Sprintf(`
+		WITH devices AS (
+			SELECT DISTINCT ON (LOWER(device_id)) LOWER(device_id) AS device_id, generator_name, zesa_name, level_name
+			FROM unnest($6::text[], $7::text[], $8::text[], $9::text[]) AS d(device_id, generator_name, zesa_name, level_name)
+			ORDER BY LOWER(device_id)
+		), states AS (
+			SELECT d.device_id,
+				CASE WHEN r.sensor_name = d.generator_name THEN 'generator_state' ELSE 'zesa_state' END AS sensor_name,
+				r.time, r.id,
+				LOWER(TRIM(r.value)) = ANY($2) AS is_on,
+				LAG(LOWER(TRIM(r.value)) = ANY($2)) OVER (PARTITION BY d.device_id, r.sensor_name ORDER BY r.time, r.id) AS was_on
+			FROM sensor_readings r
+			JOIN devices d ON LOWER(r.device_id) = d.device_id
+			WHERE r.sensor_name IN (d.generator_name, d.zesa_name)
+			  AND r.value IS NOT NULL
+			  AND r.time >= $1::timestamptz - INTERVAL '1 day'
+		), levels AS (
+			SELECT device_id, time, id, level,
+				LAG(level) OVER (PARTITION BY device_id ORDER BY time, id) AS previous_level
+			FROM (
+				SELECT d.device_id, r.time, r.id, CAST(TRIM(r.value) AS DOUBLE PRECISION) AS level
+				FROM sensor_readings r
+				JOIN devices d ON LOWER(r.device_id) = d.device_id
+				WHERE r.sensor_name = d.level_name
+				  AND r.value ~ '^\s*-?[0-9]+(\.[0-9]+)?\s*$'
+				  AND r.time >= $1::timestamptz - INTERVAL '1 day'
+			) numeric_levels
+		), thresholds AS (
+			SELECT DISTINCT ON (LOWER(device_id)) LOWER(device_id) AS device_id, low_fuel_threshold
+			FROM sites
+			WHERE LOWER(device_id) IN (SELECT device_id FROM devices)
+			ORDER BY LOWER(device_id), id
+		)
+		SELECT device_id, sensor_name, is_on, NULL::DOUBLE PRECISION AS level, time, id
+		FROM states
+		WHERE (time, id) > ($1, $5) AND was_on IS NOT NULL AND was_on <> is_on
+		UNION ALL
+		SELECT device_id, 'fuel_sensor_level', false, level, time, id
+		FROM levels
+		LEFT JOIN thresholds USING (device_id)
+		WHERE (time, id) > ($1, $5)
+		  AND previous_level > COALESCE(low_fuel_threshold, $3)
+		  AND level <= COALESCE(low_fuel_threshold, $3)
+		ORDER BY %s
+		LIMIT $4
+	`, order)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions := []*models.StateTransition{}
+	for rows.Next() {
+		var transition models.StateTransition
+		var level *float64
+		if err := rows.Scan(&transition.DeviceID, &transition.SensorName, &transition.On, &level, &transition.Time, &transition.ReadingID); err != nil {
+			return nil, fmt.Errorf("failed to scan transition: %w", err)
+		}
+		if level != nil {
+			transition.FuelLevel = *level
+		}
+		transitions = append(transitions, &transition)
+	}
+
+	return transitions, nil
+}
+
+// expectedDeviceName returns the name sensorName reports under, or "" when it
+// is not among the expected sensors
+func expectedDeviceName(names models.SensorNames, expected []string, sensorName string) string {
+	for _, sensor := range expected {
+		if sensor == sensorName {
+			return names.Device(sensorName)
+		}
+	}
+	return ""
+}
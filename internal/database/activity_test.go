@@ -0,0 +1,54 @@
+package database
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+)
+
+func TestGetRecentTransitionsExpectedSensors(t *testing.T) {
+	typeID, unknownTypeID := 2, 9
+	sites := []*models.Site{
+		{ID: 1, DeviceID: "Simbisa-A", TypeID: &typeID},
+		{ID: 2, DeviceID: "simbisa-b"},
+		{ID: 3, DeviceID: "simbisa-c", TypeID: &unknownTypeID},
+	}
+	siteTypes := map[int]*models.SiteType{typeID: {
+		ID:              typeID,
+		ExpectedSensors: []string{"fuel_sensor_level", "generator_state"},
+		SensorNames:     models.SensorNames{"generator_state": "gen_state"},
+	}}
+
+	var got [][]string
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		// Arguments $6 to $9: device IDs and their generator, zesa and level names
+		for _, arg := range args[5:9] {
+			var values []string
+			for _, value := range strings.Split(strings.Trim(arg.Value.(string), "{}"), ",") {
+				values = append(values, strings.Trim(value, `"`))
+			}
+			got = append(got, values)
+		}
+		return []string{"device_id", "sensor_name", "is_on", "level", "time", "id"}, nil, nil
+	})
+
+	_, err := db.GetRecentTransitions([]string{"Simbisa-A", "simbisa-b", "simbisa-c"},
+		models.SiteSensorNames(sites, siteTypes), models.SiteExpectedSensors(sites, siteTypes), time.Now(), 20, 10)
+	if err != nil {
+		t.Fatalf("GetRecentTransitions: %v", err)
+	}
+
+	want := [][]string{
+		{"Simbisa-A", "simbisa-b", "simbisa-c"},
+		{"gen_state", "generator_state", "generator_state"},
+		{"", "zesa_state", "zesa_state"},
+		{"fuel_sensor_level", "fuel_sensor_level", "fuel_sensor_level"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("device names = %q, want %q", got, want)
+	}
+}
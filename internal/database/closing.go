@@ -0,0 +1,174 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// RebuildDailyClosingReading recomputes a site's closing snapshot for the day
+// starting at dayStart from sensor_readings, using the last fuel level, volume
+// and temperature captured at or before cutoff. The day's existing closing rows
+// are replaced. Returns nil when the device has no fuel level in the window.
+// names maps the sensors to the names the device reports them under.
+func (db *DB) RebuildDailyClosingReading(siteID int, deviceID string, dayStart, cutoff time.Time, names models.SensorNames) (*models.SensorReading, error) {
+	query := `
+		SELECT DISTINCT ON (sensor_name)
+			sensor_name,
+			value,
+			time
+		FROM sensor_readings 
+		WHERE LOWER(device_id) = LOWER($1)
+		  AND sensor_name = ANY($4)
+		  AND time >= $2 AND time <= $3
+		  AND value IS NOT NULL
+		ORDER BY sensor_name, time DESC
+	`
+
+	sensors := append([]string{"fuel_sensor_level", "fuel_sensor_volume"}, models.TemperatureSensors()...)
+	rows, err := db.Query(query, deviceID, dayStart, cutoff, pq.Array(names.DeviceNames(sensors...)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get closing sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	reading := &models.SensorReading{
+		SiteID:   siteID,
+		DeviceID: deviceID,
+	}
+	hasFuelLevel := false
+	var temperatures models.TemperatureResolver
+
+	for rows.Next() {
+		var sensorName, value string
+		var timestamp time.Time
+
+		if err := rows.Scan(&sensorName, &value, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan closing sensor reading: %w", err)
+		}
+
+		sensor := names.Sensor(sensorName)
+		if temperatures.Offer(sensor, value) {
+			continue
+		}
+
+		switch sensor {
+		case "fuel_sensor_level":
+			reading.FuelLevel = value
+			reading.CapturedAt = timestamp
+			hasFuelLevel = true
+		case "fuel_sensor_volume":
+			reading.FuelVolume = value
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read closing sensor readings: %w", err)
+	}
+
+	if !hasFuelLevel {
+		return nil, nil
+	}
+	reading.Temperature = temperatures.Value()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Replace whatever closing rows the site already has for this day
+	dayEnd := dayStart.Add(24 * time.Hour)
+	_, err = tx.Exec(
+		"DELETE FROM daily_closing_readings WHERE site_id = $1 AND captured_at >= $2 AND captured_at < $3",
+		siteID, dayStart, dayEnd,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete existing closing reading: %w", err)
+	}
+
+	var fuelVolume, temperature sql.NullString
+	if reading.FuelVolume != "" {
+		fuelVolume = sql.NullString{String: reading.FuelVolume, Valid: true}
+	}
+	if reading.Temperature != nil {
+		temperature = sql.NullString{String: *reading.Temperature, Valid: true}
+	}
+
+	insertQuery := `
+		INSERT INTO daily_closing_readings (site_id, device_id, fuel_level, fuel_volume, temperature, captured_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err = tx.Exec(insertQuery, siteID, deviceID, reading.FuelLevel, fuelVolume, temperature, reading.CapturedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert closing reading: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit closing reading: %w", err)
+	}
+
+	reading.CreatedAt = reading.CapturedAt
+	reading.ParseValues()
+	return reading, nil
+}
+
+// GetDailyClosingLevels returns a site's closing fuel level for each day between
+// startDate and endDate (YYYY-MM-DD, inclusive) that has a numeric closing row,
+// with the generator runtime stored in cumulative_readings for that day when
+// there is one. The last closing row of a day is used.
+func (db *DB) GetDailyClosingLevels(siteID int, startDate, endDate string) ([]*models.DailyClosingLevel, error) {
+	query := `
+		SELECT TO_CHAR(dc.day, 'YYYY-MM-DD'), dc.fuel_level, dc.captured_at,
+		       CAST(cr.total_generator_runtime AS DECIMAL)
+		FROM (
+			SELECT DISTINCT ON (captured_at::date) captured_at::date AS day, fuel_level, captured_at
+			FROM daily_closing_readings
+			WHERE site_id = $1
+			  AND captured_at >= $2::date AND captured_at < $3::date + 1
+			  AND fuel_level ~ '^\s*-?[0-9]+(\.[0-9]+)?\s*$'
+			ORDER BY captured_at::date, captured_at DESC
+		) dc
+		LEFT JOIN cumulative_readings cr ON cr.site_id = $1 AND cr.date = dc.day
+		ORDER BY dc.day
+	`
+
+	rows, err := db.Query(query, siteID, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily closing levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []*models.DailyClosingLevel{}
+	for rows.Next() {
+		var level models.DailyClosingLevel
+		var fuelLevel string
+		var generatorHours sql.NullFloat64
+
+		if err := rows.Scan(&level.Date, &fuelLevel, &level.CapturedAt, &generatorHours); err != nil {
+			return nil, fmt.Errorf("failed to scan daily closing level: %w", err)
+		}
+
+		level.FuelLevel, err = strconv.ParseFloat(strings.TrimSpace(fuelLevel), 64)
+		if err != nil {
+			continue
+		}
+		if generatorHours.Valid {
+			level.GeneratorHours = &generatorHours.Float64
+		}
+
+		levels = append(levels, &level)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read daily closing levels: %w", err)
+	}
+
+	return levels, nil
+}
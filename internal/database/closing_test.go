@@ -0,0 +1,82 @@
+package database
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+)
+
+// arrayValues decodes a pq array argument such as {"a","b"}
+func arrayValues(arg driver.NamedValue) map[string]bool {
+	values := make(map[string]bool)
+	for _, value := range strings.Split(strings.Trim(arg.Value.(string), "{}"), ",") {
+		values[strings.Trim(value, `"`)] = true
+	}
+	return values
+}
+
+func TestRebuildDailyClosingReadingSensorNames(t *testing.T) {
+	at := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)
+	// The device reports its fuel under its own names, plus a stale default-named level
+	reported := map[string]string{
+		"fuel_level":        "55.5",
+		"fuel_vol":          "800",
+		"fuel_sensor_level": "10",
+		"fuel_sensor_temp":  "31",
+	}
+
+	tests := []struct {
+		name       string
+		names      models.SensorNames
+		wantLevel  string
+		wantVolume string
+	}{
+		{"default names", nil, "10", ""},
+		{"mapped names", models.SensorNames{"fuel_sensor_level": "fuel_level", "fuel_sensor_volume": "fuel_vol"}, "55.5", "800"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inserted []driver.NamedValue
+			db := newFakeDBWith(t, fakeHandlers{
+				query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+					requested := arrayValues(args[3])
+					var rows [][]driver.Value
+					for sensor, value := range reported {
+						if requested[sensor] {
+							rows = append(rows, []driver.Value{sensor, value, at})
+						}
+					}
+					return []string{"sensor_name", "value", "time"}, rows, nil
+				},
+				exec: func(query string, args []driver.NamedValue) (int64, error) {
+					if strings.Contains(query, "INSERT INTO daily_closing_readings") {
+						inserted = args
+					}
+					return 1, nil
+				},
+			})
+
+			dayStart := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+			reading, err := db.RebuildDailyClosingReading(1, "simbisa-a", dayStart, at, tt.names)
+			if err != nil {
+				t.Fatalf("RebuildDailyClosingReading: %v", err)
+			}
+			if reading == nil {
+				t.Fatal("got no reading")
+			}
+			if reading.FuelLevel != tt.wantLevel || reading.FuelVolume != tt.wantVolume {
+				t.Errorf("level %q volume %q, want %q and %q", reading.FuelLevel, reading.FuelVolume, tt.wantLevel, tt.wantVolume)
+			}
+			if reading.Temperature == nil || *reading.Temperature != "31" {
+				t.Errorf("temperature = %v, want 31", reading.Temperature)
+			}
+			if len(inserted) < 3 || inserted[2].Value != tt.wantLevel {
+				t.Errorf("inserted %v, want fuel level %q", inserted, tt.wantLevel)
+			}
+		})
+	}
+}
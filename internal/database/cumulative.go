@@ -1,6 +1,7 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
 	"math"
 	"strconv"
@@ -8,6 +9,8 @@ import (
 	"time"
 
 	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
 )
 
 // GetExistingCumulativeReadings gets existing cumulative readings for sites on a specific date
@@ -27,7 +30,7 @@ func (db *DB) GetExistingCumulativeReadings(date string, sites []*models.Site) (
 	query := fmt.Sprintf(`
 		SELECT id, site_id, device_id, date, total_fuel_consumed, total_fuel_topped_up, 
 		       fuel_consumed_percent, fuel_topped_up_percent, total_generator_runtime, 
-		       total_zesa_runtime, total_offline_time, calculated_at, created_at
+		       total_zesa_runtime, total_offline_time, generator_starts, calc_version, calculated_at, created_at
 		FROM cumulative_readings 
 		WHERE date = $1 AND site_id IN (%s)
 	`, strings.Join(placeholders, ", "))
@@ -41,7 +44,7 @@ func (db *DB) GetExistingCumulativeReadings(date string, sites []*models.Site) (
 	}
 	defer rows.Close()
 
-	var readings []*models.CumulativeReading
+	readings := []*models.CumulativeReading{}
 	for rows.Next() {
 		var reading models.CumulativeReading
 		err := rows.Scan(
@@ -56,6 +59,8 @@ func (db *DB) GetExistingCumulativeReadings(date string, sites []*models.Site) (
 			&reading.TotalGeneratorRuntime,
 			&reading.TotalZesaRuntime,
 			&reading.TotalOfflineTime,
+			&reading.GeneratorStarts,
+			&reading.CalcVersion,
 			&reading.CalculatedAt,
 			&reading.CreatedAt,
 		)
@@ -68,14 +73,41 @@ func (db *DB) GetExistingCumulativeReadings(date string, sites []*models.Site) (
 	return readings, nil
 }
 
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// GetLatestCumulativeDate returns the latest date with a stored cumulative
+// reading, or nil when none is stored
+func (db *DB) GetLatestCumulativeDate() (*time.Time, error) {
+	var latest sql.NullTime
+	if err := db.QueryRow("SELECT MAX(date) FROM cumulative_readings").Scan(&latest); err != nil {
+		return nil, fmt.Errorf("failed to get latest cumulative date: %w", err)
+	}
+	if !latest.Valid {
+		return nil, nil
+	}
+	return &latest.Time, nil
+}
+
 // CreateOrUpdateCumulativeReading creates a new cumulative reading or updates existing one
 func (db *DB) CreateOrUpdateCumulativeReading(siteID int, deviceID, date string, fuelMetrics models.FuelMetrics, powerMetrics models.PowerMetrics) (*models.CumulativeReading, error) {
+	return upsertCumulativeReading(db, siteID, deviceID, date, fuelMetrics, powerMetrics)
+}
+
+// CreateOrUpdateCumulativeReadingTx is CreateOrUpdateCumulativeReading within a transaction
+func (db *DB) CreateOrUpdateCumulativeReadingTx(tx *sql.Tx, siteID int, deviceID, date string, fuelMetrics models.FuelMetrics, powerMetrics models.PowerMetrics) (*models.CumulativeReading, error) {
+	return upsertCumulativeReading(tx, siteID, deviceID, date, fuelMetrics, powerMetrics)
+}
+
+func upsertCumulativeReading(q rowQuerier, siteID int, deviceID, date string, fuelMetrics models.FuelMetrics, powerMetrics models.PowerMetrics) (*models.CumulativeReading, error) {
 	query := `
 		INSERT INTO cumulative_readings (
 			site_id, device_id, date, total_fuel_consumed, total_fuel_topped_up,
 			fuel_consumed_percent, fuel_topped_up_percent, total_generator_runtime,
-			total_zesa_runtime, total_offline_time, calculated_at, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			total_zesa_runtime, total_offline_time, generator_starts, calc_version, calculated_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		ON CONFLICT (site_id, date) 
 		DO UPDATE SET 
 			total_fuel_consumed = EXCLUDED.total_fuel_consumed,
@@ -85,16 +117,18 @@ func (db *DB) CreateOrUpdateCumulativeReading(siteID int, deviceID, date string,
 			total_generator_runtime = EXCLUDED.total_generator_runtime,
 			total_zesa_runtime = EXCLUDED.total_zesa_runtime,
 			total_offline_time = EXCLUDED.total_offline_time,
+			generator_starts = EXCLUDED.generator_starts,
+			calc_version = EXCLUDED.calc_version,
 			calculated_at = EXCLUDED.calculated_at
 		RETURNING id, site_id, device_id, date, total_fuel_consumed, total_fuel_topped_up,
 		          fuel_consumed_percent, fuel_topped_up_percent, total_generator_runtime,
-		          total_zesa_runtime, total_offline_time, calculated_at, created_at
+		          total_zesa_runtime, total_offline_time, generator_starts, calc_version, calculated_at, created_at
 	`
 
 	now := time.Now()
 	var reading models.CumulativeReading
 
-	err := db.QueryRow(
+	err := q.QueryRow(
 		query,
 		siteID,
 		deviceID,
@@ -106,6 +140,8 @@ func (db *DB) CreateOrUpdateCumulativeReading(siteID int, deviceID, date string,
 		fmt.Sprintf("%.2f", powerMetrics.TotalGeneratorRuntime),
 		fmt.Sprintf("%.2f", powerMetrics.TotalZesaRuntime),
 		fmt.Sprintf("%.2f", powerMetrics.TotalOfflineTime),
+		powerMetrics.GeneratorStarts,
+		models.CumulativeCalcVersion,
 		now,
 		now,
 	).Scan(
@@ -120,6 +156,8 @@ func (db *DB) CreateOrUpdateCumulativeReading(siteID int, deviceID, date string,
 		&reading.TotalGeneratorRuntime,
 		&reading.TotalZesaRuntime,
 		&reading.TotalOfflineTime,
+		&reading.GeneratorStarts,
+		&reading.CalcVersion,
 		&reading.CalculatedAt,
 		&reading.CreatedAt,
 	)
@@ -131,30 +169,60 @@ func (db *DB) CreateOrUpdateCumulativeReading(siteID int, deviceID, date string,
 	return &reading, nil
 }
 
+// defaultNoiseThreshold is the fuel change (percent) ignored as noise when the generator did not run
+const defaultNoiseThreshold = 2.0
+
+// FuelCalcOptions controls how fuel changes are filtered for a site
+type FuelCalcOptions struct {
+	// HasGenerator is false for sites that have no generator (e.g. grid-only)
+	HasGenerator bool
+	// NoGeneratorNoiseThreshold replaces the generator-gated noise filter for
+	// sites without a generator; 0 disables filtering entirely
+	NoGeneratorNoiseThreshold float64
+	// SensorNames maps the fuel and generator sensors to the names the device reports them under
+	SensorNames models.SensorNames
+	// ConsistencyTolerance is how far (percent) the day's net level and volume
+	// changes may go in opposite directions before the metrics are flagged as
+	// inconsistent; 0 disables the check
+	ConsistencyTolerance float64
+}
+
 // CalculateFuelChanges calculates fuel consumption and topping metrics for a device on a specific date
-func (db *DB) CalculateFuelChanges(deviceID string, targetDate time.Time) (models.FuelMetrics, error) {
-	// Ensure we capture the full day in UTC
-	startOfDay := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, time.UTC)
-	endOfDay := startOfDay.Add(24 * time.Hour).Add(-1 * time.Nanosecond)
+func (db *DB) CalculateFuelChanges(deviceID string, targetDate time.Time, opts FuelCalcOptions) (models.FuelMetrics, error) {
+	defer db.timeQuery("CalculateFuelChanges")()
 
-	// Check if generator was running during the day
-	hasGeneratorRuntime, err := db.hasGeneratorActivity(deviceID, startOfDay, endOfDay)
-	if err != nil {
-		return models.FuelMetrics{}, fmt.Errorf("failed to check generator activity: %w", err)
+	// Capture the UTC day, or only its elapsed part when it is today
+	startOfDay, endOfDay := dayBounds(targetDate, time.Now())
+
+	// Small changes are ignored as noise unless the generator was running.
+	// Sites without a generator use their own threshold instead.
+	applyNoiseFilter := false
+	noiseThreshold := defaultNoiseThreshold
+	if opts.HasGenerator {
+		hasGeneratorRuntime, err := db.hasGeneratorActivity(deviceID, opts.SensorNames.Device("generator_state"), startOfDay, endOfDay)
+		if err != nil {
+			return models.FuelMetrics{}, fmt.Errorf("failed to check generator activity: %w", err)
+		}
+		applyNoiseFilter = !hasGeneratorRuntime
+	} else {
+		noiseThreshold = opts.NoGeneratorNoiseThreshold
+		applyNoiseFilter = noiseThreshold > 0
 	}
 
 	// Get ALL fuel readings for the day (both level and volume), ordered by time
 	levelQuery := `
 		SELECT value, time, sensor_name
 		FROM sensor_readings 
-		WHERE device_id = $1 
-		  AND sensor_name IN ('fuel_sensor_level', 'fuel_sensor_volume')
+		WHERE LOWER(device_id) = LOWER($1) 
+		  AND sensor_name IN ($4, $5)
 		  AND time >= $2 AND time <= $3 
 		  AND value IS NOT NULL
-		ORDER BY time ASC
+		ORDER BY time ASC, id ASC
 	`
 
-	rows, err := db.Query(levelQuery, deviceID, startOfDay, endOfDay)
+	levelSensor := opts.SensorNames.Device("fuel_sensor_level")
+	volumeSensor := opts.SensorNames.Device("fuel_sensor_volume")
+	rows, err := db.Query(levelQuery, deviceID, startOfDay, endOfDay, levelSensor, volumeSensor)
 	if err != nil {
 		return models.FuelMetrics{}, fmt.Errorf("failed to get fuel readings: %w", err)
 	}
@@ -182,10 +250,19 @@ func (db *DB) CalculateFuelChanges(deviceID string, targetDate time.Time) (model
 				Time  time.Time
 			}{Value: value, Time: timestamp}
 
-			if sensorName == "fuel_sensor_level" {
-				levelReadings = append(levelReadings, reading)
-			} else if sensorName == "fuel_sensor_volume" {
-				volumeReadings = append(volumeReadings, reading)
+			// Rows sharing a timestamp are deduplicated, keeping the last inserted
+			if sensorName == levelSensor {
+				if n := len(levelReadings); n > 0 && levelReadings[n-1].Time.Equal(timestamp) {
+					levelReadings[n-1] = reading
+				} else {
+					levelReadings = append(levelReadings, reading)
+				}
+			} else if sensorName == volumeSensor {
+				if n := len(volumeReadings); n > 0 && volumeReadings[n-1].Time.Equal(timestamp) {
+					volumeReadings[n-1] = reading
+				} else {
+					volumeReadings = append(volumeReadings, reading)
+				}
 			}
 		}
 	}
@@ -199,7 +276,7 @@ func (db *DB) CalculateFuelChanges(deviceID string, targetDate time.Time) (model
 
 		// Skip small changes if no generator runtime
 		changePercent := math.Abs(change)
-		if !hasGeneratorRuntime && changePercent < 2.0 {
+		if applyNoiseFilter && changePercent < noiseThreshold {
 			continue
 		}
 
@@ -221,7 +298,7 @@ func (db *DB) CalculateFuelChanges(deviceID string, targetDate time.Time) (model
 		// Convert to percentage for comparison (assuming typical tank capacity)
 		if prev > 0 {
 			changePercent := math.Abs(change) / prev * 100
-			if !hasGeneratorRuntime && changePercent < 2.0 {
+			if applyNoiseFilter && changePercent < noiseThreshold {
 				continue
 			}
 		}
@@ -233,28 +310,65 @@ func (db *DB) CalculateFuelChanges(deviceID string, targetDate time.Time) (model
 		}
 	}
 
+	// Flag days where the level and volume sensors contradict each other
+	inconsistent := false
+	if len(levelReadings) > 1 && len(volumeReadings) > 1 {
+		levelChange := levelReadings[len(levelReadings)-1].Value - levelReadings[0].Value
+		volumeChange := volumeReadings[len(volumeReadings)-1].Value - volumeReadings[0].Value
+		inconsistent = fuelChangesDisagree(levelChange, volumeChange, volumeReadings[0].Value, opts.ConsistencyTolerance)
+	}
+
 	return models.FuelMetrics{
 		TotalFuelConsumed:   totalConsumedVolume,  // Volume consumed in liters
 		TotalFuelTopped:     totalToppedVolume,    // Volume topped in liters
 		FuelConsumedPercent: totalConsumedPercent, // Percentage consumed
 		FuelToppedPercent:   totalToppedPercent,   // Percentage topped
+		MetricsInconsistent: inconsistent,
 	}, nil
 }
 
-// hasGeneratorActivity checks if the generator was running during the specified time period
-func (db *DB) hasGeneratorActivity(deviceID string, startOfDay, endOfDay time.Time) (bool, error) {
+// fuelChangesDisagree reports whether a day's net fuel level change (percent
+// points) and net volume change (liters, relative to startVolume) go in opposite
+// directions, each by more than tolerance percent. A tolerance of 0 or less, or
+// an empty starting volume, never disagrees.
+func fuelChangesDisagree(levelChange, volumeChange, startVolume, tolerance float64) bool {
+	if tolerance <= 0 || startVolume <= 0 {
+		return false
+	}
+
+	volumeChangePercent := volumeChange / startVolume * 100
+	return (levelChange > tolerance && volumeChangePercent < -tolerance) ||
+		(levelChange < -tolerance && volumeChangePercent > tolerance)
+}
+
+// dayBounds returns the start and end of the UTC day containing targetDate. When
+// the day is still in progress the end is capped at now, so same-day calculations
+// only cover elapsed time.
+func dayBounds(targetDate, now time.Time) (time.Time, time.Time) {
+	startOfDay := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, time.UTC)
+	endOfDay := startOfDay.Add(24 * time.Hour).Add(-1 * time.Nanosecond)
+
+	if now.After(startOfDay) && now.Before(endOfDay) {
+		endOfDay = now.UTC()
+	}
+	return startOfDay, endOfDay
+}
+
+// hasGeneratorActivity checks if the generator, reported as generatorSensor, was
+// running during the specified time period
+func (db *DB) hasGeneratorActivity(deviceID, generatorSensor string, startOfDay, endOfDay time.Time) (bool, error) {
 	query := `
 		SELECT COUNT(*) 
 		FROM sensor_readings 
-		WHERE device_id = $1 
-		  AND sensor_name = 'generator_state'
+		WHERE LOWER(device_id) = LOWER($1) 
+		  AND sensor_name = $5
 		  AND time >= $2 AND time <= $3 
 		  AND value IS NOT NULL
-		  AND (value = '1' OR value = '1.0')
+		  AND LOWER(TRIM(value)) = ANY($4)
 	`
 
 	var count int
-	err := db.QueryRow(query, deviceID, startOfDay, endOfDay).Scan(&count)
+	err := db.QueryRow(query, deviceID, startOfDay, endOfDay, pq.Array(models.OnStateValues()), generatorSensor).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -262,62 +376,61 @@ func (db *DB) hasGeneratorActivity(deviceID string, startOfDay, endOfDay time.Ti
 	return count > 0, nil
 }
 
-// CalculatePowerRuntimes calculates generator and zesa runtime for a device on a specific date
-func (db *DB) CalculatePowerRuntimes(deviceID string, targetDate time.Time) (models.PowerMetrics, error) {
-	// Ensure we capture the full day in UTC
-	startOfDay := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, time.UTC)
-	endOfDay := startOfDay.Add(24 * time.Hour).Add(-1 * time.Nanosecond)
+// CalculatePowerRuntimes calculates generator and zesa runtime for a device on a specific date.
+// names maps the state sensors to the names the device reports them under.
+func (db *DB) CalculatePowerRuntimes(deviceID string, targetDate time.Time, names models.SensorNames) (models.PowerMetrics, error) {
+	defer db.timeQuery("CalculatePowerRuntimes")()
+
+	// Capture the UTC day, or only its elapsed part when it is today
+	startOfDay, endOfDay := dayBounds(targetDate, time.Now())
+	elapsedHours := endOfDay.Sub(startOfDay).Hours()
 
-	// Calculate generator runtime
-	generatorHours, err := db.calculateStateRuntime(deviceID, "generator_state", startOfDay, endOfDay)
+	generatorSamples, err := db.getStateSamples(deviceID, names.Device("generator_state"), startOfDay, endOfDay)
 	if err != nil {
 		return models.PowerMetrics{}, fmt.Errorf("failed to calculate generator runtime: %w", err)
 	}
-
-	// Calculate zesa runtime
-	zesaHours, err := db.calculateStateRuntime(deviceID, "zesa_state", startOfDay, endOfDay)
+	zesaSamples, err := db.getStateSamples(deviceID, names.Device("zesa_state"), startOfDay, endOfDay)
 	if err != nil {
 		return models.PowerMetrics{}, fmt.Errorf("failed to calculate zesa runtime: %w", err)
 	}
 
-	// Calculate offline time (24 hours - active time)
-	// Note: generator and zesa can run simultaneously, so we don't simply add them
-	totalActiveHours := generatorHours + zesaHours
-	offlineHours := 0.0
-	if totalActiveHours < 24 {
-		offlineHours = 24 - totalActiveHours
+	generatorHours, generatorStarts := walkStates(generatorSamples, endOfDay)
+	zesaHours, _ := walkStates(zesaSamples, endOfDay)
+
+	// Offline time is the elapsed time with neither source on. Generator and
+	// zesa can run simultaneously, so their hours are merged rather than added.
+	offlineHours := elapsedHours - poweredHours(generatorSamples, zesaSamples, endOfDay)
+	if offlineHours < 0 {
+		offlineHours = 0
 	}
 
 	return models.PowerMetrics{
 		TotalGeneratorRuntime: generatorHours,
 		TotalZesaRuntime:      zesaHours,
 		TotalOfflineTime:      offlineHours,
+		GeneratorStarts:       generatorStarts,
 	}, nil
 }
 
-// calculateStateRuntime calculates runtime for a specific state (helper method)
-func (db *DB) calculateStateRuntime(deviceID, sensorName string, startOfDay, endOfDay time.Time) (float64, error) {
+// getStateSamples returns a state sensor's on/off readings within the day, oldest first
+func (db *DB) getStateSamples(deviceID, sensorName string, startOfDay, endOfDay time.Time) ([]stateSample, error) {
 	query := `
 		SELECT value, time 
 		FROM sensor_readings 
-		WHERE device_id = $1 
+		WHERE LOWER(device_id) = LOWER($1) 
 		  AND sensor_name = $2
 		  AND time >= $3 AND time <= $4 
 		  AND value IS NOT NULL
-		ORDER BY time ASC
+		ORDER BY time ASC, id ASC
 	`
 
 	rows, err := db.Query(query, deviceID, sensorName, startOfDay, endOfDay)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get state readings: %w", err)
+		return nil, fmt.Errorf("failed to get state readings: %w", err)
 	}
 	defer rows.Close()
 
-	var runtime float64
-	var lastTime time.Time
-	var lastState bool
-	var hasData bool
-
+	var samples []stateSample
 	for rows.Next() {
 		var valueStr string
 		var timestamp time.Time
@@ -325,25 +438,656 @@ func (db *DB) calculateStateRuntime(deviceID, sensorName string, startOfDay, end
 			continue
 		}
 
-		// Parse state: 1=on, 0=off, anything else=off
-		currentState := (valueStr == "1" || valueStr == "1.0")
+		// Parse state: configured "on" values are on, anything else is off
+		samples = append(samples, stateSample{On: models.ParseState(valueStr), Time: timestamp})
+	}
+
+	return samples, rows.Err()
+}
+
+// stateSample is one on/off state reading
+type stateSample struct {
+	On   bool
+	Time time.Time
+}
+
+// walkStates sums the hours samples (ordered by time) spend ON, with the last
+// state extending to endOfDay, and counts the OFF→ON transitions. A state that
+// is already ON at the first sample is not counted as a start.
+func walkStates(samples []stateSample, endOfDay time.Time) (runtime float64, starts int) {
+	var last stateSample
+	var hasData bool
 
-		if hasData && lastState {
+	for i, sample := range samples {
+		// Conflicting rows at the same timestamp: the last inserted one wins
+		if i+1 < len(samples) && samples[i+1].Time.Equal(sample.Time) {
+			continue
+		}
+
+		if hasData && last.On {
 			// Add runtime for the period when state was ON
-			duration := timestamp.Sub(lastTime)
-			runtime += duration.Hours()
+			runtime += sample.Time.Sub(last.Time).Hours()
+		}
+		if hasData && !last.On && sample.On {
+			starts++
 		}
 
-		lastTime = timestamp
-		lastState = currentState
+		last = sample
 		hasData = true
 	}
 
 	// Handle case where last state was ON and extends to end of day
-	if hasData && lastState && lastTime.Before(endOfDay) {
-		duration := endOfDay.Sub(lastTime)
-		runtime += duration.Hours()
+	if hasData && last.On && last.Time.Before(endOfDay) {
+		runtime += endOfDay.Sub(last.Time).Hours()
+	}
+
+	return runtime, starts
+}
+
+// poweredHours returns the hours generator or zesa (each ordered by time) is ON,
+// counting hours both are ON once, with the last states extending to endOfDay.
+// Like walkStates, the last of conflicting rows at the same timestamp wins.
+func poweredHours(generator, zesa []stateSample, endOfDay time.Time) float64 {
+	var hours float64
+	var generatorOn, zesaOn, hasData bool
+	var last time.Time
+
+	i, j := 0, 0
+	for i < len(generator) || j < len(zesa) {
+		// The earliest pending timestamp of either series
+		var at time.Time
+		if j >= len(zesa) || (i < len(generator) && !zesa[j].Time.Before(generator[i].Time)) {
+			at = generator[i].Time
+		} else {
+			at = zesa[j].Time
+		}
+
+		if hasData && (generatorOn || zesaOn) {
+			hours += at.Sub(last).Hours()
+		}
+
+		for ; i < len(generator) && generator[i].Time.Equal(at); i++ {
+			generatorOn = generator[i].On
+		}
+		for ; j < len(zesa) && zesa[j].Time.Equal(at); j++ {
+			zesaOn = zesa[j].On
+		}
+
+		last = at
+		hasData = true
+	}
+
+	if hasData && (generatorOn || zesaOn) && last.Before(endOfDay) {
+		hours += endOfDay.Sub(last).Hours()
+	}
+	return hours
+}
+
+// GetCumulativeRangeVersion returns the row count and latest calculated_at of the
+// cumulative readings for the given sites within a date range. Together they
+// change whenever any reading in the range is created or recalculated.
+func (db *DB) GetCumulativeRangeVersion(sites []*models.Site, startDate, endDate string) (int, *time.Time, error) {
+	if len(sites) == 0 {
+		return 0, nil, nil
+	}
+
+	siteIDs := make([]interface{}, len(sites))
+	placeholders := make([]string, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+		placeholders[i] = fmt.Sprintf("$%d", i+3) // +3 because $1 and $2 are the dates
+	}
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*), MAX(calculated_at)
+		FROM cumulative_readings 
+		WHERE date >= $1 AND date <= $2 AND site_id IN (%s)
+	`, strings.Join(placeholders, ", "))
+
+	args := []interface{}{startDate, endDate}
+	args = append(args, siteIDs...)
+
+	var count int
+	var maxCalculatedAt sql.NullTime
+	if err := db.QueryRow(query, args...).Scan(&count, &maxCalculatedAt); err != nil {
+		return 0, nil, fmt.Errorf("failed to get cumulative range version: %w", err)
+	}
+
+	if !maxCalculatedAt.Valid {
+		return count, nil, nil
+	}
+	return count, &maxCalculatedAt.Time, nil
+}
+
+// GetCumulativeRangeTotals aggregates cumulative readings for all given sites over a
+// date range in a single grouped query. Results are keyed by site ID and only
+// include sites with readings in the range; totals are not rounded.
+func (db *DB) GetCumulativeRangeTotals(sites []*models.Site, startDate, endDate string) (map[int]*models.CumulativeSiteRangeResult, error) {
+	defer db.timeQuery("GetCumulativeRangeTotals")()
+
+	results := make(map[int]*models.CumulativeSiteRangeResult)
+	if len(sites) == 0 {
+		return results, nil
+	}
+
+	siteIDs := make([]interface{}, len(sites))
+	placeholders := make([]string, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+		placeholders[i] = fmt.Sprintf("$%d", i+3) // +3 because $1 and $2 are the dates
+	}
+
+	query := fmt.Sprintf(`
+		SELECT 
+			site_id,
+			COUNT(*) as reading_days,
+			SUM(CAST(total_fuel_consumed AS DECIMAL)) as total_fuel_consumed,
+			SUM(CAST(total_fuel_topped_up AS DECIMAL)) as total_fuel_topped,
+			SUM(CAST(total_generator_runtime AS DECIMAL)) as total_generator_hours,
+			SUM(CAST(total_zesa_runtime AS DECIMAL)) as total_zesa_hours,
+			SUM(CAST(total_offline_time AS DECIMAL)) as total_offline_hours,
+			COUNT(*) FILTER (WHERE calc_version < %d) as outdated_days,
+			MIN(date) as first_date,
+			MAX(date) as last_date
+		FROM cumulative_readings 
+		WHERE date >= $1 AND date <= $2 AND site_id IN (%s)
+		GROUP BY site_id
+	`, models.CumulativeCalcVersion, strings.Join(placeholders, ", "))
+
+	args := []interface{}{startDate, endDate}
+	args = append(args, siteIDs...)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cumulative range totals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result models.CumulativeSiteRangeResult
+		err := rows.Scan(
+			&result.SiteID,
+			&result.ReadingDays,
+			&result.TotalFuelConsumed,
+			&result.TotalFuelTopped,
+			&result.TotalGeneratorHours,
+			&result.TotalZesaHours,
+			&result.TotalOfflineHours,
+			&result.OutdatedDays,
+			&result.DateRange.Start,
+			&result.DateRange.End,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cumulative range totals: %w", err)
+		}
+		results[result.SiteID] = &result
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cumulative range totals: %w", err)
+	}
+
+	return results, nil
+}
+
+// leaderboardOrder is an order the leaderboard can rank by. The metric columns
+// are text, so each ranks by a numeric cast that its index stores precomputed.
+type leaderboardOrder struct {
+	expression string
+	index      string
+}
+
+// leaderboardOrders are the leaderboard orders by name. Each index is built by
+// EnsureSchema over (date, expression DESC, site_id), matching the query's
+// WHERE and ORDER BY, so a day's top rows are read without sorting the day.
+var leaderboardOrders = map[string]leaderboardOrder{
+	"fuel":      {"CAST(total_fuel_consumed AS DECIMAL)", "idx_cumulative_readings_date_fuel"},
+	"generator": {"CAST(total_generator_runtime AS DECIMAL)", "idx_cumulative_readings_date_generator"},
+}
+
+// GetCumulativeLeaderboard retrieves stored cumulative readings for a date ordered by
+// the given column (descending), limited to the given sites
+func (db *DB) GetCumulativeLeaderboard(date string, sites []*models.Site, orderBy string, limit int) ([]*models.CumulativeReading, error) {
+	if len(sites) == 0 {
+		return []*models.CumulativeReading{}, nil
+	}
+
+	// Only whitelisted expressions may be interpolated into ORDER BY
+	order, ok := leaderboardOrders[orderBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported leaderboard order: %s", orderBy)
+	}
+
+	siteIDs := make([]interface{}, len(sites))
+	placeholders := make([]string, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+		placeholders[i] = fmt.Sprintf("$%d", i+3) // +3 because $1 is date and $2 is limit
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, site_id, device_id, date, total_fuel_consumed, total_fuel_topped_up, 
+		       fuel_consumed_percent, fuel_topped_up_percent, total_generator_runtime, 
+		       total_zesa_runtime, total_offline_time, generator_starts, calc_version, calculated_at, created_at
+		FROM cumulative_readings 
+		WHERE date = $1 AND site_id IN (%s)
+		ORDER BY %s DESC, site_id
+		LIMIT $2
+	`, strings.Join(placeholders, ", "), order.expression)
+
+	args := []interface{}{date, limit}
+	args = append(args, siteIDs...)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cumulative leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []*models.CumulativeReading{}
+	for rows.Next() {
+		var reading models.CumulativeReading
+		err := rows.Scan(
+			&reading.ID,
+			&reading.SiteID,
+			&reading.DeviceID,
+			&reading.Date,
+			&reading.TotalFuelConsumed,
+			&reading.TotalFuelTopped,
+			&reading.FuelConsumedPercent,
+			&reading.FuelToppedPercent,
+			&reading.TotalGeneratorRuntime,
+			&reading.TotalZesaRuntime,
+			&reading.TotalOfflineTime,
+			&reading.GeneratorStarts,
+			&reading.CalcVersion,
+			&reading.CalculatedAt,
+			&reading.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cumulative reading: %w", err)
+		}
+		readings = append(readings, &reading)
+	}
+
+	return readings, nil
+}
+
+// cumulativeMetricColumns maps the metric names accepted by the API to their
+// cumulative_readings columns. Only these may be interpolated into queries.
+var cumulativeMetricColumns = map[string]string{
+	"fuelConsumed":   "total_fuel_consumed",
+	"fuelTopped":     "total_fuel_topped_up",
+	"generatorHours": "total_generator_runtime",
+	"zesaHours":      "total_zesa_runtime",
+	"offlineHours":   "total_offline_time",
+}
+
+// IsCumulativeMetric reports whether metric can be passed to GetCumulativeDailyValues
+func IsCumulativeMetric(metric string) bool {
+	_, ok := cumulativeMetricColumns[metric]
+	return ok
+}
+
+// GetCumulativeDailyValues returns one stored metric per site and day over a date
+// range, keyed by site ID then YYYY-MM-DD date. Days without a stored reading are absent.
+func (db *DB) GetCumulativeDailyValues(sites []*models.Site, startDate, endDate, metric string) (map[int]map[string]float64, error) {
+	values := make(map[int]map[string]float64)
+	if len(sites) == 0 {
+		return values, nil
+	}
+
+	column, ok := cumulativeMetricColumns[metric]
+	if !ok {
+		return nil, fmt.Errorf("unsupported cumulative metric: %s", metric)
+	}
+
+	siteIDs := make([]interface{}, len(sites))
+	placeholders := make([]string, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+		placeholders[i] = fmt.Sprintf("$%d", i+3) // +3 because $1 and $2 are the dates
+	}
+
+	query := fmt.Sprintf(`
+		SELECT site_id, TO_CHAR(date::date, 'YYYY-MM-DD'), CAST(%s AS DECIMAL)
+		FROM cumulative_readings
+		WHERE date >= $1 AND date <= $2 AND site_id IN (%s)
+	`, column, strings.Join(placeholders, ", "))
+
+	args := []interface{}{startDate, endDate}
+	args = append(args, siteIDs...)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cumulative daily values: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var siteID int
+		var date string
+		var value float64
+		if err := rows.Scan(&siteID, &date, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan cumulative daily value: %w", err)
+		}
+
+		if values[siteID] == nil {
+			values[siteID] = make(map[string]float64)
+		}
+		values[siteID][date] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cumulative daily values: %w", err)
+	}
+
+	return values, nil
+}
+
+// GetDeviceReadingSpan returns the times of a device's first and last sensor
+// readings, or nils when the device has none
+func (db *DB) GetDeviceReadingSpan(deviceID string) (first, last *time.Time, err error) {
+	query := `SELECT MIN(time), MAX(time) FROM sensor_readings WHERE LOWER(device_id) = LOWER($1)`
+
+	if err := db.QueryRow(query, deviceID).Scan(&first, &last); err != nil {
+		return nil, nil, fmt.Errorf("failed to get device reading span: %w", err)
+	}
+
+	return first, last, nil
+}
+
+// GetCumulativeAvailableDates returns the YYYY-MM-DD dates in a range on which any
+// of the given sites has a cumulative reading, oldest first
+func (db *DB) GetCumulativeAvailableDates(sites []*models.Site, startDate, endDate string) ([]string, error) {
+	dates := []string{}
+	if len(sites) == 0 {
+		return dates, nil
+	}
+
+	siteIDs := make([]interface{}, len(sites))
+	placeholders := make([]string, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+		placeholders[i] = fmt.Sprintf("$%d", i+3) // +3 because $1 and $2 are the dates
+	}
+
+	query := fmt.Sprintf(`
+		SELECT DISTINCT TO_CHAR(date::date, 'YYYY-MM-DD') AS day
+		FROM cumulative_readings
+		WHERE date >= $1 AND date <= $2 AND site_id IN (%s)
+		ORDER BY day
+	`, strings.Join(placeholders, ", "))
+
+	args := []interface{}{startDate, endDate}
+	args = append(args, siteIDs...)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cumulative available dates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("failed to scan cumulative available date: %w", err)
+		}
+		dates = append(dates, date)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cumulative available dates: %w", err)
+	}
+
+	return dates, nil
+}
+
+// GetOutdatedCumulativeReadings returns the stored cumulative readings of the
+// given sites between startDate and endDate whose calc_version is below
+// version, ordered by date and site
+func (db *DB) GetOutdatedCumulativeReadings(sites []*models.Site, startDate, endDate string, version int) ([]*models.OutdatedCumulativeReading, error) {
+	defer db.timeQuery("GetOutdatedCumulativeReadings")()
+
+	readings := []*models.OutdatedCumulativeReading{}
+	if len(sites) == 0 {
+		return readings, nil
+	}
+
+	siteIDs := make([]interface{}, len(sites))
+	placeholders := make([]string, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+		placeholders[i] = fmt.Sprintf("$%d", i+4) // +4 because $1 and $2 are the dates and $3 the version
+	}
+
+	query := fmt.Sprintf(`
+		SELECT site_id, TO_CHAR(date::date, 'YYYY-MM-DD') AS day, calc_version, calculated_at
+		FROM cumulative_readings
+		WHERE date >= $1 AND date <= $2 AND calc_version < $3 AND site_id IN (%s)
+		ORDER BY day, site_id
+	`, strings.Join(placeholders, ", "))
+
+	args := []interface{}{startDate, endDate, version}
+	args = append(args, siteIDs...)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outdated cumulative readings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reading models.OutdatedCumulativeReading
+		if err := rows.Scan(&reading.SiteID, &reading.Date, &reading.CalcVersion, &reading.CalculatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outdated cumulative reading: %w", err)
+		}
+		readings = append(readings, &reading)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outdated cumulative readings: %w", err)
+	}
+
+	return readings, nil
+}
+
+// GetCumulativeDates returns the YYYY-MM-DD dates a site already has cumulative readings for
+func (db *DB) GetCumulativeDates(siteID int) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT TO_CHAR(date::date, 'YYYY-MM-DD') FROM cumulative_readings WHERE site_id = $1`, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cumulative dates: %w", err)
+	}
+	defer rows.Close()
+
+	dates := make(map[string]bool)
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("failed to scan cumulative date: %w", err)
+		}
+		dates[date] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cumulative dates: %w", err)
+	}
+
+	return dates, nil
+}
+
+// RecordCumulativeError stores the latest calculation failure for a site and date
+func (db *DB) RecordCumulativeError(siteID int, deviceID, date, message string) error {
+	query := `
+		INSERT INTO cumulative_errors (site_id, device_id, date, error, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (site_id, date)
+		DO UPDATE SET error = EXCLUDED.error, created_at = EXCLUDED.created_at
+	`
+
+	if _, err := db.Exec(query, siteID, deviceID, date, message); err != nil {
+		return fmt.Errorf("failed to record cumulative error: %w", err)
+	}
+	return nil
+}
+
+// ClearCumulativeError removes a recorded calculation failure once a site and date succeed
+func (db *DB) ClearCumulativeError(siteID int, date string) error {
+	if _, err := db.Exec("DELETE FROM cumulative_errors WHERE site_id = $1 AND date = $2", siteID, date); err != nil {
+		return fmt.Errorf("failed to clear cumulative error: %w", err)
+	}
+	return nil
+}
+
+// GetCumulativeProcessingStatus reports, per site, whether a cumulative reading and
+// a recorded calculation error exist for the date
+func (db *DB) GetCumulativeProcessingStatus(date string, sites []*models.Site) ([]*models.CumulativeSiteStatus, error) {
+	if len(sites) == 0 {
+		return []*models.CumulativeSiteStatus{}, nil
+	}
+
+	siteIDs := make([]interface{}, len(sites))
+	placeholders := make([]string, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+		placeholders[i] = fmt.Sprintf("$%d", i+2) // +2 because $1 is for date
+	}
+
+	query := fmt.Sprintf(`
+		SELECT s.id, s.name, s.device_id, cr.calculated_at, cr.calc_version, ce.error, ce.created_at
+		FROM sites s
+		LEFT JOIN cumulative_readings cr ON cr.site_id = s.id AND cr.date = $1
+		LEFT JOIN cumulative_errors ce ON ce.site_id = s.id AND ce.date = $1
+		WHERE s.id IN (%s)
+		ORDER BY s.name
+	`, strings.Join(placeholders, ", "))
+
+	args := []interface{}{date}
+	args = append(args, siteIDs...)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cumulative processing status: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := []*models.CumulativeSiteStatus{}
+	for rows.Next() {
+		var status models.CumulativeSiteStatus
+		var calculatedAt, erroredAt sql.NullTime
+		var calcVersion sql.NullInt64
+		var errorMessage sql.NullString
+
+		err := rows.Scan(&status.SiteID, &status.SiteName, &status.DeviceID, &calculatedAt, &calcVersion, &errorMessage, &erroredAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cumulative processing status: %w", err)
+		}
+
+		if calculatedAt.Valid {
+			status.Processed = true
+			status.CalculatedAt = &calculatedAt.Time
+		}
+		if calcVersion.Valid {
+			version := int(calcVersion.Int64)
+			status.CalcVersion = &version
+			status.Outdated = version < models.CumulativeCalcVersion
+		}
+		if errorMessage.Valid {
+			status.HasError = true
+			status.Error = errorMessage.String
+		}
+		if erroredAt.Valid {
+			status.ErroredAt = &erroredAt.Time
+		}
+
+		statuses = append(statuses, &status)
+	}
+
+	return statuses, nil
+}
+
+// GetRecentBurnStats sums a site's stored fuel consumption (liters) and generator
+// runtime (hours) over cumulative readings dated on or after since
+func (db *DB) GetRecentBurnStats(siteID int, since string) (days int, fuelConsumed, generatorHours float64, err error) {
+	query := `
+		SELECT COUNT(*),
+			COALESCE(SUM(CAST(total_fuel_consumed AS DECIMAL)), 0),
+			COALESCE(SUM(CAST(total_generator_runtime AS DECIMAL)), 0)
+		FROM cumulative_readings
+		WHERE site_id = $1 AND date >= $2
+	`
+
+	err = db.QueryRow(query, siteID, since).Scan(&days, &fuelConsumed, &generatorHours)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get recent burn stats: %w", err)
+	}
+
+	return days, fuelConsumed, generatorHours, nil
+}
+
+// GetCumulativeTotalsByLocation sums cumulative readings for the given sites over a
+// date range grouped by site location, highest fuel consumption first. Sites
+// without a location are grouped under an empty location.
+func (db *DB) GetCumulativeTotalsByLocation(sites []*models.Site, startDate, endDate string) ([]*models.LocationTotals, error) {
+	if len(sites) == 0 {
+		return []*models.LocationTotals{}, nil
+	}
+
+	siteIDs := make([]interface{}, len(sites))
+	placeholders := make([]string, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+		placeholders[i] = fmt.Sprintf("$%d", i+3) // +3 because $1 and $2 are the dates
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			COALESCE(TRIM(s.location), '') AS location,
+			COUNT(DISTINCT cr.site_id) AS site_count,
+			COUNT(*) AS reading_days,
+			SUM(CAST(cr.total_fuel_consumed AS DECIMAL)) AS total_fuel_consumed,
+			SUM(CAST(cr.total_fuel_topped_up AS DECIMAL)) AS total_fuel_topped,
+			SUM(CAST(cr.total_generator_runtime AS DECIMAL)) AS total_generator_hours,
+			SUM(CAST(cr.total_zesa_runtime AS DECIMAL)) AS total_zesa_hours,
+			SUM(CAST(cr.total_offline_time AS DECIMAL)) AS total_offline_hours
+		FROM cumulative_readings cr
+		JOIN sites s ON s.id = cr.site_id
+		WHERE cr.date >= $1 AND cr.date <= $2 AND cr.site_id IN (%s)
+		GROUP BY COALESCE(TRIM(s.location), '')
+		ORDER BY total_fuel_consumed DESC, location
+	`, strings.Join(placeholders, ", "))
+
+	args := []interface{}{startDate, endDate}
+	args = append(args, siteIDs...)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cumulative totals by location: %w", err)
+	}
+	defer rows.Close()
+
+	locations := []*models.LocationTotals{}
+	for rows.Next() {
+		var location models.LocationTotals
+		err := rows.Scan(
+			&location.Location,
+			&location.SiteCount,
+			&location.ReadingDays,
+			&location.TotalFuelConsumed,
+			&location.TotalFuelTopped,
+			&location.TotalGeneratorHours,
+			&location.TotalZesaHours,
+			&location.TotalOfflineHours,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cumulative totals by location: %w", err)
+		}
+		locations = append(locations, &location)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cumulative totals by location: %w", err)
 	}
 
-	return runtime, nil
+	return locations, nil
 }
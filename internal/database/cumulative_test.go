@@ -0,0 +1,410 @@
+package database
+
+import (
+	"database/sql/driver"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+)
+
+func TestDayBounds(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	endOfDay := day.Add(24*time.Hour - time.Nanosecond)
+
+	tests := []struct {
+		name      string
+		target    time.Time
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"past day", day, day.AddDate(0, 0, 3), day, endOfDay},
+		{"time of day ignored", day.Add(15 * time.Hour), day.AddDate(0, 0, 3), day, endOfDay},
+		{"day in progress", day, day.Add(9*time.Hour + 30*time.Minute), day, day.Add(9*time.Hour + 30*time.Minute)},
+		{"now in another zone", day, day.Add(9 * time.Hour).In(time.FixedZone("CAT", 2*60*60)), day, day.Add(9 * time.Hour)},
+		{"at midnight", day, day, day, endOfDay},
+		{"future day", day, day.Add(-time.Hour), day, endOfDay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := dayBounds(tt.target, tt.now)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("dayBounds = %v to %v, want %v to %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+			if end.Location() != time.UTC {
+				t.Errorf("end is in %v, want UTC", end.Location())
+			}
+		})
+	}
+}
+
+func TestCalculatePowerRuntimesCapsToday(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.AddDate(0, 0, -1)
+
+	tests := []struct {
+		name      string
+		target    time.Time
+		wantHours func() float64
+	}{
+		{"past day", yesterday, func() float64 { return 24 }},
+		{"today", today, func() float64 { return time.Since(today).Hours() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ends []time.Time
+			// The generator turns on at midnight and stays on; zesa never reports
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				ends = append(ends, args[3].Value.(time.Time))
+				var rows [][]driver.Value
+				if args[1].Value == "generator_state" {
+					rows = [][]driver.Value{{"1", tt.target}}
+				}
+				return []string{"value", "time"}, rows, nil
+			})
+
+			metrics, err := db.CalculatePowerRuntimes("simbisa-a", tt.target, nil)
+			if err != nil {
+				t.Fatalf("CalculatePowerRuntimes: %v", err)
+			}
+			want := tt.wantHours()
+
+			for _, end := range ends {
+				if end.After(time.Now()) {
+					t.Errorf("queried up to %v, after now", end)
+				}
+			}
+			if math.Abs(metrics.TotalGeneratorRuntime-want) > 0.01 {
+				t.Errorf("generator runtime = %.3f hours, want %.3f", metrics.TotalGeneratorRuntime, want)
+			}
+			if metrics.TotalOfflineTime > 0.01 {
+				t.Errorf("offline time = %.3f hours, want 0", metrics.TotalOfflineTime)
+			}
+		})
+	}
+}
+
+func TestStateRuntimeUsesConfiguredOnValues(t *testing.T) {
+	models.SetOnStateValues([]string{"Running"})
+	defer models.SetOnStateValues(nil)
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("runtime", func(t *testing.T) {
+		db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+			return []string{"value", "time"}, [][]driver.Value{
+				{"RUNNING", day.Add(time.Hour)},
+				{"1", day.Add(3 * time.Hour)},
+				{"running", day.Add(5 * time.Hour)},
+				{"stopped", day.Add(6 * time.Hour)},
+			}, nil
+		})
+
+		samples, err := db.getStateSamples("simbisa-a", "generator_state", day, day.Add(24*time.Hour))
+		if err != nil {
+			t.Fatalf("getStateSamples: %v", err)
+		}
+		hours, _ := walkStates(samples, day.Add(24*time.Hour))
+		// "1" is no longer an on value, so only 1-3h and 5-6h count
+		if hours != 3 {
+			t.Errorf("runtime = %g hours, want 3", hours)
+		}
+	})
+
+	t.Run("activity", func(t *testing.T) {
+		var onValues interface{}
+		db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+			onValues = args[3].Value
+			return []string{"count"}, [][]driver.Value{{int64(1)}}, nil
+		})
+
+		active, err := db.hasGeneratorActivity("simbisa-a", "generator_state", day, day.Add(24*time.Hour))
+		if err != nil {
+			t.Fatalf("hasGeneratorActivity: %v", err)
+		}
+		if !active || onValues != `{"running"}` {
+			t.Errorf("active = %t with on values %v, want true with {\"running\"}", active, onValues)
+		}
+	})
+}
+
+func TestFuelChangesDisagree(t *testing.T) {
+	tests := []struct {
+		name                      string
+		levelChange, volumeChange float64
+		startVolume, tolerance    float64
+		want                      bool
+	}{
+		{"both fall", -10, -100, 1000, 5, false},
+		{"both rise", 10, 100, 1000, 5, false},
+		{"level rises, volume falls", 10, -100, 1000, 5, true},
+		{"level falls, volume rises", -10, 100, 1000, 5, true},
+		{"volume within tolerance", 10, -40, 1000, 5, false},
+		{"level within tolerance", 4, -100, 1000, 5, false},
+		{"exactly at tolerance", 5, -50, 1000, 5, false},
+		{"tolerance off", 10, -100, 1000, 0, false},
+		{"no starting volume", 10, -100, 0, 5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fuelChangesDisagree(tt.levelChange, tt.volumeChange, tt.startVolume, tt.tolerance); got != tt.want {
+				t.Errorf("fuelChangesDisagree = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateFuelChangesFlagsInconsistentMetrics(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		endVolume string
+		tolerance float64
+		want      bool
+	}{
+		{"volume follows level", "1100", 5, false},
+		{"volume falls as level rises", "900", 5, true},
+		{"check disabled", "900", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				// The level rises 10 points over the day
+				return []string{"value", "time", "sensor_name"}, [][]driver.Value{
+					{"50", day.Add(time.Hour), "fuel_sensor_level"},
+					{"1000", day.Add(time.Hour), "fuel_sensor_volume"},
+					{"60", day.Add(5 * time.Hour), "fuel_sensor_level"},
+					{tt.endVolume, day.Add(5 * time.Hour), "fuel_sensor_volume"},
+				}, nil
+			})
+
+			metrics, err := db.CalculateFuelChanges("simbisa-a", day, FuelCalcOptions{ConsistencyTolerance: tt.tolerance})
+			if err != nil {
+				t.Fatalf("CalculateFuelChanges: %v", err)
+			}
+			if metrics.MetricsInconsistent != tt.want {
+				t.Errorf("MetricsInconsistent = %t, want %t", metrics.MetricsInconsistent, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalkStates(t *testing.T) {
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	endOfDay := midnight.Add(24*time.Hour - time.Nanosecond)
+	at := func(hours float64, on bool) stateSample {
+		return stateSample{On: on, Time: midnight.Add(time.Duration(hours * float64(time.Hour)))}
+	}
+	day := endOfDay.Sub(midnight).Hours()
+
+	tests := []struct {
+		name        string
+		samples     []stateSample
+		end         time.Time
+		wantRuntime float64
+		wantStarts  int
+	}{
+		{"no samples", nil, endOfDay, 0, 0},
+		{"off all day", []stateSample{at(0, false), at(12, false)}, endOfDay, 0, 0},
+		{"one run", []stateSample{at(0, false), at(2, true), at(5, false)}, endOfDay, 3, 1},
+		{"two runs", []stateSample{at(1, true), at(2, false), at(4, true), at(6, false)}, endOfDay, 3, 1},
+		{"on at midnight is not a start", []stateSample{at(0, true), at(3, false)}, endOfDay, 3, 0},
+		{"on at midnight runs all day", []stateSample{at(0, true), at(12, true)}, endOfDay, day, 0},
+		{"on at midnight, restarted later", []stateSample{at(0, true), at(1, false), at(2, true), at(3, false)}, endOfDay, 2, 1},
+		{"last state runs to the end", []stateSample{at(0, false), at(20, true)}, endOfDay, day - 20, 1},
+		{"day in progress", []stateSample{at(0, false), at(8, true)}, midnight.Add(10 * time.Hour), 2, 1},
+		{"same timestamp, last row wins", []stateSample{at(0, false), at(2, true), at(2, false), at(4, true)}, midnight.Add(5 * time.Hour), 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime, starts := walkStates(tt.samples, tt.end)
+			if math.Abs(runtime-tt.wantRuntime) > 1e-9 || starts != tt.wantStarts {
+				t.Errorf("walkStates = %v hours, %d starts, want %v hours, %d starts", runtime, starts, tt.wantRuntime, tt.wantStarts)
+			}
+		})
+	}
+}
+
+func TestCalculatePowerRuntimesCountsGeneratorStarts(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return day.Add(time.Duration(hours) * time.Hour) }
+
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		// The generator runs over midnight, then starts twice; zesa switching on does not count
+		if args[1].Value == "generator_state" {
+			return []string{"value", "time"}, [][]driver.Value{
+				{"1", at(0)}, {"0", at(1)},
+				{"1", at(2)}, {"0", at(3)},
+				{"1", at(5)}, {"0", at(6)},
+			}, nil
+		}
+		return []string{"value", "time"}, [][]driver.Value{{"0", at(0)}, {"1", at(6)}}, nil
+	})
+
+	metrics, err := db.CalculatePowerRuntimes("simbisa-a", day, nil)
+	if err != nil {
+		t.Fatalf("CalculatePowerRuntimes: %v", err)
+	}
+	if metrics.GeneratorStarts != 2 {
+		t.Errorf("generator starts = %d, want 2", metrics.GeneratorStarts)
+	}
+	if math.Abs(metrics.TotalGeneratorRuntime-3) > 1e-9 {
+		t.Errorf("generator runtime = %v hours, want 3", metrics.TotalGeneratorRuntime)
+	}
+}
+
+func TestPoweredHours(t *testing.T) {
+	midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	endOfDay := midnight.Add(24 * time.Hour)
+	at := func(hours float64, on bool) stateSample {
+		return stateSample{On: on, Time: midnight.Add(time.Duration(hours * float64(time.Hour)))}
+	}
+
+	tests := []struct {
+		name      string
+		generator []stateSample
+		zesa      []stateSample
+		want      float64
+	}{
+		{"no samples", nil, nil, 0},
+		{"generator only", []stateSample{at(0, false), at(2, true), at(5, false)}, nil, 3},
+		{"zesa only, runs to the end", nil, []stateSample{at(0, false), at(20, true)}, 4},
+		{"disjoint", []stateSample{at(0, true), at(6, false)}, []stateSample{at(0, false), at(6, true), at(18, false)}, 18},
+		{"overlap counted once", []stateSample{at(0, true), at(12, false)}, []stateSample{at(0, false), at(6, true), at(18, false)}, 18},
+		{"both on all day", []stateSample{at(0, true)}, []stateSample{at(0, true)}, 24},
+		{"same timestamp, last row wins", []stateSample{at(0, false), at(2, true), at(2, false), at(4, true), at(5, false)}, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := poweredHours(tt.generator, tt.zesa, endOfDay); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("poweredHours = %v, want %v", got, tt.want)
+			}
+			// Either series alone matches walkStates
+			if tt.zesa == nil {
+				if runtime, _ := walkStates(tt.generator, endOfDay); math.Abs(runtime-tt.want) > 1e-9 {
+					t.Errorf("walkStates = %v, want %v", runtime, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculatePowerRuntimesMergesOverlap(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return day.Add(time.Duration(hours) * time.Hour) }
+
+	// The generator runs 0-12h and zesa 6-18h: 18 powered hours, not 24
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		if args[1].Value == "generator_state" {
+			return []string{"value", "time"}, [][]driver.Value{{"1", at(0)}, {"0", at(12)}}, nil
+		}
+		return []string{"value", "time"}, [][]driver.Value{{"0", at(0)}, {"1", at(6)}, {"0", at(18)}}, nil
+	})
+
+	metrics, err := db.CalculatePowerRuntimes("simbisa-a", day, nil)
+	if err != nil {
+		t.Fatalf("CalculatePowerRuntimes: %v", err)
+	}
+	if metrics.TotalGeneratorRuntime != 12 || metrics.TotalZesaRuntime != 12 {
+		t.Errorf("runtimes = %v generator, %v zesa hours, want 12 each", metrics.TotalGeneratorRuntime, metrics.TotalZesaRuntime)
+	}
+	if math.Abs(metrics.TotalOfflineTime-6) > 1e-9 {
+		t.Errorf("offline time = %v hours, want 6", metrics.TotalOfflineTime)
+	}
+}
+
+func TestCalculateFuelChangesSameTimestamp(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return day.Add(time.Duration(hours) * time.Hour) }
+
+	// Rows arrive ordered by time, then id. At 2h each sensor reports twice,
+	// and only the later row (45%, 950 liters) counts.
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		return []string{"value", "time", "sensor_name"}, [][]driver.Value{
+			{"50", at(1), "fuel_sensor_level"},
+			{"1000", at(1), "fuel_sensor_volume"},
+			{"40", at(2), "fuel_sensor_level"},
+			{"900", at(2), "fuel_sensor_volume"},
+			{"45", at(2), "fuel_sensor_level"},
+			{"950", at(2), "fuel_sensor_volume"},
+			{"30", at(3), "fuel_sensor_level"},
+			{"800", at(3), "fuel_sensor_volume"},
+		}, nil
+	})
+
+	metrics, err := db.CalculateFuelChanges("simbisa-a", day, FuelCalcOptions{})
+	if err != nil {
+		t.Fatalf("CalculateFuelChanges: %v", err)
+	}
+	// Counting the duplicate would add a 5 point (50 liter) top-up and as much consumption
+	if metrics.FuelConsumedPercent != 20 || metrics.FuelToppedPercent != 0 {
+		t.Errorf("level consumed %v, topped %v points, want 20 and 0", metrics.FuelConsumedPercent, metrics.FuelToppedPercent)
+	}
+	if metrics.TotalFuelConsumed != 200 || metrics.TotalFuelTopped != 0 {
+		t.Errorf("volume consumed %v, topped %v liters, want 200 and 0", metrics.TotalFuelConsumed, metrics.TotalFuelTopped)
+	}
+}
+
+func TestCalculateFuelChangesNoiseFilter(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return day.Add(time.Duration(hours) * time.Hour) }
+
+	tests := []struct {
+		name             string
+		opts             FuelCalcOptions
+		generatorRan     bool
+		wantConsumed     float64
+		wantGeneratorChk bool
+	}{
+		{"grid-only site, filter disabled", FuelCalcOptions{NoGeneratorNoiseThreshold: 0}, false, 14, false},
+		{"grid-only site, own threshold", FuelCalcOptions{NoGeneratorNoiseThreshold: 5}, false, 10, false},
+		// A grid-only site is never gated on generator activity, even if a generator reading exists
+		{"grid-only site ignores generator readings", FuelCalcOptions{NoGeneratorNoiseThreshold: 5}, true, 10, false},
+		{"idle generator site, default threshold", FuelCalcOptions{HasGenerator: true}, false, 13, true},
+		{"running generator site, no filter", FuelCalcOptions{HasGenerator: true}, true, 14, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var generatorChecked bool
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if strings.Contains(query, "COUNT(*)") {
+					generatorChecked = true
+					count := int64(0)
+					if tt.generatorRan {
+						count = 3
+					}
+					return []string{"count"}, [][]driver.Value{{count}}, nil
+				}
+				// The level drops by 1, 3 and 10 points
+				return []string{"value", "time", "sensor_name"}, [][]driver.Value{
+					{"50", at(1), "fuel_sensor_level"},
+					{"49", at(2), "fuel_sensor_level"},
+					{"46", at(3), "fuel_sensor_level"},
+					{"36", at(4), "fuel_sensor_level"},
+				}, nil
+			})
+
+			metrics, err := db.CalculateFuelChanges("simbisa-a", day, tt.opts)
+			if err != nil {
+				t.Fatalf("CalculateFuelChanges: %v", err)
+			}
+			if metrics.FuelConsumedPercent != tt.wantConsumed {
+				t.Errorf("consumed %v points, want %v", metrics.FuelConsumedPercent, tt.wantConsumed)
+			}
+			if generatorChecked != tt.wantGeneratorChk {
+				t.Errorf("generator activity checked = %t, want %t", generatorChecked, tt.wantGeneratorChk)
+			}
+		})
+	}
+}
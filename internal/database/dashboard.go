@@ -7,7 +7,10 @@ import (
 	"strings"
 	"time"
 
+	"fuel-monitor-api/internal/logger"
 	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
 )
 
 // GetUserAdminPreference retrieves admin preference
@@ -31,24 +34,30 @@ func (db *DB) GetUserAdminPreference(userID int) (*models.AdminPreference, error
 
 // GetDashboardSitesForUser - ultra fast without subqueries
 func (db *DB) GetDashboardSitesForUser(userID int, userRole string) ([]*models.Site, error) {
+	defer db.timeQuery("GetDashboardSitesForUser")()
+
 	var query string
 	var args []interface{}
 
 	if userRole == "admin" {
 		query = `
-			SELECT id, name, location, device_id, is_active, created_at
+			SELECT id, name, location, device_id, is_active, created_at, type_id,
+			       maintenance_mode, maintenance_start, maintenance_end, alerts_enabled,
+			       low_fuel_mode, low_fuel_liters, low_fuel_threshold
 			FROM sites 
-			WHERE is_active = true AND device_id LIKE 'simbisa-%'
+			WHERE is_active = true AND device_id ILIKE '` + DeviceIDPrefix + `%'
 			ORDER BY name
 		`
 		args = []interface{}{}
 	} else {
 		query = `
-			SELECT s.id, s.name, s.location, s.device_id, s.is_active, s.created_at
+			SELECT s.id, s.name, s.location, s.device_id, s.is_active, s.created_at, s.type_id,
+			       s.maintenance_mode, s.maintenance_start, s.maintenance_end, s.alerts_enabled,
+			       s.low_fuel_mode, s.low_fuel_liters, s.low_fuel_threshold
 			FROM sites s 
 			INNER JOIN user_site_assignments usa ON usa.site_id = s.id
 			WHERE s.is_active = true 
-			  AND s.device_id LIKE 'simbisa-%'
+			  AND s.device_id ILIKE '` + DeviceIDPrefix + `%'
 			  AND usa.user_id = $1
 			ORDER BY s.name
 		`
@@ -61,12 +70,12 @@ func (db *DB) GetDashboardSitesForUser(userID int, userRole string) ([]*models.S
 	}
 	defer rows.Close()
 
-	var sites []*models.Site
+	sites := []*models.Site{}
 	for rows.Next() {
 		var site models.Site
 		var createdAt time.Time
 
-		err := rows.Scan(&site.ID, &site.Name, &site.Location, &site.DeviceID, &site.IsActive, &createdAt)
+		err := rows.Scan(&site.ID, &site.Name, &site.Location, &site.DeviceID, &site.IsActive, &createdAt, &site.TypeID, &site.MaintenanceMode, &site.MaintenanceStart, &site.MaintenanceEnd, &site.AlertsEnabled, &site.LowFuelMode, &site.LowFuelLiters, &site.LowFuelThreshold)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan site: %w", err)
 		}
@@ -78,8 +87,24 @@ func (db *DB) GetDashboardSitesForUser(userID int, userRole string) ([]*models.S
 	return sites, nil
 }
 
-// GetSingleDeviceReading - optimized for single device using your index perfectly
-func (db *DB) GetSingleDeviceReading(deviceID string) *models.SensorReading {
+// GetSingleDeviceReading - optimized for single device using your index perfectly.
+// names maps the sensors to the names the device reports them under. Returns nil
+// when the device has no fuel level or the query failed; callers that must tell
+// those apart use GetLatestDeviceReading.
+func (db *DB) GetSingleDeviceReading(deviceID string, names models.SensorNames) *models.SensorReading {
+	reading, err := db.GetLatestDeviceReading(deviceID, names)
+	if err != nil {
+		logger.Warnf("%v", err)
+		return nil
+	}
+	return reading
+}
+
+// GetLatestDeviceReading is GetSingleDeviceReading reporting query failures as
+// errors. It returns nil without an error when the device has no fuel level.
+func (db *DB) GetLatestDeviceReading(deviceID string, names models.SensorNames) (*models.SensorReading, error) {
+	defer db.timeQuery("GetSingleDeviceReading")()
+
 	// Single super-fast query per device using your idx_sensor_readings_device_time index
 	query := `
 		SELECT DISTINCT ON (sensor_name)
@@ -87,27 +112,27 @@ func (db *DB) GetSingleDeviceReading(deviceID string) *models.SensorReading {
 			value,
 			time
 		FROM sensor_readings 
-		WHERE device_id = $1
-		  AND sensor_name IN ('fuel_sensor_level', 'fuel_sensor_volume', 'fuel_sensor_temp', 'fuel_sensor_temperature', 'generator_state', 'zesa_state')
+		WHERE LOWER(device_id) = LOWER($1)
+		  AND sensor_name = ANY($2)
 		  AND value IS NOT NULL
 		ORDER BY sensor_name, time DESC
 	`
 
-	rows, err := db.Query(query, deviceID)
+	rows, err := db.Query(query, deviceID, pq.Array(names.DeviceNames(models.ReadingSensors()...)))
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("failed to get latest reading of %s: %w", deviceID, err)
 	}
 	defer rows.Close()
 
 	reading := &models.SensorReading{
 		DeviceID:       deviceID,
-		FuelVolume:     "0.00",
 		GeneratorState: "unknown",
 		ZesaState:      "unknown",
 	}
 
 	hasFuelLevel := false
 	var fuelTimestamp time.Time
+	var temperature models.TemperatureResolver
 
 	for rows.Next() {
 		var sensorName, value string
@@ -117,33 +142,50 @@ func (db *DB) GetSingleDeviceReading(deviceID string) *models.SensorReading {
 			continue
 		}
 
-		switch sensorName {
+		sensor := names.Sensor(sensorName)
+		if temperature.Offer(sensor, value) {
+			continue
+		}
+
+		switch sensor {
 		case "fuel_sensor_level":
 			reading.FuelLevel = value
 			fuelTimestamp = timestamp
 			hasFuelLevel = true
 		case "fuel_sensor_volume":
 			reading.FuelVolume = value
-		case "fuel_sensor_temp", "fuel_sensor_temperature":
-			reading.Temperature = &value
 		case "generator_state":
 			reading.GeneratorState = value
 		case "zesa_state":
 			reading.ZesaState = value
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get latest reading of %s: %w", deviceID, err)
+	}
 
 	if !hasFuelLevel {
-		return nil
+		return nil, nil
 	}
 
+	reading.Temperature = temperature.Value()
 	reading.CapturedAt = fuelTimestamp
 	reading.CreatedAt = fuelTimestamp
-	return reading
+	reading.ParseValues()
+	// A missing volume is reported as 0.00 but is not a parsed volume
+	if reading.FuelVolume == "" {
+		reading.FuelVolume = "0.00"
+	}
+	return reading, nil
 }
 
-// GetSingleSiteDailyClosing - gets daily closing data + live states for one site
-func (db *DB) GetSingleSiteDailyClosing(siteID int, deviceID string) *models.SensorReading {
+// GetSingleSiteDailyClosing - gets daily closing data + live states for one site.
+// When cutoff is set, the closing row is the last one captured at or before it;
+// otherwise the latest row is used. names maps the live state sensors to the
+// names the device reports them under.
+func (db *DB) GetSingleSiteDailyClosing(siteID int, deviceID string, cutoff *time.Time, names models.SensorNames) *models.SensorReading {
+	defer db.timeQuery("GetSingleSiteDailyClosing")()
+
 	// Get daily closing fuel data using your idx_daily_closing_site_latest index
 	dailyQuery := `
 		SELECT fuel_level, fuel_volume, temperature, captured_at
@@ -152,11 +194,24 @@ func (db *DB) GetSingleSiteDailyClosing(siteID int, deviceID string) *models.Sen
 		ORDER BY captured_at DESC
 		LIMIT 1
 	`
+	args := []interface{}{siteID}
+
+	if cutoff != nil {
+		dailyQuery = `
+			SELECT fuel_level, fuel_volume, temperature, captured_at
+			FROM daily_closing_readings
+			WHERE site_id = $1 AND fuel_level IS NOT NULL 
+			  AND captured_at <= $2
+			ORDER BY captured_at DESC
+			LIMIT 1
+		`
+		args = append(args, *cutoff)
+	}
 
 	var fuelLevel, fuelVolume, temperature sql.NullString
 	var capturedAt time.Time
 
-	err := db.QueryRow(dailyQuery, siteID).Scan(&fuelLevel, &fuelVolume, &temperature, &capturedAt)
+	err := db.QueryRow(dailyQuery, args...).Scan(&fuelLevel, &fuelVolume, &temperature, &capturedAt)
 	if err != nil {
 		return nil
 	}
@@ -164,7 +219,6 @@ func (db *DB) GetSingleSiteDailyClosing(siteID int, deviceID string) *models.Sen
 	reading := &models.SensorReading{
 		SiteID:         siteID,
 		DeviceID:       deviceID,
-		FuelVolume:     "0.00",
 		GeneratorState: "unknown",
 		ZesaState:      "unknown",
 		CapturedAt:     capturedAt,
@@ -185,25 +239,30 @@ func (db *DB) GetSingleSiteDailyClosing(siteID int, deviceID string) *models.Sen
 	// Get live generator state
 	generatorQuery := `
 		SELECT value FROM sensor_readings 
-		WHERE device_id = $1 AND sensor_name = 'generator_state' AND value IS NOT NULL
+		WHERE LOWER(device_id) = LOWER($1) AND sensor_name = $2 AND value IS NOT NULL
 		ORDER BY time DESC LIMIT 1
 	`
 	var generatorState string
-	if err := db.QueryRow(generatorQuery, deviceID).Scan(&generatorState); err == nil {
+	if err := db.QueryRow(generatorQuery, deviceID, names.Device("generator_state")).Scan(&generatorState); err == nil {
 		reading.GeneratorState = generatorState
 	}
 
 	// Get live zesa state
 	zesaQuery := `
 		SELECT value FROM sensor_readings 
-		WHERE device_id = $1 AND sensor_name = 'zesa_state' AND value IS NOT NULL
+		WHERE LOWER(device_id) = LOWER($1) AND sensor_name = $2 AND value IS NOT NULL
 		ORDER BY time DESC LIMIT 1
 	`
 	var zesaState string
-	if err := db.QueryRow(zesaQuery, deviceID).Scan(&zesaState); err == nil {
+	if err := db.QueryRow(zesaQuery, deviceID, names.Device("zesa_state")).Scan(&zesaState); err == nil {
 		reading.ZesaState = zesaState
 	}
 
+	reading.ParseValues()
+	// A missing volume is reported as 0.00 but is not a parsed volume
+	if reading.FuelVolume == "" {
+		reading.FuelVolume = "0.00"
+	}
 	return reading
 }
 
@@ -211,7 +270,7 @@ func (db *DB) GetSingleSiteDailyClosing(siteID int, deviceID string) *models.Sen
 func (db *DB) GetBatchRealTimeReadings(deviceIDs []string) (map[string]*models.SensorReading, error) {
 	result := make(map[string]*models.SensorReading)
 	for _, deviceID := range deviceIDs {
-		if reading := db.GetSingleDeviceReading(deviceID); reading != nil {
+		if reading := db.GetSingleDeviceReading(deviceID, nil); reading != nil {
 			result[deviceID] = reading
 		}
 	}
@@ -238,7 +297,7 @@ func (db *DB) GetAllActiveSites() ([]string, error) {
 	}
 	defer rows.Close()
 
-	var sites []string
+	sites := []string{}
 	for rows.Next() {
 		var deviceID string
 		if err := rows.Scan(&deviceID); err != nil {
@@ -255,7 +314,7 @@ func (db *DB) GetLatestReadingForSite(siteID, sensorName string) (*time.Time, *f
 	query := `
 		SELECT time, value
 		FROM sensor_readings
-		WHERE device_id = $1 AND sensor_name = $2 AND value IS NOT NULL
+		WHERE LOWER(device_id) = LOWER($1) AND sensor_name = $2 AND value IS NOT NULL
 		ORDER BY time DESC LIMIT 1
 	`
 
@@ -277,3 +336,216 @@ func (db *DB) GetLatestReadingForSite(siteID, sensorName string) (*time.Time, *f
 
 	return &timestamp, &value, nil
 }
+
+// GetLatestSensorReading gets a device's latest raw value for one sensor.
+// Returns nil when the device has no reading for the sensor.
+func (db *DB) GetLatestSensorReading(deviceID, sensorName string) (*models.RawSensorReading, error) {
+	query := `
+		SELECT sensor_name, value, time
+		FROM sensor_readings
+		WHERE LOWER(device_id) = LOWER($1) AND sensor_name = $2 AND value IS NOT NULL
+		ORDER BY time DESC LIMIT 1
+	`
+
+	var reading models.RawSensorReading
+	err := db.QueryRow(query, deviceID, sensorName).Scan(&reading.SensorName, &reading.Value, &reading.Time)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest sensor reading: %w", err)
+	}
+
+	return &reading, nil
+}
+
+// GetRecentSensorReadings gets a sensor's latest limit raw readings, newest first
+func (db *DB) GetRecentSensorReadings(deviceID, sensorName string, limit int) ([]models.RawSensorReading, error) {
+	query := `
+		SELECT sensor_name, value, time
+		FROM sensor_readings
+		WHERE LOWER(device_id) = LOWER($1) AND sensor_name = $2 AND value IS NOT NULL
+		ORDER BY time DESC LIMIT $3
+	`
+
+	rows, err := db.Query(query, deviceID, sensorName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []models.RawSensorReading{}
+	for rows.Next() {
+		var reading models.RawSensorReading
+		if err := rows.Scan(&reading.SensorName, &reading.Value, &reading.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, rows.Err()
+}
+
+// GetFuelLevelAt gets the most recent fuel level and volume readings at or before a point in time.
+// Either result is nil when the device has no such reading before the time.
+// names maps the sensors to the names the device reports them under.
+func (db *DB) GetFuelLevelAt(deviceID string, at time.Time, names models.SensorNames) (*models.TimedValue, *models.TimedValue, error) {
+	query := `
+		SELECT value, time
+		FROM sensor_readings
+		WHERE LOWER(device_id) = LOWER($1) AND sensor_name = $2 AND value IS NOT NULL
+		  AND time <= $3
+		ORDER BY time DESC LIMIT 1
+	`
+
+	readings := make([]*models.TimedValue, 2)
+	for i, sensorName := range []string{"fuel_sensor_level", "fuel_sensor_volume"} {
+		var reading models.TimedValue
+		err := db.QueryRow(query, deviceID, names.Device(sensorName), at).Scan(&reading.Value, &reading.Time)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to get %s at %s: %w", sensorName, at.Format(time.RFC3339), err)
+		}
+		readings[i] = &reading
+	}
+
+	return readings[0], readings[1], nil
+}
+
+// GetSensorReadingsAfter gets raw readings for a device ordered by time and
+// sensor name, starting strictly after the cursor (or from the beginning when
+// after is nil). Readings at the cursor time are skipped only up to afterSensor,
+// so a page boundary between readings sharing a timestamp loses none of them;
+// an empty afterSensor skips every reading at that time. At most limit rows are
+// returned.
+func (db *DB) GetSensorReadingsAfter(deviceID string, sensorNames []string, after *time.Time, afterSensor string, limit int) ([]*models.RawSensorReading, error) {
+	query := `
+		SELECT sensor_name, value, time
+		FROM sensor_readings
+		WHERE LOWER(device_id) = LOWER($1)
+		  AND sensor_name = ANY($2)
+		  AND value IS NOT NULL
+		  AND ($3::timestamptz IS NULL OR (time, sensor_name) > ($3, $4::text))
+		ORDER BY time ASC, sensor_name ASC
+		LIMIT $5
+	`
+
+	var afterArg, afterSensorArg interface{}
+	if after != nil {
+		afterArg = *after
+	}
+	// A NULL sensor makes the row comparison false for every reading at the
+	// cursor time, so they are all skipped
+	if afterSensor != "" {
+		afterSensorArg = afterSensor
+	}
+
+	rows, err := db.Query(query, deviceID, pq.Array(sensorNames), afterArg, afterSensorArg, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []*models.RawSensorReading{}
+	for rows.Next() {
+		var reading models.RawSensorReading
+		if err := rows.Scan(&reading.SensorName, &reading.Value, &reading.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor reading: %w", err)
+		}
+		readings = append(readings, &reading)
+	}
+
+	return readings, nil
+}
+
+// GetDeviceSensors returns the distinct sensor names a device reports, sorted by name.
+// When withLatest is true each sensor also carries its most recent value and time.
+func (db *DB) GetDeviceSensors(deviceID string, withLatest bool) ([]*models.DeviceSensor, error) {
+	query := `
+		SELECT DISTINCT sensor_name
+		FROM sensor_readings
+		WHERE LOWER(device_id) = LOWER($1)
+		ORDER BY sensor_name
+	`
+	if withLatest {
+		query = `
+			SELECT DISTINCT ON (sensor_name) sensor_name, value, time
+			FROM sensor_readings
+			WHERE LOWER(device_id) = LOWER($1)
+			ORDER BY sensor_name, time DESC
+		`
+	}
+
+	rows, err := db.Query(query, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device sensors: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []*models.DeviceSensor{}
+	for rows.Next() {
+		var sensor models.DeviceSensor
+		if withLatest {
+			var value sql.NullString
+			var readingTime time.Time
+			if err := rows.Scan(&sensor.SensorName, &value, &readingTime); err != nil {
+				return nil, fmt.Errorf("failed to scan device sensor: %w", err)
+			}
+			if value.Valid {
+				sensor.LatestValue = &value.String
+			}
+			sensor.LatestTime = &readingTime
+		} else if err := rows.Scan(&sensor.SensorName); err != nil {
+			return nil, fmt.Errorf("failed to scan device sensor: %w", err)
+		}
+		sensors = append(sensors, &sensor)
+	}
+
+	return sensors, nil
+}
+
+// GetFuelVolumeSeries buckets a device's fuel_sensor_volume readings into fixed
+// intervals between start and end. Volume is a level, so each bucket holds the
+// last reading in it rather than an average; refills show up as clean steps.
+// Buckets without numeric readings are omitted. names maps the sensors to the
+// names the device reports them under.
+func (db *DB) GetFuelVolumeSeries(deviceID string, start, end time.Time, interval time.Duration, names models.SensorNames) ([]*models.VolumePoint, error) {
+	query := `
+		SELECT DISTINCT ON (bucket) bucket, value, time
+		FROM (
+			SELECT to_timestamp(floor(extract(epoch FROM time) / $4) * $4) AS bucket, value, time, id
+			FROM sensor_readings
+			WHERE LOWER(device_id) = LOWER($1)
+			  AND sensor_name = $5
+			  AND time >= $2 AND time < $3
+			  AND value ~ '^\s*-?[0-9]+(\.[0-9]+)?\s*$'
+		) readings
+		ORDER BY bucket, time DESC, id DESC
+	`
+
+	rows, err := db.Query(query, deviceID, start, end, interval.Seconds(), names.Device("fuel_sensor_volume"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fuel volume series: %w", err)
+	}
+	defer rows.Close()
+
+	points := []*models.VolumePoint{}
+	for rows.Next() {
+		var point models.VolumePoint
+		var value string
+		if err := rows.Scan(&point.Bucket, &value, &point.ReadingTime); err != nil {
+			return nil, fmt.Errorf("failed to scan fuel volume point: %w", err)
+		}
+
+		point.Volume, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			continue
+		}
+
+		points = append(points, &point)
+	}
+
+	return points, nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestGetSingleDeviceReadingParsesValues(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		rows          [][]driver.Value
+		wantNil       bool
+		wantLevel     float64
+		wantParsed    bool
+		wantGenerator bool
+		wantZesa      bool
+	}{
+		{"numeric level and on states", [][]driver.Value{
+			{"fuel_sensor_level", " 42.5 ", at},
+			{"generator_state", "ON", at},
+			{"zesa_state", "1.0", at},
+		}, false, 42.5, true, true, true},
+		{"unparseable level and off states", [][]driver.Value{
+			{"fuel_sensor_level", "n/a", at},
+			{"generator_state", "0", at},
+		}, false, 0, false, false, false},
+		{"no fuel level", [][]driver.Value{
+			{"generator_state", "1", at},
+		}, true, 0, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				return []string{"sensor_name", "value", "time"}, tt.rows, nil
+			})
+
+			reading := db.GetSingleDeviceReading("simbisa-a", nil)
+			if tt.wantNil {
+				if reading != nil {
+					t.Fatalf("reading = %+v, want nil", reading)
+				}
+				return
+			}
+			if reading == nil {
+				t.Fatal("reading = nil")
+			}
+			if reading.FuelLevelFloat != tt.wantLevel || reading.FuelLevelParsed != tt.wantParsed {
+				t.Errorf("fuel level = %g (parsed %t), want %g (parsed %t)", reading.FuelLevelFloat, reading.FuelLevelParsed, tt.wantLevel, tt.wantParsed)
+			}
+			if reading.GeneratorOn != tt.wantGenerator || reading.ZesaOn != tt.wantZesa {
+				t.Errorf("generator on = %t, zesa on = %t, want %t and %t", reading.GeneratorOn, reading.ZesaOn, tt.wantGenerator, tt.wantZesa)
+			}
+		})
+	}
+}
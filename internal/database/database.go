@@ -1,22 +1,45 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
 	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/logger"
 	"fuel-monitor-api/internal/models"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DB struct {
 	*sql.DB
+
+	// slowQueryThreshold is how long an instrumented query may take before it is
+	// logged as slow; zero disables the logging
+	slowQueryThreshold time.Duration
 }
 
+// ErrUsernameTaken is returned when a username matches an existing one case-insensitively
+var ErrUsernameTaken = errors.New("username already exists")
+
+// ErrDeviceIDTaken is returned when a site update would give two sites the same device
+var ErrDeviceIDTaken = errors.New("device ID already in use")
+
+// ErrDeviceNotFound is returned when no site has the device being renamed
+var ErrDeviceNotFound = errors.New("device not found")
+
+// ErrAmbiguousDeviceID is returned when several sites have device IDs differing
+// only in case, left over from before device IDs were unique ignoring case
+var ErrAmbiguousDeviceID = errors.New("several sites match the device ID ignoring case")
+
+// ErrSiteTypeNotFound is returned when a site update names a site type that does not exist
+var ErrSiteTypeNotFound = errors.New("site type not found")
+
 func Connect(cfg config.DatabaseConfig) (*DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name)
@@ -27,33 +50,98 @@ func Connect(cfg config.DatabaseConfig) (*DB, error) {
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
-	db.SetConnMaxIdleTime(1 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
+	// Test the connection, giving a freshly opened SSH tunnel time to start accepting
+	if err := pingWithRetry(db.Ping, cfg.ConnectAttempts, cfg.ConnectBackoff); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("Database connection established")
-	return &DB{db}, nil
+	logger.Infof("Database connection established")
+	return &DB{DB: db, slowQueryThreshold: cfg.SlowQueryThreshold}, nil
+}
+
+// pingWithRetry calls ping up to attempts times (at least once), sleeping
+// backoff after the first failure and doubling it after each further failure.
+// It returns the last ping error when every attempt fails.
+func pingWithRetry(ping func() error, attempts int, backoff time.Duration) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ping(); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		logger.Warnf("Database ping failed (attempt %d/%d), retrying in %v: %v", attempt, attempts, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	return err
 }
 
-// GetUserByUsername retrieves a user by username
+// TryAdvisoryLock takes the PostgreSQL session advisory lock key on a connection
+// of its own, so work guarded by the same key runs on one instance at a time. It
+// returns the function releasing the lock, or nil when another session holds it.
+// The connection is taken from the pool until the lock is released.
+func (db *DB) TryAdvisoryLock(ctx context.Context, key int64) (func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for advisory lock: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, nil
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			logger.Warnf("Failed to release advisory lock %d, dropping its connection: %v", key, err)
+			// Closing the session is the only other way to release the lock
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
+
+// GetUserByUsername retrieves an active user by username
 func (db *DB) GetUserByUsername(username string) (*models.User, error) {
+	return db.getUserByUsername(username, false)
+}
+
+// GetUserByUsernameIncludingInactive retrieves a user by username whether or
+// not the account is active
+func (db *DB) GetUserByUsernameIncludingInactive(username string) (*models.User, error) {
+	return db.getUserByUsername(username, true)
+}
+
+func (db *DB) getUserByUsername(username string, includeInactive bool) (*models.User, error) {
 	query := `
 		SELECT id, username, email, password, role, full_name, is_active, last_login, created_at
 		FROM users 
-		WHERE username = $1 AND is_active = true
+		WHERE LOWER(username) = LOWER($1) AND (is_active = true OR $2)
 	`
 
 	var user models.User
 	var lastLogin sql.NullTime
 
-	err := db.QueryRow(query, username).Scan(
+	err := db.QueryRow(query, username, includeInactive).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -92,6 +180,17 @@ func (db *DB) UpdateUserLastLogin(userID int, loginTime time.Time) error {
 	return nil
 }
 
+// UsernameExists reports whether any user, active or not, already has the
+// username ignoring case
+func (db *DB) UsernameExists(username string) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(username) = LOWER($1))", username).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check username: %w", err)
+	}
+	return exists, nil
+}
+
 // GetUserByID retrieves a user by ID
 func (db *DB) GetUserByID(id int) (*models.User, error) {
 	query := `
@@ -145,7 +244,7 @@ func (db *DB) GetAllUsers() ([]*models.User, error) {
 	}
 	defer rows.Close()
 
-	var users []*models.User
+	users := []*models.User{}
 	for rows.Next() {
 		var user models.User
 		var lastLogin sql.NullTime
@@ -245,6 +344,10 @@ func (db *DB) CreateUser(userData *models.CreateUserData) (*models.User, error)
 	)
 
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == usernameLowerIndex {
+			return nil, ErrUsernameTaken
+		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -337,6 +440,7 @@ func (db *DB) DeleteUser(userID int) error {
 	queries := []string{
 		"DELETE FROM user_site_assignments WHERE user_id = $1",
 		"DELETE FROM admin_preferences WHERE user_id = $1",
+		"DELETE FROM user_preferences WHERE user_id = $1",
 		"UPDATE users SET is_active = false WHERE id = $1",
 	}
 
@@ -349,3 +453,124 @@ func (db *DB) DeleteUser(userID int) error {
 
 	return nil
 }
+
+// GetInactiveUsers retrieves active users who have not logged in since the cutoff,
+// including users who have never logged in (NULL last_login)
+func (db *DB) GetInactiveUsers(cutoff time.Time) ([]*models.User, error) {
+	query := `
+		SELECT id, username, email, password, role, full_name, is_active, last_login, created_at
+		FROM users 
+		WHERE is_active = true
+		  AND (last_login IS NULL OR last_login < $1)
+		ORDER BY last_login ASC NULLS FIRST, created_at
+	`
+
+	rows, err := db.Query(query, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inactive users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		var user models.User
+		var lastLogin sql.NullTime
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.Password,
+			&user.Role,
+			&user.FullName,
+			&user.IsActive,
+			&lastLogin,
+			&user.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+
+		if lastLogin.Valid {
+			user.LastLogin = &lastLogin.Time
+		}
+
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
+
+// EachUser streams users ordered by creation date to fn without buffering the
+// whole list. Password hashes are never selected. Iteration stops at fn's first error.
+func (db *DB) EachUser(includeInactive bool, fn func(user *models.User) error) error {
+	query := `
+		SELECT id, username, email, role, full_name, is_active, last_login, created_at
+		FROM users
+		WHERE is_active = true OR $1
+		ORDER BY created_at
+	`
+
+	rows, err := db.Query(query, includeInactive)
+	if err != nil {
+		return fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		var lastLogin sql.NullTime
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.Role,
+			&user.FullName,
+			&user.IsActive,
+			&lastLogin,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+
+		if lastLogin.Valid {
+			user.LastLogin = &lastLogin.Time
+		}
+
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetRoles returns the distinct roles held by active users, always including admin
+func (db *DB) GetRoles() ([]string, error) {
+	query := `
+		SELECT role FROM users WHERE is_active = true
+		UNION
+		SELECT 'admin'
+		ORDER BY role
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPingWithRetry(t *testing.T) {
+	errDown := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		failures  int
+		attempts  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"first try", 0, 3, 1, false},
+		{"after retries", 2, 3, 3, false},
+		{"every attempt fails", 5, 3, 3, true},
+		{"attempts below one still ping once", 1, 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			ping := func() error {
+				calls++
+				if calls <= tt.failures {
+					return errDown
+				}
+				return nil
+			}
+
+			err := pingWithRetry(ping, tt.attempts, time.Millisecond)
+			if tt.wantErr && !errors.Is(err, errDown) {
+				t.Errorf("err = %v, want %v", err, errDown)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("pinged %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
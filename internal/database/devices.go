@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// RenameDevice moves the site of oldDeviceID (matched ignoring case) to
+// newDeviceID in one transaction, along with the device ID stored on its
+// cumulative readings, daily closing readings and cumulative errors. With
+// rewriteReadings the historical sensor_readings of oldDeviceID, in any case, are
+// moved too, merging them with any readings already reported under newDeviceID. Returns
+// ErrDeviceNotFound when no site has oldDeviceID and ErrDeviceIDTaken when
+// another site already has newDeviceID.
+func (db *DB) RenameDevice(oldDeviceID, newDeviceID string, rewriteReadings bool) (*models.RenameDeviceResponse, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &models.RenameDeviceResponse{NewDeviceID: newDeviceID}
+	err = tx.QueryRow(
+		"SELECT id, device_id FROM sites WHERE LOWER(device_id) = LOWER($1) FOR UPDATE",
+		oldDeviceID,
+	).Scan(&result.SiteID, &result.OldDeviceID)
+	if err == sql.ErrNoRows {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get site for device %s: %w", oldDeviceID, err)
+	}
+
+	var taken bool
+	err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM sites WHERE LOWER(device_id) = LOWER($1) AND id <> $2)",
+		newDeviceID, result.SiteID,
+	).Scan(&taken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check device %s: %w", newDeviceID, err)
+	}
+	if taken {
+		return nil, ErrDeviceIDTaken
+	}
+
+	if _, err := tx.Exec("UPDATE sites SET device_id = $1 WHERE id = $2", newDeviceID, result.SiteID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrDeviceIDTaken
+		}
+		return nil, fmt.Errorf("failed to rename site device: %w", err)
+	}
+
+	// Rows derived from the site's readings carry its device ID as well
+	derived := []struct {
+		table string
+		count *int64
+	}{
+		{"cumulative_readings", &result.CumulativeReadings},
+		{"daily_closing_readings", &result.ClosingReadings},
+		{"cumulative_errors", &result.CumulativeErrors},
+	}
+	for _, d := range derived {
+		updated, err := tx.Exec("UPDATE "+d.table+" SET device_id = $1 WHERE site_id = $2", newDeviceID, result.SiteID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rename device in %s: %w", d.table, err)
+		}
+		*d.count, _ = updated.RowsAffected()
+	}
+
+	if rewriteReadings {
+		updated, err := tx.Exec("UPDATE sensor_readings SET device_id = $1 WHERE LOWER(device_id) = LOWER($2)", newDeviceID, result.OldDeviceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rename device in sensor_readings: %w", err)
+		}
+		result.SensorReadings, _ = updated.RowsAffected()
+		result.ReadingsRewritten = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit device rename: %w", err)
+	}
+
+	return result, nil
+}
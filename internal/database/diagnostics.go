@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+)
+
+// GetDataDiagnostics summarizes how sensor data lines up with the sites table:
+// site counts, the distinct devices reporting in sensor_readings, active sites
+// with no reading since staleSince, devices without a site and the newest
+// reading overall. Devices are enumerated with a skip scan over the
+// (device_id, time) index so the readings table is never scanned in full.
+func (db *DB) GetDataDiagnostics(staleSince time.Time) (*models.DataDiagnostics, error) {
+	defer db.timeQuery("GetDataDiagnostics")()
+
+	query := `
+		WITH RECURSIVE devices AS (
+			(SELECT device_id FROM sensor_readings ORDER BY device_id LIMIT 1)
+			UNION ALL
+			SELECT (
+				SELECT sr.device_id FROM sensor_readings sr
+				WHERE sr.device_id > d.device_id
+				ORDER BY sr.device_id LIMIT 1
+			)
+			FROM devices d
+			WHERE d.device_id IS NOT NULL
+		), device_latest AS (
+			SELECT d.device_id, (
+				SELECT MAX(sr.time) FROM sensor_readings sr WHERE sr.device_id = d.device_id
+			) AS latest
+			FROM devices d
+			WHERE d.device_id IS NOT NULL
+		)
+		SELECT
+			(SELECT COUNT(*) FROM sites),
+			(SELECT COUNT(*) FROM sites WHERE is_active = true),
+			(SELECT COUNT(*) FROM device_latest),
+			(SELECT COUNT(*) FROM sites s
+				LEFT JOIN device_latest dl ON dl.device_id = s.device_id
+				WHERE s.is_active = true AND (dl.latest IS NULL OR dl.latest < $1)),
+			(SELECT COUNT(*) FROM device_latest dl
+				WHERE NOT EXISTS (SELECT 1 FROM sites s WHERE LOWER(s.device_id) = LOWER(dl.device_id))),
+			(SELECT MAX(latest) FROM device_latest)
+	`
+
+	var diagnostics models.DataDiagnostics
+	err := db.QueryRow(query, staleSince).Scan(
+		&diagnostics.TotalSites,
+		&diagnostics.ActiveSites,
+		&diagnostics.ReportingDevices,
+		&diagnostics.StaleSites,
+		&diagnostics.OrphanDevices,
+		&diagnostics.LatestReading,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data diagnostics: %w", err)
+	}
+
+	return &diagnostics, nil
+}
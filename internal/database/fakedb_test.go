@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeQuery answers one query with columns and rows, or an error
+type fakeQuery func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error)
+
+// fakeExec runs one statement and returns the rows it affected, or an error
+type fakeExec func(query string, args []driver.NamedValue) (int64, error)
+
+// fakeHandlers answer the statements of one fake database; either may be nil
+type fakeHandlers struct {
+	query fakeQuery
+	exec  fakeExec
+}
+
+var (
+	fakeQueriesMu sync.Mutex
+	fakeQueries   = map[string]fakeHandlers{}
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+// newFakeDB opens a database whose queries are all answered by answer
+func newFakeDB(t *testing.T, answer fakeQuery) *DB {
+	t.Helper()
+	return newFakeDBWith(t, fakeHandlers{query: answer})
+}
+
+// newFakeDBWith opens a database whose queries and statements are run by handlers.
+// Transactions are accepted and committed as they go; rollbacks undo nothing.
+func newFakeDBWith(t *testing.T, handlers fakeHandlers) *DB {
+	t.Helper()
+
+	fakeQueriesMu.Lock()
+	fakeQueries[t.Name()] = handlers
+	fakeQueriesMu.Unlock()
+
+	db, err := sql.Open("fakedb", t.Name())
+	if err != nil {
+		t.Fatalf("open fake database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeQueriesMu.Lock()
+		delete(fakeQueries, t.Name())
+		fakeQueriesMu.Unlock()
+	})
+
+	return &DB{DB: db}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeQueriesMu.Lock()
+	defer fakeQueriesMu.Unlock()
+
+	handlers, ok := fakeQueries[name]
+	if !ok {
+		return nil, fmt.Errorf("no fake database %q", name)
+	}
+	return &fakeConn{handlers: handlers}, nil
+}
+
+type fakeConn struct {
+	handlers fakeHandlers
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.handlers.exec == nil {
+		return nil, fmt.Errorf("fakedb: unexpected statement: %s", query)
+	}
+	affected, err := c.handlers.exec(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.handlers.query == nil {
+		return nil, fmt.Errorf("fakedb: unexpected query: %s", query)
+	}
+	columns, values, err := c.handlers.query(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: columns, values: values}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error { return nil }
+
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"fuel-monitor-api/internal/models"
+)
+
+// RecordLoginEvent stores a login attempt. UserID is nil when the username is unknown.
+func (db *DB) RecordLoginEvent(event *models.LoginEvent) error {
+	query := `
+		INSERT INTO login_events (user_id, username, success, reason, ip_address, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := db.Exec(query, event.UserID, event.Username, event.Success, event.Reason, event.IPAddress, event.UserAgent, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record login event: %w", err)
+	}
+
+	return nil
+}
+
+// GetLoginEvents returns a user's login events newest first. When beforeID is
+// positive only events with a smaller id are returned. At most limit rows are returned.
+func (db *DB) GetLoginEvents(userID int, beforeID int64, limit int) ([]*models.LoginEvent, error) {
+	query := `
+		SELECT id, user_id, username, success, reason, ip_address, user_agent, created_at
+		FROM login_events
+		WHERE user_id = $1
+		  AND ($2::bigint <= 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+
+	rows, err := db.Query(query, userID, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.LoginEvent{}
+	for rows.Next() {
+		var event models.LoginEvent
+		var eventUserID sql.NullInt64
+
+		err := rows.Scan(
+			&event.ID,
+			&eventUserID,
+			&event.Username,
+			&event.Success,
+			&event.Reason,
+			&event.IPAddress,
+			&event.UserAgent,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan login event: %w", err)
+		}
+
+		if eventUserID.Valid {
+			id := int(eventUserID.Int64)
+			event.UserID = &id
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+)
+
+// GetUserPreferences retrieves a user's stored preferences blob, or nil when none is stored
+func (db *DB) GetUserPreferences(userID int) (*models.UserPreferences, error) {
+	query := `SELECT preferences, updated_at FROM user_preferences WHERE user_id = $1`
+
+	var prefs models.UserPreferences
+	var raw []byte
+
+	err := db.QueryRow(query, userID).Scan(&raw, &prefs.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+	}
+
+	prefs.Preferences = json.RawMessage(raw)
+	return &prefs, nil
+}
+
+// SaveUserPreferences replaces a user's preferences blob. The blob must already be valid JSON.
+func (db *DB) SaveUserPreferences(userID int, preferences json.RawMessage) (*models.UserPreferences, error) {
+	query := `
+		INSERT INTO user_preferences (user_id, preferences, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			preferences = EXCLUDED.preferences,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	now := time.Now()
+	if _, err := db.Exec(query, userID, string(preferences), now); err != nil {
+		return nil, fmt.Errorf("failed to save user preferences: %w", err)
+	}
+
+	return &models.UserPreferences{
+		Preferences: preferences,
+		UpdatedAt:   &now,
+	}, nil
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// GetFrozenFuelSensors finds devices whose latest fuel_sensor_level value has been
+// byte-identical for at least the given window. The frozen run starts at the first
+// reading after the last differing value. Only readings within twice the window
+// of the latest are searched, so a sensor frozen for longer reports FrozenSince
+// at that bound. names maps lowercase device IDs to the sensor names they report
+// under.
+func (db *DB) GetFrozenFuelSensors(sites []*models.Site, names map[string]models.SensorNames, window time.Duration) ([]*models.FrozenSensor, error) {
+	if len(sites) == 0 {
+		return []*models.FrozenSensor{}, nil
+	}
+
+	sitesByDevice := make(map[string]*models.Site, len(sites))
+	deviceIDs := make([]string, len(sites))
+	levelNames := make([]string, len(sites))
+	for i, site := range sites {
+		deviceID := strings.ToLower(site.DeviceID)
+		sitesByDevice[deviceID] = site
+		deviceIDs[i] = deviceID
+		levelNames[i] = names[deviceID].Device("fuel_sensor_level")
+	}
+
+	query := `
+		WITH devices AS (
+			SELECT DISTINCT ON (device_id) device_id, level_name
+			FROM unnest($1::text[], $2::text[]) AS d(device_id, level_name)
+			ORDER BY device_id
+		), latest AS (
+			SELECT DISTINCT ON (d.device_id) d.device_id, d.level_name, r.value, r.time
+			FROM devices d
+			JOIN sensor_readings r ON LOWER(r.device_id) = d.device_id AND r.sensor_name = d.level_name
+			WHERE r.value IS NOT NULL
+			ORDER BY d.device_id, r.time DESC
+		), runs AS (
+			SELECT l.device_id, l.level_name, l.value, l.time AS last_seen,
+				(SELECT MAX(c.time) FROM sensor_readings c
+				 WHERE LOWER(c.device_id) = l.device_id
+				   AND c.sensor_name = l.level_name
+				   AND c.time >= l.time - $3 * INTERVAL '1 second'
+				   AND c.value IS NOT NULL
+				   AND c.value <> l.value) AS last_change
+			FROM latest l
+		)
+		SELECT r.device_id, r.value, r.last_seen,
+			(SELECT MIN(f.time) FROM sensor_readings f
+			 WHERE LOWER(f.device_id) = r.device_id
+			   AND f.sensor_name = r.level_name
+			   AND f.time >= r.last_seen - $3 * INTERVAL '1 second'
+			   AND f.value = r.value
+			   AND (r.last_change IS NULL OR f.time > r.last_change)) AS frozen_since
+		FROM runs r
+	`
+
+	// Looking back twice the window finds every run of at least the window
+	lookback := 2 * window
+	rows, err := db.Query(query, pq.Array(deviceIDs), pq.Array(levelNames), lookback.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get frozen fuel sensors: %w", err)
+	}
+	defer rows.Close()
+
+	frozen := []*models.FrozenSensor{}
+	for rows.Next() {
+		var deviceID, value string
+		var lastSeen, frozenSince time.Time
+
+		if err := rows.Scan(&deviceID, &value, &lastSeen, &frozenSince); err != nil {
+			return nil, fmt.Errorf("failed to scan frozen fuel sensor: %w", err)
+		}
+
+		frozenFor := lastSeen.Sub(frozenSince)
+		if frozenFor < window {
+			continue
+		}
+
+		sensor := &models.FrozenSensor{
+			DeviceID:    deviceID,
+			SensorName:  "fuel_sensor_level",
+			Value:       value,
+			FrozenSince: frozenSince,
+			LastSeen:    lastSeen,
+			FrozenHours: frozenFor.Hours(),
+		}
+		if site, ok := sitesByDevice[deviceID]; ok {
+			sensor.DeviceID = site.DeviceID
+			sensor.SiteID = site.ID
+			sensor.SiteName = site.Name
+		}
+
+		frozen = append(frozen, sensor)
+	}
+
+	return frozen, nil
+}
+
+// GetContinuousGeneratorRuns finds devices whose generator_state is currently on and
+// returns the ongoing run, walked from readings since the given time. A run whose
+// first reading in the window is already on is marked StartBeforeWindow, since
+// its real start is earlier than RunningSince. names maps lowercase device IDs to
+// the sensor names they report under.
+func (db *DB) GetContinuousGeneratorRuns(sites []*models.Site, names map[string]models.SensorNames, since time.Time) ([]*models.GeneratorRun, error) {
+	if len(sites) == 0 {
+		return []*models.GeneratorRun{}, nil
+	}
+
+	sitesByDevice := make(map[string]*models.Site, len(sites))
+	deviceIDs := make([]string, len(sites))
+	generatorNames := make([]string, len(sites))
+	for i, site := range sites {
+		deviceID := strings.ToLower(site.DeviceID)
+		sitesByDevice[deviceID] = site
+		deviceIDs[i] = deviceID
+		generatorNames[i] = names[deviceID].Device("generator_state")
+	}
+
+	query := `
+		WITH devices AS (
+			SELECT DISTINCT ON (device_id) device_id, generator_name
+			FROM unnest($2::text[], $3::text[]) AS d(device_id, generator_name)
+			ORDER BY device_id
+		)
+		SELECT d.device_id, r.value, r.time
+		FROM devices d
+		JOIN sensor_readings r ON LOWER(r.device_id) = d.device_id AND r.sensor_name = d.generator_name
+		WHERE r.value IS NOT NULL
+		  AND r.time >= $1
+		ORDER BY d.device_id, r.time ASC, r.id ASC
+	`
+
+	rows, err := db.Query(query, since, pq.Array(deviceIDs), pq.Array(generatorNames))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get generator state readings: %w", err)
+	}
+	defer rows.Close()
+
+	// Walk each device's state segments, keeping the start of the current on-run.
+	// Conflicting rows at the same timestamp: the last inserted one wins.
+	type deviceRun struct {
+		firstSeen time.Time
+		lastSeen  time.Time
+		on        bool
+		runStart  time.Time
+	}
+	runsByDevice := make(map[string]*deviceRun)
+	var deviceOrder []string
+
+	for rows.Next() {
+		var deviceID, value string
+		var timestamp time.Time
+		if err := rows.Scan(&deviceID, &value, &timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan generator state reading: %w", err)
+		}
+
+		run, ok := runsByDevice[deviceID]
+		if !ok {
+			run = &deviceRun{firstSeen: timestamp}
+			runsByDevice[deviceID] = run
+			deviceOrder = append(deviceOrder, deviceID)
+		}
+
+		on := models.ParseState(value)
+		if on && !run.on {
+			run.runStart = timestamp
+		}
+
+		run.on = on
+		run.lastSeen = timestamp
+	}
+
+	generatorRuns := []*models.GeneratorRun{}
+	for _, deviceID := range deviceOrder {
+		run := runsByDevice[deviceID]
+		if !run.on {
+			continue
+		}
+
+		generatorRun := &models.GeneratorRun{
+			DeviceID:          deviceID,
+			RunningSince:      run.runStart,
+			LastSeen:          run.lastSeen,
+			RunningHours:      run.lastSeen.Sub(run.runStart).Hours(),
+			StartBeforeWindow: run.runStart.Equal(run.firstSeen),
+		}
+		if site, ok := sitesByDevice[deviceID]; ok {
+			generatorRun.DeviceID = site.DeviceID
+			generatorRun.SiteID = site.ID
+			generatorRun.SiteName = site.Name
+		}
+
+		generatorRuns = append(generatorRuns, generatorRun)
+	}
+
+	return generatorRuns, nil
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+)
+
+func TestGetContinuousGeneratorRuns(t *testing.T) {
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	hour := func(h int) time.Time { return since.Add(time.Duration(h) * time.Hour) }
+
+	typeID := 2
+	sites := []*models.Site{
+		{ID: 1, Name: "Mapped", DeviceID: "Simbisa-A", TypeID: &typeID},
+		{ID: 2, Name: "Default", DeviceID: "simbisa-b"},
+		{ID: 3, Name: "Stopped", DeviceID: "simbisa-c"},
+	}
+	siteTypes := map[int]*models.SiteType{typeID: {ID: typeID, SensorNames: models.SensorNames{"generator_state": "gen_state"}}}
+
+	// Readings per lowercase device and sensor name, oldest first
+	readings := map[string][]struct {
+		value string
+		at    time.Time
+	}{
+		"simbisa-a/gen_state":       {{"off", hour(1)}, {"on", hour(3)}, {"on", hour(9)}},
+		"simbisa-a/generator_state": {{"off", hour(9)}},
+		"simbisa-b/generator_state": {{"on", hour(0)}, {"on", hour(5)}},
+		"simbisa-c/generator_state": {{"on", hour(1)}, {"off", hour(2)}},
+	}
+
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		devices := strings.Split(strings.Trim(args[1].Value.(string), "{}"), ",")
+		names := strings.Split(strings.Trim(args[2].Value.(string), "{}"), ",")
+		var rows [][]driver.Value
+		for i, device := range devices {
+			device = strings.Trim(device, `"`)
+			for _, reading := range readings[device+"/"+strings.Trim(names[i], `"`)] {
+				rows = append(rows, []driver.Value{device, reading.value, reading.at})
+			}
+		}
+		return []string{"device_id", "value", "time"}, rows, nil
+	})
+
+	runs, err := db.GetContinuousGeneratorRuns(sites, models.SiteSensorNames(sites, siteTypes), since)
+	if err != nil {
+		t.Fatalf("GetContinuousGeneratorRuns: %v", err)
+	}
+
+	want := map[int]models.GeneratorRun{
+		1: {DeviceID: "Simbisa-A", RunningSince: hour(3), LastSeen: hour(9), RunningHours: 6},
+		2: {DeviceID: "simbisa-b", RunningSince: hour(0), LastSeen: hour(5), RunningHours: 5, StartBeforeWindow: true},
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("got %d runs, want %d: %+v", len(runs), len(want), runs)
+	}
+	for _, run := range runs {
+		w, ok := want[run.SiteID]
+		if !ok {
+			t.Errorf("unexpected run for site %d", run.SiteID)
+			continue
+		}
+		if run.DeviceID != w.DeviceID || !run.RunningSince.Equal(w.RunningSince) || !run.LastSeen.Equal(w.LastSeen) ||
+			run.RunningHours != w.RunningHours || run.StartBeforeWindow != w.StartBeforeWindow {
+			t.Errorf("site %d: got %+v, want %+v", run.SiteID, *run, w)
+		}
+	}
+}
+
+func TestGetFrozenFuelSensors(t *testing.T) {
+	lastSeen := time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+	window := 12 * time.Hour
+	sites := []*models.Site{
+		{ID: 1, Name: "Frozen", DeviceID: "Simbisa-A"},
+		{ID: 2, Name: "Recent change", DeviceID: "simbisa-b"},
+	}
+
+	var lookback interface{}
+	db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		lookback = args[2].Value
+		return []string{"device_id", "value", "last_seen", "frozen_since"}, [][]driver.Value{
+			{"simbisa-a", "42.0", lastSeen, lastSeen.Add(-13 * time.Hour)},
+			{"simbisa-b", "40.0", lastSeen, lastSeen.Add(-2 * time.Hour)},
+		}, nil
+	})
+
+	frozen, err := db.GetFrozenFuelSensors(sites, nil, window)
+	if err != nil {
+		t.Fatalf("GetFrozenFuelSensors: %v", err)
+	}
+
+	if lookback != (2 * window).Seconds() {
+		t.Errorf("lookback = %v seconds, want %v", lookback, (2 * window).Seconds())
+	}
+	if len(frozen) != 1 {
+		t.Fatalf("got %d frozen sensors, want 1: %+v", len(frozen), frozen)
+	}
+	if got := frozen[0]; got.SiteID != 1 || got.DeviceID != "Simbisa-A" || got.FrozenHours != 13 || got.SensorName != "fuel_sensor_level" {
+		t.Errorf("got %+v, want site 1 (Simbisa-A) frozen for 13h", *got)
+	}
+}
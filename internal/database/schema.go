@@ -0,0 +1,193 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"fuel-monitor-api/internal/logger"
+)
+
+// usernameLowerIndex enforces case-insensitive username uniqueness
+const usernameLowerIndex = "users_username_lower_key"
+
+// siteDeviceIDIndex enforces one site per device
+const siteDeviceIDIndex = "sites_device_id_key"
+
+// siteDeviceIDLowerIndex enforces one site per device ignoring the case of its ID
+const siteDeviceIDLowerIndex = "sites_device_id_lower_key"
+
+// sensorReadingsLowerIndex serves reading lookups by LOWER(device_id), so a
+// device reporting under another casing is still found
+const sensorReadingsLowerIndex = "idx_sensor_readings_device_id_lower_time"
+
+// concurrentIndex is an index on a large table that is built concurrently
+// outside schemaStatements (see ensureConcurrentIndex)
+type concurrentIndex struct {
+	name       string
+	table      string
+	definition string
+}
+
+// concurrentIndexes are the indexes EnsureSchema builds concurrently
+var concurrentIndexes = []concurrentIndex{
+	{sensorReadingsLowerIndex, "sensor_readings", "(LOWER(device_id), time)"},
+	// The leaderboard reads one date's rows in the order it ranks them
+	{leaderboardOrders["fuel"].index, "cumulative_readings", "(date, (" + leaderboardOrders["fuel"].expression + ") DESC, site_id)"},
+	{leaderboardOrders["generator"].index, "cumulative_readings", "(date, (" + leaderboardOrders["generator"].expression + ") DESC, site_id)"},
+}
+
+// schemaStatements create the tables and columns this API owns. Each statement
+// is idempotent so EnsureSchema can run on every startup.
+var schemaStatements = []string{
+	// Usernames are unique ignoring case. Existing case-duplicates are left alone
+	// (with a warning) rather than failing startup; they must be merged by hand.
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM users GROUP BY LOWER(username) HAVING COUNT(*) > 1) THEN
+			CREATE UNIQUE INDEX IF NOT EXISTS ` + usernameLowerIndex + ` ON users (LOWER(username));
+		ELSE
+			RAISE WARNING 'users contains usernames differing only in case; skipping ` + usernameLowerIndex + `';
+		END IF;
+	END $$`,
+	// One site per device. Existing duplicates are left alone (with a warning)
+	// rather than failing startup; they must be merged by hand.
+	`DO $$
+	BEGIN
+		CREATE UNIQUE INDEX IF NOT EXISTS ` + siteDeviceIDIndex + ` ON sites (device_id);
+	EXCEPTION WHEN unique_violation THEN
+		RAISE WARNING 'sites contains duplicate device IDs; skipping ` + siteDeviceIDIndex + `';
+	END $$`,
+	// Device IDs are unique ignoring case, so a device reporting under two
+	// casings gets one site. Existing case-duplicates are left alone (with a
+	// warning) rather than failing startup; they must be merged by hand.
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM sites GROUP BY LOWER(device_id) HAVING COUNT(*) > 1) THEN
+			CREATE UNIQUE INDEX IF NOT EXISTS ` + siteDeviceIDLowerIndex + ` ON sites (LOWER(device_id));
+		ELSE
+			RAISE WARNING 'sites contains device IDs differing only in case; skipping ` + siteDeviceIDLowerIndex + `';
+		END IF;
+	END $$`,
+	`CREATE TABLE IF NOT EXISTS site_types (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL UNIQUE,
+		expected_sensors TEXT[] NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`ALTER TABLE site_types ADD COLUMN IF NOT EXISTS sensor_names JSONB NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE sites ADD COLUMN IF NOT EXISTS type_id INTEGER REFERENCES site_types(id) ON DELETE SET NULL`,
+	`ALTER TABLE sites ADD COLUMN IF NOT EXISTS maintenance_mode BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE sites ADD COLUMN IF NOT EXISTS maintenance_start TIMESTAMPTZ`,
+	`ALTER TABLE sites ADD COLUMN IF NOT EXISTS maintenance_end TIMESTAMPTZ`,
+	`ALTER TABLE sites ADD COLUMN IF NOT EXISTS alerts_enabled BOOLEAN NOT NULL DEFAULT true`,
+	`ALTER TABLE sites ADD COLUMN IF NOT EXISTS low_fuel_mode VARCHAR(10) NOT NULL DEFAULT 'percent'`,
+	`ALTER TABLE sites ADD COLUMN IF NOT EXISTS low_fuel_liters DOUBLE PRECISION`,
+	`ALTER TABLE sites ADD COLUMN IF NOT EXISTS low_fuel_threshold DOUBLE PRECISION`,
+	`ALTER TABLE cumulative_readings ADD COLUMN IF NOT EXISTS generator_starts INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE cumulative_readings ADD COLUMN IF NOT EXISTS calc_version INTEGER NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS cumulative_errors (
+		id SERIAL PRIMARY KEY,
+		site_id INTEGER NOT NULL,
+		device_id VARCHAR(255) NOT NULL,
+		date DATE NOT NULL,
+		error TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (site_id, date)
+	)`,
+	`CREATE TABLE IF NOT EXISTS settings (
+		key VARCHAR(100) PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS login_events (
+		id BIGSERIAL PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		username VARCHAR(255) NOT NULL,
+		success BOOLEAN NOT NULL,
+		reason VARCHAR(50) NOT NULL DEFAULT '',
+		ip_address VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_login_events_user_id ON login_events (user_id, id DESC)`,
+	`CREATE TABLE IF NOT EXISTS user_preferences (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		preferences TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id SERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		alert_types TEXT[] NOT NULL DEFAULT '{}',
+		is_active BOOLEAN NOT NULL DEFAULT true,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS alert_acknowledgements (
+		id SERIAL PRIMARY KEY,
+		site_id INTEGER NOT NULL REFERENCES sites(id) ON DELETE CASCADE,
+		alert_type VARCHAR(30) NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		acknowledged_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		acknowledged_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		cleared_at TIMESTAMPTZ
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_acknowledgements_active ON alert_acknowledgements (site_id, alert_type) WHERE cleared_at IS NULL`,
+}
+
+// EnsureSchema applies the schema statements owned by this API
+func (db *DB) EnsureSchema() error {
+	for _, statement := range schemaStatements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to apply schema statement: %w", err)
+		}
+	}
+
+	for _, index := range concurrentIndexes {
+		if err := db.ensureConcurrentIndex(index); err != nil {
+			return err
+		}
+	}
+
+	logger.Infof("Database schema is up to date")
+	return nil
+}
+
+// ensureConcurrentIndex builds index once, concurrently so writers can keep
+// writing while it builds. CREATE INDEX CONCURRENTLY cannot run in a
+// transaction or DO block, so these indexes are kept out of schemaStatements.
+// Tables owned by the collector may not exist yet; the index is added on the
+// first startup after they do. A build that was interrupted leaves an invalid
+// index behind, which is dropped and rebuilt.
+func (db *DB) ensureConcurrentIndex(index concurrentIndex) error {
+	query := `
+		SELECT to_regclass('public.` + index.table + `') IS NOT NULL,
+		       (SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass('public.` + index.name + `'))
+	`
+
+	var tableExists bool
+	var indexValid sql.NullBool
+	if err := db.QueryRow(query).Scan(&tableExists, &indexValid); err != nil {
+		return fmt.Errorf("failed to check %s: %w", index.name, err)
+	}
+
+	if !tableExists || (indexValid.Valid && indexValid.Bool) {
+		return nil
+	}
+
+	if indexValid.Valid {
+		logger.Warnf("Dropping invalid index %s left by an interrupted build", index.name)
+		if _, err := db.Exec(`DROP INDEX CONCURRENTLY IF EXISTS ` + index.name); err != nil {
+			return fmt.Errorf("failed to drop invalid %s: %w", index.name, err)
+		}
+	}
+
+	logger.Infof("Building index %s concurrently; this can take a while on a large %s table", index.name, index.table)
+	_, err := db.Exec(`CREATE INDEX CONCURRENTLY IF NOT EXISTS ` + index.name + ` ON ` + index.table + ` ` + index.definition)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", index.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fuel-monitor-api/internal/models"
+)
+
+func TestEnsureConcurrentIndex(t *testing.T) {
+	index := concurrentIndexes[0]
+
+	tests := []struct {
+		name        string
+		tableExists bool
+		indexValid  driver.Value // nil when the index does not exist
+		wantExecs   []string
+	}{
+		{"no sensor_readings yet", false, nil, nil},
+		{"index missing", true, nil, []string{"CREATE INDEX CONCURRENTLY IF NOT EXISTS"}},
+		{"index built", true, true, nil},
+		{"interrupted build", true, false, []string{"DROP INDEX CONCURRENTLY IF EXISTS", "CREATE INDEX CONCURRENTLY IF NOT EXISTS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var execs []string
+			db := newFakeDBWith(t, fakeHandlers{
+				query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+					return []string{"table_exists", "indisvalid"}, [][]driver.Value{{tt.tableExists, tt.indexValid}}, nil
+				},
+				exec: func(query string, args []driver.NamedValue) (int64, error) {
+					if !strings.Contains(query, index.name) {
+						t.Errorf("statement does not name %s: %s", index.name, query)
+					}
+					for _, prefix := range []string{"DROP INDEX CONCURRENTLY IF EXISTS", "CREATE INDEX CONCURRENTLY IF NOT EXISTS"} {
+						if strings.HasPrefix(query, prefix) {
+							execs = append(execs, prefix)
+						}
+					}
+					return 0, nil
+				},
+			})
+
+			if err := db.ensureConcurrentIndex(index); err != nil {
+				t.Fatalf("ensureConcurrentIndex: %v", err)
+			}
+			if !reflect.DeepEqual(execs, tt.wantExecs) {
+				t.Errorf("statements = %q, want %q", execs, tt.wantExecs)
+			}
+		})
+	}
+}
+
+func TestSchemaStatementsLeaveConcurrentIndexesOut(t *testing.T) {
+	// The indexes are built concurrently outside the DDL loop, which a DO block forbids
+	for _, index := range concurrentIndexes {
+		for _, statement := range schemaStatements {
+			if strings.Contains(statement, index.name) {
+				t.Errorf("schema statement builds %s in the DDL loop:\n%s", index.name, statement)
+			}
+		}
+	}
+}
+
+func TestLeaderboardOrdersAreIndexed(t *testing.T) {
+	for name, order := range leaderboardOrders {
+		t.Run(name, func(t *testing.T) {
+			var leaderboardQuery string
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				leaderboardQuery = query
+				return []string{"id"}, nil, nil
+			})
+			if _, err := db.GetCumulativeLeaderboard("2024-03-01", []*models.Site{{ID: 1}}, name, 10); err != nil {
+				t.Fatalf("GetCumulativeLeaderboard: %v", err)
+			}
+			if !strings.Contains(leaderboardQuery, "ORDER BY "+order.expression+" DESC, site_id") {
+				t.Errorf("query does not order by %s:\n%s", order.expression, leaderboardQuery)
+			}
+
+			// An index over the same date, expression and tie-break serves the query
+			want := "(date, (" + order.expression + ") DESC, site_id)"
+			var found bool
+			for _, index := range concurrentIndexes {
+				if index.name == order.index && index.table == "cumulative_readings" && index.definition == want {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("no index %s on cumulative_readings %s", order.index, want)
+			}
+		})
+	}
+}
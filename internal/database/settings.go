@@ -0,0 +1,61 @@
+package database
+
+import (
+	"fmt"
+)
+
+// GetSettings returns every stored runtime setting as raw key/value pairs
+func (db *DB) GetSettings() (map[string]string, error) {
+	rows, err := db.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		settings[key] = value
+	}
+
+	return settings, nil
+}
+
+// SaveSettings upserts the given settings in one transaction. A nil value deletes the setting.
+func (db *DB) SaveSettings(values map[string]*string, updatedBy int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, value := range values {
+		if value == nil {
+			if _, err := tx.Exec("DELETE FROM settings WHERE key = $1", key); err != nil {
+				return fmt.Errorf("failed to delete setting %s: %w", key, err)
+			}
+			continue
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO settings (key, value, updated_by, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (key) DO UPDATE SET
+				value = EXCLUDED.value,
+				updated_by = EXCLUDED.updated_by,
+				updated_at = EXCLUDED.updated_at
+		`, key, *value, updatedBy)
+		if err != nil {
+			return fmt.Errorf("failed to save setting %s: %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit settings: %w", err)
+	}
+
+	return nil
+}
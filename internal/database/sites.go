@@ -2,16 +2,37 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/logger"
 	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
 )
 
+// DeviceIDPrefix is the device ID prefix of the fuel monitoring devices; sites are
+// discovered for, and the dashboard lists, only devices with this prefix (in any case)
+const DeviceIDPrefix = "simbisa-"
+
+// ErrSiteDeviceIDNotUnique is returned by FastAutoCreateSites when sites has no
+// unique index on device_id, which its ON CONFLICT relies on to stay idempotent.
+// EnsureSchema skips the indexes while duplicate sites exist.
+var ErrSiteDeviceIDNotUnique = errors.New("sites has no unique index on device_id; merge the duplicate sites so it can be created")
+
 // FastAutoCreateSites creates sites from distinct device_ids in sensor_readings
-func (db *DB) FastAutoCreateSites() error {
-	log.Println("🚀 FAST auto-creating sites from sensor_readings...")
+// and returns how many sites were actually created. Device IDs differing only in
+// case are one device: it gets a single site, under its lowercase ID when the
+// device reports one. New sites are named and located as naming configures;
+// existing sites are never renamed.
+func (db *DB) FastAutoCreateSites(naming config.SitesConfig) (int, error) {
+	logger.Infof("🚀 FAST auto-creating sites from sensor_readings...")
 
 	// Check if sensor_readings table exists
 	tableExistsQuery := `
@@ -24,25 +45,25 @@ func (db *DB) FastAutoCreateSites() error {
 	var tableExists bool
 	err := db.QueryRow(tableExistsQuery).Scan(&tableExists)
 	if err != nil {
-		return fmt.Errorf("failed to check if sensor_readings table exists: %w", err)
+		return 0, fmt.Errorf("failed to check if sensor_readings table exists: %w", err)
 	}
 
 	if !tableExists {
-		log.Println("⚠️ sensor_readings table not found")
-		return nil
+		logger.Warnf("sensor_readings table not found")
+		return 0, nil
 	}
 
 	// Get distinct device_ids from sensor_readings
 	distinctDevicesQuery := `
 		SELECT DISTINCT device_id 
 		FROM sensor_readings 
-		WHERE device_id LIKE 'simbisa-%'
+		WHERE device_id ILIKE '` + DeviceIDPrefix + `%'
 		ORDER BY device_id
 	`
 
 	rows, err := db.Query(distinctDevicesQuery)
 	if err != nil {
-		return fmt.Errorf("failed to get distinct devices: %w", err)
+		return 0, fmt.Errorf("failed to get distinct devices: %w", err)
 	}
 	defer rows.Close()
 
@@ -50,102 +71,218 @@ func (db *DB) FastAutoCreateSites() error {
 	for rows.Next() {
 		var deviceId string
 		if err := rows.Scan(&deviceId); err != nil {
-			return fmt.Errorf("failed to scan device_id: %w", err)
+			return 0, fmt.Errorf("failed to scan device_id: %w", err)
 		}
 		deviceIds = append(deviceIds, deviceId)
 	}
 
-	log.Printf("📊 Found %d distinct devices", len(deviceIds))
+	deviceIds = uniqueDeviceIDs(deviceIds)
+	logger.Debugf("📊 Found %d distinct devices", len(deviceIds))
 
 	if len(deviceIds) == 0 {
-		log.Println("⚠️ No devices found in sensor_readings")
-		return nil
+		logger.Warnf("No devices found in sensor_readings")
+		return 0, nil
+	}
+
+	// ON CONFLICT keeps this idempotent when several instances run it at once,
+	// and skips devices whose site exists under another casing. Without a
+	// unique index there is no conflict, so concurrent runs would duplicate sites.
+	unique, err := db.siteDeviceIDUnique()
+	if err != nil {
+		return 0, err
+	}
+	if !unique {
+		return 0, ErrSiteDeviceIDNotUnique
 	}
 
+	insertQuery := `
+		INSERT INTO sites (name, location, device_id, is_active, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT DO NOTHING
+	`
+
 	createdCount := 0
 	for _, deviceId := range deviceIds {
-		// Check if site already exists
-		existsQuery := `SELECT id FROM sites WHERE device_id = $1`
-		var existingId int
-		err := db.QueryRow(existsQuery, deviceId).Scan(&existingId)
+		siteName := siteNameFor(deviceId, naming.NameStyle)
+		siteLocation := siteLocationFor(naming.LocationTemplate, siteName, deviceId)
 
-		if err == nil {
-			// Site already exists, skip
+		result, err := db.Exec(insertQuery, siteName, siteLocation, deviceId, true)
+		if err != nil {
+			logger.Errorf("❌ Error creating site for %s: %v", deviceId, err)
 			continue
 		}
 
-		// Create new site
-		siteName := deviceId                   // Keep exact: simbisa-avondale
-		siteLocation := deviceId + " location" // simbisa-avondale location
-
-		insertQuery := `
-			INSERT INTO sites (name, location, device_id, is_active, created_at)
-			VALUES ($1, $2, $3, $4, NOW())
-		`
-
-		_, err = db.Exec(insertQuery, siteName, siteLocation, deviceId, true)
-		if err != nil {
-			log.Printf("❌ Error creating site for %s: %v", deviceId, err)
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			// Site already exists
 			continue
 		}
 
-		log.Printf("✅ Created: %s (%s)", siteName, deviceId)
+		logger.Debugf("✅ Created: %s (%s)", siteName, deviceId)
 		createdCount++
 	}
 
 	if createdCount > 0 {
-		log.Printf("🎉 FAST created %d sites from %d sensor devices", createdCount, len(deviceIds))
+		logger.Infof("🎉 FAST created %d sites from %d sensor devices", createdCount, len(deviceIds))
 	} else {
-		log.Println("ℹ️ All sensor devices already have sites")
+		logger.Infof("ℹ️ All sensor devices already have sites")
 	}
 
-	return nil
+	return createdCount, nil
+}
+
+// siteDeviceIDUnique reports whether a valid unique index on sites.device_id,
+// exact or ignoring case, is in place
+func (db *DB) siteDeviceIDUnique() (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_index
+			WHERE indexrelid IN (to_regclass('public.` + siteDeviceIDIndex + `'), to_regclass('public.` + siteDeviceIDLowerIndex + `'))
+			  AND indisunique AND indisvalid
+		)
+	`
+
+	var unique bool
+	if err := db.QueryRow(query).Scan(&unique); err != nil {
+		return false, fmt.Errorf("failed to check the sites device_id index: %w", err)
+	}
+	return unique, nil
+}
+
+// siteNameFor derives an auto-created site's name from its device ID. The
+// "title" style strips DeviceIDPrefix, turns hyphens and underscores into
+// spaces and capitalizes each word, e.g. simbisa-borrowdale-brooke becomes
+// "Borrowdale Brooke"; a device ID with nothing after the prefix is kept as is.
+func siteNameFor(deviceId, style string) string {
+	if style == "device" {
+		return deviceId
+	}
+
+	name := deviceId
+	if len(name) >= len(DeviceIDPrefix) && strings.EqualFold(name[:len(DeviceIDPrefix)], DeviceIDPrefix) {
+		name = name[len(DeviceIDPrefix):]
+	}
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || unicode.IsSpace(r)
+	})
+	if len(words) == 0 {
+		return deviceId
+	}
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(first)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+// siteLocationFor fills in an auto-created site's location from template
+func siteLocationFor(template, name, deviceId string) string {
+	return strings.NewReplacer("{name}", name, "{device}", deviceId).Replace(template)
+}
+
+// uniqueDeviceIDs collapses device IDs differing only in case to one ID each,
+// preferring the lowercase form, and keeps their order
+func uniqueDeviceIDs(deviceIds []string) []string {
+	chosen := make(map[string]int, len(deviceIds))
+	unique := make([]string, 0, len(deviceIds))
+	for _, deviceId := range deviceIds {
+		normalized := strings.ToLower(deviceId)
+		i, seen := chosen[normalized]
+		if !seen {
+			chosen[normalized] = len(unique)
+			unique = append(unique, deviceId)
+			continue
+		}
+
+		logger.Warnf("Device %s also reports as %s; treating them as one device", unique[i], deviceId)
+		if deviceId == normalized {
+			unique[i] = deviceId
+		}
+	}
+	return unique
 }
 
-// GetSiteByDeviceID retrieves a site by device ID
+// GetSiteByDeviceID retrieves a site by device ID, ignoring case. Returns
+// ErrAmbiguousDeviceID when more than one site matches, which is possible only
+// while the case-insensitive unique index could not be created.
 func (db *DB) GetSiteByDeviceID(deviceId string) (*models.Site, error) {
 	query := `
-		SELECT id, name, location, device_id, is_active, created_at
+		SELECT id, name, location, device_id, is_active, created_at, type_id,
+		       maintenance_mode, maintenance_start, maintenance_end, alerts_enabled,
+		       low_fuel_mode, low_fuel_liters, low_fuel_threshold
 		FROM sites 
-		WHERE device_id = $1
+		WHERE LOWER(device_id) = LOWER($1)
+		ORDER BY id
+		LIMIT 2
 	`
 
-	var site models.Site
-	err := db.QueryRow(query, deviceId).Scan(
-		&site.ID,
-		&site.Name,
-		&site.Location,
-		&site.DeviceID,
-		&site.IsActive,
-		&site.CreatedAt,
-	)
-
+	rows, err := db.Query(query, deviceId)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // Site not found
+		return nil, fmt.Errorf("failed to get site by device ID: %w", err)
+	}
+	defer rows.Close()
+
+	var sites []*models.Site
+	for rows.Next() {
+		var site models.Site
+		err := rows.Scan(
+			&site.ID,
+			&site.Name,
+			&site.Location,
+			&site.DeviceID,
+			&site.IsActive,
+			&site.CreatedAt,
+			&site.TypeID,
+			&site.MaintenanceMode,
+			&site.MaintenanceStart,
+			&site.MaintenanceEnd,
+			&site.AlertsEnabled,
+			&site.LowFuelMode,
+			&site.LowFuelLiters,
+			&site.LowFuelThreshold,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan site: %w", err)
 		}
+		sites = append(sites, &site)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed to get site by device ID: %w", err)
 	}
 
-	return &site, nil
+	switch len(sites) {
+	case 0:
+		return nil, nil // Site not found
+	case 1:
+		return sites[0], nil
+	default:
+		return nil, fmt.Errorf("%w: %s and %s", ErrAmbiguousDeviceID, sites[0].DeviceID, sites[1].DeviceID)
+	}
 }
 
 // GetAllSites retrieves all active sites
 func (db *DB) GetAllSites() ([]*models.Site, error) {
+	return db.getAllSites(false)
+}
+
+// getAllSites retrieves all active sites, and deactivated ones too when includeInactive is set
+func (db *DB) getAllSites(includeInactive bool) ([]*models.Site, error) {
 	query := `
-		SELECT id, name, location, device_id, is_active, created_at
+		SELECT id, name, location, device_id, is_active, created_at, type_id,
+		       maintenance_mode, maintenance_start, maintenance_end, alerts_enabled,
+		       low_fuel_mode, low_fuel_liters, low_fuel_threshold
 		FROM sites 
-		WHERE is_active = true
+		WHERE is_active = true OR $1
 		ORDER BY name
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, includeInactive)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all sites: %w", err)
 	}
 	defer rows.Close()
 
-	var sites []*models.Site
+	sites := []*models.Site{}
 	for rows.Next() {
 		var site models.Site
 		err := rows.Scan(
@@ -155,6 +292,14 @@ func (db *DB) GetAllSites() ([]*models.Site, error) {
 			&site.DeviceID,
 			&site.IsActive,
 			&site.CreatedAt,
+			&site.TypeID,
+			&site.MaintenanceMode,
+			&site.MaintenanceStart,
+			&site.MaintenanceEnd,
+			&site.AlertsEnabled,
+			&site.LowFuelMode,
+			&site.LowFuelLiters,
+			&site.LowFuelThreshold,
 		)
 
 		if err != nil {
@@ -183,7 +328,7 @@ func (db *DB) GetUserSiteAssignments(userID int) ([]*models.UserSiteAssignmentRe
 	}
 	defer rows.Close()
 
-	var assignments []*models.UserSiteAssignmentResponse
+	assignments := []*models.UserSiteAssignmentResponse{}
 	for rows.Next() {
 		var assignment models.UserSiteAssignmentResponse
 		err := rows.Scan(
@@ -202,29 +347,78 @@ func (db *DB) GetUserSiteAssignments(userID int) ([]*models.UserSiteAssignmentRe
 	return assignments, nil
 }
 
-// GetSitesForUser retrieves sites visible to a user (all for admin, assigned for others)
+// GetAssignmentCounts returns every active non-admin user with the number of
+// active sites assigned to them, including users with no assignments
+func (db *DB) GetAssignmentCounts() ([]*models.UserAssignmentCount, error) {
+	query := `
+		SELECT u.id, u.username, u.full_name, u.role, COUNT(s.id)
+		FROM users u
+		LEFT JOIN user_site_assignments usa ON usa.user_id = u.id
+		LEFT JOIN sites s ON s.id = usa.site_id AND s.is_active = true
+		WHERE u.is_active = true AND u.role <> 'admin'
+		GROUP BY u.id, u.username, u.full_name, u.role
+		ORDER BY u.username
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assignment counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []*models.UserAssignmentCount{}
+	for rows.Next() {
+		var count models.UserAssignmentCount
+		err := rows.Scan(
+			&count.UserID,
+			&count.Username,
+			&count.FullName,
+			&count.Role,
+			&count.SiteCount,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan assignment count: %w", err)
+		}
+
+		counts = append(counts, &count)
+	}
+
+	return counts, rows.Err()
+}
+
+// GetSitesForUser retrieves active sites visible to a user (all for admin, assigned for others)
 func (db *DB) GetSitesForUser(userID int, userRole string) ([]*models.Site, error) {
+	return db.GetReportSitesForUser(userID, userRole, false)
+}
+
+// GetReportSitesForUser retrieves the sites visible to a user like GetSitesForUser,
+// adding deactivated sites when includeInactive is set so their stored history
+// stays reportable after they are retired
+func (db *DB) GetReportSitesForUser(userID int, userRole string, includeInactive bool) ([]*models.Site, error) {
 	if userRole == "admin" {
-		// Admin can see all active sites
-		return db.GetAllSites()
+		// Admin can see all sites
+		return db.getAllSites(includeInactive)
 	}
 
 	// Manager/Supervisor can only see assigned sites
 	query := `
-		SELECT s.id, s.name, s.location, s.device_id, s.is_active, s.created_at
+		SELECT s.id, s.name, s.location, s.device_id, s.is_active, s.created_at, s.type_id,
+		       s.maintenance_mode, s.maintenance_start, s.maintenance_end, s.alerts_enabled,
+		       s.low_fuel_mode, s.low_fuel_liters, s.low_fuel_threshold
 		FROM sites s
 		INNER JOIN user_site_assignments usa ON usa.site_id = s.id
-		WHERE usa.user_id = $1 AND s.is_active = true
+		WHERE usa.user_id = $1 AND (s.is_active = true OR $2)
 		ORDER BY s.name
 	`
 
-	rows, err := db.Query(query, userID)
+	rows, err := db.Query(query, userID, includeInactive)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user sites: %w", err)
 	}
 	defer rows.Close()
 
-	var sites []*models.Site
+	sites := []*models.Site{}
 	for rows.Next() {
 		var site models.Site
 		err := rows.Scan(
@@ -234,6 +428,14 @@ func (db *DB) GetSitesForUser(userID int, userRole string) ([]*models.Site, erro
 			&site.DeviceID,
 			&site.IsActive,
 			&site.CreatedAt,
+			&site.TypeID,
+			&site.MaintenanceMode,
+			&site.MaintenanceStart,
+			&site.MaintenanceEnd,
+			&site.AlertsEnabled,
+			&site.LowFuelMode,
+			&site.LowFuelLiters,
+			&site.LowFuelThreshold,
 		)
 
 		if err != nil {
@@ -294,3 +496,446 @@ func (db *DB) AssignSitesToUser(userID int, siteIDs []int) error {
 
 	return tx.Commit()
 }
+
+// GetSiteTypes retrieves all site types keyed by ID
+func (db *DB) GetSiteTypes() (map[int]*models.SiteType, error) {
+	query := `
+		SELECT id, name, expected_sensors, sensor_names, created_at
+		FROM site_types
+		ORDER BY name
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get site types: %w", err)
+	}
+	defer rows.Close()
+
+	siteTypes := make(map[int]*models.SiteType)
+	for rows.Next() {
+		var siteType models.SiteType
+		var sensorNames []byte
+		err := rows.Scan(
+			&siteType.ID,
+			&siteType.Name,
+			pq.Array(&siteType.ExpectedSensors),
+			&sensorNames,
+			&siteType.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan site type: %w", err)
+		}
+
+		if err := json.Unmarshal(sensorNames, &siteType.SensorNames); err != nil {
+			return nil, fmt.Errorf("failed to parse sensor names of site type %s: %w", siteType.Name, err)
+		}
+
+		siteTypes[siteType.ID] = &siteType
+	}
+
+	return siteTypes, nil
+}
+
+// BulkAssignSitesToUsers assigns the same sites to several users in one transaction.
+// With replace, each user's assignments to other sites are removed; otherwise sites
+// are added to what the users already have. Returns the number of sites newly
+// assigned per user, not counting the ones they already had.
+func (db *DB) BulkAssignSitesToUsers(userIDs []int, siteIDs []int, replace bool) (map[int]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insertQuery := `
+		INSERT INTO user_site_assignments (user_id, site_id, created_at)
+		SELECT $1, $2, NOW()
+		WHERE NOT EXISTS (
+			SELECT 1 FROM user_site_assignments WHERE user_id = $1 AND site_id = $2
+		)
+	`
+
+	written := make(map[int]int, len(userIDs))
+	for _, userID := range userIDs {
+		if replace {
+			_, err = tx.Exec("DELETE FROM user_site_assignments WHERE user_id = $1 AND NOT (site_id = ANY($2))", userID, pq.Array(siteIDs))
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete existing assignments for user %d: %w", userID, err)
+			}
+		}
+
+		for _, siteID := range siteIDs {
+			result, err := tx.Exec(insertQuery, userID, siteID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to assign site %d to user %d: %w", siteID, userID, err)
+			}
+
+			if affected, err := result.RowsAffected(); err == nil {
+				written[userID] += int(affected)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit assignments: %w", err)
+	}
+
+	return written, nil
+}
+
+// SetSitesLowFuelThreshold sets the low fuel threshold of several active sites in
+// one transaction and returns how many were updated; a nil threshold clears it.
+// Nothing is updated unless every site exists and is active.
+func (db *DB) SetSitesLowFuelThreshold(siteIDs []int, threshold *float64) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE sites
+		SET low_fuel_threshold = $2
+		WHERE id = ANY($1) AND is_active = true
+	`, pq.Array(siteIDs), threshold)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update low fuel thresholds: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count updated sites: %w", err)
+	}
+	if int(affected) != len(siteIDs) {
+		return 0, fmt.Errorf("failed to update low fuel thresholds: %d of %d sites are active", affected, len(siteIDs))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit low fuel thresholds: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// SetSiteMaintenance switches an active site's maintenance mode and window.
+// Disabling maintenance clears the window. Returns nil when the site does not exist.
+func (db *DB) SetSiteMaintenance(siteID int, enabled bool, start, end *time.Time) (*models.Site, error) {
+	if !enabled {
+		start, end = nil, nil
+	}
+
+	query := `
+		UPDATE sites
+		SET maintenance_mode = $2, maintenance_start = $3, maintenance_end = $4
+		WHERE id = $1 AND is_active = true
+		RETURNING id, name, location, device_id, is_active, created_at, type_id,
+		          maintenance_mode, maintenance_start, maintenance_end, alerts_enabled,
+		          low_fuel_mode, low_fuel_liters, low_fuel_threshold
+	`
+
+	var site models.Site
+	err := db.QueryRow(query, siteID, enabled, start, end).Scan(
+		&site.ID,
+		&site.Name,
+		&site.Location,
+		&site.DeviceID,
+		&site.IsActive,
+		&site.CreatedAt,
+		&site.TypeID,
+		&site.MaintenanceMode,
+		&site.MaintenanceStart,
+		&site.MaintenanceEnd,
+		&site.AlertsEnabled,
+		&site.LowFuelMode,
+		&site.LowFuelLiters,
+		&site.LowFuelThreshold,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Site not found
+		}
+		return nil, fmt.Errorf("failed to set site maintenance: %w", err)
+	}
+
+	return &site, nil
+}
+
+// SetSiteAlertsEnabled enables or disables alerting for an active site.
+// Returns nil when the site does not exist.
+func (db *DB) SetSiteAlertsEnabled(siteID int, enabled bool) (*models.Site, error) {
+	query := `
+		UPDATE sites
+		SET alerts_enabled = $2
+		WHERE id = $1 AND is_active = true
+		RETURNING id, name, location, device_id, is_active, created_at, type_id,
+		          maintenance_mode, maintenance_start, maintenance_end, alerts_enabled,
+		          low_fuel_mode, low_fuel_liters, low_fuel_threshold
+	`
+
+	var site models.Site
+	err := db.QueryRow(query, siteID, enabled).Scan(
+		&site.ID,
+		&site.Name,
+		&site.Location,
+		&site.DeviceID,
+		&site.IsActive,
+		&site.CreatedAt,
+		&site.TypeID,
+		&site.MaintenanceMode,
+		&site.MaintenanceStart,
+		&site.MaintenanceEnd,
+		&site.AlertsEnabled,
+		&site.LowFuelMode,
+		&site.LowFuelLiters,
+		&site.LowFuelThreshold,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Site not found
+		}
+		return nil, fmt.Errorf("failed to set site alerting: %w", err)
+	}
+
+	return &site, nil
+}
+
+// UpdateSite changes the fields of an active site that are set in siteData.
+// Returns nil when the site does not exist.
+func (db *DB) UpdateSite(siteID int, siteData *models.UpdateSiteData) (*models.Site, error) {
+	// Build dynamic query based on what fields are provided
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if siteData.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *siteData.Name)
+		argIndex++
+	}
+
+	if siteData.Location != nil {
+		setParts = append(setParts, fmt.Sprintf("location = $%d", argIndex))
+		args = append(args, *siteData.Location)
+		argIndex++
+	}
+
+	if siteData.DeviceID != nil {
+		setParts = append(setParts, fmt.Sprintf("device_id = $%d", argIndex))
+		args = append(args, *siteData.DeviceID)
+		argIndex++
+	}
+
+	if siteData.TypeID != nil {
+		setParts = append(setParts, fmt.Sprintf("type_id = $%d", argIndex))
+		args = append(args, *siteData.TypeID)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	// Add WHERE clause parameter
+	args = append(args, siteID)
+
+	query := fmt.Sprintf(`
+		UPDATE sites
+		SET %s
+		WHERE id = $%d AND is_active = true
+		RETURNING id, name, location, device_id, is_active, created_at, type_id,
+		          maintenance_mode, maintenance_start, maintenance_end, alerts_enabled,
+		          low_fuel_mode, low_fuel_liters, low_fuel_threshold
+	`, strings.Join(setParts, ", "), argIndex)
+
+	var site models.Site
+	err := db.QueryRow(query, args...).Scan(
+		&site.ID,
+		&site.Name,
+		&site.Location,
+		&site.DeviceID,
+		&site.IsActive,
+		&site.CreatedAt,
+		&site.TypeID,
+		&site.MaintenanceMode,
+		&site.MaintenanceStart,
+		&site.MaintenanceEnd,
+		&site.AlertsEnabled,
+		&site.LowFuelMode,
+		&site.LowFuelLiters,
+		&site.LowFuelThreshold,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Site not found
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch {
+			case pqErr.Code == "23505" && (pqErr.Constraint == siteDeviceIDIndex || pqErr.Constraint == siteDeviceIDLowerIndex):
+				return nil, ErrDeviceIDTaken
+			case pqErr.Code == "23503":
+				return nil, ErrSiteTypeNotFound
+			}
+		}
+		return nil, fmt.Errorf("failed to update site: %w", err)
+	}
+
+	return &site, nil
+}
+
+// SetSiteLowFuel sets how an active site's low fuel is judged. The liters
+// threshold is cleared in percent mode. Returns nil when the site does not exist.
+func (db *DB) SetSiteLowFuel(siteID int, mode string, liters *float64) (*models.Site, error) {
+	if mode == models.LowFuelModePercent {
+		liters = nil
+	}
+
+	query := `
+		UPDATE sites
+		SET low_fuel_mode = $2, low_fuel_liters = $3
+		WHERE id = $1 AND is_active = true
+		RETURNING id, name, location, device_id, is_active, created_at, type_id,
+		          maintenance_mode, maintenance_start, maintenance_end, alerts_enabled,
+		          low_fuel_mode, low_fuel_liters, low_fuel_threshold
+	`
+
+	var site models.Site
+	err := db.QueryRow(query, siteID, mode, liters).Scan(
+		&site.ID,
+		&site.Name,
+		&site.Location,
+		&site.DeviceID,
+		&site.IsActive,
+		&site.CreatedAt,
+		&site.TypeID,
+		&site.MaintenanceMode,
+		&site.MaintenanceStart,
+		&site.MaintenanceEnd,
+		&site.AlertsEnabled,
+		&site.LowFuelMode,
+		&site.LowFuelLiters,
+		&site.LowFuelThreshold,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Site not found
+		}
+		return nil, fmt.Errorf("failed to set site low fuel mode: %w", err)
+	}
+
+	return &site, nil
+}
+
+// SearchSitesForUser finds active sites the user may access whose name, location
+// or device ID contains query, ignoring case. Exact name matches rank first, then
+// name prefixes, then other prefixes, then any other match, each by name.
+func (db *DB) SearchSitesForUser(userID int, userRole, query string, limit int) ([]*models.Site, error) {
+	// Match the query literally: escape LIKE wildcards before adding our own
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+
+	sqlQuery := `
+		SELECT s.id, s.name, s.location, s.device_id, s.is_active, s.created_at, s.type_id,
+		       s.maintenance_mode, s.maintenance_start, s.maintenance_end, s.alerts_enabled,
+		       s.low_fuel_mode, s.low_fuel_liters, s.low_fuel_threshold
+		FROM sites s
+		WHERE s.is_active = true
+		  AND ($2 = 'admin' OR EXISTS (
+			SELECT 1 FROM user_site_assignments usa
+			WHERE usa.site_id = s.id AND usa.user_id = $1
+		  ))
+		  AND (s.name ILIKE '%' || $3 || '%' OR s.location ILIKE '%' || $3 || '%' OR s.device_id ILIKE '%' || $3 || '%')
+		ORDER BY
+			CASE
+				WHEN LOWER(s.name) = LOWER($4) THEN 0
+				WHEN s.name ILIKE $3 || '%' THEN 1
+				WHEN s.location ILIKE $3 || '%' OR s.device_id ILIKE $3 || '%' THEN 2
+				ELSE 3
+			END,
+			s.name
+		LIMIT $5
+	`
+
+	rows, err := db.Query(sqlQuery, userID, userRole, escaped, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search sites: %w", err)
+	}
+	defer rows.Close()
+
+	sites := []*models.Site{}
+	for rows.Next() {
+		var site models.Site
+		err := rows.Scan(
+			&site.ID,
+			&site.Name,
+			&site.Location,
+			&site.DeviceID,
+			&site.IsActive,
+			&site.CreatedAt,
+			&site.TypeID,
+			&site.MaintenanceMode,
+			&site.MaintenanceStart,
+			&site.MaintenanceEnd,
+			&site.AlertsEnabled,
+			&site.LowFuelMode,
+			&site.LowFuelLiters,
+			&site.LowFuelThreshold,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan site: %w", err)
+		}
+
+		sites = append(sites, &site)
+	}
+
+	return sites, rows.Err()
+}
+
+// GetSiteForUser retrieves an active site by ID if the user may access it
+// (any site for admin, assigned sites for others). Returns nil when the site
+// does not exist or is outside the user's scope.
+func (db *DB) GetSiteForUser(siteID, userID int, userRole string) (*models.Site, error) {
+	query := `
+		SELECT s.id, s.name, s.location, s.device_id, s.is_active, s.created_at, s.type_id,
+		       s.maintenance_mode, s.maintenance_start, s.maintenance_end, s.alerts_enabled,
+		       s.low_fuel_mode, s.low_fuel_liters, s.low_fuel_threshold
+		FROM sites s
+		WHERE s.id = $1 AND s.is_active = true
+		  AND ($3 = 'admin' OR EXISTS (
+			SELECT 1 FROM user_site_assignments usa
+			WHERE usa.site_id = s.id AND usa.user_id = $2
+		  ))
+	`
+
+	var site models.Site
+	err := db.QueryRow(query, siteID, userID, userRole).Scan(
+		&site.ID,
+		&site.Name,
+		&site.Location,
+		&site.DeviceID,
+		&site.IsActive,
+		&site.CreatedAt,
+		&site.TypeID,
+		&site.MaintenanceMode,
+		&site.MaintenanceStart,
+		&site.MaintenanceEnd,
+		&site.AlertsEnabled,
+		&site.LowFuelMode,
+		&site.LowFuelLiters,
+		&site.LowFuelThreshold,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Site not found or not accessible
+		}
+		return nil, fmt.Errorf("failed to get site for user: %w", err)
+	}
+
+	return &site, nil
+}
@@ -0,0 +1,373 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+)
+
+func TestGetReportSitesForUser(t *testing.T) {
+	columns := []string{"id", "name", "location", "device_id", "is_active", "created_at", "type_id",
+		"maintenance_mode", "maintenance_start", "maintenance_end", "alerts_enabled",
+		"low_fuel_mode", "low_fuel_liters", "low_fuel_threshold"}
+	// Site 1 is active and site 2 has been deactivated; the manager is assigned to both
+	active := map[int64]bool{1: true, 2: false}
+
+	tests := []struct {
+		name            string
+		role            string
+		includeInactive bool
+		want            []int
+	}{
+		{"admin active only", "admin", false, []int{1}},
+		{"admin include inactive", "admin", true, []int{1, 2}},
+		{"manager active only", "manager", false, []int{1}},
+		{"manager include inactive", "manager", true, []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				// The include flag is the last argument of both the admin and the assignment query
+				include := args[len(args)-1].Value.(bool)
+				if tt.role != "admin" && !strings.Contains(query, "user_site_assignments") {
+					t.Errorf("manager query does not use assignments:\n%s", query)
+				}
+				var rows [][]driver.Value
+				for _, id := range []int64{1, 2} {
+					if active[id] || include {
+						rows = append(rows, []driver.Value{id, "Site", "", "simbisa-site", active[id], time.Now(), nil,
+							false, nil, nil, true, "percent", nil, nil})
+					}
+				}
+				return columns, rows, nil
+			})
+
+			sites, err := db.GetReportSitesForUser(7, tt.role, tt.includeInactive)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(sites) != len(tt.want) {
+				t.Fatalf("got %d sites, want %v", len(sites), tt.want)
+			}
+			for i, site := range sites {
+				if site.ID != tt.want[i] {
+					t.Errorf("site %d ID = %d, want %d", i, site.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSiteNameFor(t *testing.T) {
+	tests := []struct {
+		deviceID string
+		style    string
+		want     string
+	}{
+		{"simbisa-borrowdale-brooke", "title", "Borrowdale Brooke"},
+		{"Simbisa-Avondale", "title", "Avondale"},
+		{"simbisa-msasa_park", "title", "Msasa Park"},
+		{"simbisa--double--hyphen", "title", "Double Hyphen"},
+		{"simbisa-élan", "title", "Élan"},
+		{"harare-depot", "title", "Harare Depot"},
+		{"simbisa-", "title", "simbisa-"},
+		{"simbisa", "title", "Simbisa"},
+		{"simbisa-borrowdale-brooke", "device", "simbisa-borrowdale-brooke"},
+		{"simbisa-borrowdale", "", "Borrowdale"},
+	}
+
+	for _, tt := range tests {
+		if got := siteNameFor(tt.deviceID, tt.style); got != tt.want {
+			t.Errorf("siteNameFor(%q, %q) = %q, want %q", tt.deviceID, tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestFastAutoCreateSitesNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		naming      config.SitesConfig
+		wantCreated [][]string // name, location, device ID
+	}{
+		{"title names", config.SitesConfig{NameStyle: "title", LocationTemplate: "{name}, Harare"}, [][]string{
+			{"Avondale", "Avondale, Harare", "Simbisa-Avondale"},
+			{"Borrowdale Brooke", "Borrowdale Brooke, Harare", "simbisa-borrowdale-brooke"},
+		}},
+		{"device names", config.SitesConfig{NameStyle: "device", LocationTemplate: "{device}"}, [][]string{
+			{"Simbisa-Avondale", "Simbisa-Avondale", "Simbisa-Avondale"},
+			{"simbisa-borrowdale-brooke", "simbisa-borrowdale-brooke", "simbisa-borrowdale-brooke"},
+		}},
+		{"blank location", config.SitesConfig{NameStyle: "title"}, [][]string{
+			{"Avondale", "", "Simbisa-Avondale"},
+			{"Borrowdale Brooke", "", "simbisa-borrowdale-brooke"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created [][]string
+			db := newFakeDBWith(t, fakeHandlers{
+				query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+					if strings.Contains(query, "information_schema.tables") || strings.Contains(query, "pg_index") {
+						return []string{"exists"}, [][]driver.Value{{true}}, nil
+					}
+					return []string{"device_id"}, [][]driver.Value{
+						{"Simbisa-Avondale"}, {"simbisa-borrowdale-brooke"}, {"simbisa-msasa_park"},
+					}, nil
+				},
+				exec: func(query string, args []driver.NamedValue) (int64, error) {
+					// Msasa Park already has a site, so the insert does nothing
+					if args[2].Value == "simbisa-msasa_park" {
+						return 0, nil
+					}
+					created = append(created, []string{args[0].Value.(string), args[1].Value.(string), args[2].Value.(string)})
+					return 1, nil
+				},
+			})
+
+			count, err := db.FastAutoCreateSites(tt.naming)
+			if err != nil {
+				t.Fatalf("FastAutoCreateSites: %v", err)
+			}
+			if count != len(tt.wantCreated) {
+				t.Errorf("created count = %d, want %d", count, len(tt.wantCreated))
+			}
+			if !reflect.DeepEqual(created, tt.wantCreated) {
+				t.Errorf("created sites = %q, want %q", created, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestUniqueDeviceIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"distinct", []string{"simbisa-a", "simbisa-b"}, []string{"simbisa-a", "simbisa-b"}},
+		{"lowercase first", []string{"simbisa-avondale", "Simbisa-Avondale"}, []string{"simbisa-avondale"}},
+		{"lowercase later", []string{"SIMBISA-AVONDALE", "Simbisa-Avondale", "simbisa-avondale", "simbisa-b"}, []string{"simbisa-avondale", "simbisa-b"}},
+		{"no lowercase form", []string{"Simbisa-X", "SIMBISA-X"}, []string{"Simbisa-X"}},
+		{"empty", nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uniqueDeviceIDs(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uniqueDeviceIDs(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSiteByDeviceID(t *testing.T) {
+	columns := []string{"id", "name", "location", "device_id", "is_active", "created_at", "type_id",
+		"maintenance_mode", "maintenance_start", "maintenance_end", "alerts_enabled",
+		"low_fuel_mode", "low_fuel_liters", "low_fuel_threshold"}
+
+	tests := []struct {
+		name      string
+		stored    []string
+		lookup    string
+		wantID    int
+		wantFound bool
+		wantErr   error
+	}{
+		{"exact", []string{"simbisa-avondale"}, "simbisa-avondale", 1, true, nil},
+		{"mixed case lookup", []string{"simbisa-avondale"}, "Simbisa-AVONDALE", 1, true, nil},
+		{"mixed case stored", []string{"simbisa-a", "Simbisa-Avondale"}, "simbisa-avondale", 2, true, nil},
+		{"not found", []string{"simbisa-a"}, "simbisa-b", 0, false, nil},
+		{"case duplicates", []string{"simbisa-avondale", "SIMBISA-AVONDALE"}, "Simbisa-Avondale", 0, false, ErrAmbiguousDeviceID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "LOWER(device_id) = LOWER($1)") {
+					t.Errorf("query does not match the device ID ignoring case:\n%s", query)
+				}
+				var rows [][]driver.Value
+				for i, deviceID := range tt.stored {
+					if strings.EqualFold(deviceID, args[0].Value.(string)) {
+						rows = append(rows, []driver.Value{int64(i + 1), "Site", "", deviceID, true, time.Now(), nil,
+							false, nil, nil, true, "percent", nil, nil})
+					}
+				}
+				return columns, rows, nil
+			})
+
+			site, err := db.GetSiteByDeviceID(tt.lookup)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if (site != nil) != tt.wantFound {
+				t.Fatalf("found = %v, want %v", site != nil, tt.wantFound)
+			}
+			if site != nil && site.ID != tt.wantID {
+				t.Errorf("site ID = %d, want %d", site.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestBulkAssignSitesToUsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []int
+		siteIDs  []int
+		replace  bool
+		want     int
+		wantKept []int
+	}{
+		{"add skips existing", []int{1, 2}, []int{2, 3}, false, 1, []int{1, 2, 3}},
+		{"add nothing new", []int{1, 2}, []int{1, 2}, false, 0, []int{1, 2}},
+		{"replace keeps overlap", []int{1, 2}, []int{2, 3}, true, 1, []int{2, 3}},
+		{"replace with none", []int{1, 2}, []int{}, true, 0, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assigned := make(map[int64]bool)
+			for _, id := range tt.existing {
+				assigned[int64(id)] = true
+			}
+
+			db := newFakeDBWith(t, fakeHandlers{exec: func(query string, args []driver.NamedValue) (int64, error) {
+				switch {
+				case strings.HasPrefix(strings.TrimSpace(query), "DELETE"):
+					keep := make(map[string]bool)
+					for _, id := range strings.Split(strings.Trim(args[1].Value.(string), "{}"), ",") {
+						keep[id] = true
+					}
+					var deleted int64
+					for id := range assigned {
+						if !keep[strconv.FormatInt(id, 10)] {
+							delete(assigned, id)
+							deleted++
+						}
+					}
+					return deleted, nil
+				case strings.Contains(query, "INSERT INTO user_site_assignments"):
+					siteID := args[1].Value.(int64)
+					if assigned[siteID] {
+						return 0, nil
+					}
+					assigned[siteID] = true
+					return 1, nil
+				}
+				return 0, errors.New("unexpected statement")
+			}})
+
+			written, err := db.BulkAssignSitesToUsers([]int{7}, tt.siteIDs, tt.replace)
+			if err != nil {
+				t.Fatalf("BulkAssignSitesToUsers: %v", err)
+			}
+			if written[7] != tt.want {
+				t.Errorf("assigned %d sites, want %d", written[7], tt.want)
+			}
+
+			kept := []int{}
+			for id := range assigned {
+				kept = append(kept, int(id))
+			}
+			sort.Ints(kept)
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("assignments = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestFastAutoCreateSitesConcurrently(t *testing.T) {
+	devices := [][]driver.Value{{"simbisa-avondale"}, {"Simbisa-Borrowdale"}, {"simbisa-borrowdale"}, {"simbisa-msasa"}, {"simbisa-westgate"}}
+
+	// sites emulates the unique index on LOWER(device_id): a second insert of a
+	// device, in any case, conflicts and does nothing
+	var mu sync.Mutex
+	sites := map[string]int{}
+	db := newFakeDBWith(t, fakeHandlers{
+		query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+			if strings.Contains(query, "information_schema.tables") || strings.Contains(query, "pg_index") {
+				return []string{"exists"}, [][]driver.Value{{true}}, nil
+			}
+			return []string{"device_id"}, devices, nil
+		},
+		exec: func(query string, args []driver.NamedValue) (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			deviceID := strings.ToLower(args[2].Value.(string))
+			sites[deviceID]++
+			if sites[deviceID] > 1 {
+				return 0, nil
+			}
+			return 1, nil
+		},
+	})
+
+	const instances = 8
+	counts := make(chan int, instances)
+	var wg sync.WaitGroup
+	for i := 0; i < instances; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, err := db.FastAutoCreateSites(config.SitesConfig{NameStyle: "title"})
+			if err != nil {
+				t.Errorf("FastAutoCreateSites: %v", err)
+			}
+			counts <- count
+		}()
+	}
+	wg.Wait()
+	close(counts)
+
+	// Between them the instances create each of the four devices exactly once
+	total := 0
+	for count := range counts {
+		total += count
+	}
+	if total != 4 {
+		t.Errorf("instances created %d sites in total, want 4", total)
+	}
+	if len(sites) != 4 {
+		t.Errorf("inserted devices = %v, want 4 distinct", sites)
+	}
+}
+
+func TestFastAutoCreateSitesRequiresUniqueIndex(t *testing.T) {
+	var inserts int
+	db := newFakeDBWith(t, fakeHandlers{
+		query: func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+			switch {
+			case strings.Contains(query, "information_schema.tables"):
+				return []string{"exists"}, [][]driver.Value{{true}}, nil
+			case strings.Contains(query, "pg_index"):
+				// EnsureSchema skipped the indexes because of duplicate sites
+				return []string{"exists"}, [][]driver.Value{{false}}, nil
+			}
+			return []string{"device_id"}, [][]driver.Value{{"simbisa-avondale"}}, nil
+		},
+		exec: func(query string, args []driver.NamedValue) (int64, error) {
+			inserts++
+			return 1, nil
+		},
+	})
+
+	count, err := db.FastAutoCreateSites(config.SitesConfig{NameStyle: "title"})
+	if !errors.Is(err, ErrSiteDeviceIDNotUnique) {
+		t.Fatalf("error = %v, want ErrSiteDeviceIDNotUnique", err)
+	}
+	if count != 0 || inserts != 0 {
+		t.Errorf("created %d sites with %d inserts, want none", count, inserts)
+	}
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"time"
+
+	"fuel-monitor-api/internal/logger"
+)
+
+// timeQuery starts timing the named query and returns the function that ends
+// it, logging a warning when the query ran longer than the slow query
+// threshold. Use it as: defer db.timeQuery("GetSingleDeviceReading")()
+func (db *DB) timeQuery(name string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		if isSlowQuery(elapsed, db.slowQueryThreshold) {
+			logger.Warnf("Slow query %s took %v (threshold %v)", name, elapsed.Round(time.Millisecond), db.slowQueryThreshold)
+		}
+	}
+}
+
+// isSlowQuery reports whether a query that took elapsed is over threshold. A
+// threshold of zero or less turns slow query logging off.
+func isSlowQuery(elapsed, threshold time.Duration) bool {
+	return threshold > 0 && elapsed > threshold
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsSlowQuery(t *testing.T) {
+	tests := []struct {
+		elapsed   time.Duration
+		threshold time.Duration
+		want      bool
+	}{
+		{2 * time.Second, time.Second, true},
+		{time.Second, time.Second, false},
+		{500 * time.Millisecond, time.Second, false},
+		{time.Hour, 0, false},
+		{time.Hour, -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		if got := isSlowQuery(tt.elapsed, tt.threshold); got != tt.want {
+			t.Errorf("isSlowQuery(%v, %v) = %t, want %t", tt.elapsed, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestTimedQueryLogsSlowQueries(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		wantLog   bool
+	}{
+		{"over threshold", 5 * time.Millisecond, true},
+		{"under threshold", time.Hour, false},
+		{"disabled", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			db := newFakeDB(t, func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				time.Sleep(20 * time.Millisecond)
+				return []string{"id"}, nil, nil
+			})
+			db.slowQueryThreshold = tt.threshold
+
+			if _, err := db.GetDashboardSitesForUser(1, "admin"); err != nil {
+				t.Fatalf("GetDashboardSitesForUser: %v", err)
+			}
+
+			logged := strings.Contains(buf.String(), "Slow query GetDashboardSitesForUser")
+			if logged != tt.wantLog {
+				t.Errorf("slow query logged = %t, want %t; log:\n%s", logged, tt.wantLog, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// CreateWebhook registers an outgoing alert webhook
+func (db *DB) CreateWebhook(url, secret string, alertTypes []string, createdBy int) (*models.Webhook, error) {
+	query := `
+		INSERT INTO webhooks (url, secret, alert_types, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, url, secret, alert_types, is_active, created_at
+	`
+
+	var webhook models.Webhook
+	err := db.QueryRow(query, url, secret, pq.Array(alertTypes), createdBy).Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.AlertTypes),
+		&webhook.IsActive,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return &webhook, nil
+}
+
+// GetWebhooks retrieves registered webhooks, optionally only active ones, oldest first
+func (db *DB) GetWebhooks(activeOnly bool) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, url, secret, alert_types, is_active, created_at
+		FROM webhooks
+		WHERE is_active = true OR NOT $1
+		ORDER BY id
+	`
+
+	rows, err := db.Query(query, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		err := rows.Scan(
+			&webhook.ID,
+			&webhook.URL,
+			&webhook.Secret,
+			pq.Array(&webhook.AlertTypes),
+			&webhook.IsActive,
+			&webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// GetWebhookByID retrieves a webhook, or nil when it does not exist
+func (db *DB) GetWebhookByID(id int) (*models.Webhook, error) {
+	query := `
+		SELECT id, url, secret, alert_types, is_active, created_at
+		FROM webhooks
+		WHERE id = $1
+	`
+
+	var webhook models.Webhook
+	err := db.QueryRow(query, id).Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.AlertTypes),
+		&webhook.IsActive,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook and reports whether it existed
+func (db *DB) DeleteWebhook(id int) (bool, error) {
+	result, err := db.Exec("DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return affected > 0, nil
+}
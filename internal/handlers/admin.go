@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/middleware"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	DB       *database.DB
+	Config   *config.Config
+	Settings *settings.Store
+	Watchdog *watchdog.Watchdog
+}
+
+func NewAdminHandler(db *database.DB, cfg *config.Config, store *settings.Store, wd *watchdog.Watchdog) *AdminHandler {
+	return &AdminHandler{
+		DB:       db,
+		Config:   cfg,
+		Settings: store,
+		Watchdog: wd,
+	}
+}
+
+// GetDBStats returns live database connection pool and worker pool statistics (admin only)
+func (h *AdminHandler) GetDBStats(c *gin.Context) {
+	stats := h.DB.Stats()
+	workers := h.Watchdog.Stats()
+
+	workerStats := models.WorkerStats{
+		ActiveWorkers:      workers.ActiveWorkers,
+		InFlightRequests:   workers.InFlightRequests,
+		OldestRequestKind:  workers.OldestKind,
+		OldestRequestAgeMs: workers.OldestAge.Milliseconds(),
+	}
+	if workers.InFlightRequests > 0 {
+		workerStats.OldestRequestAge = workers.OldestAge.Round(time.Millisecond).String()
+	}
+
+	c.JSON(http.StatusOK, models.DBStatsResponse{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		Workers:            workerStats,
+		Timestamp:          time.Now().Format(time.RFC3339),
+	})
+}
+
+// defaultStaleAfter is how long an active site may go without readings before
+// diagnostics count it as stale
+const defaultStaleAfter = 24 * time.Hour
+
+// GetDiagnostics returns a one-call picture of the sensor data flow: site and
+// reporting device counts, sites without readings within ?staleAfter= (default
+// 24h), devices without a site and the newest reading (admin only)
+func (h *AdminHandler) GetDiagnostics(c *gin.Context) {
+	staleAfter := defaultStaleAfter
+	if staleParam := c.Query("staleAfter"); staleParam != "" {
+		parsed, err := time.ParseDuration(staleParam)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "staleAfter must be a positive duration such as 6h or 90m",
+			})
+			return
+		}
+		staleAfter = parsed
+	}
+
+	now := time.Now()
+	diagnostics, err := h.DB.GetDataDiagnostics(now.Add(-staleAfter))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get data diagnostics: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get diagnostics",
+		})
+		return
+	}
+
+	diagnostics.StaleAfter = staleAfter.String()
+	diagnostics.Timestamp = now.Format(time.RFC3339)
+	c.JSON(http.StatusOK, diagnostics)
+}
+
+// RenameDevice moves a relabeled device's site, and optionally its historical
+// sensor readings, to the new device ID (admin only)
+func (h *AdminHandler) RenameDevice(c *gin.Context) {
+	var req models.RenameDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	oldDeviceID := strings.TrimSpace(req.OldDeviceID)
+	newDeviceID := strings.TrimSpace(req.NewDeviceID)
+	if oldDeviceID == "" || newDeviceID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "oldDeviceId and newDeviceId cannot be empty",
+		})
+		return
+	}
+	if oldDeviceID == newDeviceID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "newDeviceId must differ from oldDeviceId",
+		})
+		return
+	}
+
+	result, err := h.DB.RenameDevice(oldDeviceID, newDeviceID, req.RewriteReadings)
+	if errors.Is(err, database.ErrDeviceNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "No site has device " + oldDeviceID,
+		})
+		return
+	}
+	if errors.Is(err, database.ErrDeviceIDTaken) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Message: "Device ID is already used by another site",
+		})
+		return
+	}
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to rename device %s to %s: %v", oldDeviceID, newDeviceID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to rename device",
+		})
+		return
+	}
+
+	if user, ok := middleware.GetUserFromContext(c); ok {
+		middleware.Log(c).Infof("%s renamed device %s to %s (site %d, %d sensor readings moved)", user.Username, result.OldDeviceID, newDeviceID, result.SiteID, result.SensorReadings)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetConfig returns the effective non-secret configuration, with runtime
+// settings applied over the environment values (admin only)
+func (h *AdminHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, configSnapshot(h.Config, h.Settings))
+}
+
+// configSnapshot copies the reportable configuration field by field, so secrets
+// and fields added to config later stay out until they are listed here
+func configSnapshot(cfg *config.Config, store *settings.Store) models.ConfigResponse {
+	frozenWindow := time.Duration(store.Float(settings.FrozenSensorWindowHours) * float64(time.Hour))
+
+	return models.ConfigResponse{
+		Server: models.ServerConfigInfo{
+			Port:           cfg.Server.Port,
+			Environment:    cfg.Server.Environment,
+			BasePath:       cfg.Server.BasePath,
+			LogLevel:       cfg.Server.LogLevel,
+			Timezone:       localTimezone(),
+			WriteTimeout:   cfg.Server.WriteTimeout.String(),
+			RequestTimeout: cfg.Server.RequestTimeout.String(),
+		},
+		Database: models.DatabaseConfigInfo{
+			Host:               cfg.Database.Host,
+			Port:               cfg.Database.Port,
+			Name:               cfg.Database.Name,
+			MaxOpenConns:       cfg.Database.MaxOpenConns,
+			MaxIdleConns:       cfg.Database.MaxIdleConns,
+			ConnMaxLifetime:    cfg.Database.ConnMaxLifetime.String(),
+			ConnMaxIdleTime:    cfg.Database.ConnMaxIdleTime.String(),
+			ConnectAttempts:    cfg.Database.ConnectAttempts,
+			ConnectBackoff:     cfg.Database.ConnectBackoff.String(),
+			SlowQueryThreshold: cfg.Database.SlowQueryThreshold.String(),
+		},
+		SSH: models.SSHConfigInfo{
+			Host:           cfg.SSH.Host,
+			RemoteBindHost: cfg.SSH.RemoteBindHost,
+			RemoteBindPort: cfg.SSH.RemoteBindPort,
+			LocalPort:      cfg.SSH.LocalPort,
+		},
+		Auth: models.AuthConfigInfo{
+			TokenExpiresIn:             cfg.JWT.ExpiresIn,
+			RecheckUser:                cfg.JWT.RecheckUser,
+			RecheckTTL:                 cfg.JWT.RecheckTTL.String(),
+			IntrospectionKeyConfigured: cfg.JWT.IntrospectionAPIKey != "",
+			ReportDisabledAccounts:     cfg.JWT.ReportDisabledAccounts,
+		},
+		Sites: models.SitesConfigInfo{
+			DeviceIDPrefix:   database.DeviceIDPrefix,
+			NameStyle:        cfg.Sites.NameStyle,
+			LocationTemplate: cfg.Sites.LocationTemplate,
+		},
+		Closing: models.ClosingConfigInfo{
+			Cutoff: cfg.Closing.Cutoff,
+		},
+		Cumulative: models.CumulativeConfigInfo{
+			RangeGroupedQuery:           cfg.Cumulative.RangeGroupedQuery,
+			RangeBatchSize:              cfg.Cumulative.RangeBatchSize,
+			RangeMaxRows:                cfg.Cumulative.RangeMaxRows,
+			RangeCacheMaxAge:            cfg.Cumulative.RangeCacheMaxAge.String(),
+			NoGeneratorNoiseThreshold:   store.Float(settings.NoGeneratorNoiseThreshold),
+			TransactionalBatches:        cfg.Cumulative.TransactionalBatches,
+			ScheduleEnabled:             cfg.Cumulative.ScheduleEnabled,
+			ScheduleTime:                cfg.Cumulative.ScheduleTime,
+			ScheduleBackfillDays:        cfg.Cumulative.ScheduleBackfillDays,
+			EfficiencyMinGeneratorHours: cfg.Cumulative.EfficiencyMinGeneratorHours,
+		},
+		Sensors: models.SensorsConfigInfo{
+			FrozenWindow:         frozenWindow.String(),
+			OnStateValues:        cfg.Sensors.OnStateValues,
+			TemperatureSensors:   models.TemperatureSensors(),
+			LongRuntimeThreshold: cfg.Sensors.LongRuntimeThreshold.String(),
+			DailyDropThreshold:   cfg.Sensors.DailyDropThreshold,
+		},
+		Dashboard: models.DashboardConfigInfo{
+			RealtimeWorkers:       store.Int(settings.DashboardRealtimeWorkers),
+			ClosingWorkers:        store.Int(settings.DashboardClosingWorkers),
+			CollectTimeout:        cfg.Dashboard.CollectTimeout.String(),
+			SoftDeadline:          cfg.Dashboard.SoftDeadline.String(),
+			RequestTimeout:        cfg.Dashboard.RequestTimeout.String(),
+			LowFuelThreshold:      store.Float(settings.LowFuelThreshold),
+			CriticalFuelThreshold: store.Float(settings.CriticalFuelThreshold),
+			CriticalFuelDuration:  cfg.Dashboard.CriticalFuelDuration.String(),
+			ActivityLimit:         cfg.Dashboard.ActivityLimit,
+			ActivityWindow:        cfg.Dashboard.ActivityWindow.String(),
+		},
+		Features: models.FeaturesConfigInfo{
+			Introspection: cfg.Features.Introspection,
+			Leaderboard:   cfg.Features.Leaderboard,
+			SensorQuality: cfg.Features.SensorQuality,
+			RawReadings:   cfg.Features.RawReadings,
+			AdminTools:    cfg.Features.AdminTools,
+			Alerting:      cfg.Features.Alerting,
+		},
+		Audit: models.AuditConfigInfo{
+			LogFailedLogins: cfg.Audit.LogFailedLogins,
+		},
+		Webhooks: models.WebhooksConfigInfo{
+			PollInterval: cfg.Webhooks.PollInterval.String(),
+			Timeout:      cfg.Webhooks.Timeout.String(),
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			RetryBackoff: cfg.Webhooks.RetryBackoff.String(),
+		},
+		Pagination: models.PaginationConfigInfo{
+			DefaultPageSize: cfg.Pagination.DefaultPageSize,
+			MaxPageSize:     cfg.Pagination.MaxPageSize,
+		},
+		SMTP: models.SMTPConfigInfo{
+			Host:    cfg.SMTP.Host,
+			Port:    cfg.SMTP.Port,
+			From:    cfg.SMTP.From,
+			Timeout: cfg.SMTP.Timeout.String(),
+		},
+		RateLimit: models.RateLimitConfigInfo{
+			Dashboard:       cfg.RateLimit.Dashboard,
+			Cumulative:      cfg.RateLimit.Cumulative,
+			AdminMultiplier: cfg.RateLimit.AdminMultiplier,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
+
+// localTimezone names the local time zone, e.g. "Africa/Harare", or its
+// abbreviation when it was loaded from the system without a name
+func localTimezone() string {
+	if name := time.Local.String(); name != "Local" {
+		return name
+	}
+	name, _ := time.Now().Zone()
+	return name
+}
+
+// GetSettings returns every runtime setting with its effective value (admin only)
+func (h *AdminHandler) GetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SettingsResponse{
+		Settings: h.Settings.List(),
+	})
+}
+
+// UpdateSettings validates and stores runtime settings, then returns the refreshed list (admin only)
+func (h *AdminHandler) UpdateSettings(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	var req models.UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	var warning string
+	if err := h.Settings.Update(req.Settings, user.ID); err != nil {
+		if settings.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: err.Error(),
+			})
+			return
+		}
+		if !settings.IsRefreshError(err) {
+			middleware.Log(c).Errorf("Failed to update settings: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Message: "Failed to update settings",
+			})
+			return
+		}
+		// The values are stored, so a retry would change nothing
+		middleware.Log(c).Errorf("Failed to reload settings after update: %v", err)
+		warning = "Settings were saved but could not be reloaded from the database; other stored values may be stale until the next reload"
+	}
+
+	middleware.Log(c).Infof("Settings updated by %s: %d value(s)", user.Username, len(req.Settings))
+
+	c.JSON(http.StatusOK, models.SettingsResponse{
+		Settings: h.Settings.List(),
+		Warning:  warning,
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetDBStatsReportsWorkers(t *testing.T) {
+	db, _ := newFakeDB(t, 4)
+	cfg := &config.Config{}
+	wd := watchdog.New()
+	handler := NewAdminHandler(db, cfg, settings.NewStore(db, cfg), wd)
+
+	getStats := func() models.WorkerStats {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/admin/db-stats", nil)
+
+		handler.GetDBStats(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp models.DBStatsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.Workers
+	}
+
+	wd.WorkerStarted()
+	finished := wd.RequestStarted("dashboard")
+
+	stats := getStats()
+	if stats.ActiveWorkers != 1 || stats.InFlightRequests != 1 || stats.OldestRequestKind != "dashboard" {
+		t.Fatalf("unexpected busy worker stats: %+v", stats)
+	}
+
+	wd.WorkerFinished()
+	finished()
+
+	stats = getStats()
+	if stats.ActiveWorkers != 0 || stats.InFlightRequests != 0 || stats.OldestRequestKind != "" {
+		t.Fatalf("unexpected idle worker stats: %+v", stats)
+	}
+}
+
+func TestUpdateSettings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantWarning bool
+		wantSaved   bool
+	}{
+		// The fake database stores the values but cannot list them back
+		{"saved but not reloaded", `{"settings": {"low_fuel_threshold": 20}}`, http.StatusOK, true, true},
+		{"out of range", `{"settings": {"low_fuel_threshold": 120}}`, http.StatusBadRequest, false, false},
+		{"unknown key", `{"settings": {"nope": 1}}`, http.StatusBadRequest, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			handler := NewAdminHandler(db, &config.Config{}, settings.NewStore(db, &config.Config{}), nil)
+
+			router := gin.New()
+			router.PUT("/settings", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+			}, handler.UpdateSettings)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/settings", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if saved := len(fake.execs) > 0; saved != tt.wantSaved {
+				t.Errorf("saved = %t, want %t (execs %q)", saved, tt.wantSaved, fake.execs)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp models.SettingsResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if (resp.Warning != "") != tt.wantWarning {
+				t.Errorf("warning = %q, want one: %t", resp.Warning, tt.wantWarning)
+			}
+			for _, setting := range resp.Settings {
+				if setting.Key == settings.LowFuelThreshold && (setting.Value != 20 || setting.Source != "database") {
+					t.Errorf("%s = %g from %s, want 20 from database", setting.Key, setting.Value, setting.Source)
+				}
+			}
+		})
+	}
+}
+
+func TestGetConfigLeavesSecretsOut(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := newFakeDB(t, 1)
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Host: "db.internal", Name: "fuel", User: "db-user-value", Password: "db-password-value"},
+		SSH:      config.SSHConfig{Host: "bastion.internal", Username: "ssh-user-value", Password: "ssh-password-value"},
+		JWT:      config.JWTConfig{Secret: "jwt-secret-value", IntrospectionAPIKey: "introspection-key-value", ExpiresIn: "24h"},
+		SMTP:     config.SMTPConfig{Host: "smtp.internal", Username: "smtp-user-value", Password: "smtp-password-value"},
+	}
+	handler := NewAdminHandler(db, cfg, settings.NewStore(db, cfg), watchdog.New())
+
+	router := gin.New()
+	router.GET("/admin/config", handler.GetConfig)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	body := recorder.Body.String()
+
+	for _, secret := range []string{
+		"db-user-value", "db-password-value", "ssh-user-value", "ssh-password-value",
+		"jwt-secret-value", "introspection-key-value", "smtp-user-value", "smtp-password-value",
+	} {
+		if strings.Contains(body, secret) {
+			t.Errorf("config response contains %q", secret)
+		}
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	// No field may even be named after a credential, empty or not
+	var walk func(path string, value interface{})
+	walk = func(path string, value interface{}) {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, field := range fields {
+			lower := strings.ToLower(key)
+			if strings.Contains(lower, "password") || strings.Contains(lower, "secret") || strings.Contains(lower, "username") || lower == "user" || strings.HasSuffix(lower, "apikey") {
+				t.Errorf("config response has credential field %s.%s", path, key)
+			}
+			walk(path+"."+key, field)
+		}
+	}
+	walk("config", response)
+
+	// Whether a secret is set is reported, and plain settings come through
+	auth := response["auth"].(map[string]interface{})
+	if auth["introspectionKeyConfigured"] != true {
+		t.Errorf("auth.introspectionKeyConfigured = %v, want true", auth["introspectionKeyConfigured"])
+	}
+	dbInfo := response["database"].(map[string]interface{})
+	if dbInfo["host"] != "db.internal" || dbInfo["name"] != "fuel" {
+		t.Errorf("database = %v, want host db.internal and name fuel", dbInfo)
+	}
+}
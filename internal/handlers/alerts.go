@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"fuel-monitor-api/internal/alerts"
+	"fuel-monitor-api/internal/middleware"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AcknowledgeAlert records that the user has handled a site's current alert.
+// Webhooks are not sent for the alert again until it clears and recurs.
+func (h *DashboardHandler) AcknowledgeAlert(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	var req models.AcknowledgeAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	site, err := h.DB.GetSiteForUser(req.SiteID, user.ID, user.Role)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get site %d for %s: %v", req.SiteID, user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if site == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Site not found",
+		})
+		return
+	}
+
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get site types, using default sensor names: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
+	// Only the alert the site is currently in can be acknowledged
+	siteType := siteTypeFor(site, siteTypes)
+	reading, err := h.DB.GetLatestDeviceReading(site.DeviceID, siteType.Names())
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get reading for site %s: %v", site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	status := alerts.CurrentStatus(site, reading, siteType, h.Settings.Float(settings.LowFuelThreshold), h.Escalation.Critical(site.ID), time.Now())
+	if status != req.AlertType {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Message: "Site has no active " + req.AlertType + " alert",
+		})
+		return
+	}
+
+	ack, err := h.DB.CreateAlertAcknowledgement(site.ID, req.AlertType, req.Note, user.ID)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to acknowledge %s alert for site %d: %v", req.AlertType, site.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if ack == nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Message: "Alert already acknowledged",
+		})
+		return
+	}
+	ack.Username = user.Username
+
+	middleware.Log(c).Infof("%s acknowledged %s alert for site %s", user.Username, req.AlertType, site.Name)
+	c.JSON(http.StatusCreated, ack)
+}
+
+// GetActiveAlerts lists the current alerts of the user's sites from their
+// realtime readings, with whether each has been acknowledged. Sites in
+// maintenance or with alerting disabled, and sites whose reading could not be
+// queried, are left out. It only reads: acknowledgements of resolved alerts are
+// cleared by the webhook notifier's polls and by bulk acknowledgement.
+func (h *DashboardHandler) GetActiveAlerts(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	finished := h.Watchdog.RequestStarted("active_alerts")
+	defer finished()
+
+	sites, err := h.DB.GetDashboardSitesForUser(user.ID, user.Role)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get site types, using default expected sensors: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
+	ctx := c.Request.Context()
+	collected, err := h.getAggressiveParallelRealTimeReadings(ctx, sites, siteTypes, 0)
+	if ctx.Err() != nil {
+		return
+	}
+	if err == errCollectTimeout {
+		c.JSON(http.StatusGatewayTimeout, models.ErrorResponse{
+			Message: "Timed out waiting for readings",
+		})
+		return
+	}
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get readings: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get readings",
+		})
+		return
+	}
+
+	current := currentAlertStatuses(sites, collected, time.Now())
+	readingsBySite := make(map[int]*models.SiteWithReadings, len(collected.results))
+	for _, siteWithReadings := range collected.results {
+		readingsBySite[siteWithReadings.ID] = siteWithReadings
+	}
+
+	acks, err := h.activeAcknowledgements()
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get alert acknowledgements: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get alert acknowledgements",
+		})
+		return
+	}
+
+	response := models.ActiveAlertsResponse{Alerts: []models.ActiveAlert{}}
+	for _, site := range sites {
+		status, ok := current[site.ID]
+		if !ok || status == "normal" || !site.AlertsEnabled {
+			continue
+		}
+
+		alert := models.ActiveAlert{
+			SiteID:    site.ID,
+			SiteName:  site.Name,
+			DeviceID:  site.DeviceID,
+			AlertType: status,
+		}
+		if siteWithReadings, ok := readingsBySite[site.ID]; ok {
+			level := siteWithReadings.FuelLevelPercentage
+			alert.FuelLevelPercentage = &level
+		}
+		if ack, ok := acks[site.ID]; ok && ack.AlertType == status {
+			alert.Acknowledged = true
+			alert.Acknowledgement = ack
+			response.Acknowledged++
+		}
+		response.Alerts = append(response.Alerts, alert)
+	}
+
+	// Unacknowledged alerts first, then by site name
+	sort.SliceStable(response.Alerts, func(i, j int) bool {
+		if response.Alerts[i].Acknowledged != response.Alerts[j].Acknowledged {
+			return !response.Alerts[i].Acknowledged
+		}
+		return response.Alerts[i].SiteName < response.Alerts[j].SiteName
+	})
+	response.Total = len(response.Alerts)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PreviewLowFuelAlerts evaluates the accessible sites' realtime readings against
+// a proposed global low fuel threshold (?threshold=, percent) without storing
+// it, listing the sites that would be low on fuel. Sites' own thresholds and low
+// fuel modes still apply; sites in maintenance or with alerting disabled are left out.
+func (h *DashboardHandler) PreviewLowFuelAlerts(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	threshold, err := strconv.ParseFloat(c.Query("threshold"), 64)
+	if err != nil || threshold < 0 || threshold > 100 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "threshold must be a percentage between 0 and 100",
+		})
+		return
+	}
+
+	finished := h.Watchdog.RequestStarted("alert_preview")
+	defer finished()
+
+	sites, err := h.DB.GetDashboardSitesForUser(user.ID, user.Role)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get site types, using default expected sensors: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
+	ctx := c.Request.Context()
+	collected, err := h.getAggressiveParallelRealTimeReadings(ctx, sites, siteTypes, 0)
+	if ctx.Err() != nil {
+		return
+	}
+	if err == errCollectTimeout {
+		c.JSON(http.StatusGatewayTimeout, models.ErrorResponse{
+			Message: "Timed out waiting for readings",
+		})
+		return
+	}
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get readings: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get readings",
+		})
+		return
+	}
+
+	response := models.AlertPreviewResponse{
+		Threshold:        threshold,
+		CurrentThreshold: h.Settings.Float(settings.LowFuelThreshold),
+		Sites:            []models.AlertPreviewSite{},
+	}
+	for _, site := range collected.results {
+		if site.AlertStatus == "maintenance" || !site.AlertsEnabled || site.LatestReading == nil {
+			continue
+		}
+
+		currentlyLow := site.AlertStatus == models.AlertLowFuel || site.AlertStatus == models.AlertCriticalFuel
+		if !alerts.LowFuel(site.Site, site.LatestReading, threshold) {
+			if currentlyLow {
+				response.NoLongerAlerting++
+			}
+			continue
+		}
+
+		preview := models.AlertPreviewSite{
+			SiteID:              site.ID,
+			SiteName:            site.Name,
+			DeviceID:            site.DeviceID,
+			FuelLevelPercentage: site.FuelLevelPercentage,
+			CurrentStatus:       site.AlertStatus,
+			NewlyAlerting:       !currentlyLow,
+			SiteThreshold:       site.LowFuelThreshold != nil,
+		}
+		if preview.NewlyAlerting {
+			response.NewlyAlerting++
+		}
+		response.Sites = append(response.Sites, preview)
+	}
+
+	// Newly alerting sites first, then by fuel level
+	sort.SliceStable(response.Sites, func(i, j int) bool {
+		if response.Sites[i].NewlyAlerting != response.Sites[j].NewlyAlerting {
+			return response.Sites[i].NewlyAlerting
+		}
+		return response.Sites[i].FuelLevelPercentage < response.Sites[j].FuelLevelPercentage
+	})
+	response.LowFuel = len(response.Sites)
+
+	middleware.Log(c).Infof("%s previewed low fuel threshold %.1f: %d sites low, %d newly", user.Username, threshold, response.LowFuel, response.NewlyAlerting)
+	c.JSON(http.StatusOK, response)
+}
+
+// BulkAcknowledgeAlerts acknowledges the alertType alert of several sites at
+// once, e.g. after a regional outage. Without siteIds every accessible site
+// currently in that alert is acknowledged. Listed sites that are not accessible
+// or not in the alert are reported back rather than failing the request.
+func (h *DashboardHandler) BulkAcknowledgeAlerts(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	var req models.BulkAcknowledgeAlertsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	finished := h.Watchdog.RequestStarted("bulk_acknowledge")
+	defer finished()
+
+	sites, err := h.DB.GetDashboardSitesForUser(user.ID, user.Role)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	response := models.BulkAcknowledgeAlertsResponse{
+		AlertType:   req.AlertType,
+		SiteIDs:     []int{},
+		NotAlerting: []int{},
+		NotFound:    []int{},
+		Unavailable: []int{},
+	}
+
+	// Narrow down to the listed sites, in the order given
+	if len(req.SiteIDs) > 0 {
+		accessible := make(map[int]*models.Site, len(sites))
+		for _, site := range sites {
+			accessible[site.ID] = site
+		}
+		seen := make(map[int]bool, len(req.SiteIDs))
+		listed := make([]*models.Site, 0, len(req.SiteIDs))
+		for _, siteID := range req.SiteIDs {
+			if seen[siteID] {
+				continue
+			}
+			seen[siteID] = true
+			if site, ok := accessible[siteID]; ok {
+				listed = append(listed, site)
+			} else {
+				response.NotFound = append(response.NotFound, siteID)
+			}
+		}
+		sites = listed
+	}
+
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get site types, using default expected sensors: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
+	ctx := c.Request.Context()
+	collected, err := h.getAggressiveParallelRealTimeReadings(ctx, sites, siteTypes, 0)
+	if ctx.Err() != nil {
+		return
+	}
+	if err == errCollectTimeout {
+		c.JSON(http.StatusGatewayTimeout, models.ErrorResponse{
+			Message: "Timed out waiting for readings",
+		})
+		return
+	}
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get readings: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get readings",
+		})
+		return
+	}
+
+	// Only the alert a site is currently in can be acknowledged
+	current := currentAlertStatuses(sites, collected, time.Now())
+	alerting := []int{}
+	for _, site := range sites {
+		switch {
+		case current[site.ID] == req.AlertType && site.AlertsEnabled:
+			alerting = append(alerting, site.ID)
+		case len(req.SiteIDs) == 0:
+			// Only listed sites are reported back
+		case collected.failed[site.ID]:
+			response.Unavailable = append(response.Unavailable, site.ID)
+		default:
+			response.NotAlerting = append(response.NotAlerting, site.ID)
+		}
+	}
+
+	// Earlier acknowledgements of alerts that have since cleared must not block
+	// new ones. Sites whose status is unknown keep theirs.
+	if _, err := h.DB.ClearResolvedAlertAcknowledgements(current); err != nil {
+		middleware.Log(c).Errorf("Failed to clear resolved alert acknowledgements: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	acknowledged, err := h.DB.CreateAlertAcknowledgements(alerting, req.AlertType, req.Note, user.ID)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to bulk acknowledge %s alerts: %v", req.AlertType, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	sort.Ints(acknowledged)
+
+	response.SiteIDs = acknowledged
+	response.Acknowledged = len(acknowledged)
+	response.AlreadyAcknowledged = len(alerting) - len(acknowledged)
+
+	middleware.Log(c).Infof("%s bulk acknowledged %s alerts: %d sites, %d already acknowledged", user.Username, req.AlertType, response.Acknowledged, response.AlreadyAcknowledged)
+	c.JSON(http.StatusOK, response)
+}
+
+// currentAlertStatuses maps each site not in maintenance to its current alert
+// status from its realtime readings. Sites without a fuel reading are offline;
+// sites whose reading could not be queried, or whose worker did not finish, are
+// left out since their status is unknown.
+func currentAlertStatuses(sites []*models.Site, collected collection, now time.Time) map[int]string {
+	current := make(map[int]string, len(sites))
+	for _, site := range sites {
+		if collected.done[site.ID] && !collected.failed[site.ID] && !site.InMaintenance(now) {
+			current[site.ID] = models.AlertOffline
+		}
+	}
+	for _, siteWithReadings := range collected.results {
+		if siteWithReadings.AlertStatus == "maintenance" {
+			delete(current, siteWithReadings.ID)
+		} else {
+			current[siteWithReadings.ID] = siteWithReadings.AlertStatus
+		}
+	}
+	return current
+}
+
+// activeAcknowledgements returns the active acknowledgements by site ID. Some
+// may belong to alerts that have since cleared; callers match them against the
+// current alert status.
+func (h *DashboardHandler) activeAcknowledgements() (map[int]*models.AlertAcknowledgement, error) {
+	acks, err := h.DB.GetActiveAlertAcknowledgements()
+	if err != nil {
+		return nil, err
+	}
+
+	bySite := make(map[int]*models.AlertAcknowledgement, len(acks))
+	for _, ack := range acks {
+		bySite[ack.SiteID] = ack
+	}
+	return bySite, nil
+}
+
+// markAcknowledged sets Acknowledged on the sites whose alert status has an
+// active acknowledgement
+func markAcknowledged(sitesWithReadings []*models.SiteWithReadings, acks []*models.AlertAcknowledgement) {
+	acked := make(map[int]map[string]bool, len(acks))
+	for _, ack := range acks {
+		if acked[ack.SiteID] == nil {
+			acked[ack.SiteID] = make(map[string]bool)
+		}
+		acked[ack.SiteID][ack.AlertType] = true
+	}
+
+	for _, site := range sitesWithReadings {
+		site.Acknowledged = acked[site.ID][site.AlertStatus]
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/alerts"
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCurrentAlertStatuses(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	site := func(id int, maintenance bool) *models.Site {
+		return &models.Site{ID: id, MaintenanceMode: maintenance}
+	}
+	withStatus := func(id int, status string) *models.SiteWithReadings {
+		return &models.SiteWithReadings{Site: &models.Site{ID: id}, AlertStatus: status}
+	}
+
+	tests := []struct {
+		name      string
+		sites     []*models.Site
+		collected collection
+		want      map[int]string
+	}{
+		{
+			name:  "statuses from readings",
+			sites: []*models.Site{site(1, false), site(2, false)},
+			collected: collection{
+				results: []*models.SiteWithReadings{withStatus(1, "normal"), withStatus(2, models.AlertLowFuel)},
+				done:    map[int]bool{1: true, 2: true},
+			},
+			want: map[int]string{1: "normal", 2: models.AlertLowFuel},
+		},
+		{
+			name:      "no fuel reading is offline",
+			sites:     []*models.Site{site(1, false)},
+			collected: collection{done: map[int]bool{1: true}},
+			want:      map[int]string{1: models.AlertOffline},
+		},
+		{
+			name:      "failed reading is unknown",
+			sites:     []*models.Site{site(1, false), site(2, false)},
+			collected: collection{done: map[int]bool{1: true, 2: true}, failed: map[int]bool{2: true}},
+			want:      map[int]string{1: models.AlertOffline},
+		},
+		{
+			name:      "unfinished worker is unknown",
+			sites:     []*models.Site{site(1, false)},
+			collected: collection{done: map[int]bool{}},
+			want:      map[int]string{},
+		},
+		{
+			name:  "maintenance is left out",
+			sites: []*models.Site{site(1, true), site(2, false)},
+			collected: collection{
+				results: []*models.SiteWithReadings{withStatus(2, "maintenance")},
+				done:    map[int]bool{1: true, 2: true},
+			},
+			want: map[int]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := currentAlertStatuses(tt.sites, tt.collected, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("currentAlertStatuses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcknowledgeSameAlertRepeatedly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Every site is offline: no device reports a fuel level
+	sites := []*models.Site{
+		{ID: 1, Name: "Site A", DeviceID: "simbisa-a", IsActive: true, AlertsEnabled: true},
+		{ID: 2, Name: "Site B", DeviceID: "simbisa-b", IsActive: true, AlertsEnabled: true},
+		{ID: 3, Name: "Site C", DeviceID: "simbisa-c", IsActive: true, AlertsEnabled: true},
+	}
+
+	db, fake := newFakeDB(t, 4)
+	dashboard := answerDashboard(nil, sites...)
+	// active emulates the unique index on active acknowledgements, which makes
+	// ON CONFLICT skip sites whose alert is already acknowledged
+	active := map[int]bool{}
+	at := time.Now()
+	fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "INSERT INTO alert_acknowledgements") && strings.Contains(query, "unnest"):
+			var values [][]driver.Value
+			for _, field := range strings.Split(strings.Trim(args[0].Value.(string), "{}"), ",") {
+				siteID, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, nil, fmt.Errorf("site IDs %v: %v", args[0].Value, err)
+				}
+				if !active[siteID] {
+					active[siteID] = true
+					values = append(values, []driver.Value{int64(siteID)})
+				}
+			}
+			return []string{"site_id"}, values, nil
+		case strings.Contains(query, "INSERT INTO alert_acknowledgements"):
+			siteID := int(args[0].Value.(int64))
+			if active[siteID] {
+				return nil, nil, nil
+			}
+			active[siteID] = true
+			return []string{"id", "site_id", "alert_type", "note", "acknowledged_by", "acknowledged_at", "cleared_at"}, [][]driver.Value{
+				{int64(len(active)), int64(siteID), args[1].Value, args[2].Value, args[3].Value, at, nil},
+			}, nil
+		}
+		return dashboard(query, args)
+	}
+	cfg := &config.Config{}
+	handler := NewDashboardHandler(db, cfg, settings.NewStore(db, cfg), alerts.NewFuelEscalation(time.Hour), watchdog.New())
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+	})
+	router.POST("/alerts/ack", handler.AcknowledgeAlert)
+	router.POST("/alerts/ack/bulk", handler.BulkAcknowledgeAlerts)
+
+	steps := []struct {
+		name       string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"first acknowledgement", "/alerts/ack", `{"siteId": 1, "alertType": "offline"}`, http.StatusCreated, `"siteId":1`},
+		{"same site again", "/alerts/ack", `{"siteId": 1, "alertType": "offline"}`, http.StatusConflict, "Alert already acknowledged"},
+		// Site 1 is skipped, and site 2 is acknowledged once though listed twice
+		{"bulk with repeats", "/alerts/ack/bulk", `{"siteIds": [1, 2, 2], "alertType": "offline"}`, http.StatusOK,
+			`{"alertType":"offline","acknowledged":1,"alreadyAcknowledged":1,"siteIds":[2],"notAlerting":[],"notFound":[],"unavailable":[]}`},
+		{"bulk of every site", "/alerts/ack/bulk", `{"alertType": "offline"}`, http.StatusOK,
+			`{"alertType":"offline","acknowledged":1,"alreadyAcknowledged":2,"siteIds":[3],"notAlerting":[],"notFound":[],"unavailable":[]}`},
+		{"bulk of every site again", "/alerts/ack/bulk", `{"alertType": "offline"}`, http.StatusOK,
+			`{"alertType":"offline","acknowledged":0,"alreadyAcknowledged":3,"siteIds":[],"notAlerting":[],"notFound":[],"unavailable":[]}`},
+	}
+
+	for _, step := range steps {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, step.target, strings.NewReader(step.body)))
+
+		if recorder.Code != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, recorder.Code, step.wantStatus, recorder.Body)
+		}
+		if !strings.Contains(recorder.Body.String(), step.wantBody) {
+			t.Errorf("%s: body = %s, want it to contain %s", step.name, recorder.Body, step.wantBody)
+		}
+	}
+
+	if len(active) != len(sites) {
+		t.Errorf("%d active acknowledgements, want %d", len(active), len(sites))
+	}
+}
@@ -30,14 +30,13 @@ func NewAuthHandler(db *database.DB, cfg *config.Config) *AuthHandler {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Message: "Invalid request format",
-		})
+		respondBindError(c, err, "Invalid request format")
 		return
 	}
 
-	// Get user from database
-	user, err := h.DB.GetUserByUsername(req.Username)
+	// Get user from database. Deactivated users are looked up too so that, once
+	// their password checks out, they can be told their account is disabled.
+	user, err := h.DB.GetUserByUsernameIncludingInactive(req.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Message: "Database error",
@@ -46,24 +45,33 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	if user == nil {
+		h.recordFailedLogin(c, nil, req.Username, "unknown_user")
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Message: "Invalid credentials",
 		})
 		return
 	}
 
-	if !user.IsActive {
+	// Verify password before revealing anything about the account
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		h.recordFailedLogin(c, &user.ID, user.Username, "invalid_password")
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-			Message: "Account is inactive",
+			Message: "Invalid credentials",
 		})
 		return
 	}
 
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-			Message: "Invalid credentials",
-		})
+	if !user.IsActive {
+		h.recordFailedLogin(c, &user.ID, user.Username, "inactive")
+		if h.Config.JWT.ReportDisabledAccounts {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Message: "Account disabled. Contact your administrator.",
+			})
+		} else {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Message: "Invalid credentials",
+			})
+		}
 		return
 	}
 
@@ -86,12 +94,45 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	// Update user's last login for response
 	user.LastLogin = &now
 
+	h.recordLogin(c, &models.LoginEvent{
+		UserID:    &user.ID,
+		Username:  user.Username,
+		Success:   true,
+		CreatedAt: now,
+	})
+
 	c.JSON(http.StatusOK, models.LoginResponse{
 		User:  user.ToResponse(),
 		Token: token,
 	})
 }
 
+// recordFailedLogin records a rejected login attempt when failed logins are audited
+func (h *AuthHandler) recordFailedLogin(c *gin.Context, userID *int, username, reason string) {
+	if !h.Config.Audit.LogFailedLogins {
+		return
+	}
+
+	h.recordLogin(c, &models.LoginEvent{
+		UserID:    userID,
+		Username:  username,
+		Success:   false,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	})
+}
+
+// recordLogin fills in the request's client details and stores the login event.
+// Failures are logged but never fail the login request.
+func (h *AuthHandler) recordLogin(c *gin.Context, event *models.LoginEvent) {
+	event.IPAddress = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+
+	if err := h.DB.RecordLoginEvent(event); err != nil {
+		middleware.Log(c).Warnf("Failed to record login event for %s: %v", event.Username, err)
+	}
+}
+
 // Logout handles user logout
 func (h *AuthHandler) Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -122,6 +163,103 @@ func (h *AuthHandler) ValidateToken(c *gin.Context) {
 	})
 }
 
+// Introspect validates arbitrary supplied tokens for gateways, mirroring OAuth
+// token introspection. Invalid tokens are reported as inactive, not as errors,
+// as are tokens whose user has since been deactivated or changed role.
+func (h *AuthHandler) Introspect(c *gin.Context) {
+	var req models.IntrospectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	// Each user is looked up once per request, however many tokens they have
+	users := make(map[int]*models.User)
+
+	if len(req.Tokens) > 0 {
+		if len(req.Tokens) > 100 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "At most 100 tokens can be introspected at once",
+			})
+			return
+		}
+
+		results := make([]models.IntrospectionResult, len(req.Tokens))
+		for i, token := range req.Tokens {
+			result, err := h.introspectToken(token, users)
+			if err != nil {
+				middleware.Log(c).Errorf("Failed to introspect token: %v", err)
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+					Message: "Internal server error",
+				})
+				return
+			}
+			results[i] = result
+		}
+		c.JSON(http.StatusOK, models.IntrospectBatchResponse{Results: results})
+		return
+	}
+
+	if req.Token == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "token is required",
+		})
+		return
+	}
+
+	result, err := h.introspectToken(req.Token, users)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to introspect token: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// introspectToken parses a single token into an introspection result and checks
+// its user against the database, caching looked-up users in users
+func (h *AuthHandler) introspectToken(tokenString string, users map[int]*models.User) (models.IntrospectionResult, error) {
+	claims, err := middleware.ParseToken(tokenString, h.Config.JWT.Secret)
+	if err != nil {
+		return models.IntrospectionResult{
+			Active: false,
+			Reason: middleware.TokenInvalidReason(err),
+		}, nil
+	}
+
+	user, ok := users[claims.ID]
+	if !ok {
+		user, err = h.DB.GetUserByID(claims.ID)
+		if err != nil {
+			return models.IntrospectionResult{}, err
+		}
+		users[claims.ID] = user
+	}
+	switch {
+	case user == nil:
+		return models.IntrospectionResult{Active: false, Reason: "user_inactive"}, nil
+	case user.Role != claims.Role:
+		return models.IntrospectionResult{Active: false, Reason: "role_changed"}, nil
+	}
+
+	result := models.IntrospectionResult{
+		Active:   true,
+		UserID:   claims.ID,
+		Username: claims.Username,
+		Role:     claims.Role,
+	}
+	if claims.IssuedAt != nil {
+		result.IssuedAt = &claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		result.ExpiresAt = &claims.ExpiresAt.Time
+	}
+
+	return result, nil
+}
+
 // generateToken creates a JWT token for the user
 func (h *AuthHandler) generateToken(user *models.User) (string, error) {
 	// Calculate expiration time (24 hours from now)
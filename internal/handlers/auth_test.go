@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginIgnoresUsernameCase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	stored := &models.User{ID: 1, Username: "Admin", Email: "admin@example.com", Password: string(hash),
+		Role: "admin", FullName: "Admin", IsActive: true, CreatedAt: time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name       string
+		username   string
+		wantStatus int
+	}{
+		{"stored casing", "Admin", http.StatusOK},
+		{"lower case", "admin", http.StatusOK},
+		{"mixed case", "aDMIn", http.StatusOK},
+		{"different name", "admins", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			// Emulate LOWER(username) = LOWER($1) so the handler is tested
+			// against the comparison the database makes
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "LOWER(username) = LOWER($1)") {
+					return nil, nil, fmt.Errorf("unexpected query: %s", query)
+				}
+				if !strings.EqualFold(args[0].Value.(string), stored.Username) {
+					return userRows()
+				}
+				return userRows(stored)
+			}
+			handler := NewAuthHandler(db, &config.Config{JWT: config.JWTConfig{Secret: "test-secret"}})
+
+			router := gin.New()
+			router.POST("/auth/login", handler.Login)
+
+			body := fmt.Sprintf(`{"username":%q,"password":"secret1"}`, tt.username)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response models.LoginResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			// The account keeps the casing it was created with
+			if response.User.Username != "Admin" {
+				t.Errorf("username = %q, want %q", response.User.Username, "Admin")
+			}
+			if response.Token == "" {
+				t.Error("no token issued")
+			}
+		})
+	}
+}
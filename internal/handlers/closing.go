@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/logger"
+	"fuel-monitor-api/internal/middleware"
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClosingHandler struct {
+	DB     *database.DB
+	Config *config.Config
+}
+
+func NewClosingHandler(db *database.DB, cfg *config.Config) *ClosingHandler {
+	return &ClosingHandler{
+		DB:     db,
+		Config: cfg,
+	}
+}
+
+// RebuildDailyClosing regenerates daily_closing_readings for a date from sensor data (admin only)
+func (h *ClosingHandler) RebuildDailyClosing(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	var req models.RebuildClosingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	targetDate, err := parseDate(req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid date format. Use DD/MM/YYYY or YYYY-MM-DD",
+		})
+		return
+	}
+
+	closing := h.Config.Closing
+	if req.Cutoff != "" {
+		closing.Cutoff = req.Cutoff
+		if err := closing.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "Invalid cutoff format. Use HH:MM",
+			})
+			return
+		}
+	}
+
+	dateString := targetDate.Format("2006-01-02")
+	dayStart := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, time.Local)
+	cutoff := closing.CutoffOn(targetDate)
+
+	sites, err := h.DB.GetAllSites()
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for closing rebuild: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	// Restrict to the requested sites when provided
+	if len(req.SiteIds) > 0 {
+		requested := make(map[int]bool, len(req.SiteIds))
+		for _, id := range req.SiteIds {
+			requested[id] = true
+		}
+
+		var filtered []*models.Site
+		for _, site := range sites {
+			if requested[site.ID] {
+				filtered = append(filtered, site)
+			}
+		}
+
+		if len(filtered) != len(requested) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "One or more site IDs do not exist",
+			})
+			return
+		}
+		sites = filtered
+	}
+
+	middleware.Log(c).Infof("Rebuilding daily closing for %s (cutoff %s) on %d sites, requested by %s",
+		dateString, cutoff.Format(time.RFC3339), len(sites), user.Username)
+
+	// Site types map sensors to the names their devices report them under
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get site types, using default sensor names: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
+	results := h.rebuildSitesInBatches(sites, models.SiteSensorNames(sites, siteTypes), dayStart, cutoff)
+
+	summary := models.RebuildClosingSummary{TotalSites: len(sites)}
+	for _, result := range results {
+		switch result.Status {
+		case "WRITTEN":
+			summary.RowsWritten++
+		case "NO_DATA":
+			summary.NoDataSites++
+		default:
+			summary.ErrorSites++
+		}
+	}
+
+	middleware.Log(c).Infof("Daily closing rebuild completed for %s: %+v", dateString, summary)
+
+	c.JSON(http.StatusOK, models.RebuildClosingResponse{
+		Date:        dateString,
+		Cutoff:      cutoff.Format(time.RFC3339),
+		ProcessedAt: time.Now().Format(time.RFC3339),
+		Sites:       results,
+		Summary:     summary,
+	})
+}
+
+// rebuildSitesInBatches rebuilds closing snapshots in parallel batches. names
+// maps lowercase device IDs to the sensor names they report under.
+func (h *ClosingHandler) rebuildSitesInBatches(sites []*models.Site, names map[string]models.SensorNames, dayStart, cutoff time.Time) []models.RebuildClosingSiteResult {
+	const batchSize = 10
+	allResults := []models.RebuildClosingSiteResult{}
+	var resultMutex sync.Mutex
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < len(sites); i += batchSize {
+		end := i + batchSize
+		if end > len(sites) {
+			end = len(sites)
+		}
+		batch := sites[i:end]
+
+		wg.Add(1)
+		go func(batchSites []*models.Site) {
+			defer wg.Done()
+
+			var batchResults []models.RebuildClosingSiteResult
+			for _, site := range batchSites {
+				batchResults = append(batchResults, h.rebuildSingleSite(site, names[strings.ToLower(site.DeviceID)], dayStart, cutoff))
+			}
+
+			resultMutex.Lock()
+			allResults = append(allResults, batchResults...)
+			resultMutex.Unlock()
+		}(batch)
+	}
+
+	wg.Wait()
+
+	return allResults
+}
+
+// rebuildSingleSite rebuilds the closing snapshot for a single site
+func (h *ClosingHandler) rebuildSingleSite(site *models.Site, names models.SensorNames, dayStart, cutoff time.Time) models.RebuildClosingSiteResult {
+	result := models.RebuildClosingSiteResult{
+		SiteID:   site.ID,
+		SiteName: site.Name,
+		DeviceID: site.DeviceID,
+	}
+
+	reading, err := h.DB.RebuildDailyClosingReading(site.ID, site.DeviceID, dayStart, cutoff, names)
+	if err != nil {
+		logger.Errorf("Error rebuilding daily closing for site %s: %v", site.Name, err)
+		result.Status = "ERROR"
+		result.Error = err.Error()
+		return result
+	}
+
+	if reading == nil {
+		result.Status = "NO_DATA"
+		return result
+	}
+
+	result.Status = "WRITTEN"
+	result.FuelLevel = reading.FuelLevel
+	result.FuelVolume = reading.FuelVolume
+	result.Temperature = reading.Temperature
+	result.CapturedAt = &reading.CapturedAt
+	return result
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRebuildDailyClosingValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid date", `{"date": "2024-13-40"}`},
+		{"invalid cutoff", `{"date": "2024-03-01", "cutoff": "25:00"}`},
+		{"cutoff without minutes", `{"date": "2024-03-01", "cutoff": "23"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No database: the request must be rejected before it is used
+			handler := NewClosingHandler(nil, &config.Config{})
+			router := gin.New()
+			router.POST("/closing/rebuild", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+			}, handler.RebuildDailyClosing)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/closing/rebuild", strings.NewReader(tt.body)))
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
@@ -1,26 +1,42 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"fuel-monitor-api/internal/config"
 	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/logger"
 	"fuel-monitor-api/internal/middleware"
 	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
 
 	"github.com/gin-gonic/gin"
 )
 
 type CumulativeHandler struct {
-	DB *database.DB
+	DB       *database.DB
+	Config   *config.Config
+	Settings *settings.Store
+	Watchdog *watchdog.Watchdog
 }
 
-func NewCumulativeHandler(db *database.DB) *CumulativeHandler {
+func NewCumulativeHandler(db *database.DB, cfg *config.Config, store *settings.Store, wd *watchdog.Watchdog) *CumulativeHandler {
 	return &CumulativeHandler{
-		DB: db,
+		DB:       db,
+		Config:   cfg,
+		Settings: store,
+		Watchdog: wd,
 	}
 }
 
@@ -36,14 +52,12 @@ func (h *CumulativeHandler) GetCumulativeReadings(c *gin.Context) {
 
 	var req models.CumulativeReadingsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Message: "Invalid request format",
-		})
+		respondBindError(c, err, "Invalid request format")
 		return
 	}
 
 	// Parse target date
-	targetDate, err := h.parseDate(req.Date)
+	targetDate, err := parseDate(req.Date)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Message: "Invalid date format. Use DD/MM/YYYY or YYYY-MM-DD",
@@ -51,13 +65,21 @@ func (h *CumulativeHandler) GetCumulativeReadings(c *gin.Context) {
 		return
 	}
 
+	// Future dates have no readings and would only store empty rows
+	if targetDate.Format("2006-01-02") > today().Format("2006-01-02") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "date cannot be in the future",
+		})
+		return
+	}
+
 	dateString := targetDate.Format("2006-01-02")
-	log.Printf("Processing cumulative readings for %s requested by %s", dateString, user.Username)
+	middleware.Log(c).Infof("Processing cumulative readings for %s requested by %s", dateString, user.Username)
 
 	// Get user's accessible sites
 	sites, err := h.DB.GetSitesForUser(user.ID, user.Role)
 	if err != nil {
-		log.Printf("Failed to get sites for user %s: %v", user.Username, err)
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Message: "Failed to get sites",
 		})
@@ -88,27 +110,17 @@ func (h *CumulativeHandler) GetCumulativeReadings(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Processing %d sites for date %s", len(sites), dateString)
+	middleware.Log(c).Debugf("Processing %d sites for date %s", len(sites), dateString)
 
-	// Check for existing cumulative readings (for status determination only)
-	existingReadings, err := h.DB.GetExistingCumulativeReadings(dateString, sites)
+	results, err := h.processSites(sites, targetDate, dateString)
 	if err != nil {
-		log.Printf("Failed to get existing readings: %v", err)
+		middleware.Log(c).Errorf("Failed to get existing readings: %v", err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Message: "Failed to check existing readings",
 		})
 		return
 	}
 
-	// Create map of existing readings for status determination
-	existingBySiteID := make(map[int]*models.CumulativeReading)
-	for _, reading := range existingReadings {
-		existingBySiteID[reading.SiteID] = reading
-	}
-
-	// Process sites in parallel batches
-	results := h.processSitesInBatches(sites, existingBySiteID, targetDate, dateString)
-
 	// Calculate summary
 	summary := h.calculateSummary(results, len(sites))
 
@@ -123,16 +135,52 @@ func (h *CumulativeHandler) GetCumulativeReadings(c *gin.Context) {
 		Summary: summary,
 	}
 
-	log.Printf("Cumulative readings completed for %s: %+v", dateString, summary)
+	middleware.Log(c).Infof("Cumulative readings completed for %s: %+v", dateString, summary)
 
 	// Ensure response is sent
 	c.Header("Content-Type", "application/json")
 	c.JSON(http.StatusOK, response)
-	log.Printf("Response sent successfully for %s", dateString)
+	middleware.Log(c).Debugf("Response sent successfully for %s", dateString)
+}
+
+// processSites calculates and saves the cumulative readings of the sites for one date.
+// It fails only when the existing readings cannot be checked; per-site failures are
+// reported in the results.
+func (h *CumulativeHandler) processSites(sites []*models.Site, targetDate time.Time, dateString string) ([]models.CumulativeSiteResult, error) {
+	finished := h.Watchdog.RequestStarted("cumulative")
+	defer finished()
+
+	// Check for existing cumulative readings (for status determination only)
+	existingReadings, err := h.DB.GetExistingCumulativeReadings(dateString, sites)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create map of existing readings for status determination
+	existingBySiteID := make(map[int]*models.CumulativeReading)
+	for _, reading := range existingReadings {
+		existingBySiteID[reading.SiteID] = reading
+	}
+
+	// Site types tell the fuel calculation which sites have no generator
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		logger.Warnf("Failed to get site types, assuming every site has a generator: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
+	// Process sites in parallel batches
+	return h.processSitesInBatches(sites, existingBySiteID, siteTypes, targetDate, dateString), nil
+}
+
+// includeInactive reports whether ?includeInactive=true asks a report to cover
+// deactivated sites' stored history as well
+func includeInactive(c *gin.Context) bool {
+	return c.Query("includeInactive") == "true"
 }
 
 // parseDate handles both DD/MM/YYYY and YYYY-MM-DD formats
-func (h *CumulativeHandler) parseDate(dateStr string) (time.Time, error) {
+func parseDate(dateStr string) (time.Time, error) {
 	if dateStr == "" {
 		now := time.Now()
 		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), nil
@@ -147,95 +195,355 @@ func (h *CumulativeHandler) parseDate(dateStr string) (time.Time, error) {
 	return time.Parse("2006-01-02", dateStr)
 }
 
+// parseOptionalDate parses the date query parameter param, returning nil when it
+// is absent. An invalid date is answered with 400 and ok false.
+func parseOptionalDate(c *gin.Context, param string) (date *time.Time, ok bool) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, true
+	}
+
+	parsed, err := parseDate(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: fmt.Sprintf("Invalid %s format. Use DD/MM/YYYY or YYYY-MM-DD", param),
+		})
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// parseDateRange reads the required startDate and optional endDate (defaulting
+// to startDate) query parameters, writing a 400 response when either is invalid
+// or the range starts in the future. An endDate after today is capped at today.
+func parseDateRange(c *gin.Context) (startDate, endDate time.Time, ok bool) {
+	startDateStr := c.Query("startDate")
+	if startDateStr == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "startDate parameter is required",
+		})
+		return startDate, endDate, false
+	}
+
+	startDate, err := parseDate(startDateStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid startDate format. Use DD/MM/YYYY or YYYY-MM-DD",
+		})
+		return startDate, endDate, false
+	}
+
+	endDate = startDate
+	if endDateStr := c.Query("endDate"); endDateStr != "" {
+		endDate, err = parseDate(endDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "Invalid endDate format. Use DD/MM/YYYY or YYYY-MM-DD",
+			})
+			return startDate, endDate, false
+		}
+	}
+
+	// Ranges may not start in the future; a future end is capped at today
+	todayDate := today()
+	if startDate.Format("2006-01-02") > todayDate.Format("2006-01-02") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "startDate cannot be in the future",
+		})
+		return startDate, endDate, false
+	}
+	if endDate.Format("2006-01-02") > todayDate.Format("2006-01-02") {
+		endDate = todayDate
+	}
+
+	return startDate, endDate, true
+}
+
+// today returns the start of the current UTC day. Cumulative days are UTC days
+// (see database.dayBounds), and the dates parseDate returns are UTC as well.
+func today() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// processBatchWorkers bounds how many batches are processed at once. Each batch
+// runs its fuel and power queries in parallel and may hold a transaction, so
+// this keeps processing well within the connection pool.
+const processBatchWorkers = 4
+
 // processSitesInBatches processes sites in parallel batches
-func (h *CumulativeHandler) processSitesInBatches(sites []*models.Site, existingReadings map[int]*models.CumulativeReading, targetDate time.Time, dateString string) []models.CumulativeSiteResult {
+func (h *CumulativeHandler) processSitesInBatches(sites []*models.Site, existingReadings map[int]*models.CumulativeReading, siteTypes map[int]*models.SiteType, targetDate time.Time, dateString string) []models.CumulativeSiteResult {
 	const batchSize = 10
-	var allResults []models.CumulativeSiteResult
+	allResults := []models.CumulativeSiteResult{}
 	var resultMutex sync.Mutex
 
+	forEachBatch(sites, batchSize, processBatchWorkers, func(batchSites []*models.Site) {
+		h.Watchdog.WorkerStarted()
+		defer h.Watchdog.WorkerFinished()
+
+		batchResults := h.processBatch(batchSites, existingReadings, siteTypes, targetDate, dateString)
+
+		resultMutex.Lock()
+		allResults = append(allResults, batchResults...)
+		resultMutex.Unlock()
+	})
+
+	// Sort by fuel consumed (highest first)
+	h.sortResultsByFuelConsumed(allResults)
+
+	return allResults
+}
+
+// forEachBatch splits sites into batches of batchSize and calls fn for each,
+// running at most workers batches at once. It returns once every batch is done.
+func forEachBatch(sites []*models.Site, batchSize, workers int, fn func(batch []*models.Site)) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	batches := make(chan []*models.Site)
 	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				fn(batch)
+			}
+		}()
+	}
 
 	for i := 0; i < len(sites); i += batchSize {
 		end := i + batchSize
 		if end > len(sites) {
 			end = len(sites)
 		}
-		batch := sites[i:end]
+		batches <- sites[i:end]
+	}
+	close(batches)
 
-		wg.Add(1)
-		go func(batchSites []*models.Site) {
-			defer wg.Done()
+	wg.Wait()
+}
 
-			batchResults := h.processBatch(batchSites, existingReadings, targetDate, dateString)
+// processBatch processes a batch of sites
+func (h *CumulativeHandler) processBatch(sites []*models.Site, existingReadings map[int]*models.CumulativeReading, siteTypes map[int]*models.SiteType, targetDate time.Time, dateString string) []models.CumulativeSiteResult {
+	if h.Config.Cumulative.TransactionalBatches {
+		return h.processBatchInTransaction(sites, existingReadings, siteTypes, targetDate, dateString)
+	}
 
-			resultMutex.Lock()
-			allResults = append(allResults, batchResults...)
-			resultMutex.Unlock()
-		}(batch)
+	results := []models.CumulativeSiteResult{}
+
+	for _, site := range sites {
+		result := h.processSingleSite(site, existingReadings[site.ID], siteTypeFor(site, siteTypes), targetDate, dateString)
+		results = append(results, result)
 	}
 
-	wg.Wait()
+	return results
+}
 
-	// Sort by fuel consumed (highest first)
-	h.sortResultsByFuelConsumed(allResults)
+// processBatchInTransaction saves a batch's readings in one transaction. The first
+// failing site rolls back the whole batch and every other site in it is reported
+// as an error, so results always reflect what was committed. Every site's
+// metrics are calculated before the transaction begins, so it never holds a
+// connection while the calculations wait for theirs.
+func (h *CumulativeHandler) processBatchInTransaction(sites []*models.Site, existingReadings map[int]*models.CumulativeReading, siteTypes map[int]*models.SiteType, targetDate time.Time, dateString string) []models.CumulativeSiteResult {
+	calculations := make([]siteCalculation, 0, len(sites))
+	for _, site := range sites {
+		calculation := h.calculateSite(site, existingReadings[site.ID], siteTypeFor(site, siteTypes), targetDate, dateString)
+		if result, failed := h.calculationFailure(calculation, dateString); failed {
+			logger.Warnf("Skipping cumulative batch after failure at site %s", site.Name)
+			return h.failBatch(sites, dateString, &result, fmt.Sprintf("Rolled back: batch failed at site %s", site.Name))
+		}
+		calculations = append(calculations, calculation)
+	}
 
-	return allResults
+	return h.saveBatchInTransaction(sites, calculations, dateString)
 }
 
-// processBatch processes a batch of sites
-func (h *CumulativeHandler) processBatch(sites []*models.Site, existingReadings map[int]*models.CumulativeReading, targetDate time.Time, dateString string) []models.CumulativeSiteResult {
-	var results []models.CumulativeSiteResult
+// saveBatchInTransaction upserts the batch's calculated readings in one
+// transaction, rolling it back at the first failure
+func (h *CumulativeHandler) saveBatchInTransaction(sites []*models.Site, calculations []siteCalculation, dateString string) []models.CumulativeSiteResult {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		logger.Errorf("Failed to begin cumulative batch transaction: %v", err)
+		return h.failBatch(sites, dateString, nil, fmt.Sprintf("Batch not processed: %v", err))
+	}
+	defer tx.Rollback()
 
-	for _, site := range sites {
-		result := h.processSingleSite(site, existingReadings[site.ID], targetDate, dateString)
+	results := []models.CumulativeSiteResult{}
+	for _, calculation := range calculations {
+		result := h.saveCalculation(tx, calculation, dateString)
+		if result.Status == "ERROR" {
+			site := calculation.site
+			logger.Warnf("Rolling back cumulative batch after failure at site %s", site.Name)
+			// Errors are recorded on another connection, so release this one first
+			tx.Rollback()
+			h.recordSiteError(site, dateString, result.Error)
+			return h.failBatch(sites, dateString, &result, fmt.Sprintf("Rolled back: batch failed at site %s", site.Name))
+		}
 		results = append(results, result)
 	}
 
+	if err := tx.Commit(); err != nil {
+		logger.Errorf("Failed to commit cumulative batch: %v", err)
+		return h.failBatch(sites, dateString, nil, fmt.Sprintf("Rolled back: commit failed: %v", err))
+	}
+
+	for _, site := range sites {
+		if err := h.DB.ClearCumulativeError(site.ID, dateString); err != nil {
+			logger.Warnf("Failed to clear cumulative error for site %s: %v", site.Name, err)
+		}
+	}
+
 	return results
 }
 
-// processSingleSite processes a single site
-func (h *CumulativeHandler) processSingleSite(site *models.Site, existingReading *models.CumulativeReading, targetDate time.Time, dateString string) models.CumulativeSiteResult {
-	log.Printf("Processing site: %s (%s)", site.Name, site.DeviceID)
+// failBatch reports every site in a rolled-back batch as an error. The failed
+// site keeps its own result; the others get the given message.
+func (h *CumulativeHandler) failBatch(sites []*models.Site, dateString string, failed *models.CumulativeSiteResult, message string) []models.CumulativeSiteResult {
+	results := make([]models.CumulativeSiteResult, 0, len(sites))
+	for _, site := range sites {
+		if failed != nil && failed.SiteID == site.ID {
+			results = append(results, *failed)
+			continue
+		}
 
-	// Calculate fuel and power metrics in parallel
-	var fuelMetrics models.FuelMetrics
-	var powerMetrics models.PowerMetrics
-	var wg sync.WaitGroup
-	var fuelErr, powerErr error
+		h.recordSiteError(site, dateString, message)
+		results = append(results, models.CumulativeSiteResult{
+			SiteID:   site.ID,
+			SiteName: site.Name,
+			DeviceID: site.DeviceID,
+			Status:   "ERROR",
+			Error:    message,
+		})
+	}
+	return results
+}
 
-	wg.Add(2)
+// siteCalculation is a site's metrics for a day, calculated before anything is saved
+type siteCalculation struct {
+	site         *models.Site
+	existing     *models.CumulativeReading
+	fuelMetrics  models.FuelMetrics
+	powerMetrics models.PowerMetrics
+	fuelErr      error
+	powerErr     error
+}
 
-	go func() {
-		defer wg.Done()
-		fuelMetrics, fuelErr = h.DB.CalculateFuelChanges(site.DeviceID, targetDate)
-	}()
+// processSingleSite calculates and saves a single site's readings
+func (h *CumulativeHandler) processSingleSite(site *models.Site, existingReading *models.CumulativeReading, siteType *models.SiteType, targetDate time.Time, dateString string) models.CumulativeSiteResult {
+	calculation := h.calculateSite(site, existingReading, siteType, targetDate, dateString)
+	if result, failed := h.calculationFailure(calculation, dateString); failed {
+		return result
+	}
 
-	go func() {
-		defer wg.Done()
-		powerMetrics, powerErr = h.DB.CalculatePowerRuntimes(site.DeviceID, targetDate)
-	}()
+	result := h.saveCalculation(nil, calculation, dateString)
+	if result.Status == "ERROR" {
+		h.recordSiteError(site, dateString, result.Error)
+		return result
+	}
 
-	wg.Wait()
+	if err := h.DB.ClearCumulativeError(site.ID, dateString); err != nil {
+		logger.Warnf("Failed to clear cumulative error for site %s: %v", site.Name, err)
+	}
+	return result
+}
 
-	if fuelErr != nil || powerErr != nil {
-		log.Printf("Error calculating metrics for site %s: fuel=%v, power=%v", site.Name, fuelErr, powerErr)
+// calculateSite calculates a site's metrics for a day
+func (h *CumulativeHandler) calculateSite(site *models.Site, existingReading *models.CumulativeReading, siteType *models.SiteType, targetDate time.Time, dateString string) siteCalculation {
+	logger.Debugf("Processing site: %s (%s)", site.Name, site.DeviceID)
+
+	fuelMetrics, powerMetrics, fuelErr, powerErr := h.calculateSiteMetrics(site, siteType, targetDate)
+	if fuelErr == nil && fuelMetrics.MetricsInconsistent {
+		logger.Warnf("Fuel level and volume disagree for site %s on %s; fuel metrics may be unreliable", site.Name, dateString)
+	}
+
+	return siteCalculation{
+		site:         site,
+		existing:     existingReading,
+		fuelMetrics:  fuelMetrics,
+		powerMetrics: powerMetrics,
+		fuelErr:      fuelErr,
+		powerErr:     powerErr,
+	}
+}
+
+// calculationFailure returns the ERROR or PARTIAL result of a calculation in
+// which a metric failed, recording the error. Such calculations are not saved,
+// since upserting zeros for the failed metric would overwrite good stored values.
+func (h *CumulativeHandler) calculationFailure(calculation siteCalculation, dateString string) (models.CumulativeSiteResult, bool) {
+	site := calculation.site
+	fuelErr, powerErr := calculation.fuelErr, calculation.powerErr
+
+	if fuelErr != nil && powerErr != nil {
+		logger.Errorf("Error calculating metrics for site %s: fuel=%v, power=%v", site.Name, fuelErr, powerErr)
+		errorMessage := fmt.Sprintf("Calculation error: fuel=%v, power=%v", fuelErr, powerErr)
+		h.recordSiteError(site, dateString, errorMessage)
 		return models.CumulativeSiteResult{
+			SiteID:     site.ID,
+			SiteName:   site.Name,
+			DeviceID:   site.DeviceID,
+			Status:     "ERROR",
+			Error:      errorMessage,
+			FuelError:  fuelErr.Error(),
+			PowerError: powerErr.Error(),
+		}, true
+	}
+
+	// One metric failed: report the one that succeeded
+	if fuelErr != nil || powerErr != nil {
+		logger.Warnf("Partial metrics for site %s: fuel=%v, power=%v", site.Name, fuelErr, powerErr)
+		errorMessage := fmt.Sprintf("Calculation error: fuel=%v, power=%v", fuelErr, powerErr)
+		h.recordSiteError(site, dateString, errorMessage)
+
+		result := models.CumulativeSiteResult{
 			SiteID:   site.ID,
 			SiteName: site.Name,
 			DeviceID: site.DeviceID,
-			Status:   "ERROR",
-			Error:    fmt.Sprintf("Calculation error: fuel=%v, power=%v", fuelErr, powerErr),
+			Status:   "PARTIAL",
+			Error:    errorMessage,
+		}
+		if fuelErr != nil {
+			powerMetrics := calculation.powerMetrics
+			result.FuelError = fuelErr.Error()
+			result.GeneratorHours = powerMetrics.TotalGeneratorRuntime
+			result.ZesaHours = powerMetrics.TotalZesaRuntime
+			result.OfflineHours = powerMetrics.TotalOfflineTime
+			result.GeneratorStarts = powerMetrics.GeneratorStarts
+		} else {
+			fuelMetrics := calculation.fuelMetrics
+			result.PowerError = powerErr.Error()
+			result.FuelConsumed = fuelMetrics.TotalFuelConsumed
+			result.FuelTopped = fuelMetrics.TotalFuelTopped
+			result.FuelConsumedPercent = fuelMetrics.FuelConsumedPercent
+			result.FuelToppedPercent = fuelMetrics.FuelToppedPercent
+			result.MetricsInconsistent = fuelMetrics.MetricsInconsistent
 		}
+		return result, true
 	}
 
-	// Use UPSERT - automatically handles create or update
-	log.Printf("Creating/updating cumulative reading for %s", site.Name)
-	_, err := h.DB.CreateOrUpdateCumulativeReading(site.ID, site.DeviceID, dateString, fuelMetrics, powerMetrics)
+	return models.CumulativeSiteResult{}, false
+}
 
-	var status string
+// saveCalculation upserts a calculated reading, within tx when it is not nil.
+// A failed save is returned as an ERROR result; recording it is left to the caller.
+func (h *CumulativeHandler) saveCalculation(tx *sql.Tx, calculation siteCalculation, dateString string) models.CumulativeSiteResult {
+	site := calculation.site
+	fuelMetrics, powerMetrics := calculation.fuelMetrics, calculation.powerMetrics
+
+	// Use UPSERT - automatically handles create or update
+	logger.Debugf("Creating/updating cumulative reading for %s", site.Name)
+	var err error
+	if tx != nil {
+		_, err = h.DB.CreateOrUpdateCumulativeReadingTx(tx, site.ID, site.DeviceID, dateString, fuelMetrics, powerMetrics)
+	} else {
+		_, err = h.DB.CreateOrUpdateCumulativeReading(site.ID, site.DeviceID, dateString, fuelMetrics, powerMetrics)
+	}
 	if err != nil {
-		log.Printf("Error saving cumulative reading for site %s: %v", site.Name, err)
+		logger.Errorf("Error saving cumulative reading for site %s: %v", site.Name, err)
 		return models.CumulativeSiteResult{
 			SiteID:   site.ID,
 			SiteName: site.Name,
@@ -246,10 +554,9 @@ func (h *CumulativeHandler) processSingleSite(site *models.Site, existingReading
 	}
 
 	// Determine status based on whether record existed
-	if existingReading != nil {
+	status := "CREATED"
+	if calculation.existing != nil {
 		status = "UPDATED"
-	} else {
-		status = "CREATED"
 	}
 
 	return models.CumulativeSiteResult{
@@ -263,23 +570,72 @@ func (h *CumulativeHandler) processSingleSite(site *models.Site, existingReading
 		GeneratorHours:      powerMetrics.TotalGeneratorRuntime,
 		ZesaHours:           powerMetrics.TotalZesaRuntime,
 		OfflineHours:        powerMetrics.TotalOfflineTime,
+		GeneratorStarts:     powerMetrics.GeneratorStarts,
 		Status:              status,
+		CalcVersion:         models.CumulativeCalcVersion,
+		MetricsInconsistent: fuelMetrics.MetricsInconsistent,
 		CalculatedAt:        time.Now(),
 	}
 }
 
+// calculateSiteMetrics calculates a site's fuel and power metrics for a day in parallel
+func (h *CumulativeHandler) calculateSiteMetrics(site *models.Site, siteType *models.SiteType, targetDate time.Time) (fuelMetrics models.FuelMetrics, powerMetrics models.PowerMetrics, fuelErr, powerErr error) {
+	fuelOpts := database.FuelCalcOptions{
+		HasGenerator:              siteType.Expects("generator_state"),
+		NoGeneratorNoiseThreshold: h.Settings.Float(settings.NoGeneratorNoiseThreshold),
+		SensorNames:               siteType.Names(),
+		ConsistencyTolerance:      h.Config.Cumulative.ConsistencyTolerance,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		fuelMetrics, fuelErr = h.DB.CalculateFuelChanges(site.DeviceID, targetDate, fuelOpts)
+	}()
+
+	go func() {
+		defer wg.Done()
+		powerMetrics, powerErr = h.DB.CalculatePowerRuntimes(site.DeviceID, targetDate, siteType.Names())
+	}()
+
+	wg.Wait()
+	return fuelMetrics, powerMetrics, fuelErr, powerErr
+}
+
+// recordSiteError stores a site's calculation failure so it can be reviewed without re-running
+func (h *CumulativeHandler) recordSiteError(site *models.Site, dateString, message string) {
+	if err := h.DB.RecordCumulativeError(site.ID, site.DeviceID, dateString, message); err != nil {
+		logger.Warnf("Failed to record cumulative error for site %s: %v", site.Name, err)
+	}
+}
+
 // calculateSummary calculates the summary statistics
 func (h *CumulativeHandler) calculateSummary(results []models.CumulativeSiteResult, totalSites int) models.CumulativeSummary {
 	var totalFuelConsumed, totalFuelTopped, totalGeneratorHours, totalZesaHours, totalOfflineHours float64
-	var processedSites, errorSites int
+	var processedSites, partialSites, errorSites, inconsistentSites int
 
 	for _, result := range results {
-		if result.Status == "ERROR" {
+		switch result.Status {
+		case "ERROR":
 			errorSites++
-		} else {
+			continue
+		case "PARTIAL":
+			partialSites++
+		default:
 			processedSites++
+		}
+
+		// Partial results only carry the metrics that were calculated
+		if result.FuelError == "" {
 			totalFuelConsumed += result.FuelConsumed
 			totalFuelTopped += result.FuelTopped
+		}
+		if result.MetricsInconsistent {
+			inconsistentSites++
+		}
+		if result.PowerError == "" {
 			totalGeneratorHours += result.GeneratorHours
 			totalZesaHours += result.ZesaHours
 			totalOfflineHours += result.OfflineHours
@@ -289,7 +645,9 @@ func (h *CumulativeHandler) calculateSummary(results []models.CumulativeSiteResu
 	return models.CumulativeSummary{
 		TotalSites:          totalSites,
 		ProcessedSites:      processedSites,
+		PartialSites:        partialSites,
 		ErrorSites:          errorSites,
+		InconsistentSites:   inconsistentSites,
 		TotalFuelConsumed:   h.roundToDecimal(totalFuelConsumed, 1),
 		TotalFuelTopped:     h.roundToDecimal(totalFuelTopped, 1),
 		TotalGeneratorHours: h.roundToDecimal(totalGeneratorHours, 2),
@@ -300,13 +658,13 @@ func (h *CumulativeHandler) calculateSummary(results []models.CumulativeSiteResu
 
 // sortResultsByFuelConsumed sorts results by fuel consumed in descending order
 func (h *CumulativeHandler) sortResultsByFuelConsumed(results []models.CumulativeSiteResult) {
-	for i := 0; i < len(results)-1; i++ {
-		for j := i + 1; j < len(results); j++ {
-			if results[j].FuelConsumed > results[i].FuelConsumed {
-				results[i], results[j] = results[j], results[i]
-			}
+	// Site ID breaks ties so the order does not depend on batch completion order
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].FuelConsumed != results[j].FuelConsumed {
+			return results[i].FuelConsumed > results[j].FuelConsumed
 		}
-	}
+		return results[i].SiteID < results[j].SiteID
+	})
 }
 
 // roundToDecimal rounds a float to specified decimal places
@@ -318,8 +676,9 @@ func (h *CumulativeHandler) roundToDecimal(val float64, decimals int) float64 {
 	return float64(int(val*multiplier+0.5)) / multiplier
 }
 
-// GetCumulativeReadingsByDateRange retrieves cumulative readings for a date range
-func (h *CumulativeHandler) GetCumulativeReadingsByDateRange(c *gin.Context) {
+// GetStoredCumulativeReadings returns the stored cumulative readings for a date
+// without recomputing them. Pass format=legacy for string-typed metric fields.
+func (h *CumulativeHandler) GetStoredCumulativeReadings(c *gin.Context) {
 	user, exists := middleware.GetUserFromContext(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
@@ -328,48 +687,79 @@ func (h *CumulativeHandler) GetCumulativeReadingsByDateRange(c *gin.Context) {
 		return
 	}
 
-	// Get query parameters
-	startDateStr := c.Query("startDate")
-	endDateStr := c.Query("endDate")
-
-	if startDateStr == "" {
+	targetDate, err := parseDate(c.Query("date"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Message: "startDate parameter is required",
+			Message: "Invalid date format. Use DD/MM/YYYY or YYYY-MM-DD",
 		})
 		return
 	}
+	dateString := targetDate.Format("2006-01-02")
 
-	// Parse dates
-	startDate, err := h.parseDate(startDateStr)
+	sites, err := h.DB.GetSitesForUser(user.ID, user.Role)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Message: "Invalid startDate format. Use DD/MM/YYYY or YYYY-MM-DD",
+		logger.Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
 		})
 		return
 	}
 
-	var endDate time.Time
-	if endDateStr != "" {
-		endDate, err = h.parseDate(endDateStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{
-				Message: "Invalid endDate format. Use DD/MM/YYYY or YYYY-MM-DD",
-			})
-			return
+	readings, err := h.DB.GetExistingCumulativeReadings(dateString, sites)
+	if err != nil {
+		logger.Errorf("Failed to get cumulative readings for %s: %v", dateString, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get cumulative readings",
+		})
+		return
+	}
+
+	response := models.StoredCumulativeReadingsResponse{Date: dateString}
+	if c.Query("format") == "legacy" {
+		legacy := make([]models.LegacyCumulativeReading, 0, len(readings))
+		for _, reading := range readings {
+			legacy = append(legacy, reading.ToLegacy())
 		}
+		response.Readings = legacy
 	} else {
-		endDate = startDate
+		if readings == nil {
+			readings = []*models.CumulativeReading{}
+		}
+		response.Readings = readings
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetCumulativeReadingsByDateRange retrieves cumulative readings for a date range
+func (h *CumulativeHandler) GetCumulativeReadingsByDateRange(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	page, ok := parsePagination(c, h.Config.Pagination)
+	if !ok {
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
 	}
 
 	startDateString := startDate.Format("2006-01-02")
 	endDateString := endDate.Format("2006-01-02")
 
-	log.Printf("Getting cumulative readings from %s to %s for user: %s", startDateString, endDateString, user.Username)
+	middleware.Log(c).Debugf("Getting cumulative readings from %s to %s for user: %s", startDateString, endDateString, user.Username)
 
 	// Get user's accessible sites
-	sites, err := h.DB.GetSitesForUser(user.ID, user.Role)
+	sites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
 	if err != nil {
-		log.Printf("Failed to get sites for user %s: %v", user.Username, err)
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Message: "Failed to get sites",
 		})
@@ -377,7 +767,7 @@ func (h *CumulativeHandler) GetCumulativeReadingsByDateRange(c *gin.Context) {
 	}
 
 	if len(sites) == 0 {
-		log.Printf("No accessible sites for user: %s", user.Username)
+		middleware.Log(c).Debugf("No accessible sites for user: %s", user.Username)
 		response := models.CumulativeReadingsRangeResponse{
 			Sites: []models.CumulativeSiteRangeResult{},
 			Summary: models.CumulativeRangeSummary{
@@ -397,55 +787,130 @@ func (h *CumulativeHandler) GetCumulativeReadingsByDateRange(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Found %d accessible sites for %s (%s)", len(sites), user.Username, user.Role)
+	middleware.Log(c).Debugf("Found %d accessible sites for %s (%s)", len(sites), user.Username, user.Role)
 
-	// Get cumulative readings for the date range with parallel processing
-	siteReadings := h.getCumulativeReadingsForRange(sites, startDateString, endDateString)
-
-	// Calculate summary
-	summary := h.calculateRangeSummary(siteReadings, startDateString, endDateString, startDate, endDate)
-
-	response := models.CumulativeReadingsRangeResponse{
-		Sites:   siteReadings,
-		Summary: summary,
+	if !h.checkRangeSize(c, len(sites), startDate, endDate) {
+		return
 	}
 
-	log.Printf("Cumulative readings range query completed: %s to %s, Sites: %d, Total Fuel: %.1fL, Gen Hours: %.2fh, Zesa Hours: %.2fh",
-		startDateString, endDateString, len(siteReadings), summary.TotalFuelConsumed, summary.TotalGeneratorHours, summary.TotalZesaHours)
+	// Conditional caching: closed historical ranges are immutable once calculated
+	count, maxCalculatedAt, err := h.DB.GetCumulativeRangeVersion(sites, startDateString, endDateString)
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get cumulative range version: %v", err)
+	} else {
+		etag := h.rangeETag(sites, startDateString, endDateString, count, maxCalculatedAt, page)
+		c.Header("ETag", etag)
 
-	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, response)
-}
+		// Compare against the UTC day; the server's local date may already be tomorrow
+		if endDateString >= today().Format("2006-01-02") {
+			middleware.SetRevalidate(c)
+		} else {
+			middleware.SetPrivateCache(c, h.Config.Cumulative.RangeCacheMaxAge)
+		}
 
-// getCumulativeReadingsForRange retrieves and aggregates cumulative readings for multiple sites in parallel
-func (h *CumulativeHandler) getCumulativeReadingsForRange(sites []*models.Site, startDate, endDate string) []models.CumulativeSiteRangeResult {
-	const batchSize = 20
-	var allResults []models.CumulativeSiteRangeResult
-	var resultMutex sync.Mutex
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
 
-	var wg sync.WaitGroup
+	siteReadings, err := h.getRangeResults(sites, startDateString, endDateString)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get grouped range data: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get cumulative readings",
+		})
+		return
+	}
 
-	// Process sites in parallel batches
-	for i := 0; i < len(sites); i += batchSize {
-		end := i + batchSize
-		if end > len(sites) {
-			end = len(sites)
+	// Calculate summary
+	summary := h.calculateRangeSummary(siteReadings, startDateString, endDateString, startDate, endDate)
+
+	// The summary always covers every site; only the site list is paginated
+	response := models.CumulativeReadingsRangeResponse{
+		Sites:   paginate(c, siteReadings, page),
+		Summary: summary,
+	}
+
+	middleware.Log(c).Infof("Cumulative readings range query completed: %s to %s, Sites: %d, Total Fuel: %.1fL, Gen Hours: %.2fh, Zesa Hours: %.2fh",
+		startDateString, endDateString, len(siteReadings), summary.TotalFuelConsumed, summary.TotalGeneratorHours, summary.TotalZesaHours)
+
+	c.Header("Content-Type", "application/json")
+	c.JSON(http.StatusOK, response)
+}
+
+// getRangeResults gets the sites' cumulative readings for the date range, either
+// in one grouped query or per site in parallel
+func (h *CumulativeHandler) getRangeResults(sites []*models.Site, startDate, endDate string) ([]models.CumulativeSiteRangeResult, error) {
+	finished := h.Watchdog.RequestStarted("cumulative_range")
+	defer finished()
+
+	if h.Config.Cumulative.RangeGroupedQuery {
+		return h.getGroupedCumulativeReadingsForRange(sites, startDate, endDate)
+	}
+	return h.getCumulativeReadingsForRange(sites, startDate, endDate), nil
+}
+
+// getGroupedCumulativeReadingsForRange aggregates cumulative readings for all sites in a single query
+func (h *CumulativeHandler) getGroupedCumulativeReadingsForRange(sites []*models.Site, startDate, endDate string) ([]models.CumulativeSiteRangeResult, error) {
+	totals, err := h.DB.GetCumulativeRangeTotals(sites, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	results := []models.CumulativeSiteRangeResult{}
+	for _, site := range sites {
+		total, ok := totals[site.ID]
+		if !ok {
+			// Only return sites that have readings in the date range
+			continue
 		}
-		batch := sites[i:end]
 
-		wg.Add(1)
-		go func(batchSites []*models.Site) {
-			defer wg.Done()
+		uptime := uptimePercent(total.TotalGeneratorHours+total.TotalZesaHours, total.TotalOfflineHours,
+			coveredHours(total.ReadingDays, total.DateRange.End, now))
+		results = append(results, models.CumulativeSiteRangeResult{
+			SiteID:              site.ID,
+			SiteName:            site.Name,
+			DeviceID:            site.DeviceID,
+			TotalFuelConsumed:   h.roundToDecimal(total.TotalFuelConsumed, 1),
+			TotalFuelTopped:     h.roundToDecimal(total.TotalFuelTopped, 1),
+			TotalGeneratorHours: h.roundToDecimal(total.TotalGeneratorHours, 2),
+			TotalZesaHours:      h.roundToDecimal(total.TotalZesaHours, 2),
+			TotalOfflineHours:   h.roundToDecimal(total.TotalOfflineHours, 2),
+			UptimePercent:       h.roundToDecimal(uptime, 1),
+			ReadingDays:         total.ReadingDays,
+			OutdatedDays:        total.OutdatedDays,
+			DateRange:           total.DateRange,
+		})
+	}
+
+	// Sort by total fuel consumed (highest first)
+	h.sortRangeResultsByFuelConsumed(results)
 
-			batchResults := h.processSiteRangeBatch(batchSites, startDate, endDate)
+	return results, nil
+}
 
-			resultMutex.Lock()
-			allResults = append(allResults, batchResults...)
-			resultMutex.Unlock()
-		}(batch)
+// getCumulativeReadingsForRange retrieves and aggregates cumulative readings for multiple sites in parallel
+func (h *CumulativeHandler) getCumulativeReadingsForRange(sites []*models.Site, startDate, endDate string) []models.CumulativeSiteRangeResult {
+	batchSize := h.Config.Cumulative.RangeBatchSize
+	if batchSize < 1 {
+		batchSize = 20
 	}
+	allResults := []models.CumulativeSiteRangeResult{}
+	var resultMutex sync.Mutex
 
-	wg.Wait()
+	// Process sites in parallel batches
+	forEachBatch(sites, batchSize, processBatchWorkers, func(batchSites []*models.Site) {
+		h.Watchdog.WorkerStarted()
+		defer h.Watchdog.WorkerFinished()
+
+		batchResults := h.processSiteRangeBatch(batchSites, startDate, endDate)
+
+		resultMutex.Lock()
+		allResults = append(allResults, batchResults...)
+		resultMutex.Unlock()
+	})
 
 	// Sort by total fuel consumed (highest first)
 	h.sortRangeResultsByFuelConsumed(allResults)
@@ -455,7 +920,7 @@ func (h *CumulativeHandler) getCumulativeReadingsForRange(sites []*models.Site,
 
 // processSiteRangeBatch processes a batch of sites for date range query
 func (h *CumulativeHandler) processSiteRangeBatch(sites []*models.Site, startDate, endDate string) []models.CumulativeSiteRangeResult {
-	var results []models.CumulativeSiteRangeResult
+	results := []models.CumulativeSiteRangeResult{}
 
 	for _, site := range sites {
 		result := h.getSiteRangeData(site, startDate, endDate)
@@ -477,6 +942,7 @@ func (h *CumulativeHandler) getSiteRangeData(site *models.Site, startDate, endDa
 			SUM(CAST(total_generator_runtime AS DECIMAL)) as total_generator_hours,
 			SUM(CAST(total_zesa_runtime AS DECIMAL)) as total_zesa_hours,
 			SUM(CAST(total_offline_time AS DECIMAL)) as total_offline_hours,
+			COUNT(*) FILTER (WHERE calc_version < $4) as outdated_days,
 			MIN(date) as first_date,
 			MAX(date) as last_date
 		FROM cumulative_readings 
@@ -485,23 +951,24 @@ func (h *CumulativeHandler) getSiteRangeData(site *models.Site, startDate, endDa
 		  AND date <= $3
 	`
 
-	var readingDays int
+	var readingDays, outdatedDays int
 	var totalFuelConsumed, totalFuelTopped, totalGeneratorHours, totalZesaHours, totalOfflineHours float64
 	var firstDate, lastDate string
 
-	err := h.DB.QueryRow(query, site.ID, startDate, endDate).Scan(
+	err := h.DB.QueryRow(query, site.ID, startDate, endDate, models.CumulativeCalcVersion).Scan(
 		&readingDays,
 		&totalFuelConsumed,
 		&totalFuelTopped,
 		&totalGeneratorHours,
 		&totalZesaHours,
 		&totalOfflineHours,
+		&outdatedDays,
 		&firstDate,
 		&lastDate,
 	)
 
 	if err != nil {
-		log.Printf("Error getting range data for site %s: %v", site.Name, err)
+		logger.Errorf("Error getting range data for site %s: %v", site.Name, err)
 		return nil
 	}
 
@@ -510,6 +977,7 @@ func (h *CumulativeHandler) getSiteRangeData(site *models.Site, startDate, endDa
 		return nil
 	}
 
+	uptime := uptimePercent(totalGeneratorHours+totalZesaHours, totalOfflineHours, coveredHours(readingDays, lastDate, time.Now()))
 	return &models.CumulativeSiteRangeResult{
 		SiteID:              site.ID,
 		SiteName:            site.Name,
@@ -519,7 +987,9 @@ func (h *CumulativeHandler) getSiteRangeData(site *models.Site, startDate, endDa
 		TotalGeneratorHours: h.roundToDecimal(totalGeneratorHours, 2),
 		TotalZesaHours:      h.roundToDecimal(totalZesaHours, 2),
 		TotalOfflineHours:   h.roundToDecimal(totalOfflineHours, 2),
+		UptimePercent:       h.roundToDecimal(uptime, 1),
 		ReadingDays:         readingDays,
+		OutdatedDays:        outdatedDays,
 		DateRange: models.DateRange{
 			Start: firstDate,
 			End:   lastDate,
@@ -529,9 +999,10 @@ func (h *CumulativeHandler) getSiteRangeData(site *models.Site, startDate, endDa
 
 // calculateRangeSummary calculates summary statistics for the date range
 func (h *CumulativeHandler) calculateRangeSummary(results []models.CumulativeSiteRangeResult, startDate, endDate string, startDateTime, endDateTime time.Time) models.CumulativeRangeSummary {
-	var totalFuelConsumed, totalFuelTopped, totalGeneratorHours, totalZesaHours, totalOfflineHours float64
+	var totalFuelConsumed, totalFuelTopped, totalGeneratorHours, totalZesaHours, totalOfflineHours, totalUptime float64
 
 	for _, result := range results {
+		totalUptime += result.UptimePercent
 		totalFuelConsumed += result.TotalFuelConsumed
 		totalFuelTopped += result.TotalFuelTopped
 		totalGeneratorHours += result.TotalGeneratorHours
@@ -539,9 +1010,10 @@ func (h *CumulativeHandler) calculateRangeSummary(results []models.CumulativeSit
 		totalOfflineHours += result.TotalOfflineHours
 	}
 
-	var averageFuelPerSite float64
+	var averageFuelPerSite, averageUptime float64
 	if len(results) > 0 {
 		averageFuelPerSite = totalFuelConsumed / float64(len(results))
+		averageUptime = totalUptime / float64(len(results))
 	}
 
 	return models.CumulativeRangeSummary{
@@ -557,10 +1029,37 @@ func (h *CumulativeHandler) calculateRangeSummary(results []models.CumulativeSit
 		TotalZesaHours:      h.roundToDecimal(totalZesaHours, 2),
 		TotalOfflineHours:   h.roundToDecimal(totalOfflineHours, 2),
 		AverageFuelPerSite:  h.roundToDecimal(averageFuelPerSite, 1),
+		AverageUptime:       h.roundToDecimal(averageUptime, 1),
 		DaysIncluded:        h.calculateDaysDifference(startDateTime, endDateTime),
 	}
 }
 
+// uptimePercent returns the share of covered hours that had power (generator or zesa).
+// Generator and zesa can run at the same time, so their summed hours may exceed
+// the powered time; the powered hours are capped at the covered hours that were
+// not offline.
+func uptimePercent(activeHours, offlineHours, coveredHours float64) float64 {
+	if coveredHours <= 0 {
+		return 0
+	}
+	powered := math.Min(activeHours, coveredHours-offlineHours)
+	if powered <= 0 {
+		return 0
+	}
+	return math.Min(powered/coveredHours*100, 100)
+}
+
+// coveredHours returns the hours spanned by readingDays stored days ending on
+// lastDate (YYYY-MM-DD, possibly followed by a time). Every day is a full UTC
+// day except today, which is covered only up to now.
+func coveredHours(readingDays int, lastDate string, now time.Time) float64 {
+	hours := float64(readingDays) * 24
+	if midnight := now.UTC().Truncate(24 * time.Hour); strings.HasPrefix(lastDate, midnight.Format("2006-01-02")) {
+		hours -= 24 - now.Sub(midnight).Hours()
+	}
+	return hours
+}
+
 // calculateDaysDifference calculates the number of days between two dates
 func (h *CumulativeHandler) calculateDaysDifference(startDate, endDate time.Time) int {
 	if startDate.Equal(endDate) {
@@ -570,13 +1069,1048 @@ func (h *CumulativeHandler) calculateDaysDifference(startDate, endDate time.Time
 	return int(diff.Hours()/24) + 1
 }
 
+// checkRangeSize rejects a range covering more site-day rows than the configured
+// maximum with 413, before any readings are queried
+func (h *CumulativeHandler) checkRangeSize(c *gin.Context, siteCount int, startDate, endDate time.Time) bool {
+	maxRows := h.Config.Cumulative.RangeMaxRows
+	if maxRows <= 0 {
+		return true
+	}
+
+	days := h.calculateDaysDifference(startDate, endDate)
+	if rows := siteCount * days; rows > maxRows {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Message: fmt.Sprintf("Range covers %d site-days (%d sites × %d days), more than the maximum of %d. Narrow the date range.", rows, siteCount, days, maxRows),
+		})
+		return false
+	}
+
+	return true
+}
+
 // sortRangeResultsByFuelConsumed sorts results by total fuel consumed in descending order
 func (h *CumulativeHandler) sortRangeResultsByFuelConsumed(results []models.CumulativeSiteRangeResult) {
-	for i := 0; i < len(results)-1; i++ {
-		for j := i + 1; j < len(results); j++ {
-			if results[j].TotalFuelConsumed > results[i].TotalFuelConsumed {
-				results[i], results[j] = results[j], results[i]
-			}
+	// Site ID breaks ties so the order does not depend on batch completion order
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].TotalFuelConsumed != results[j].TotalFuelConsumed {
+			return results[i].TotalFuelConsumed > results[j].TotalFuelConsumed
+		}
+		return results[i].SiteID < results[j].SiteID
+	})
+}
+
+// rangeETag builds a weak ETag from the accessible site set, the requested range and
+// the version (row count and latest calculation time) of the readings inside it
+func (h *CumulativeHandler) rangeETag(sites []*models.Site, startDate, endDate string, count int, maxCalculatedAt *time.Time, page pagination) string {
+	siteIDs := make([]int, len(sites))
+	for i, site := range sites {
+		siteIDs[i] = site.ID
+	}
+	sort.Ints(siteIDs)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s|%s|%v|%d", startDate, endDate, siteIDs, count)
+	if maxCalculatedAt != nil {
+		fmt.Fprintf(hash, "|%d", maxCalculatedAt.UnixNano())
+	}
+	if page.Requested {
+		fmt.Fprintf(hash, "|page=%d,%d", page.Page, page.PageSize)
+	}
+
+	return fmt.Sprintf(`W/"%s"`, hex.EncodeToString(hash.Sum(nil))[:32])
+}
+
+// etagMatches reports whether an If-None-Match header matches the given ETag
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLeaderboard returns the top consumers for a date from stored cumulative readings
+func (h *CumulativeHandler) GetLeaderboard(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	targetDate, err := parseDate(c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid date format. Use DD/MM/YYYY or YYYY-MM-DD",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "limit must be between 1 and 100",
+		})
+		return
+	}
+
+	orderBy := c.DefaultQuery("orderBy", "fuel")
+	if orderBy != "fuel" && orderBy != "generator" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "orderBy must be 'fuel' or 'generator'",
+		})
+		return
+	}
+
+	dateString := targetDate.Format("2006-01-02")
+
+	sites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	readings, err := h.DB.GetCumulativeLeaderboard(dateString, sites, orderBy, limit)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get leaderboard for %s: %v", dateString, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get leaderboard",
+		})
+		return
+	}
+
+	sitesByID := make(map[int]*models.Site, len(sites))
+	for _, site := range sites {
+		sitesByID[site.ID] = site
+	}
+
+	entries := make([]models.LeaderboardEntry, 0, len(readings))
+	for i, reading := range readings {
+		entry := models.LeaderboardEntry{
+			Rank:           i + 1,
+			SiteID:         reading.SiteID,
+			DeviceID:       reading.DeviceID,
+			FuelConsumed:   reading.TotalFuelConsumed,
+			FuelTopped:     reading.TotalFuelTopped,
+			GeneratorHours: reading.TotalGeneratorRuntime,
+			ZesaHours:      reading.TotalZesaRuntime,
+			OfflineHours:   reading.TotalOfflineTime,
+			CalcVersion:    reading.CalcVersion,
+			CalculatedAt:   reading.CalculatedAt,
+		}
+		if site, ok := sitesByID[reading.SiteID]; ok {
+			entry.SiteName = site.Name
+		}
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, models.LeaderboardResponse{
+		Date:    dateString,
+		OrderBy: orderBy,
+		Limit:   limit,
+		Entries: entries,
+	})
+}
+
+// rankingRequest is a parsed ranking request with the range totals of the
+// accessible sites that have stored readings in the range
+type rankingRequest struct {
+	startDate time.Time
+	endDate   time.Time
+	dateRange models.DateRange
+	limit     int
+	results   []models.CumulativeSiteRangeResult
+}
+
+// loadRankingRequest parses ?startDate=&endDate=&limit= and loads the range
+// totals for a ranking. It responds with an error and returns false on failure;
+// name is used in the error message.
+func (h *CumulativeHandler) loadRankingRequest(c *gin.Context, name string) (rankingRequest, bool) {
+	var req rankingRequest
+
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return req, false
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return req, false
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "limit must be between 1 and 100",
+		})
+		return req, false
+	}
+
+	startDateString := startDate.Format("2006-01-02")
+	endDateString := endDate.Format("2006-01-02")
+
+	sites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return req, false
+	}
+
+	if !h.checkRangeSize(c, len(sites), startDate, endDate) {
+		return req, false
+	}
+
+	finished := h.Watchdog.RequestStarted("cumulative_range")
+	results, err := h.getGroupedCumulativeReadingsForRange(sites, startDateString, endDateString)
+	finished()
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get %s for %s to %s: %v", name, startDateString, endDateString, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get " + name,
+		})
+		return req, false
+	}
+
+	return rankingRequest{
+		startDate: startDate,
+		endDate:   endDate,
+		dateRange: models.DateRange{
+			Start:   startDateString,
+			End:     endDateString,
+			IsRange: startDateString != endDateString,
+		},
+		limit:   limit,
+		results: results,
+	}, true
+}
+
+// GetOfflineRanking ranks the accessible sites with stored cumulative readings
+// between ?startDate= and ?endDate= by total offline hours, least reliable first
+func (h *CumulativeHandler) GetOfflineRanking(c *gin.Context) {
+	req, ok := h.loadRankingRequest(c, "offline ranking")
+	if !ok {
+		return
+	}
+	results := req.results
+
+	// Site ID breaks ties so the ranking is stable
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].TotalOfflineHours != results[j].TotalOfflineHours {
+			return results[i].TotalOfflineHours > results[j].TotalOfflineHours
+		}
+		return results[i].SiteID < results[j].SiteID
+	})
+
+	response := models.OfflineRankingResponse{
+		DateRange:   req.dateRange,
+		DaysInRange: h.calculateDaysDifference(req.startDate, req.endDate),
+		Limit:       req.limit,
+		TotalSites:  len(results),
+		Entries:     []models.OfflineRankingEntry{},
+	}
+	for i, result := range results {
+		if i == req.limit {
+			break
+		}
+		response.Entries = append(response.Entries, models.OfflineRankingEntry{
+			Rank:               i + 1,
+			SiteID:             result.SiteID,
+			SiteName:           result.SiteName,
+			DeviceID:           result.DeviceID,
+			OfflineHours:       result.TotalOfflineHours,
+			OfflineHoursPerDay: h.roundToDecimal(result.TotalOfflineHours/float64(result.ReadingDays), 2),
+			UptimePercent:      result.UptimePercent,
+			ReadingDays:        result.ReadingDays,
+			DateRange:          result.DateRange,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetEfficiencyRanking ranks the accessible sites with stored cumulative readings
+// between ?startDate= and ?endDate= by liters consumed per generator hour, least
+// efficient first. Sites whose generator ran less than ?minHours= (default from
+// configuration) are left out, as their ratio is mostly noise.
+func (h *CumulativeHandler) GetEfficiencyRanking(c *gin.Context) {
+	minHours := h.Config.Cumulative.EfficiencyMinGeneratorHours
+	if minHoursParam := c.Query("minHours"); minHoursParam != "" {
+		parsed, err := strconv.ParseFloat(minHoursParam, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "minHours must be a non-negative number",
+			})
+			return
+		}
+		minHours = parsed
+	}
+
+	req, ok := h.loadRankingRequest(c, "efficiency ranking")
+	if !ok {
+		return
+	}
+
+	ranked := []models.CumulativeSiteRangeResult{}
+	for _, result := range req.results {
+		if result.TotalGeneratorHours > 0 && result.TotalGeneratorHours >= minHours {
+			ranked = append(ranked, result)
+		}
+	}
+
+	litersPerHour := func(result models.CumulativeSiteRangeResult) float64 {
+		return result.TotalFuelConsumed / result.TotalGeneratorHours
+	}
+
+	// Site ID breaks ties so the ranking is stable
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ratioI, ratioJ := litersPerHour(ranked[i]), litersPerHour(ranked[j]); ratioI != ratioJ {
+			return ratioI > ratioJ
+		}
+		return ranked[i].SiteID < ranked[j].SiteID
+	})
+
+	response := models.EfficiencyRankingResponse{
+		DateRange:         req.dateRange,
+		MinGeneratorHours: minHours,
+		Limit:             req.limit,
+		TotalSites:        len(ranked),
+		ExcludedSites:     len(req.results) - len(ranked),
+		Entries:           []models.EfficiencyRankingEntry{},
+	}
+	for i, result := range ranked {
+		if i == req.limit {
+			break
+		}
+		response.Entries = append(response.Entries, models.EfficiencyRankingEntry{
+			Rank:           i + 1,
+			SiteID:         result.SiteID,
+			SiteName:       result.SiteName,
+			DeviceID:       result.DeviceID,
+			FuelConsumed:   result.TotalFuelConsumed,
+			GeneratorHours: result.TotalGeneratorHours,
+			LitersPerHour:  h.roundToDecimal(litersPerHour(result), 2),
+			ReadingDays:    result.ReadingDays,
+			DateRange:      result.DateRange,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetProcessingStatus reports which accessible sites were processed or errored for a date
+func (h *CumulativeHandler) GetProcessingStatus(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	targetDate, err := parseDate(c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid date format. Use DD/MM/YYYY or YYYY-MM-DD",
+		})
+		return
+	}
+
+	dateString := targetDate.Format("2006-01-02")
+
+	sites, err := h.DB.GetSitesForUser(user.ID, user.Role)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	statuses, err := h.DB.GetCumulativeProcessingStatus(dateString, sites)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get processing status for %s: %v", dateString, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get processing status",
+		})
+		return
+	}
+
+	summary := models.CumulativeStatusSummary{TotalSites: len(statuses)}
+	for _, status := range statuses {
+		switch {
+		case status.HasError:
+			summary.ErrorSites++
+		case status.Processed:
+			summary.ProcessedSites++
+		default:
+			summary.PendingSites++
+		}
+		if status.Outdated {
+			summary.OutdatedSites++
 		}
 	}
+
+	c.JSON(http.StatusOK, models.CumulativeStatusResponse{
+		Date:        dateString,
+		CalcVersion: models.CumulativeCalcVersion,
+		Sites:       statuses,
+		Summary:     summary,
+	})
+}
+
+// GetCumulativeByDate returns stored cumulative readings for specific sites on a date
+// without recomputation. Pass format=legacy for string-typed metric fields.
+func (h *CumulativeHandler) GetCumulativeByDate(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	targetDate, err := parseDate(c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid date format. Use DD/MM/YYYY or YYYY-MM-DD",
+		})
+		return
+	}
+
+	siteIDs, err := parseIDList(c.Query("siteIds"))
+	if err != nil || len(siteIDs) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "siteIds must be a comma-separated list of site IDs",
+		})
+		return
+	}
+
+	legacyFormat := c.Query("format") == "legacy"
+	dateString := targetDate.Format("2006-01-02")
+
+	accessibleSites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	accessibleByID := make(map[int]*models.Site, len(accessibleSites))
+	for _, site := range accessibleSites {
+		accessibleByID[site.ID] = site
+	}
+
+	// Every requested site must be within the caller's scope
+	sites := make([]*models.Site, 0, len(siteIDs))
+	for _, siteID := range siteIDs {
+		site, ok := accessibleByID[siteID]
+		if !ok {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Message: fmt.Sprintf("Access denied to site %d", siteID),
+			})
+			return
+		}
+		sites = append(sites, site)
+	}
+
+	readings, err := h.DB.GetExistingCumulativeReadings(dateString, sites)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get cumulative readings for %s: %v", dateString, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get cumulative readings",
+		})
+		return
+	}
+
+	readingsBySiteID := make(map[int]*models.CumulativeReading, len(readings))
+	for _, reading := range readings {
+		readingsBySiteID[reading.SiteID] = reading
+	}
+
+	entries := make([]models.CumulativeByDateEntry, 0, len(sites))
+	for _, site := range sites {
+		entry := models.CumulativeByDateEntry{
+			SiteID:   site.ID,
+			SiteName: site.Name,
+			DeviceID: site.DeviceID,
+		}
+
+		if reading, ok := readingsBySiteID[site.ID]; ok {
+			entry.HasData = true
+			if legacyFormat {
+				entry.Reading = reading.ToLegacy()
+			} else {
+				entry.Reading = reading
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, models.CumulativeByDateResponse{
+		Date:  dateString,
+		Sites: entries,
+	})
+}
+
+// parseIDList parses a comma-separated list of integer IDs, dropping duplicates
+func parseIDList(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q: %w", part, err)
+		}
+		ids = append(ids, id)
+	}
+	return uniqueIDs(ids), nil
+}
+
+// GetCumulativeByLocation rolls up stored cumulative readings for accessible sites by site location
+func (h *CumulativeHandler) GetCumulativeByLocation(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "endDate must not be before startDate",
+		})
+		return
+	}
+
+	startDateString := startDate.Format("2006-01-02")
+	endDateString := endDate.Format("2006-01-02")
+
+	sites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	locations, err := h.DB.GetCumulativeTotalsByLocation(sites, startDateString, endDateString)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get totals by location: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get cumulative readings by location",
+		})
+		return
+	}
+
+	for _, location := range locations {
+		location.TotalFuelConsumed = h.roundToDecimal(location.TotalFuelConsumed, 1)
+		location.TotalFuelTopped = h.roundToDecimal(location.TotalFuelTopped, 1)
+		location.TotalGeneratorHours = h.roundToDecimal(location.TotalGeneratorHours, 2)
+		location.TotalZesaHours = h.roundToDecimal(location.TotalZesaHours, 2)
+		location.TotalOfflineHours = h.roundToDecimal(location.TotalOfflineHours, 2)
+	}
+
+	c.JSON(http.StatusOK, models.CumulativeByLocationResponse{
+		DateRange: models.DateRange{
+			Start:   startDateString,
+			End:     endDateString,
+			IsRange: startDateString != endDateString,
+		},
+		Locations: locations,
+	})
+}
+
+// maxMatrixDays caps the date axis of the cumulative matrix
+const maxMatrixDays = 92
+
+// GetCumulativeMatrix returns a sites-by-days matrix of one stored metric for
+// accessible sites, aligned to a shared date axis with missing days as null
+func (h *CumulativeHandler) GetCumulativeMatrix(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "endDate must not be before startDate",
+		})
+		return
+	}
+	if h.calculateDaysDifference(startDate, endDate) > maxMatrixDays {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: fmt.Sprintf("Range cannot exceed %d days", maxMatrixDays),
+		})
+		return
+	}
+
+	metric := c.DefaultQuery("metric", "fuelConsumed")
+	if !database.IsCumulativeMetric(metric) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "metric must be one of fuelConsumed, fuelTopped, generatorHours, zesaHours or offlineHours",
+		})
+		return
+	}
+
+	startDateString := startDate.Format("2006-01-02")
+	endDateString := endDate.Format("2006-01-02")
+
+	sites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	if !h.checkRangeSize(c, len(sites), startDate, endDate) {
+		return
+	}
+
+	values, err := h.DB.GetCumulativeDailyValues(sites, startDateString, endDateString, metric)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get cumulative matrix: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get cumulative matrix",
+		})
+		return
+	}
+
+	// Fuel metrics are litres (one decimal); the others are hours (two decimals)
+	decimals := 2
+	if metric == "fuelConsumed" || metric == "fuelTopped" {
+		decimals = 1
+	}
+
+	var dates []string
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.Format("2006-01-02"))
+	}
+
+	rows := make([]models.CumulativeMatrixRow, 0, len(sites))
+	for _, site := range sites {
+		row := models.CumulativeMatrixRow{
+			SiteID:   site.ID,
+			SiteName: site.Name,
+			Location: site.Location,
+			Values:   make([]*float64, len(dates)),
+		}
+
+		siteValues := values[site.ID]
+		for i, date := range dates {
+			if value, ok := siteValues[date]; ok {
+				rounded := h.roundToDecimal(value, decimals)
+				row.Values[i] = &rounded
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, models.CumulativeMatrixResponse{
+		Metric: metric,
+		Dates:  dates,
+		Sites:  rows,
+	})
+}
+
+// maxAvailableDatesDays caps the range searched for available dates
+const maxAvailableDatesDays = 366
+
+// GetAvailableDates lists the days between ?startDate= and ?endDate= on which any
+// of the accessible sites has stored cumulative readings, oldest first, so a
+// calendar can highlight them
+func (h *CumulativeHandler) GetAvailableDates(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "endDate must not be before startDate",
+		})
+		return
+	}
+	if h.calculateDaysDifference(startDate, endDate) > maxAvailableDatesDays {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: fmt.Sprintf("Range cannot exceed %d days", maxAvailableDatesDays),
+		})
+		return
+	}
+
+	startDateString := startDate.Format("2006-01-02")
+	endDateString := endDate.Format("2006-01-02")
+
+	sites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	dates, err := h.DB.GetCumulativeAvailableDates(sites, startDateString, endDateString)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get available cumulative dates: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get available dates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.CumulativeAvailableDatesResponse{
+		StartDate: startDateString,
+		EndDate:   endDateString,
+		Dates:     dates,
+	})
+}
+
+// GetOutdatedReadings lists the stored cumulative readings between ?startDate=
+// and ?endDate= computed before the current calculation version, so they can be
+// recomputed by date (POST /cumulative-readings) or by site (rebuild) (admin only)
+func (h *CumulativeHandler) GetOutdatedReadings(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "endDate must not be before startDate",
+		})
+		return
+	}
+
+	startDateString := startDate.Format("2006-01-02")
+	endDateString := endDate.Format("2006-01-02")
+
+	sites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	if !h.checkRangeSize(c, len(sites), startDate, endDate) {
+		return
+	}
+
+	readings, err := h.DB.GetOutdatedCumulativeReadings(sites, startDateString, endDateString, models.CumulativeCalcVersion)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get outdated cumulative readings for %s to %s: %v", startDateString, endDateString, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get outdated readings",
+		})
+		return
+	}
+
+	sitesByID := make(map[int]*models.Site, len(sites))
+	for _, site := range sites {
+		sitesByID[site.ID] = site
+	}
+
+	response := models.OutdatedCumulativeResponse{
+		DateRange: models.DateRange{
+			Start:   startDateString,
+			End:     endDateString,
+			IsRange: startDateString != endDateString,
+		},
+		CalcVersion: models.CumulativeCalcVersion,
+		Total:       len(readings),
+		Dates:       []string{},
+		SiteIDs:     []int{},
+		Readings:    readings,
+	}
+
+	// Readings are ordered by date, so distinct dates come out sorted
+	seenSites := make(map[int]bool)
+	for _, reading := range readings {
+		if site, ok := sitesByID[reading.SiteID]; ok {
+			reading.SiteName = site.Name
+			reading.DeviceID = site.DeviceID
+		}
+		if n := len(response.Dates); n == 0 || response.Dates[n-1] != reading.Date {
+			response.Dates = append(response.Dates, reading.Date)
+		}
+		if !seenSites[reading.SiteID] {
+			seenSites[reading.SiteID] = true
+			response.SiteIDs = append(response.SiteIDs, reading.SiteID)
+		}
+	}
+	sort.Ints(response.SiteIDs)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// siteRebuildWorkers bounds how many days of one site's history are recomputed at once
+const siteRebuildWorkers = 4
+
+// RebuildSiteHistory recomputes and saves cumulative readings for every day of a
+// site's sensor history. With ?dryRun=true the metrics are calculated but nothing
+// is saved or recorded (admin only).
+func (h *CumulativeHandler) RebuildSiteHistory(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	siteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid site ID",
+		})
+		return
+	}
+
+	dryRun := c.Query("dryRun") == "true"
+
+	// Optional bounds narrow the rebuild to part of the site's history
+	fromDate, ok := parseOptionalDate(c, "startDate")
+	if !ok {
+		return
+	}
+	toDate, ok := parseOptionalDate(c, "endDate")
+	if !ok {
+		return
+	}
+
+	site, err := h.DB.GetSiteForUser(siteID, user.ID, user.Role)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get site %d: %v", siteID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if site == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Site not found",
+		})
+		return
+	}
+
+	response := models.SiteCumulativeRebuildResponse{
+		SiteID:   site.ID,
+		SiteName: site.Name,
+		DeviceID: site.DeviceID,
+		DryRun:   dryRun,
+		Days:     []models.CumulativeRebuildDay{},
+	}
+
+	first, last, err := h.DB.GetDeviceReadingSpan(site.DeviceID)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get reading span for site %s: %v", site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sensor history",
+		})
+		return
+	}
+	if first == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Cumulative days are UTC days (see dayBounds) and never run past today
+	startDate := time.Date(first.UTC().Year(), first.UTC().Month(), first.UTC().Day(), 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(last.UTC().Year(), last.UTC().Month(), last.UTC().Day(), 0, 0, 0, 0, time.UTC)
+	if todayDate := today(); endDate.After(todayDate) {
+		endDate = todayDate
+	}
+	if fromDate != nil && fromDate.After(startDate) {
+		startDate = *fromDate
+	}
+	if toDate != nil && toDate.Before(endDate) {
+		endDate = *toDate
+	}
+	response.StartDate = startDate.Format("2006-01-02")
+	response.EndDate = endDate.Format("2006-01-02")
+	if startDate.After(endDate) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Every day is a full recalculation; large histories are rebuilt in parts
+	if maxDays := h.Config.Cumulative.RebuildMaxDays; maxDays > 0 {
+		if days := h.calculateDaysDifference(startDate, endDate); days > maxDays {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+				Message: fmt.Sprintf("Rebuild covers %d days (%s to %s), more than the maximum of %d. Rebuild it in parts with startDate and endDate.",
+					days, response.StartDate, response.EndDate, maxDays),
+			})
+			return
+		}
+	}
+
+	storedDates, err := h.DB.GetCumulativeDates(site.ID)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get stored cumulative dates for site %s: %v", site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get cumulative readings",
+		})
+		return
+	}
+
+	var siteType *models.SiteType
+	if site.TypeID != nil {
+		siteTypes, err := h.DB.GetSiteTypes()
+		if err != nil {
+			middleware.Log(c).Warnf("Failed to get site types, using default expected sensors: %v", err)
+		} else {
+			siteType = siteTypes[*site.TypeID]
+		}
+	}
+
+	var dates []time.Time
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day)
+	}
+
+	middleware.Log(c).Infof("Rebuilding cumulative history for site %s: %d days (dryRun=%t)", site.Name, len(dates), dryRun)
+
+	days := make([]models.CumulativeRebuildDay, len(dates))
+	dayChan := make(chan int, len(dates))
+	for i := range dates {
+		dayChan <- i
+	}
+	close(dayChan)
+
+	var wg sync.WaitGroup
+	for i := 0; i < siteRebuildWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range dayChan {
+				days[index] = h.rebuildSiteDay(site, siteType, dates[index], storedDates, dryRun)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, day := range days {
+		switch day.Status {
+		case "CREATED", "WOULD_CREATE":
+			response.Summary.Created++
+		case "UPDATED", "WOULD_UPDATE":
+			response.Summary.Updated++
+		case "PARTIAL":
+			response.Summary.Partial++
+		default:
+			response.Summary.Errors++
+		}
+	}
+	response.Summary.DaysProcessed = len(days)
+	response.Days = days
+
+	c.JSON(http.StatusOK, response)
+}
+
+// rebuildSiteDay recomputes one day of a site's history, saving it unless dryRun
+func (h *CumulativeHandler) rebuildSiteDay(site *models.Site, siteType *models.SiteType, targetDate time.Time, storedDates map[string]bool, dryRun bool) models.CumulativeRebuildDay {
+	dateString := targetDate.Format("2006-01-02")
+
+	if !dryRun {
+		var existing *models.CumulativeReading
+		if storedDates[dateString] {
+			existing = &models.CumulativeReading{SiteID: site.ID, Date: dateString}
+		}
+
+		result := h.processSingleSite(site, existing, siteType, targetDate, dateString)
+		return models.CumulativeRebuildDay{
+			Date:           dateString,
+			Status:         result.Status,
+			FuelConsumed:   h.roundToDecimal(result.FuelConsumed, 1),
+			FuelTopped:     h.roundToDecimal(result.FuelTopped, 1),
+			GeneratorHours: h.roundToDecimal(result.GeneratorHours, 2),
+			ZesaHours:      h.roundToDecimal(result.ZesaHours, 2),
+			OfflineHours:   h.roundToDecimal(result.OfflineHours, 2),
+			Error:          result.Error,
+
+			MetricsInconsistent: result.MetricsInconsistent,
+		}
+	}
+
+	fuelMetrics, powerMetrics, fuelErr, powerErr := h.calculateSiteMetrics(site, siteType, targetDate)
+
+	day := models.CumulativeRebuildDay{
+		Date:           dateString,
+		Status:         "WOULD_CREATE",
+		FuelConsumed:   h.roundToDecimal(fuelMetrics.TotalFuelConsumed, 1),
+		FuelTopped:     h.roundToDecimal(fuelMetrics.TotalFuelTopped, 1),
+		GeneratorHours: h.roundToDecimal(powerMetrics.TotalGeneratorRuntime, 2),
+		ZesaHours:      h.roundToDecimal(powerMetrics.TotalZesaRuntime, 2),
+		OfflineHours:   h.roundToDecimal(powerMetrics.TotalOfflineTime, 2),
+
+		MetricsInconsistent: fuelErr == nil && fuelMetrics.MetricsInconsistent,
+	}
+	if storedDates[dateString] {
+		day.Status = "WOULD_UPDATE"
+	}
+
+	switch {
+	case fuelErr != nil && powerErr != nil:
+		day.Status = "ERROR"
+	case fuelErr != nil || powerErr != nil:
+		day.Status = "PARTIAL"
+	}
+	if fuelErr != nil || powerErr != nil {
+		day.Error = fmt.Sprintf("Calculation error: fuel=%v, power=%v", fuelErr, powerErr)
+	}
+
+	return day
 }
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"fuel-monitor-api/internal/middleware"
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Number formats of the exported workbook
+const (
+	litersFormat  = "#,##0.0"
+	hoursFormat   = "#,##0.00"
+	percentFormat = "0.0"
+)
+
+// ExportCumulativeRange downloads the accessible sites' cumulative readings for
+// ?startDate=&endDate= as an Excel workbook (?format=xlsx) with a summary sheet
+// and a per-site sheet ending in a totals row
+func (h *CumulativeHandler) ExportCumulativeRange(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	if format := c.DefaultQuery("format", "xlsx"); format != "xlsx" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "format must be xlsx",
+		})
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	startDateString := startDate.Format("2006-01-02")
+	endDateString := endDate.Format("2006-01-02")
+
+	sites, err := h.DB.GetReportSitesForUser(user.ID, user.Role, includeInactive(c))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	if !h.checkRangeSize(c, len(sites), startDate, endDate) {
+		return
+	}
+
+	siteReadings, err := h.getRangeResults(sites, startDateString, endDateString)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get range data for export: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get cumulative readings",
+		})
+		return
+	}
+
+	summary := h.calculateRangeSummary(siteReadings, startDateString, endDateString, startDate, endDate)
+
+	workbook, err := buildRangeWorkbook(siteReadings, summary)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to build cumulative workbook: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to build export",
+		})
+		return
+	}
+	defer workbook.Close()
+
+	buffer, err := workbook.WriteToBuffer()
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to write cumulative workbook: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to build export",
+		})
+		return
+	}
+
+	middleware.Log(c).Infof("Cumulative range export for %s: %s to %s, %d sites", user.Username, startDateString, endDateString, len(siteReadings))
+
+	filename := fmt.Sprintf("cumulative-%s-to-%s.xlsx", startDateString, endDateString)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, xlsxContentType, buffer.Bytes())
+}
+
+// buildRangeWorkbook lays out the range summary and the per-site totals as a workbook
+func buildRangeWorkbook(results []models.CumulativeSiteRangeResult, summary models.CumulativeRangeSummary) (*excelize.File, error) {
+	f := excelize.NewFile()
+	if err := writeRangeWorkbook(f, results, summary); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// writeRangeWorkbook fills the Summary and Sites sheets
+func writeRangeWorkbook(f *excelize.File, results []models.CumulativeSiteRangeResult, summary models.CumulativeRangeSummary) error {
+	styles, err := newWorkbookStyles(f)
+	if err != nil {
+		return err
+	}
+
+	// Summary sheet: one labelled value per row
+	const summarySheet = "Summary"
+	if err := f.SetSheetName("Sheet1", summarySheet); err != nil {
+		return err
+	}
+
+	summaryRows := []struct {
+		label string
+		value interface{}
+		style int
+	}{
+		{"Start date", summary.DateRange.Start, 0},
+		{"End date", summary.DateRange.End, 0},
+		{"Days included", summary.DaysIncluded, 0},
+		{"Sites", summary.TotalSites, 0},
+		{"Fuel consumed (L)", summary.TotalFuelConsumed, styles.liters},
+		{"Fuel topped (L)", summary.TotalFuelTopped, styles.liters},
+		{"Generator hours", summary.TotalGeneratorHours, styles.hours},
+		{"ZESA hours", summary.TotalZesaHours, styles.hours},
+		{"Offline hours", summary.TotalOfflineHours, styles.hours},
+		{"Average fuel per site (L)", summary.AverageFuelPerSite, styles.liters},
+		{"Average uptime (%)", summary.AverageUptime, styles.percent},
+	}
+	for i, row := range summaryRows {
+		labelCell, _ := excelize.CoordinatesToCellName(1, i+1)
+		valueCell, _ := excelize.CoordinatesToCellName(2, i+1)
+		if err := f.SetSheetRow(summarySheet, labelCell, &[]interface{}{row.label, row.value}); err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(summarySheet, labelCell, labelCell, styles.header); err != nil {
+			return err
+		}
+		if row.style != 0 {
+			if err := f.SetCellStyle(summarySheet, valueCell, valueCell, row.style); err != nil {
+				return err
+			}
+		}
+	}
+	if err := f.SetColWidth(summarySheet, "A", "A", 28); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(summarySheet, "B", "B", 16); err != nil {
+		return err
+	}
+
+	// Sites sheet: a header row, one row per site and a totals row
+	const sitesSheet = "Sites"
+	if _, err := f.NewSheet(sitesSheet); err != nil {
+		return err
+	}
+
+	headers := []interface{}{"Site", "Device ID", "Fuel consumed (L)", "Fuel topped (L)", "Generator hours",
+		"ZESA hours", "Offline hours", "Uptime (%)", "Reading days", "First date", "Last date"}
+	if err := f.SetSheetRow(sitesSheet, "A1", &headers); err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sitesSheet, "A1", "K1", styles.header); err != nil {
+		return err
+	}
+
+	for i, result := range results {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		row := []interface{}{
+			result.SiteName,
+			result.DeviceID,
+			result.TotalFuelConsumed,
+			result.TotalFuelTopped,
+			result.TotalGeneratorHours,
+			result.TotalZesaHours,
+			result.TotalOfflineHours,
+			result.UptimePercent,
+			result.ReadingDays,
+			result.DateRange.Start,
+			result.DateRange.End,
+		}
+		if err := f.SetSheetRow(sitesSheet, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	totalsRow := len(results) + 2
+	totalsCell, _ := excelize.CoordinatesToCellName(1, totalsRow)
+	totals := []interface{}{
+		"Total",
+		"",
+		summary.TotalFuelConsumed,
+		summary.TotalFuelTopped,
+		summary.TotalGeneratorHours,
+		summary.TotalZesaHours,
+		summary.TotalOfflineHours,
+		summary.AverageUptime,
+	}
+	if err := f.SetSheetRow(sitesSheet, totalsCell, &totals); err != nil {
+		return err
+	}
+
+	// Number formats per column, with the totals row in bold
+	columns := []struct {
+		column      string
+		style       int
+		totalsStyle int
+	}{
+		{"A", 0, styles.header},
+		{"C", styles.liters, styles.totalLiters},
+		{"D", styles.liters, styles.totalLiters},
+		{"E", styles.hours, styles.totalHours},
+		{"F", styles.hours, styles.totalHours},
+		{"G", styles.hours, styles.totalHours},
+		{"H", styles.percent, styles.totalPercent},
+	}
+	for _, column := range columns {
+		if column.style != 0 && len(results) > 0 {
+			if err := f.SetCellStyle(sitesSheet, fmt.Sprintf("%s2", column.column), fmt.Sprintf("%s%d", column.column, totalsRow-1), column.style); err != nil {
+				return err
+			}
+		}
+		cell := fmt.Sprintf("%s%d", column.column, totalsRow)
+		if err := f.SetCellStyle(sitesSheet, cell, cell, column.totalsStyle); err != nil {
+			return err
+		}
+	}
+
+	if err := f.SetColWidth(sitesSheet, "A", "B", 24); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(sitesSheet, "C", "K", 16); err != nil {
+		return err
+	}
+
+	// Keep the header visible while scrolling through sites
+	return f.SetPanes(sitesSheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	})
+}
+
+// workbookStyles are the cell style IDs used by the exported workbook
+type workbookStyles struct {
+	header       int
+	liters       int
+	hours        int
+	percent      int
+	totalLiters  int
+	totalHours   int
+	totalPercent int
+}
+
+// newWorkbookStyles registers the workbook's cell styles
+func newWorkbookStyles(f *excelize.File) (workbookStyles, error) {
+	var styles workbookStyles
+	var err error
+
+	newStyle := func(numFmt string, bold bool) int {
+		if err != nil {
+			return 0
+		}
+		style := &excelize.Style{}
+		if numFmt != "" {
+			format := numFmt
+			style.CustomNumFmt = &format
+		}
+		if bold {
+			style.Font = &excelize.Font{Bold: true}
+		}
+		var id int
+		id, err = f.NewStyle(style)
+		return id
+	}
+
+	styles.header = newStyle("", true)
+	styles.liters = newStyle(litersFormat, false)
+	styles.hours = newStyle(hoursFormat, false)
+	styles.percent = newStyle(percentFormat, false)
+	styles.totalLiters = newStyle(litersFormat, true)
+	styles.totalHours = newStyle(hoursFormat, true)
+	styles.totalPercent = newStyle(percentFormat, true)
+	return styles, err
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fuel-monitor-api/internal/logger"
+)
+
+// cumulativeScheduleLockKey is the PostgreSQL advisory lock held by the instance
+// running scheduled cumulative processing
+const cumulativeScheduleLockKey int64 = 0x46756c4375 // "FulCu"
+
+// RunDailySchedule processes the previous day's cumulative readings for every
+// active site once a day at Cumulative.ScheduleTime (HH:MM, local time) until ctx
+// is cancelled. Days missed while the service was down are caught up first. Site
+// failures are recorded in cumulative_errors as for requested runs.
+func (h *CumulativeHandler) RunDailySchedule(ctx context.Context) {
+	if !h.Config.Cumulative.ScheduleEnabled {
+		logger.Infof("Scheduled cumulative processing disabled")
+		return
+	}
+
+	clock, err := time.Parse("15:04", h.Config.Cumulative.ScheduleTime)
+	if err != nil {
+		logger.Errorf("Scheduled cumulative processing disabled: invalid time %q, use HH:MM", h.Config.Cumulative.ScheduleTime)
+		return
+	}
+
+	h.backfillScheduledDays(ctx, clock.Hour(), clock.Minute())
+
+	for {
+		next := nextDailyRun(time.Now(), clock.Hour(), clock.Minute())
+		logger.Infof("Next scheduled cumulative processing at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		h.runScheduledDays(ctx, []time.Time{next.AddDate(0, 0, -1)})
+	}
+}
+
+// nextDailyRun returns the first time after now at hour:minute local time
+func nextDailyRun(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, now.Location())
+	}
+	return next
+}
+
+// backfillScheduledDays processes the days the schedule should already have run
+// for that have no stored readings
+func (h *CumulativeHandler) backfillScheduledDays(ctx context.Context, hour, minute int) {
+	if h.Config.Cumulative.ScheduleBackfillDays < 1 {
+		return
+	}
+
+	latest, err := h.DB.GetLatestCumulativeDate()
+	if err != nil {
+		logger.Errorf("Scheduled cumulative catch-up skipped: %v", err)
+		return
+	}
+
+	days := missedScheduledDays(time.Now(), hour, minute, latest, h.Config.Cumulative.ScheduleBackfillDays)
+	if len(days) == 0 {
+		return
+	}
+	logger.Infof("Catching up scheduled cumulative processing for %d missed days from %s",
+		len(days), days[0].Format("2006-01-02"))
+	h.runScheduledDays(ctx, days)
+}
+
+// missedScheduledDays returns the days after latest (nil when nothing is stored)
+// that a schedule running daily at hour:minute should have processed by now,
+// oldest first and at most limit of the most recent
+func missedScheduledDays(now time.Time, hour, minute int, latest *time.Time, limit int) []time.Time {
+	// The last run was the day before the next one and processed the day before it
+	lastRun := nextDailyRun(now, hour, minute).AddDate(0, 0, -1)
+	lastDay := time.Date(lastRun.Year(), lastRun.Month(), lastRun.Day()-1, 0, 0, 0, 0, now.Location())
+
+	first := lastDay.AddDate(0, 0, 1-limit)
+	if latest != nil {
+		afterLatest := time.Date(latest.Year(), latest.Month(), latest.Day()+1, 0, 0, 0, 0, now.Location())
+		if afterLatest.After(first) {
+			first = afterLatest
+		}
+	}
+
+	days := []time.Time{}
+	for day := first; !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// runScheduledDays processes the days in order while holding the schedule's
+// advisory lock, so instances sharing the database do not process them twice.
+// Nothing is processed when another instance holds the lock.
+func (h *CumulativeHandler) runScheduledDays(ctx context.Context, days []time.Time) {
+	unlock, err := h.DB.TryAdvisoryLock(ctx, cumulativeScheduleLockKey)
+	if err != nil {
+		logger.Errorf("Scheduled cumulative processing failed: %v", err)
+		return
+	}
+	if unlock == nil {
+		logger.Infof("Scheduled cumulative processing skipped: another instance is running it")
+		return
+	}
+	defer unlock()
+
+	for _, day := range days {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := h.runScheduledDay(day); err != nil {
+			logger.Errorf("Scheduled cumulative processing failed: %v", err)
+		}
+	}
+}
+
+// runScheduledDay processes the cumulative readings of every active site for the given day
+func (h *CumulativeHandler) runScheduledDay(day time.Time) error {
+	start := time.Now()
+	dateString := day.Format("2006-01-02")
+	targetDate, err := time.Parse("2006-01-02", dateString)
+	if err != nil {
+		return err
+	}
+
+	sites, err := h.DB.GetAllSites()
+	if err != nil {
+		return fmt.Errorf("failed to get sites: %w", err)
+	}
+
+	logger.Infof("Scheduled cumulative processing for %s started: %d sites", dateString, len(sites))
+
+	results, err := h.processSites(sites, targetDate, dateString)
+	if err != nil {
+		return fmt.Errorf("failed to check existing readings: %w", err)
+	}
+
+	summary := h.calculateSummary(results, len(sites))
+	logger.Infof("Scheduled cumulative processing for %s completed in %v: %d sites, %d processed, %d partial, %d errors",
+		dateString, time.Since(start).Round(time.Second), summary.TotalSites, summary.ProcessedSites, summary.PartialSites, summary.ErrorSites)
+	return nil
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextDailyRun(t *testing.T) {
+	day := func(d, h, m int) time.Time { return time.Date(2024, 3, d, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"before today's run", day(5, 0, 30), day(5, 1, 0)},
+		{"at the run time", day(5, 1, 0), day(6, 1, 0)},
+		{"after today's run", day(5, 13, 0), day(6, 1, 0)},
+		{"end of month", time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 1, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextDailyRun(tt.now, 1, 0); !got.Equal(tt.want) {
+				t.Errorf("nextDailyRun(%s) = %s, want %s", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissedScheduledDays(t *testing.T) {
+	date := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		latest *time.Time
+		limit  int
+		want   []string
+	}{
+		{"up to date", time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC), ptr(date(9)), 7, []string{}},
+		{"before today's run", time.Date(2024, 3, 10, 0, 30, 0, 0, time.UTC), ptr(date(8)), 7, []string{}},
+		{"missed two days", time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC), ptr(date(7)), 7, []string{"2024-03-08", "2024-03-09"}},
+		{"capped at limit", time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC), ptr(date(1)), 3, []string{"2024-03-07", "2024-03-08", "2024-03-09"}},
+		{"nothing stored", time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC), nil, 2, []string{"2024-03-08", "2024-03-09"}},
+		{"latest in the future", time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC), ptr(date(12)), 7, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days := missedScheduledDays(tt.now, 1, 0, tt.latest, tt.limit)
+			got := make([]string, len(days))
+			for i, day := range days {
+				got[i] = day.Format("2006-01-02")
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("missedScheduledDays = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunScheduledDaysLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		lockHeld bool
+		wantRun  bool
+	}{
+		{"lock free", false, true},
+		{"held by another instance", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, state := newFakeDB(t, 2)
+			state.lockHeld = tt.lockHeld
+			h := &CumulativeHandler{DB: db}
+
+			h.runScheduledDays(context.Background(), []time.Time{time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)})
+
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			ran, unlocked := false, false
+			for _, query := range state.queries {
+				if strings.Contains(query, "FROM sites") {
+					ran = true
+				}
+			}
+			for _, exec := range state.execs {
+				if strings.Contains(exec, "pg_advisory_unlock") {
+					unlocked = true
+				}
+			}
+			if ran != tt.wantRun {
+				t.Errorf("processed sites = %v, want %v", ran, tt.wantRun)
+			}
+			if unlocked != tt.wantRun {
+				t.Errorf("released lock = %v, want %v", unlocked, tt.wantRun)
+			}
+		})
+	}
+}
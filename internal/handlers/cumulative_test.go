@@ -0,0 +1,621 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetStoredCumulativeReadings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	at := time.Date(2024, 3, 2, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		target   string
+		wantFuel interface{}
+	}{
+		{"numbers by default", "/stored?date=2024-03-01", 120.456},
+		{"legacy strings", "/stored?date=2024-03-01&format=legacy", "120.46"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				switch {
+				case strings.Contains(query, "FROM sites"):
+					return siteRows(&models.Site{ID: 3, Name: "Site A", DeviceID: "simbisa-a", IsActive: true, CreatedAt: at})
+				case strings.Contains(query, "FROM cumulative_readings"):
+					if args[0].Value != "2024-03-01" {
+						return nil, nil, fmt.Errorf("date = %v, want 2024-03-01", args[0].Value)
+					}
+					// Metric columns are text in the database
+					return []string{"id", "site_id", "device_id", "date", "total_fuel_consumed", "total_fuel_topped_up",
+							"fuel_consumed_percent", "fuel_topped_up_percent", "total_generator_runtime",
+							"total_zesa_runtime", "total_offline_time", "generator_starts", "calc_version", "calculated_at", "created_at"},
+						[][]driver.Value{{int64(7), int64(3), "simbisa-a", "2024-03-01", "120.456", "0", "12.5", "0",
+							"3.333", "20.666", "0.001", int64(2), int64(3), at, at}}, nil
+				}
+				return nil, nil, fmt.Errorf("unexpected query: %s", query)
+			}
+			cfg := &config.Config{}
+			handler := NewCumulativeHandler(db, cfg, settings.NewStore(db, cfg), watchdog.New())
+
+			router := gin.New()
+			router.GET("/stored", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+			}, handler.GetStoredCumulativeReadings)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+			}
+
+			var resp struct {
+				Date     string                   `json:"date"`
+				Readings []map[string]interface{} `json:"readings"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Readings) != 1 {
+				t.Fatalf("got %d readings, want 1: %s", len(resp.Readings), recorder.Body)
+			}
+			if got := resp.Readings[0]["totalFuelConsumed"]; got != tt.wantFuel {
+				t.Errorf("totalFuelConsumed = %#v, want %#v", got, tt.wantFuel)
+			}
+		})
+	}
+}
+
+func testSites(count int) []*models.Site {
+	sites := make([]*models.Site, count)
+	for i := range sites {
+		sites[i] = &models.Site{ID: i + 1, Name: "Site", DeviceID: "device"}
+	}
+	return sites
+}
+
+func testCalculations(sites []*models.Site) []siteCalculation {
+	calculations := make([]siteCalculation, len(sites))
+	for i, site := range sites {
+		calculations[i] = siteCalculation{site: site}
+	}
+	return calculations
+}
+
+func TestForEachBatchBoundsConcurrency(t *testing.T) {
+	tests := []struct {
+		name      string
+		sites     int
+		batchSize int
+		workers   int
+		batches   int
+	}{
+		{"more batches than workers", 95, 10, 3, 10},
+		{"fewer batches than workers", 15, 10, 4, 2},
+		{"no sites", 0, 10, 4, 0},
+		{"invalid limits", 3, 0, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			running, maxRunning, batches := 0, 0, 0
+			seen := make(map[int]int)
+
+			forEachBatch(testSites(tt.sites), tt.batchSize, tt.workers, func(batch []*models.Site) {
+				mu.Lock()
+				running++
+				batches++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				for _, site := range batch {
+					seen[site.ID]++
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+			})
+
+			workers := tt.workers
+			if workers < 1 {
+				workers = 1
+			}
+			if maxRunning > workers {
+				t.Errorf("ran %d batches at once, want at most %d", maxRunning, workers)
+			}
+			if batches != tt.batches {
+				t.Errorf("ran %d batches, want %d", batches, tt.batches)
+			}
+			for id := 1; id <= tt.sites; id++ {
+				if seen[id] != 1 {
+					t.Errorf("site %d processed %d times, want 1", id, seen[id])
+				}
+			}
+		})
+	}
+}
+
+func TestSaveBatchInTransaction(t *testing.T) {
+	tests := []struct {
+		name       string
+		failSiteID int64
+		wantStatus []string
+		commits    int
+		rollbacks  int
+	}{
+		{"all saved", 0, []string{"CREATED", "CREATED", "CREATED"}, 1, 0},
+		{"mid-batch failure rolls back", 2, []string{"ERROR", "ERROR", "ERROR"}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A single connection: recording errors while the transaction still
+			// held it would block forever
+			db, state := newFakeDB(t, 1)
+			state.failSiteID = tt.failSiteID
+			h := &CumulativeHandler{DB: db}
+
+			sites := testSites(3)
+			done := make(chan []models.CumulativeSiteResult)
+			go func() {
+				done <- h.saveBatchInTransaction(sites, testCalculations(sites), "2024-01-02")
+			}()
+
+			var results []models.CumulativeSiteResult
+			select {
+			case results = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("batch did not finish; connection pool deadlocked")
+			}
+
+			if len(results) != len(tt.wantStatus) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.wantStatus))
+			}
+			for i, result := range results {
+				if result.Status != tt.wantStatus[i] {
+					t.Errorf("site %d: status %s, want %s", result.SiteID, result.Status, tt.wantStatus[i])
+				}
+			}
+
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			if state.commits != tt.commits {
+				t.Errorf("commits = %d, want %d", state.commits, tt.commits)
+			}
+			if state.rollbacks < tt.rollbacks {
+				t.Errorf("rollbacks = %d, want at least %d", state.rollbacks, tt.rollbacks)
+			}
+
+			var recorded, cleared int
+			for _, exec := range state.execs {
+				switch {
+				case strings.HasPrefix(exec, "INSERT INTO cumulative_errors"):
+					recorded++
+				case strings.HasPrefix(exec, "DELETE FROM cumulative_errors"):
+					cleared++
+				}
+			}
+			if tt.failSiteID != 0 && (recorded != len(sites) || cleared != 0) {
+				t.Errorf("after rollback: %d errors recorded and %d cleared, want %d and 0", recorded, cleared, len(sites))
+			}
+			if tt.failSiteID == 0 && (recorded != 0 || cleared != len(sites)) {
+				t.Errorf("after commit: %d errors recorded and %d cleared, want 0 and %d", recorded, cleared, len(sites))
+			}
+		})
+	}
+}
+
+func TestTodayIsUTCDay(t *testing.T) {
+	before := time.Now().UTC()
+	got := today()
+	after := time.Now().UTC()
+
+	if got.Location() != time.UTC || got.Hour() != 0 || got.Minute() != 0 || got.Second() != 0 || got.Nanosecond() != 0 {
+		t.Fatalf("today() = %v, want a UTC midnight", got)
+	}
+	// The UTC date may roll over between the calls
+	if day := got.Format("2006-01-02"); day != before.Format("2006-01-02") && day != after.Format("2006-01-02") {
+		t.Errorf("today() = %s, want the UTC date %s", day, before.Format("2006-01-02"))
+	}
+}
+
+// storedDay is one stored cumulative_readings row
+type storedDay struct {
+	siteID                           int64
+	date                             string
+	fuel, topped, gen, zesa, offline float64
+	calcVersion                      int
+}
+
+// answerRangeTotals answers both range total queries, the grouped one and the
+// per-site one, by aggregating days the way PostgreSQL would
+func answerRangeTotals(days []storedDay) fakeQuery {
+	// aggregate returns a site's totals between start and end, or nil without readings
+	aggregate := func(siteID int64, start, end string) []driver.Value {
+		var count, outdated int64
+		var fuel, topped, gen, zesa, offline float64
+		var first, last string
+		for _, day := range days {
+			if day.siteID != siteID || day.date < start || day.date > end {
+				continue
+			}
+			count++
+			fuel += day.fuel
+			topped += day.topped
+			gen += day.gen
+			zesa += day.zesa
+			offline += day.offline
+			if day.calcVersion < models.CumulativeCalcVersion {
+				outdated++
+			}
+			if first == "" || day.date < first {
+				first = day.date
+			}
+			if day.date > last {
+				last = day.date
+			}
+		}
+		if count == 0 {
+			return nil
+		}
+		return []driver.Value{count, fuel, topped, gen, zesa, offline, outdated, first, last}
+	}
+	columns := []string{"reading_days", "total_fuel_consumed", "total_fuel_topped", "total_generator_hours",
+		"total_zesa_hours", "total_offline_hours", "outdated_days", "first_date", "last_date"}
+
+	return func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		if !strings.Contains(query, "FROM cumulative_readings") {
+			return nil, nil, fmt.Errorf("unexpected query: %s", query)
+		}
+
+		if strings.Contains(query, "GROUP BY site_id") {
+			start, end := args[0].Value.(string), args[1].Value.(string)
+			var rows [][]driver.Value
+			for _, arg := range args[2:] {
+				if totals := aggregate(arg.Value.(int64), start, end); totals != nil {
+					rows = append(rows, append([]driver.Value{arg.Value}, totals...))
+				}
+			}
+			return append([]string{"site_id"}, columns...), rows, nil
+		}
+
+		totals := aggregate(args[0].Value.(int64), args[1].Value.(string), args[2].Value.(string))
+		if totals == nil {
+			// An aggregate over no rows still returns one row, with NULL sums
+			totals = []driver.Value{int64(0), nil, nil, nil, nil, nil, int64(0), nil, nil}
+		}
+		return columns, [][]driver.Value{totals}, nil
+	}
+}
+
+func TestRangeTotalsGroupedMatchesPerSite(t *testing.T) {
+	var sites []*models.Site
+	var days []storedDay
+	for id := 1; id <= 12; id++ {
+		sites = append(sites, &models.Site{ID: id, Name: fmt.Sprintf("Site %d", id), DeviceID: fmt.Sprintf("simbisa-%d", id)})
+		// Site 12 has no readings in the range
+		if id == 12 {
+			days = append(days, storedDay{siteID: 12, date: "2024-02-28", fuel: 50, calcVersion: models.CumulativeCalcVersion})
+			continue
+		}
+		for day := 1; day <= 3; day++ {
+			days = append(days, storedDay{
+				siteID: int64(id), date: fmt.Sprintf("2024-03-%02d", day),
+				// Distinct totals that need rounding
+				fuel: float64(id)*10.37 + float64(day)*0.01, topped: float64(day) * 1.234,
+				gen: 0.105 * float64(id), zesa: 20.333, offline: 24 - 0.105*float64(id) - 20.333,
+				calcVersion: models.CumulativeCalcVersion - day%2,
+			})
+		}
+		// Outside the range
+		days = append(days, storedDay{siteID: int64(id), date: "2024-03-09", fuel: 999, calcVersion: models.CumulativeCalcVersion})
+	}
+
+	results := func(grouped bool) []models.CumulativeSiteRangeResult {
+		t.Helper()
+		db, fake := newFakeDB(t, 4)
+		fake.answer = answerRangeTotals(days)
+		cfg := &config.Config{Cumulative: config.CumulativeConfig{RangeGroupedQuery: grouped, RangeBatchSize: 5}}
+		handler := NewCumulativeHandler(db, cfg, settings.NewStore(db, cfg), watchdog.New())
+
+		results, err := handler.getRangeResults(sites, "2024-03-01", "2024-03-05")
+		if err != nil {
+			t.Fatalf("getRangeResults(grouped %t): %v", grouped, err)
+		}
+		return results
+	}
+
+	grouped, perSite := results(true), results(false)
+	if !reflect.DeepEqual(grouped, perSite) {
+		t.Fatalf("grouped totals differ from per-site totals:\ngrouped  %+v\nper-site %+v", grouped, perSite)
+	}
+
+	if len(grouped) != 11 {
+		t.Fatalf("got %d sites, want the 11 with readings in range", len(grouped))
+	}
+	// Highest fuel consumption first: site 11 used 3*114.07 + 0.06 liters
+	top := grouped[0]
+	if top.SiteID != 11 || top.TotalFuelConsumed != 342.3 || top.TotalGeneratorHours != 3.47 || top.ReadingDays != 3 || top.OutdatedDays != 2 ||
+		top.DateRange != (models.DateRange{Start: "2024-03-01", End: "2024-03-03"}) {
+		t.Errorf("top site = %+v", top)
+	}
+}
+
+func TestRangeCachingUsesUTCDay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+
+	todayUTC := today().Format("2006-01-02")
+	yesterdayUTC := today().AddDate(0, 0, -1).Format("2006-01-02")
+	calculatedAt := time.Now().UTC()
+
+	tests := []struct {
+		name    string
+		endDate string
+		want    string
+	}{
+		{"range ending today", todayUTC, "private, no-cache"},
+		{"closed range", yesterdayUTC, "private, max-age=3600"},
+	}
+
+	// The server's local date is a day off from UTC for part of every day in these zones
+	for _, zone := range []*time.Location{time.FixedZone("UTC+14", 14*3600), time.FixedZone("UTC-12", -12*3600)} {
+		time.Local = zone
+		for _, tt := range tests {
+			t.Run(zone.String()+"/"+tt.name, func(t *testing.T) {
+				db, fake := newFakeDB(t, 1)
+				rangeTotals := answerRangeTotals([]storedDay{{siteID: 1, date: tt.endDate, fuel: 10, calcVersion: models.CumulativeCalcVersion}})
+				fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+					switch {
+					case strings.Contains(query, "FROM sites"):
+						return siteRows(&models.Site{ID: 1, Name: "Site A", DeviceID: "simbisa-a", IsActive: true})
+					case strings.Contains(query, "MAX(calculated_at)"):
+						return []string{"count", "max"}, [][]driver.Value{{int64(1), calculatedAt}}, nil
+					}
+					return rangeTotals(query, args)
+				}
+				cfg := &config.Config{Cumulative: config.CumulativeConfig{RangeCacheMaxAge: time.Hour}}
+				handler := NewCumulativeHandler(db, cfg, settings.NewStore(db, cfg), watchdog.New())
+
+				router := gin.New()
+				router.GET("/range", func(c *gin.Context) {
+					c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+				}, handler.GetCumulativeReadingsByDateRange)
+
+				recorder := httptest.NewRecorder()
+				target := "/range?startDate=" + yesterdayUTC + "&endDate=" + tt.endDate
+				router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+				if recorder.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+				}
+				if got := recorder.Header().Get("Cache-Control"); got != tt.want {
+					t.Errorf("Cache-Control = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestUptimePercent(t *testing.T) {
+	now := time.Date(2024, 3, 3, 6, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		active      float64
+		offline     float64
+		readingDays int
+		lastDate    string
+		want        float64
+	}{
+		{"full uptime", 48, 0, 2, "2024-03-02", 100},
+		{"full uptime with overlapping sources", 40, 0, 1, "2024-03-02", 100},
+		{"partial uptime", 18, 6, 1, "2024-03-02", 75},
+		// Generator and zesa overlapped for 6 hours: only 24-6 hours had power
+		{"partial uptime with overlapping sources", 24, 6, 1, "2024-03-02", 75},
+		{"zero uptime", 0, 48, 2, "2024-03-02", 0},
+		{"no covered hours", 0, 0, 0, "", 0},
+		// Today is covered for the 6 hours elapsed so far
+		{"today in progress", 3, 3, 1, "2024-03-03", 50},
+		{"range ending today", 27, 3, 2, "2024-03-03T00:00:00Z", 90},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uptimePercent(tt.active, tt.offline, coveredHours(tt.readingDays, tt.lastDate, now))
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("uptimePercent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRebuildSiteHistoryCapsDays(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	first := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	last := time.Date(2024, 12, 31, 22, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantDays   int
+		wantRange  string
+		wantErr    string
+	}{
+		{"whole history over the limit", "", http.StatusRequestEntityTooLarge, 0, "", "Rebuild covers 366 days (2024-01-01 to 2024-12-31), more than the maximum of 92"},
+		{"narrowed to part of the history", "&startDate=2024-03-01&endDate=2024-03-03", http.StatusOK, 3, "2024-03-01 2024-03-03", ""},
+		{"bounds clamped to the history", "&startDate=2023-12-01&endDate=2024-01-02", http.StatusOK, 2, "2024-01-01 2024-01-02", ""},
+		{"bounds outside the history", "&endDate=2023-12-31", http.StatusOK, 0, "2024-01-01 2023-12-31", ""},
+		{"invalid bound", "&startDate=yesterday", http.StatusBadRequest, 0, "", "Invalid startDate format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				switch {
+				case strings.Contains(query, "FROM sites"):
+					return siteRows(&models.Site{ID: 3, Name: "Site A", DeviceID: "simbisa-a", IsActive: true})
+				case strings.Contains(query, "MIN(time), MAX(time)"):
+					return []string{"min", "max"}, [][]driver.Value{{first, last}}, nil
+				case strings.Contains(query, "FROM cumulative_readings"):
+					return []string{"date"}, nil, nil
+				}
+				// No sensor readings: every day fails to calculate
+				return nil, nil, fmt.Errorf("no readings")
+			}
+			cfg := &config.Config{Cumulative: config.CumulativeConfig{RebuildMaxDays: 92}}
+			handler := NewCumulativeHandler(db, cfg, settings.NewStore(db, cfg), watchdog.New())
+
+			router := gin.New()
+			router.POST("/sites/:id/cumulative/rebuild", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+			}, handler.RebuildSiteHistory)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/sites/3/cumulative/rebuild?dryRun=true"+tt.query, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantErr != "" {
+				if !strings.Contains(recorder.Body.String(), tt.wantErr) {
+					t.Errorf("body = %s, want it to mention %q", recorder.Body, tt.wantErr)
+				}
+				return
+			}
+
+			var resp models.SiteCumulativeRebuildResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got := resp.StartDate + " " + resp.EndDate; got != tt.wantRange {
+				t.Errorf("range = %s, want %s", got, tt.wantRange)
+			}
+			if len(resp.Days) != tt.wantDays || resp.Summary.DaysProcessed != tt.wantDays {
+				t.Errorf("got %d days (%d processed), want %d", len(resp.Days), resp.Summary.DaysProcessed, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestGetCumulativeReadingsRejectsFutureDates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		date       string
+		wantStatus int
+	}{
+		{"today", today().Format("2006-01-02"), http.StatusOK},
+		{"tomorrow", today().AddDate(0, 0, 1).Format("2006-01-02"), http.StatusBadRequest},
+		{"tomorrow day first", today().AddDate(0, 0, 1).Format("02/01/2006"), http.StatusBadRequest},
+		{"far future", "2999-12-31", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			// No sites: an accepted date answers straight away with empty results
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "FROM sites") {
+					return nil, nil, fmt.Errorf("unexpected query: %s", query)
+				}
+				return siteRows()
+			}
+			cfg := &config.Config{}
+			handler := NewCumulativeHandler(db, cfg, settings.NewStore(db, cfg), watchdog.New())
+
+			router := gin.New()
+			router.POST("/cumulative-readings", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+			}, handler.GetCumulativeReadings)
+
+			recorder := httptest.NewRecorder()
+			body := fmt.Sprintf(`{"date":%q}`, tt.date)
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/cumulative-readings", strings.NewReader(body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+			if got := recorder.Body.String(); got != `{"message":"date cannot be in the future"}` {
+				t.Errorf("body = %s, want the future date error", got)
+			}
+			// Rejected before any sites are loaded or rows stored
+			if len(fake.queries) != 0 || len(fake.execs) != 0 {
+				t.Errorf("ran %d queries and %d statements, want none", len(fake.queries), len(fake.execs))
+			}
+		})
+	}
+}
+
+func TestParseDateRangeFutureDates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	todayDate := today().Format("2006-01-02")
+	tomorrow := today().AddDate(0, 0, 1).Format("2006-01-02")
+	yesterday := today().AddDate(0, 0, -1).Format("2006-01-02")
+
+	tests := []struct {
+		name      string
+		query     string
+		wantOK    bool
+		wantRange string
+	}{
+		{"ending today", "startDate=" + yesterday + "&endDate=" + todayDate, true, yesterday + " " + todayDate},
+		{"ending tomorrow is capped", "startDate=" + yesterday + "&endDate=" + tomorrow, true, yesterday + " " + todayDate},
+		{"ending far in the future is capped", "startDate=" + todayDate + "&endDate=2999-12-31", true, todayDate + " " + todayDate},
+		{"starting tomorrow", "startDate=" + tomorrow, false, ""},
+		{"starting far in the future", "startDate=2999-01-01&endDate=2999-12-31", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/range?"+tt.query, nil)
+
+			startDate, endDate, ok := parseDateRange(c)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v: %s", ok, tt.wantOK, recorder.Body)
+			}
+			if !ok {
+				if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "startDate cannot be in the future") {
+					t.Errorf("response = %d %s, want 400 with the future start error", recorder.Code, recorder.Body)
+				}
+				return
+			}
+			if got := startDate.Format("2006-01-02") + " " + endDate.Format("2006-01-02"); got != tt.wantRange {
+				t.Errorf("range = %s, want %s", got, tt.wantRange)
+			}
+		})
+	}
+}
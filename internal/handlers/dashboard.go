@@ -1,29 +1,42 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"fuel-monitor-api/internal/alerts"
+	"fuel-monitor-api/internal/config"
 	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/logger"
 	"fuel-monitor-api/internal/middleware"
 	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
 
 	"github.com/gin-gonic/gin"
 )
 
 type DashboardHandler struct {
-	DB *database.DB
+	DB         *database.DB
+	Config     *config.Config
+	Settings   *settings.Store
+	Escalation *alerts.FuelEscalation
+	Watchdog   *watchdog.Watchdog
 }
 
-func NewDashboardHandler(db *database.DB) *DashboardHandler {
+func NewDashboardHandler(db *database.DB, cfg *config.Config, store *settings.Store, escalation *alerts.FuelEscalation, wd *watchdog.Watchdog) *DashboardHandler {
 	return &DashboardHandler{
-		DB: db,
+		DB:         db,
+		Config:     cfg,
+		Settings:   store,
+		Escalation: escalation,
+		Watchdog:   wd,
 	}
 }
 
@@ -38,42 +51,45 @@ func (h *DashboardHandler) GetDashboard(c *gin.Context) {
 		return
 	}
 
-	log.Printf("DASHBOARD START: User=%s, Role=%s", user.Username, user.Role)
+	finished := h.Watchdog.RequestStarted("dashboard")
+	defer finished()
 
-	// Parallel Step 1 & 2: Get view mode and sites simultaneously
-	var viewMode string
-	var sites []*models.Site
-	var err error
+	middleware.Log(c).Debugf("DASHBOARD START: User=%s, Role=%s", user.Username, user.Role)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	// Parallel Step 1 & 2: Get view mode and sites simultaneously.
+	// Each lookup hands its whole outcome back on its own channel.
+	viewModeChan := make(chan viewModeResult, 1)
+	sitesChan := make(chan sitesResult, 1)
 
 	go func() {
-		defer wg.Done()
-		viewMode = "closing"
-		if user.Role == "admin" {
-			if pref, err := h.DB.GetUserAdminPreference(user.ID); err == nil && pref != nil {
-				viewMode = pref.ViewMode
-			}
-		}
+		viewModeChan <- h.getViewMode(user)
 	}()
 
 	go func() {
-		defer wg.Done()
-		sites, err = h.DB.GetDashboardSitesForUser(user.ID, user.Role)
+		sites, err := h.DB.GetDashboardSitesForUser(user.ID, user.Role)
+		sitesChan <- sitesResult{sites: sites, err: err}
 	}()
 
-	wg.Wait()
+	mode := <-viewModeChan
+	loaded := <-sitesChan
 
-	if err != nil {
-		log.Printf("Failed to get sites: %v", err)
+	// A failed preference lookup degrades to the default view mode
+	viewMode := mode.viewMode
+	if mode.err != nil {
+		middleware.Log(c).Warnf("Failed to get admin preference for %s, defaulting to %s view: %v", user.Username, viewMode, mode.err)
+	}
+
+	// Never continue with the sites of a failed lookup
+	if loaded.err != nil {
+		middleware.Log(c).Errorf("Failed to get sites: %v", loaded.err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Message: "Failed to get sites",
 		})
 		return
 	}
+	sites := loaded.sites
 
-	log.Printf("Sites retrieved: %d sites, Mode: %s", len(sites), viewMode)
+	middleware.Log(c).Debugf("Sites retrieved: %d sites, Mode: %s", len(sites), viewMode)
 
 	if len(sites) == 0 {
 		c.JSON(http.StatusOK, models.DashboardData{
@@ -81,29 +97,68 @@ func (h *DashboardHandler) GetDashboard(c *gin.Context) {
 			SystemStatus:   createEmptySystemStatus(),
 			RecentActivity: []models.ActivityItem{},
 			ViewMode:       viewMode,
+			ScopeEmpty:     true,
 		})
 		return
 	}
 
+	// Site types decide which sensors each site is expected to report
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get site types, using default expected sensors: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
 	// Step 3: Get readings with maximum parallel processing
 	readingsStart := time.Now()
-	var sitesWithReadings []*models.SiteWithReadings
 
+	// Stop issuing per-site queries as soon as the client goes away
+	ctx := c.Request.Context()
+
+	// Past the soft deadline the sites still pending are reported as timed out
+	var collected collection
+	softDeadline := h.Config.Dashboard.SoftDeadline
 	if viewMode == "realtime" && user.Role == "admin" {
-		sitesWithReadings, err = h.getAggressiveParallelRealTimeReadings(sites)
+		collected, err = h.getAggressiveParallelRealTimeReadings(ctx, sites, siteTypes, softDeadline)
 	} else {
-		sitesWithReadings, err = h.getAggressiveParallelDailyClosingReadings(sites)
+		collected, err = h.getAggressiveParallelDailyClosingReadings(ctx, sites, siteTypes, softDeadline)
+	}
+
+	if ctx.Err() != nil {
+		middleware.Log(c).Debugf("DASHBOARD CANCELLED: User=%s, Mode=%s (%v)", user.Username, viewMode, ctx.Err())
+		return
+	}
+
+	if err == errCollectTimeout {
+		c.JSON(http.StatusGatewayTimeout, models.ErrorResponse{
+			Message: "Timed out waiting for readings",
+		})
+		return
 	}
 
 	if err != nil {
-		log.Printf("Failed to get readings: %v", err)
+		middleware.Log(c).Errorf("Failed to get readings: %v", err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Message: "Failed to get readings",
 		})
 		return
 	}
 
-	log.Printf("Readings completed: %d sites with data (took %v)", len(sitesWithReadings), time.Since(readingsStart))
+	sitesWithReadings := collected.results
+	if collected.partial {
+		timedOut := timedOutSites(sites, collected.done, siteTypes)
+		middleware.Log(c).Warnf("Dashboard soft deadline of %v passed for %s, %d of %d sites timed out", softDeadline, user.Username, len(timedOut), len(sites))
+		sitesWithReadings = append(sitesWithReadings, timedOut...)
+	}
+
+	middleware.Log(c).Debugf("Readings completed: %d sites with data (took %v)", len(collected.results), time.Since(readingsStart))
+
+	// Flag sites whose current alert has been acknowledged
+	if acks, err := h.DB.GetActiveAlertAcknowledgements(); err != nil {
+		middleware.Log(c).Warnf("Failed to get alert acknowledgements: %v", err)
+	} else {
+		markAcknowledged(sitesWithReadings, acks)
+	}
 
 	// Sort by fuel level descending
 	sort.Slice(sitesWithReadings, func(i, j int) bool {
@@ -111,11 +166,11 @@ func (h *DashboardHandler) GetDashboard(c *gin.Context) {
 	})
 
 	// Calculate system status and recent activity
-	systemStatus := calculateSystemStatus(sitesWithReadings, len(sites))
-	recentActivity := generateRecentActivity(sitesWithReadings)
+	systemStatus := calculateSystemStatus(sitesWithReadings, sites)
+	recentActivity := h.getRecentActivity(sites, siteTypes)
 
 	totalTime := time.Since(startTime)
-	log.Printf("DASHBOARD COMPLETE: User=%s, Mode=%s, Sites=%d/%d, Total=%v",
+	middleware.Log(c).Infof("DASHBOARD COMPLETE: User=%s, Mode=%s, Sites=%d/%d, Total=%v",
 		user.Username, viewMode, len(sitesWithReadings), len(sites), totalTime)
 
 	c.JSON(http.StatusOK, models.DashboardData{
@@ -123,19 +178,58 @@ func (h *DashboardHandler) GetDashboard(c *gin.Context) {
 		SystemStatus:   systemStatus,
 		RecentActivity: recentActivity,
 		ViewMode:       viewMode,
+		Partial:        collected.partial,
 	})
 }
 
+// viewModeResult is the outcome of the view mode lookup
+type viewModeResult struct {
+	viewMode string
+	err      error
+}
+
+// sitesResult is the outcome of the dashboard sites lookup
+type sitesResult struct {
+	sites []*models.Site
+	err   error
+}
+
+// getViewMode returns the user's dashboard view mode: the admin's stored
+// preference, or "closing" for everyone else and when no preference is stored
+func (h *DashboardHandler) getViewMode(user *models.UserResponse) viewModeResult {
+	result := viewModeResult{viewMode: "closing"}
+	if user.Role != "admin" {
+		return result
+	}
+
+	pref, err := h.DB.GetUserAdminPreference(user.ID)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	if pref != nil {
+		result.viewMode = pref.ViewMode
+	}
+	return result
+}
+
 // getAggressiveParallelRealTimeReadings uses maximum parallelism for real-time data
-func (h *DashboardHandler) getAggressiveParallelRealTimeReadings(sites []*models.Site) ([]*models.SiteWithReadings, error) {
+// and abandons remaining devices once ctx is cancelled or softDeadline (0 for
+// none) passes, returning a partial collection in the latter case
+func (h *DashboardHandler) getAggressiveParallelRealTimeReadings(ctx context.Context, sites []*models.Site, siteTypes map[int]*models.SiteType, softDeadline time.Duration) (collection, error) {
 	start := time.Now()
 
+	// Stop the workers once the results are no longer awaited
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	// Use more workers with smaller batches for maximum parallelism
-	const maxWorkers = 15
-	const batchSize = 4
+	maxWorkers := workerCount(h.Settings.Int(settings.DashboardRealtimeWorkers), 15)
+	lowFuelThreshold := h.Settings.Float(settings.LowFuelThreshold)
+	criticalFuelThreshold := h.Settings.Float(settings.CriticalFuelThreshold)
 
-	deviceChan := make(chan string, len(sites))
-	resultChan := make(chan *models.SiteWithReadings, len(sites))
+	siteChan := make(chan *models.Site, len(sites))
+	resultChan := make(chan siteOutcome, len(sites))
 
 	// Start aggressive worker pool
 	var wg sync.WaitGroup
@@ -143,32 +237,44 @@ func (h *DashboardHandler) getAggressiveParallelRealTimeReadings(sites []*models
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			for deviceID := range deviceChan {
+			h.Watchdog.WorkerStarted()
+			defer h.Watchdog.WorkerFinished()
+			for site := range siteChan {
+				// Drain remaining work without querying once cancelled
+				if ctx.Err() != nil {
+					continue
+				}
+
 				// Get readings for single device (fastest possible)
-				reading := h.DB.GetSingleDeviceReading(deviceID)
+				siteType := siteTypeFor(site, siteTypes)
+				reading, err := h.DB.GetLatestDeviceReading(site.DeviceID, siteType.Names())
+				if err != nil {
+					logger.Warnf("Failed to get realtime reading for site %s: %v", site.Name, err)
+					resultChan <- siteOutcome{siteID: site.ID, failed: true}
+					continue
+				}
 				if reading != nil && reading.FuelLevel != "" {
-					// Find the site for this device
-					var site *models.Site
-					for _, s := range sites {
-						if s.DeviceID == deviceID {
-							site = s
-							break
-						}
-					}
-					if site != nil {
-						siteWithReading := processSiteReading(site, reading)
-						resultChan <- siteWithReading
-					}
+					// Realtime readings feed the low fuel escalation
+					critical := reading.FuelLevelParsed &&
+						h.Escalation.Observe(site.ID, reading.FuelLevelFloat, criticalFuelThreshold, reading.CapturedAt)
+					siteWithReading := processSiteReading(site, reading, siteType, lowFuelThreshold, critical)
+					resultChan <- siteOutcome{siteID: site.ID, result: siteWithReading}
+				} else {
+					resultChan <- siteOutcome{siteID: site.ID}
 				}
 			}
 		}(i)
 	}
 
-	// Send all device IDs to workers
+	// Send all sites to workers
 	go func() {
-		defer close(deviceChan)
+		defer close(siteChan)
 		for _, site := range sites {
-			deviceChan <- site.DeviceID
+			select {
+			case <-ctx.Done():
+				return
+			case siteChan <- site:
+			}
 		}
 	}()
 
@@ -178,23 +284,41 @@ func (h *DashboardHandler) getAggressiveParallelRealTimeReadings(sites []*models
 		close(resultChan)
 	}()
 
-	var sitesWithReadings []*models.SiteWithReadings
-	for siteWithReading := range resultChan {
-		sitesWithReadings = append(sitesWithReadings, siteWithReading)
+	collected, err := collectResults(ctx, resultChan, softDeadline, h.Config.Dashboard.CollectTimeout)
+	if err == errCollectTimeout {
+		logger.Errorf("Realtime dashboard workers still running after %v, giving up with %d of %d sites", h.Config.Dashboard.CollectTimeout, len(collected.done), len(sites))
+		return collection{}, err
+	}
+	if err != nil {
+		logger.Debugf("Aggressive parallel real-time cancelled after %d sites (took %v)", len(collected.done), time.Since(start))
+		return collection{}, err
 	}
 
-	log.Printf("Aggressive parallel real-time completed: %d sites (took %v)", len(sitesWithReadings), time.Since(start))
-	return sitesWithReadings, nil
+	logger.Debugf("Aggressive parallel real-time completed: %d of %d sites (took %v)", len(collected.done), len(sites), time.Since(start))
+	return collected, nil
 }
 
 // getAggressiveParallelDailyClosingReadings uses maximum parallelism for daily closing
-func (h *DashboardHandler) getAggressiveParallelDailyClosingReadings(sites []*models.Site) ([]*models.SiteWithReadings, error) {
+// and abandons remaining sites once ctx is cancelled or softDeadline (0 for
+// none) passes, returning a partial collection in the latter case
+func (h *DashboardHandler) getAggressiveParallelDailyClosingReadings(ctx context.Context, sites []*models.Site, siteTypes map[int]*models.SiteType, softDeadline time.Duration) (collection, error) {
 	start := time.Now()
 
-	const maxWorkers = 12
+	// Stop the workers once the results are no longer awaited
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	maxWorkers := workerCount(h.Settings.Int(settings.DashboardClosingWorkers), 12)
+	lowFuelThreshold := h.Settings.Float(settings.LowFuelThreshold)
+
+	// Resolve the closing cutoff once so every site uses the same business day
+	var cutoff *time.Time
+	if lastCutoff, ok := h.Config.Closing.LastCutoff(time.Now()); ok {
+		cutoff = &lastCutoff
+	}
 
 	siteChan := make(chan *models.Site, len(sites))
-	resultChan := make(chan *models.SiteWithReadings, len(sites))
+	resultChan := make(chan siteOutcome, len(sites))
 
 	// Start worker pool for sites
 	var wg sync.WaitGroup
@@ -202,12 +326,23 @@ func (h *DashboardHandler) getAggressiveParallelDailyClosingReadings(sites []*mo
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
+			h.Watchdog.WorkerStarted()
+			defer h.Watchdog.WorkerFinished()
 			for site := range siteChan {
+				// Drain remaining work without querying once cancelled
+				if ctx.Err() != nil {
+					continue
+				}
+
 				// Get daily closing for single site + live states
-				reading := h.DB.GetSingleSiteDailyClosing(site.ID, site.DeviceID)
+				siteType := siteTypeFor(site, siteTypes)
+				reading := h.DB.GetSingleSiteDailyClosing(site.ID, site.DeviceID, cutoff, siteType.Names())
 				if reading != nil && reading.FuelLevel != "" {
-					siteWithReading := processSiteReading(site, reading)
-					resultChan <- siteWithReading
+					// Closing snapshots are historical, so they are never escalated
+					siteWithReading := processSiteReading(site, reading, siteType, lowFuelThreshold, false)
+					resultChan <- siteOutcome{siteID: site.ID, result: siteWithReading}
+				} else {
+					resultChan <- siteOutcome{siteID: site.ID}
 				}
 			}
 		}(i)
@@ -217,7 +352,11 @@ func (h *DashboardHandler) getAggressiveParallelDailyClosingReadings(sites []*mo
 	go func() {
 		defer close(siteChan)
 		for _, site := range sites {
-			siteChan <- site
+			select {
+			case <-ctx.Done():
+				return
+			case siteChan <- site:
+			}
 		}
 	}()
 
@@ -227,40 +366,137 @@ func (h *DashboardHandler) getAggressiveParallelDailyClosingReadings(sites []*mo
 		close(resultChan)
 	}()
 
-	var sitesWithReadings []*models.SiteWithReadings
-	for siteWithReading := range resultChan {
-		sitesWithReadings = append(sitesWithReadings, siteWithReading)
+	collected, err := collectResults(ctx, resultChan, softDeadline, h.Config.Dashboard.CollectTimeout)
+	if err == errCollectTimeout {
+		logger.Errorf("Daily closing dashboard workers still running after %v, giving up with %d of %d sites", h.Config.Dashboard.CollectTimeout, len(collected.done), len(sites))
+		return collection{}, err
+	}
+	if err != nil {
+		logger.Debugf("Aggressive parallel daily closing cancelled after %d sites (took %v)", len(collected.done), time.Since(start))
+		return collection{}, err
 	}
 
-	log.Printf("Aggressive parallel daily closing completed: %d sites (took %v)", len(sitesWithReadings), time.Since(start))
-	return sitesWithReadings, nil
+	logger.Debugf("Aggressive parallel daily closing completed: %d of %d sites (took %v)", len(collected.done), len(sites), time.Since(start))
+	return collected, nil
 }
 
-// processSiteReading processes a site with its sensor reading into SiteWithReadings
-func processSiteReading(site *models.Site, reading *models.SensorReading) *models.SiteWithReadings {
-	// Parse fuel level percentage
-	fuelLevelPercentage := 0.0
-	if reading.FuelLevel != "" {
-		if level, err := strconv.ParseFloat(reading.FuelLevel, 64); err == nil {
-			if level < 0 {
-				level = 0
-			} else if level > 100 {
-				level = 100
+// errCollectTimeout reports dashboard workers that did not finish within Dashboard.CollectTimeout
+var errCollectTimeout = errors.New("timed out waiting for dashboard workers")
+
+// siteOutcome is a dashboard worker's result for one site; result is nil when
+// the site has no reading
+type siteOutcome struct {
+	siteID int
+	result *models.SiteWithReadings
+	failed bool // the reading could not be queried
+}
+
+// collection is what collectResults gathered: the sites with readings, every
+// site whose worker finished, the sites whose reading could not be queried, and
+// whether the soft deadline cut it short
+type collection struct {
+	results []*models.SiteWithReadings
+	done    map[int]bool
+	failed  map[int]bool
+	partial bool
+}
+
+// collectResults gathers worker results until resultChan is closed. Once
+// softDeadline passes it returns the results so far as a partial collection.
+// It gives up with an error when ctx is cancelled or after timeout, so a pool
+// that never closes its channel cannot hang the request. A zero softDeadline
+// or timeout waits indefinitely.
+func collectResults(ctx context.Context, resultChan <-chan siteOutcome, softDeadline, timeout time.Duration) (collection, error) {
+	collected := collection{
+		results: []*models.SiteWithReadings{},
+		done:    make(map[int]bool),
+		failed:  make(map[int]bool),
+	}
+
+	var softExpired, expired <-chan time.Time
+	if softDeadline > 0 {
+		timer := time.NewTimer(softDeadline)
+		defer timer.Stop()
+		softExpired = timer.C
+	}
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case outcome, ok := <-resultChan:
+			if !ok {
+				return collected, nil
 			}
-			fuelLevelPercentage = level
+			collected.done[outcome.siteID] = true
+			if outcome.failed {
+				collected.failed[outcome.siteID] = true
+			}
+			if outcome.result != nil {
+				collected.results = append(collected.results, outcome.result)
+			}
+		case <-ctx.Done():
+			return collected, ctx.Err()
+		case <-softExpired:
+			collected.partial = true
+			return collected, nil
+		case <-expired:
+			return collected, errCollectTimeout
+		}
+	}
+}
+
+// timedOutSites lists the sites whose worker did not finish as timed_out,
+// without a reading
+func timedOutSites(sites []*models.Site, done map[int]bool, siteTypes map[int]*models.SiteType) []*models.SiteWithReadings {
+	timedOut := []*models.SiteWithReadings{}
+	for _, site := range sites {
+		if done[site.ID] {
+			continue
 		}
+
+		expectedSensors := models.DefaultExpectedSensors
+		if siteType := siteTypeFor(site, siteTypes); siteType != nil {
+			expectedSensors = siteType.ExpectedSensors
+		}
+		timedOut = append(timedOut, &models.SiteWithReadings{
+			Site:            site,
+			AlertStatus:     models.AlertTimedOut,
+			ExpectedSensors: expectedSensors,
+		})
 	}
+	return timedOut
+}
+
+// workerCount returns the configured worker count, or fallback when it is not positive
+func workerCount(configured, fallback int) int {
+	if configured < 1 {
+		return fallback
+	}
+	return configured
+}
+
+// siteTypeFor returns the site's type, or nil when it has none
+func siteTypeFor(site *models.Site, siteTypes map[int]*models.SiteType) *models.SiteType {
+	if site.TypeID == nil {
+		return nil
+	}
+	return siteTypes[*site.TypeID]
+}
 
-	// Determine power states
-	generatorOnline := isStateOnline(reading.GeneratorState)
-	zesaOnline := isStateOnline(reading.ZesaState)
+// processSiteReading processes a site with its sensor reading into SiteWithReadings.
+// criticalFuel marks a site escalated after sustained critically low fuel.
+func processSiteReading(site *models.Site, reading *models.SensorReading, siteType *models.SiteType, lowFuelThreshold float64, criticalFuel bool) *models.SiteWithReadings {
+	// Determine power states, ignoring sensors the site type does not have
+	generatorOnline := siteType.Expects("generator_state") && reading.GeneratorOn
+	zesaOnline := siteType.Expects("zesa_state") && reading.ZesaOn
 
-	// Determine alert status
-	alertStatus := "normal"
-	if fuelLevelPercentage <= 25.0 {
-		alertStatus = "low_fuel"
-	} else if !generatorOnline && fuelLevelPercentage > 0 {
-		alertStatus = "generator_off"
+	expectedSensors := models.DefaultExpectedSensors
+	if siteType != nil {
+		expectedSensors = siteType.ExpectedSensors
 	}
 
 	return &models.SiteWithReadings{
@@ -268,26 +504,38 @@ func processSiteReading(site *models.Site, reading *models.SensorReading) *model
 		LatestReading:       reading,
 		GeneratorOnline:     generatorOnline,
 		ZesaOnline:          zesaOnline,
-		FuelLevelPercentage: fuelLevelPercentage,
-		AlertStatus:         alertStatus,
+		FuelLevelPercentage: reading.FuelLevelPercentage(),
+		AlertStatus:         alerts.Status(site, reading, siteType, lowFuelThreshold, criticalFuel, time.Now()),
+		ExpectedSensors:     expectedSensors,
 	}
 }
 
-// isStateOnline checks if a state string represents "online" status
-func isStateOnline(state string) bool {
-	state = strings.ToLower(strings.TrimSpace(state))
-	return state == "1" || state == "on" || state == "true" || state == "1.0"
-}
-
-// calculateSystemStatus calculates overall system status
-func calculateSystemStatus(sitesWithReadings []*models.SiteWithReadings, totalSites int) models.SystemStatus {
+// calculateSystemStatus calculates overall system status. Low fuel and critical
+// fuel sites are counted separately. Sites in maintenance are counted separately
+// and never as low fuel or offline. Sites with alerting
+// disabled still count as online and running but never as low fuel or offline.
+// Timed out sites are counted separately and never as online or offline.
+func calculateSystemStatus(sitesWithReadings []*models.SiteWithReadings, sites []*models.Site) models.SystemStatus {
 	lowFuelCount := 0
+	criticalFuelCount := 0
 	generatorsRunningCount := 0
 	zesaRunningCount := 0
 
+	reporting := make(map[int]bool, len(sitesWithReadings))
+	timedOut := make(map[int]bool)
 	for _, site := range sitesWithReadings {
-		if site.AlertStatus == "low_fuel" {
-			lowFuelCount++
+		if site.AlertStatus == models.AlertTimedOut {
+			timedOut[site.ID] = true
+			continue
+		}
+		reporting[site.ID] = true
+		if site.AlertsEnabled {
+			switch site.AlertStatus {
+			case "low_fuel":
+				lowFuelCount++
+			case "critical_fuel":
+				criticalFuelCount++
+			}
 		}
 		if site.GeneratorOnline {
 			generatorsRunningCount++
@@ -297,72 +545,116 @@ func calculateSystemStatus(sitesWithReadings []*models.SiteWithReadings, totalSi
 		}
 	}
 
+	now := time.Now()
+	offlineCount := 0
+	maintenanceCount := 0
+	alertsDisabledCount := 0
+	for _, site := range sites {
+		if !site.AlertsEnabled {
+			alertsDisabledCount++
+		}
+		if site.InMaintenance(now) {
+			maintenanceCount++
+		} else if !reporting[site.ID] && !timedOut[site.ID] && site.AlertsEnabled {
+			offlineCount++
+		}
+	}
+
 	return models.SystemStatus{
-		SitesOnline:       len(sitesWithReadings),
-		TotalSites:        totalSites,
-		LowFuelAlerts:     lowFuelCount,
-		GeneratorsRunning: generatorsRunningCount,
-		ZesaRunning:       zesaRunningCount,
-		OfflineSites:      totalSites - len(sitesWithReadings),
+		SitesOnline:         len(reporting),
+		TotalSites:          len(sites),
+		LowFuelAlerts:       lowFuelCount,
+		CriticalFuelAlerts:  criticalFuelCount,
+		GeneratorsRunning:   generatorsRunningCount,
+		ZesaRunning:         zesaRunningCount,
+		OfflineSites:        offlineCount,
+		MaintenanceSites:    maintenanceCount,
+		AlertsDisabledSites: alertsDisabledCount,
+		TimedOutSites:       len(timedOut),
 	}
 }
 
-// generateRecentActivity creates recent activity items from sites
-func generateRecentActivity(sitesWithReadings []*models.SiteWithReadings) []models.ActivityItem {
-	var activities []models.ActivityItem
-
-	count := 0
-	for _, site := range sitesWithReadings {
-		if site.LatestReading == nil || count >= 10 {
-			break
-		}
+// getRecentActivity lists the newest real state transitions across the sites:
+// generator and zesa switching on or off, and fuel dropping to the low fuel level.
+// Transitions for sensors a site's type does not have are skipped.
+func (h *DashboardHandler) getRecentActivity(sites []*models.Site, siteTypes map[int]*models.SiteType) []models.ActivityItem {
+	limit := h.Config.Dashboard.ActivityLimit
+	if limit < 1 {
+		return []models.ActivityItem{}
+	}
+	window := h.Config.Dashboard.ActivityWindow
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
 
-		event := "Normal Reading"
-		status := "Normal"
+	sitesByDevice := make(map[string]*models.Site, len(sites))
+	deviceIDs := make([]string, 0, len(sites))
+	for _, site := range sites {
+		sitesByDevice[strings.ToLower(site.DeviceID)] = site
+		deviceIDs = append(deviceIDs, site.DeviceID)
+	}
 
-		if site.AlertStatus == "low_fuel" {
-			event = "Low Fuel Alert"
-			status = "Low Fuel"
-		} else if site.AlertStatus == "generator_off" {
-			event = "Generator Offline"
-			status = "Offline"
-		}
+	lowFuelThreshold := h.Settings.Float(settings.LowFuelThreshold)
+	transitions, err := h.DB.GetRecentTransitions(deviceIDs, models.SiteSensorNames(sites, siteTypes), models.SiteExpectedSensors(sites, siteTypes), time.Now().Add(-window), lowFuelThreshold, limit)
+	if err != nil {
+		logger.Warnf("Failed to get recent activity: %v", err)
+		return []models.ActivityItem{}
+	}
 
-		fuelVolume := "0"
-		if site.LatestReading.FuelVolume != "" {
-			fuelVolume = site.LatestReading.FuelVolume
+	activities := []models.ActivityItem{}
+	for _, transition := range transitions {
+		site, ok := sitesByDevice[transition.DeviceID]
+		if !ok {
+			continue
 		}
 
 		activity := models.ActivityItem{
-			ID:        count + 1,
+			ID:        len(activities) + 1,
 			SiteID:    site.ID,
 			SiteName:  site.Name,
-			Event:     event,
-			Value:     fmt.Sprintf("%.1f%% (%sL)", site.FuelLevelPercentage, fuelVolume),
-			Timestamp: site.LatestReading.CapturedAt,
-			Status:    status,
+			Timestamp: transition.Time,
+		}
+
+		switch transition.SensorName {
+		case "generator_state":
+			activity.Event, activity.Status = "Generator Offline", "Offline"
+			if transition.On {
+				activity.Event, activity.Status = "Generator Started", "Online"
+			}
+		case "zesa_state":
+			activity.Event, activity.Status = "ZESA Outage", "Offline"
+			if transition.On {
+				activity.Event, activity.Status = "ZESA Restored", "Online"
+			}
+		default:
+			activity.Event, activity.Status = "Low Fuel Alert", "Low Fuel"
+			activity.Value = fmt.Sprintf("%.1f%%", transition.FuelLevel)
+		}
+		if activity.Value == "" {
+			activity.Value = "OFF"
+			if transition.On {
+				activity.Value = "ON"
+			}
 		}
 
 		activities = append(activities, activity)
-		count++
 	}
 
-	// Sort by timestamp descending (newest first)
-	sort.Slice(activities, func(i, j int) bool {
-		return activities[i].Timestamp.After(activities[j].Timestamp)
-	})
-
 	return activities
 }
 
 // createEmptySystemStatus creates an empty system status
 func createEmptySystemStatus() models.SystemStatus {
 	return models.SystemStatus{
-		SitesOnline:       0,
-		TotalSites:        0,
-		LowFuelAlerts:     0,
-		GeneratorsRunning: 0,
-		ZesaRunning:       0,
-		OfflineSites:      0,
+		SitesOnline:         0,
+		TotalSites:          0,
+		LowFuelAlerts:       0,
+		CriticalFuelAlerts:  0,
+		GeneratorsRunning:   0,
+		ZesaRunning:         0,
+		OfflineSites:        0,
+		MaintenanceSites:    0,
+		AlertsDisabledSites: 0,
+		TimedOutSites:       0,
 	}
 }
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/alerts"
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// answerDashboard answers the realtime dashboard queries for an admin: devices
+// report the fuel levels given for them, and site types and activity are empty
+func answerDashboard(fuelLevels map[string]string, sites ...*models.Site) fakeQuery {
+	at := time.Now().Add(-time.Minute)
+	return func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "FROM admin_preferences"):
+			return []string{"id", "user_id", "view_mode", "updated_at"}, [][]driver.Value{{int64(1), int64(1), "realtime", at}}, nil
+		case strings.Contains(query, "FROM sites"):
+			return siteRows(sites...)
+		case strings.Contains(query, "DISTINCT ON (sensor_name)"):
+			level, ok := fuelLevels[args[0].Value.(string)]
+			if !ok {
+				return []string{"sensor_name", "value", "time"}, nil, nil
+			}
+			return []string{"sensor_name", "value", "time"}, [][]driver.Value{{"fuel_sensor_level", level, at}}, nil
+		}
+		return nil, nil, nil
+	}
+}
+
+// getDashboard serves GET /dashboard to an admin and decodes the response
+func getDashboard(t *testing.T, handler *DashboardHandler) models.DashboardData {
+	t.Helper()
+
+	router := gin.New()
+	router.GET("/dashboard", func(c *gin.Context) {
+		c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+	}, handler.GetDashboard)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+
+	var data models.DashboardData
+	if err := json.Unmarshal(recorder.Body.Bytes(), &data); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return data
+}
+
+func TestGetDashboardMaintenance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Now()
+	ended := now.Add(-time.Hour)
+	sites := []*models.Site{
+		{ID: 1, Name: "In maintenance", DeviceID: "simbisa-a", IsActive: true, AlertsEnabled: true, MaintenanceMode: true},
+		{ID: 2, Name: "Maintenance ended", DeviceID: "simbisa-b", IsActive: true, AlertsEnabled: true, MaintenanceMode: true, MaintenanceEnd: &ended},
+		{ID: 3, Name: "Normal", DeviceID: "simbisa-c", IsActive: true, AlertsEnabled: true},
+	}
+
+	db, fake := newFakeDB(t, 4)
+	// Every site is low on fuel
+	fake.answer = answerDashboard(map[string]string{"simbisa-a": "10", "simbisa-b": "10", "simbisa-c": "10"}, sites...)
+	cfg := &config.Config{Dashboard: config.DashboardConfig{LowFuelThreshold: 25}}
+	data := getDashboard(t, NewDashboardHandler(db, cfg, settings.NewStore(db, cfg), alerts.NewFuelEscalation(time.Hour), watchdog.New()))
+
+	want := map[int]string{1: "maintenance", 2: "low_fuel", 3: "low_fuel"}
+	for _, site := range data.Sites {
+		if site.AlertStatus != want[site.ID] {
+			t.Errorf("site %d alert = %q, want %q", site.ID, site.AlertStatus, want[site.ID])
+		}
+	}
+	if len(data.Sites) != len(want) {
+		t.Errorf("got %d sites, want %d", len(data.Sites), len(want))
+	}
+
+	status := data.SystemStatus
+	if status.MaintenanceSites != 1 || status.LowFuelAlerts != 2 || status.OfflineSites != 0 {
+		t.Errorf("system status = %+v, want 1 in maintenance, 2 low fuel, 0 offline", status)
+	}
+}
+
+func TestGetDashboardAlertsDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sites := []*models.Site{
+		{ID: 1, Name: "Low", DeviceID: "simbisa-a", IsActive: true, AlertsEnabled: true},
+		{ID: 2, Name: "Low, muted", DeviceID: "simbisa-b", IsActive: true},
+		{ID: 3, Name: "Offline", DeviceID: "simbisa-c", IsActive: true, AlertsEnabled: true},
+		{ID: 4, Name: "Offline, muted", DeviceID: "simbisa-d", IsActive: true},
+	}
+
+	db, fake := newFakeDB(t, 4)
+	fake.answer = answerDashboard(map[string]string{"simbisa-a": "10", "simbisa-b": "10"}, sites...)
+	cfg := &config.Config{Dashboard: config.DashboardConfig{LowFuelThreshold: 25}}
+	data := getDashboard(t, NewDashboardHandler(db, cfg, settings.NewStore(db, cfg), alerts.NewFuelEscalation(time.Hour), watchdog.New()))
+
+	// Muted sites keep their real status
+	for _, site := range data.Sites {
+		if site.AlertStatus != "low_fuel" {
+			t.Errorf("site %d alert = %q, want low_fuel", site.ID, site.AlertStatus)
+		}
+	}
+
+	status := data.SystemStatus
+	if status.LowFuelAlerts != 1 || status.OfflineSites != 1 || status.AlertsDisabledSites != 2 || status.SitesOnline != 2 {
+		t.Errorf("system status = %+v, want 1 low fuel, 1 offline, 2 with alerts disabled, 2 online", status)
+	}
+}
+
+func TestCollectResults(t *testing.T) {
+	withReading := func(id int) siteOutcome {
+		return siteOutcome{siteID: id, result: &models.SiteWithReadings{Site: &models.Site{ID: id}}}
+	}
+
+	tests := []struct {
+		name         string
+		softDeadline time.Duration
+		timeout      time.Duration
+		cancel       bool
+		// outcomes are sent right away and slow ones after a delay; close
+		// closes the channel once all are sent
+		outcomes    []siteOutcome
+		slow        []siteOutcome
+		close       bool
+		wantErr     error
+		wantResults []int
+		wantDone    []int
+		wantPartial bool
+	}{
+		{
+			name:        "all finish",
+			outcomes:    []siteOutcome{withReading(1), {siteID: 2}},
+			close:       true,
+			wantResults: []int{1},
+			wantDone:    []int{1, 2},
+		},
+		{
+			name:         "slow worker cut off at the soft deadline",
+			softDeadline: 50 * time.Millisecond,
+			outcomes:     []siteOutcome{withReading(1)},
+			slow:         []siteOutcome{withReading(2)},
+			close:        true,
+			wantResults:  []int{1},
+			wantDone:     []int{1},
+			wantPartial:  true,
+		},
+		{
+			name:         "slow worker within the soft deadline",
+			softDeadline: time.Second,
+			outcomes:     []siteOutcome{withReading(1)},
+			slow:         []siteOutcome{withReading(2)},
+			close:        true,
+			wantResults:  []int{1, 2},
+			wantDone:     []int{1, 2},
+		},
+		{
+			name:        "pool never closes",
+			timeout:     50 * time.Millisecond,
+			outcomes:    []siteOutcome{withReading(1)},
+			wantErr:     errCollectTimeout,
+			wantResults: []int{1},
+			wantDone:    []int{1},
+		},
+		{
+			name:        "request cancelled",
+			cancel:      true,
+			wantErr:     context.Canceled,
+			wantResults: []int{},
+			wantDone:    []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			resultChan := make(chan siteOutcome)
+			stop := make(chan struct{})
+			defer close(stop)
+			// The sender outlives the subtest, so it must not read tt
+			go func(outcomes, slow []siteOutcome, closeWhenSent bool) {
+				for _, outcome := range outcomes {
+					select {
+					case resultChan <- outcome:
+					case <-stop:
+						return
+					}
+				}
+				for _, outcome := range slow {
+					select {
+					case <-time.After(200 * time.Millisecond):
+					case <-stop:
+						return
+					}
+					select {
+					case resultChan <- outcome:
+					case <-stop:
+						return
+					}
+				}
+				if closeWhenSent {
+					close(resultChan)
+				}
+			}(tt.outcomes, tt.slow, tt.close)
+
+			collected, err := collectResults(ctx, resultChan, tt.softDeadline, tt.timeout)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			results := []int{}
+			for _, result := range collected.results {
+				results = append(results, result.Site.ID)
+			}
+			if !reflect.DeepEqual(results, tt.wantResults) {
+				t.Errorf("results = %v, want %v", results, tt.wantResults)
+			}
+			if !reflect.DeepEqual(collected.done, idSet(tt.wantDone)) {
+				t.Errorf("done = %v, want %v", collected.done, tt.wantDone)
+			}
+			if collected.partial != tt.wantPartial {
+				t.Errorf("partial = %t, want %t", collected.partial, tt.wantPartial)
+			}
+		})
+	}
+}
+
+// idSet returns the IDs as a set
+func idSet(ids []int) map[int]bool {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func TestGetDashboardStartupLookups(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	site := &models.Site{ID: 1, Name: "Site A", DeviceID: "simbisa-a", IsActive: true, AlertsEnabled: true}
+
+	tests := []struct {
+		name         string
+		prefErr      error
+		sitesErr     error
+		wantStatus   int
+		wantViewMode string
+		wantSites    int
+	}{
+		{"both lookups succeed", nil, nil, http.StatusOK, "realtime", 1},
+		// The site has no closing reading, so the closing view leaves it out
+		{"preference fails", errors.New("preference lookup failed"), nil, http.StatusOK, "closing", 0},
+		{"sites fail", nil, errors.New("sites lookup failed"), http.StatusInternalServerError, "", 0},
+		{"both fail", errors.New("preference lookup failed"), errors.New("sites lookup failed"), http.StatusInternalServerError, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 4)
+			answer := answerDashboard(map[string]string{"simbisa-a": "50"}, site)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				switch {
+				case strings.Contains(query, "FROM admin_preferences") && tt.prefErr != nil:
+					return nil, nil, tt.prefErr
+				case strings.Contains(query, "FROM sites") && tt.sitesErr != nil:
+					return nil, nil, tt.sitesErr
+				}
+				return answer(query, args)
+			}
+			cfg := &config.Config{Dashboard: config.DashboardConfig{LowFuelThreshold: 25}}
+			handler := NewDashboardHandler(db, cfg, settings.NewStore(db, cfg), alerts.NewFuelEscalation(time.Hour), watchdog.New())
+
+			router := gin.New()
+			router.GET("/dashboard", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 1, Username: "admin", Role: "admin"})
+			}, handler.GetDashboard)
+
+			// Concurrent requests give the race detector (go test -race) both
+			// lookup goroutines of many requests to check
+			const requests = 20
+			recorders := make([]*httptest.ResponseRecorder, requests)
+			done := make(chan struct{})
+			for i := range recorders {
+				recorders[i] = httptest.NewRecorder()
+				go func(recorder *httptest.ResponseRecorder) {
+					defer func() { done <- struct{}{} }()
+					router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+				}(recorders[i])
+			}
+			for range recorders {
+				<-done
+			}
+
+			for _, recorder := range recorders {
+				if recorder.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+				}
+				if tt.wantStatus != http.StatusOK {
+					if !strings.Contains(recorder.Body.String(), "Failed to get sites") {
+						t.Errorf("body = %s, want the sites error", recorder.Body)
+					}
+					continue
+				}
+
+				var data models.DashboardData
+				if err := json.Unmarshal(recorder.Body.Bytes(), &data); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if data.ViewMode != tt.wantViewMode || len(data.Sites) != tt.wantSites {
+					t.Errorf("view mode %q with %d sites, want %q with %d", data.ViewMode, len(data.Sites), tt.wantViewMode, tt.wantSites)
+				}
+			}
+		})
+	}
+}
+
+func TestRealtimeReadingsCancelledPartway(t *testing.T) {
+	sites := make([]*models.Site, 50)
+	for i := range sites {
+		sites[i] = &models.Site{ID: i + 1, Name: fmt.Sprintf("Site %d", i+1), DeviceID: fmt.Sprintf("simbisa-%d", i+1), IsActive: true}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const cancelAfter = 5
+	var mu sync.Mutex
+	var queried int
+	db, fake := newFakeDB(t, 4)
+	answer := answerDashboard(map[string]string{})
+	fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "DISTINCT ON (sensor_name)") {
+			mu.Lock()
+			queried++
+			// The client goes away while the fifth device is being read
+			if queried == cancelAfter {
+				cancel()
+			}
+			mu.Unlock()
+		}
+		return answer(query, args)
+	}
+	cfg := &config.Config{Dashboard: config.DashboardConfig{RealtimeWorkers: 3}}
+	wd := watchdog.New()
+	handler := NewDashboardHandler(db, cfg, settings.NewStore(db, cfg), alerts.NewFuelEscalation(time.Hour), wd)
+
+	_, err := handler.getAggressiveParallelRealTimeReadings(ctx, sites, map[int]*models.SiteType{}, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+
+	// The workers drain the remaining sites without querying and exit
+	deadline := time.Now().Add(time.Second)
+	for wd.Stats().ActiveWorkers > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d workers still running after cancellation", wd.Stats().ActiveWorkers)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Workers already reading when the context was cancelled may finish their device
+	if queried > cancelAfter+cfg.Dashboard.RealtimeWorkers {
+		t.Errorf("read %d of %d devices, want at most %d after cancelling", queried, len(sites), cancelAfter+cfg.Dashboard.RealtimeWorkers)
+	}
+}
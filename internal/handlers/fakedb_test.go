@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/models"
+)
+
+// fakeQuery answers one query with columns and rows, or an error
+type fakeQuery func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error)
+
+// fakeDB is an in-memory stand-in for PostgreSQL. Cumulative upserts succeed
+// except for failSiteID, advisory locks fail to be taken while lockHeld, and
+// other queries are answered by answer. Every statement succeeds, and all are
+// recorded.
+type fakeDB struct {
+	mu         sync.Mutex
+	answer     fakeQuery
+	failSiteID int64
+	lockHeld   bool
+	upserts    []int64
+	execs      []string
+	queries    []string
+	commits    int
+	rollbacks  int
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+// newFakeDB opens a database backed by a new fakeDB, limited to maxOpenConns connections
+func newFakeDB(t *testing.T, maxOpenConns int) (*database.DB, *fakeDB) {
+	t.Helper()
+
+	state := &fakeDB{}
+	fakeDBsMu.Lock()
+	fakeDBs[t.Name()] = state
+	fakeDBsMu.Unlock()
+
+	db, err := sql.Open("fakedb", t.Name())
+	if err != nil {
+		t.Fatalf("open fake database: %v", err)
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+	t.Cleanup(func() {
+		db.Close()
+		fakeDBsMu.Lock()
+		delete(fakeDBs, t.Name())
+		fakeDBsMu.Unlock()
+	})
+
+	return &database.DB{DB: db}, state
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+
+	state, ok := fakeDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("no fake database %q", name)
+	}
+	return &fakeConn{db: state}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.execs = append(c.db.execs, strings.TrimSpace(query))
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.queries = append(c.db.queries, strings.TrimSpace(query))
+	if strings.Contains(query, "pg_try_advisory_lock") {
+		return &fakeRows{columns: []string{"locked"}, values: [][]driver.Value{{!c.db.lockHeld}}}, nil
+	}
+	if strings.Contains(query, "INSERT INTO cumulative_readings") {
+		return c.db.upsertCumulative(args)
+	}
+	if c.db.answer == nil {
+		return nil, fmt.Errorf("fakedb: unexpected query: %s", query)
+	}
+	columns, values, err := c.db.answer(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: columns, values: values}, nil
+}
+
+// upsertCumulative answers a cumulative reading upsert with the stored row; the caller holds mu
+func (db *fakeDB) upsertCumulative(args []driver.NamedValue) (driver.Rows, error) {
+	siteID := args[0].Value.(int64)
+	if siteID == db.failSiteID {
+		return nil, fmt.Errorf("fakedb: upsert failed for site %d", siteID)
+	}
+	db.upserts = append(db.upserts, siteID)
+
+	now := time.Now()
+	return &fakeRows{
+		columns: []string{"id", "site_id", "device_id", "date", "total_fuel_consumed", "total_fuel_topped_up",
+			"fuel_consumed_percent", "fuel_topped_up_percent", "total_generator_runtime", "total_zesa_runtime",
+			"total_offline_time", "generator_starts", "calc_version", "calculated_at", "created_at"},
+		values: [][]driver.Value{{siteID, siteID, args[1].Value, args[2].Value, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, int64(0), int64(1), now, now}},
+	}, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.rollbacks++
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// siteRows answers a site listing query with one row per site
+func siteRows(sites ...*models.Site) ([]string, [][]driver.Value, error) {
+	columns := []string{"id", "name", "location", "device_id", "is_active", "created_at", "type_id",
+		"maintenance_mode", "maintenance_start", "maintenance_end", "alerts_enabled",
+		"low_fuel_mode", "low_fuel_liters", "low_fuel_threshold"}
+	var values [][]driver.Value
+	for _, site := range sites {
+		values = append(values, []driver.Value{int64(site.ID), site.Name, site.Location, site.DeviceID, site.IsActive, site.CreatedAt, nil,
+			site.MaintenanceMode, timeValue(site.MaintenanceStart), timeValue(site.MaintenanceEnd), site.AlertsEnabled,
+			site.LowFuelMode, floatValue(site.LowFuelLiters), floatValue(site.LowFuelThreshold)})
+	}
+	return columns, values, nil
+}
+
+// userRows answers a user listing query with one row per user
+func userRows(users ...*models.User) ([]string, [][]driver.Value, error) {
+	columns := []string{"id", "username", "email", "password", "role", "full_name", "is_active", "last_login", "created_at"}
+	var values [][]driver.Value
+	for _, user := range users {
+		values = append(values, []driver.Value{int64(user.ID), user.Username, user.Email, user.Password, user.Role, user.FullName, user.IsActive, timeValue(user.LastLogin), user.CreatedAt})
+	}
+	return columns, values, nil
+}
+
+// timeValue returns t as a column value, NULL when nil
+func timeValue(t *time.Time) driver.Value {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// floatValue returns f as a column value, NULL when nil
+func floatValue(f *float64) driver.Value {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
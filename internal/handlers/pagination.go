@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pagination holds validated ?page=&pageSize= values. Requested is false when
+// the client sent neither, in which case handlers return every item.
+type pagination struct {
+	Page      int
+	PageSize  int
+	Requested bool
+}
+
+// parsePagination reads page and pageSize, defaulting to page 1 of the configured
+// default page size and clamping pageSize to the configured maximum. Invalid
+// values get a 400 response and ok=false.
+func parsePagination(c *gin.Context, cfg config.PaginationConfig) (p pagination, ok bool) {
+	p = pagination{Page: 1, PageSize: cfg.DefaultPageSize}
+
+	if value := c.Query("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "page must be a positive integer",
+			})
+			return p, false
+		}
+		p.Page = page
+		p.Requested = true
+	}
+
+	if value := c.Query("pageSize"); value != "" {
+		pageSize, err := strconv.Atoi(value)
+		if err != nil || pageSize < 1 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "pageSize must be a positive integer",
+			})
+			return p, false
+		}
+		if pageSize > cfg.MaxPageSize {
+			pageSize = cfg.MaxPageSize
+		}
+		p.PageSize = pageSize
+		p.Requested = true
+	}
+
+	return p, true
+}
+
+// paginate returns the requested page of items and sets the pagination headers.
+// Items are returned unchanged when pagination was not requested.
+func paginate[T any](c *gin.Context, items []T, p pagination) []T {
+	if !p.Requested {
+		return items
+	}
+
+	setPaginationHeaders(c, len(items), p)
+
+	start := (p.Page - 1) * p.PageSize
+	if start >= len(items) {
+		return items[:0]
+	}
+	end := start + p.PageSize
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+// setPaginationHeaders sets X-Total-Count, X-Page, X-Page-Size and an RFC 5988
+// Link header with first, prev, next and last pages. Link URLs keep the
+// request's path and other query parameters.
+func setPaginationHeaders(c *gin.Context, total int, p pagination) {
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Header("X-Page", strconv.Itoa(p.Page))
+	c.Header("X-Page-Size", strconv.Itoa(p.PageSize))
+
+	lastPage := (total + p.PageSize - 1) / p.PageSize
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	links := []string{pageLink(c, 1, p.PageSize, "first")}
+	if p.Page > 1 {
+		// A page past the end points back at the last page
+		prev := p.Page - 1
+		if prev > lastPage {
+			prev = lastPage
+		}
+		links = append(links, pageLink(c, prev, p.PageSize, "prev"))
+	}
+	if p.Page < lastPage {
+		links = append(links, pageLink(c, p.Page+1, p.PageSize, "next"))
+	}
+	links = append(links, pageLink(c, lastPage, p.PageSize, "last"))
+
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// pageLink formats one Link header entry for the request URL at the given page
+func pageLink(c *gin.Context, page, pageSize int, rel string) string {
+	query := c.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("pageSize", strconv.Itoa(pageSize))
+
+	return fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, query.Encode(), rel)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testContext returns a gin context for a GET of target and its recorder
+func testContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	return c, recorder
+}
+
+func TestParsePagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.PaginationConfig{DefaultPageSize: 20, MaxPageSize: 100}
+
+	tests := []struct {
+		query  string
+		want   pagination
+		wantOK bool
+	}{
+		{"", pagination{Page: 1, PageSize: 20}, true},
+		{"?page=3", pagination{Page: 3, PageSize: 20, Requested: true}, true},
+		{"?pageSize=5", pagination{Page: 1, PageSize: 5, Requested: true}, true},
+		{"?page=2&pageSize=9000", pagination{Page: 2, PageSize: 100, Requested: true}, true},
+		{"?page=0", pagination{}, false},
+		{"?page=-1", pagination{}, false},
+		{"?page=abc", pagination{}, false},
+		{"?pageSize=0", pagination{}, false},
+		{"?pageSize=1.5", pagination{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, recorder := testContext("/items" + tt.query)
+			got, ok := parsePagination(c, cfg)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %t, want %t", ok, tt.wantOK)
+			}
+			if !ok {
+				if recorder.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400", recorder.Code)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parsePagination = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	items := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name      string
+		p         pagination
+		want      []int
+		wantTotal string
+	}{
+		{"not requested", pagination{Page: 1, PageSize: 2}, []int{1, 2, 3, 4, 5}, ""},
+		{"first page", pagination{Page: 1, PageSize: 2, Requested: true}, []int{1, 2}, "5"},
+		{"last partial page", pagination{Page: 3, PageSize: 2, Requested: true}, []int{5}, "5"},
+		{"past the end", pagination{Page: 9, PageSize: 2, Requested: true}, []int{}, "5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, recorder := testContext("/items")
+			got := paginate(c, items, tt.p)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paginate = %v, want %v", got, tt.want)
+			}
+			if total := recorder.Header().Get("X-Total-Count"); total != tt.wantTotal {
+				t.Errorf("X-Total-Count = %q, want %q", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestGetUsersPaginated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// Pages default to and are capped at two users
+	cfg := &config.Config{Pagination: config.PaginationConfig{DefaultPageSize: 2, MaxPageSize: 2}}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	users := []*models.User{
+		{ID: 1, Username: "admin", Role: "admin", IsActive: true, CreatedAt: created},
+		{ID: 2, Username: "ann", Role: "manager", IsActive: true, CreatedAt: created},
+		{ID: 3, Username: "ben", Role: "supervisor", IsActive: true, CreatedAt: created},
+	}
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantIDs    []int
+		wantTotal  string
+	}{
+		{"/users", http.StatusOK, []int{1, 2, 3}, ""},
+		{"/users?page=2&pageSize=2", http.StatusOK, []int{3}, "3"},
+		{"/users?page=2", http.StatusOK, []int{3}, "3"},
+		{"/users?page=1&pageSize=9000", http.StatusOK, []int{1, 2}, "3"},
+		{"/users?page=0", http.StatusBadRequest, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if strings.Contains(query, "FROM users") {
+					return userRows(users...)
+				}
+				return nil, nil, fmt.Errorf("unexpected query: %s", query)
+			}
+
+			router := gin.New()
+			router.GET("/users", NewUserHandler(db, cfg).GetUsers)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(fake.queries) != 0 {
+					t.Errorf("queried the database for an invalid page: %q", fake.queries)
+				}
+				return
+			}
+
+			var got []models.UserResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			var ids []int
+			for _, user := range got {
+				ids = append(ids, user.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("user IDs = %v, want %v", ids, tt.wantIDs)
+			}
+			if total := recorder.Header().Get("X-Total-Count"); total != tt.wantTotal {
+				t.Errorf("X-Total-Count = %q, want %q", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestSetPaginationHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		target   string
+		total    int
+		page     pagination
+		wantLink string
+	}{
+		{
+			"middle page", "/users?role=admin&page=2&pageSize=10", 25, pagination{Page: 2, PageSize: 10},
+			`</users?page=1&pageSize=10&role=admin>; rel="first", ` +
+				`</users?page=1&pageSize=10&role=admin>; rel="prev", ` +
+				`</users?page=3&pageSize=10&role=admin>; rel="next", ` +
+				`</users?page=3&pageSize=10&role=admin>; rel="last"`,
+		},
+		{
+			"first page", "/users", 25, pagination{Page: 1, PageSize: 10},
+			`</users?page=1&pageSize=10>; rel="first", ` +
+				`</users?page=2&pageSize=10>; rel="next", ` +
+				`</users?page=3&pageSize=10>; rel="last"`,
+		},
+		{
+			"last page", "/users?page=3&pageSize=10", 25, pagination{Page: 3, PageSize: 10},
+			`</users?page=1&pageSize=10>; rel="first", ` +
+				`</users?page=2&pageSize=10>; rel="prev", ` +
+				`</users?page=3&pageSize=10>; rel="last"`,
+		},
+		{
+			"past the end", "/users?page=7&pageSize=10", 25, pagination{Page: 7, PageSize: 10},
+			`</users?page=1&pageSize=10>; rel="first", ` +
+				`</users?page=3&pageSize=10>; rel="prev", ` +
+				`</users?page=3&pageSize=10>; rel="last"`,
+		},
+		{
+			"no results", "/users", 0, pagination{Page: 1, PageSize: 10},
+			`</users?page=1&pageSize=10>; rel="first", ` +
+				`</users?page=1&pageSize=10>; rel="last"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, recorder := testContext(tt.target)
+			setPaginationHeaders(c, tt.total, tt.page)
+
+			header := recorder.Header()
+			if got := header.Get("X-Total-Count"); got != fmt.Sprint(tt.total) {
+				t.Errorf("X-Total-Count = %q, want %d", got, tt.total)
+			}
+			if got := header.Get("X-Page"); got != fmt.Sprint(tt.page.Page) {
+				t.Errorf("X-Page = %q, want %d", got, tt.page.Page)
+			}
+			if got := header.Get("X-Page-Size"); got != fmt.Sprint(tt.page.PageSize) {
+				t.Errorf("X-Page-Size = %q, want %d", got, tt.page.PageSize)
+			}
+			if got := header.Get("Link"); got != tt.wantLink {
+				t.Errorf("Link =\n%s\nwant\n%s", got, tt.wantLink)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fuel-monitor-api/internal/mail"
+	"fuel-monitor-api/internal/middleware"
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxEmailReportDays caps the range of a weekly report given by startDate and endDate
+const maxEmailReportDays = 31
+
+type ReportHandler struct {
+	Cumulative *CumulativeHandler
+	Mailer     *mail.Sender
+}
+
+func NewReportHandler(cumulative *CumulativeHandler, mailer *mail.Sender) *ReportHandler {
+	return &ReportHandler{
+		Cumulative: cumulative,
+		Mailer:     mailer,
+	}
+}
+
+// EmailReport builds a daily or weekly consumption report for the accessible
+// sites from the stored cumulative readings and emails it as the range export
+// workbook. The report goes to the requester, or to the given recipients for
+// admins. It is built before responding, so range errors are reported, and sent
+// in the background: the response only acknowledges the queued email.
+func (h *ReportHandler) EmailReport(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	if !h.Mailer.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Message: "Email is not configured",
+		})
+		return
+	}
+
+	var req models.EmailReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	recipients := req.Recipients
+	if len(recipients) > 0 && user.Role != "admin" {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Message: "Only admins can choose report recipients",
+		})
+		return
+	}
+	if len(recipients) == 0 {
+		if user.Email == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "Your account has no email address",
+			})
+			return
+		}
+		recipients = []string{user.Email}
+	}
+
+	startDate, endDate, message := reportRange(req)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: message,
+		})
+		return
+	}
+
+	startDateString := startDate.Format("2006-01-02")
+	endDateString := endDate.Format("2006-01-02")
+
+	sites, err := h.Cumulative.DB.GetReportSitesForUser(user.ID, user.Role, false)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sites for user %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sites",
+		})
+		return
+	}
+
+	if !h.Cumulative.checkRangeSize(c, len(sites), startDate, endDate) {
+		return
+	}
+
+	siteReadings, err := h.Cumulative.getRangeResults(sites, startDateString, endDateString)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get range data for emailed report: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get cumulative readings",
+		})
+		return
+	}
+
+	summary := h.Cumulative.calculateRangeSummary(siteReadings, startDateString, endDateString, startDate, endDate)
+
+	workbook, err := buildRangeWorkbook(siteReadings, summary)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to build report workbook: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to build report",
+		})
+		return
+	}
+	buffer, err := workbook.WriteToBuffer()
+	workbook.Close()
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to write report workbook: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to build report",
+		})
+		return
+	}
+
+	subject, body := reportEmail(req.Type, summary)
+	attachment := mail.Attachment{
+		Filename:    fmt.Sprintf("%s-report-%s-to-%s.xlsx", req.Type, startDateString, endDateString),
+		ContentType: xlsxContentType,
+		Data:        buffer.Bytes(),
+	}
+
+	// The context must not be used once the handler returns
+	log := middleware.Log(c)
+	go func() {
+		if err := h.Mailer.Send(recipients, subject, body, attachment); err != nil {
+			log.Errorf("Failed to email %s report requested by %s to %s: %v", req.Type, user.Username, strings.Join(recipients, ", "), err)
+			return
+		}
+		log.Infof("Emailed %s report %s to %s, requested by %s, to %s", req.Type, startDateString, endDateString, user.Username, strings.Join(recipients, ", "))
+	}()
+
+	c.JSON(http.StatusAccepted, models.EmailReportResponse{
+		Status:     "queued",
+		Type:       req.Type,
+		StartDate:  startDateString,
+		EndDate:    endDateString,
+		Sites:      len(siteReadings),
+		Recipients: recipients,
+	})
+}
+
+// reportRange resolves the days a report covers. It returns a message
+// describing the problem when the dates are invalid.
+func reportRange(req models.EmailReportRequest) (startDate, endDate time.Time, message string) {
+	// Reports default to yesterday, the last complete day
+	endDate = today().AddDate(0, 0, -1)
+	if req.Date != "" {
+		date, err := parseDate(req.Date)
+		if err != nil {
+			return startDate, endDate, "Invalid date format. Use DD/MM/YYYY or YYYY-MM-DD"
+		}
+		endDate = date
+	}
+
+	switch {
+	case req.Type == "daily":
+		if req.StartDate != "" || req.EndDate != "" {
+			return startDate, endDate, "Daily reports cover date; startDate and endDate are only for weekly reports"
+		}
+		startDate = endDate
+	case req.StartDate != "" || req.EndDate != "":
+		if req.StartDate == "" || req.EndDate == "" {
+			return startDate, endDate, "startDate and endDate must be given together"
+		}
+		var err error
+		if startDate, err = parseDate(req.StartDate); err != nil {
+			return startDate, endDate, "Invalid startDate format. Use DD/MM/YYYY or YYYY-MM-DD"
+		}
+		if endDate, err = parseDate(req.EndDate); err != nil {
+			return startDate, endDate, "Invalid endDate format. Use DD/MM/YYYY or YYYY-MM-DD"
+		}
+		if endDate.Before(startDate) {
+			return startDate, endDate, "endDate must not be before startDate"
+		}
+		if endDate.Sub(startDate) >= maxEmailReportDays*24*time.Hour {
+			return startDate, endDate, fmt.Sprintf("Range cannot exceed %d days", maxEmailReportDays)
+		}
+	default:
+		startDate = endDate.AddDate(0, 0, -6)
+	}
+
+	// Ranges may not start in the future; a future end is capped at today
+	todayDate := today()
+	if startDate.Format("2006-01-02") > todayDate.Format("2006-01-02") {
+		return startDate, endDate, "Report dates cannot be in the future"
+	}
+	if endDate.Format("2006-01-02") > todayDate.Format("2006-01-02") {
+		endDate = todayDate
+	}
+
+	return startDate, endDate, ""
+}
+
+// reportEmail returns the subject and plain text body of an emailed report
+func reportEmail(reportType string, summary models.CumulativeRangeSummary) (subject, body string) {
+	if reportType == "daily" {
+		subject = fmt.Sprintf("Daily fuel consumption report for %s", summary.DateRange.Start)
+	} else {
+		subject = fmt.Sprintf("Weekly fuel consumption report for %s to %s", summary.DateRange.Start, summary.DateRange.End)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\r\n\r\n", subject)
+	fmt.Fprintf(&b, "Sites: %d\r\n", summary.TotalSites)
+	fmt.Fprintf(&b, "Fuel consumed: %.1f L\r\n", summary.TotalFuelConsumed)
+	fmt.Fprintf(&b, "Fuel topped: %.1f L\r\n", summary.TotalFuelTopped)
+	fmt.Fprintf(&b, "Generator hours: %.2f\r\n", summary.TotalGeneratorHours)
+	fmt.Fprintf(&b, "ZESA hours: %.2f\r\n", summary.TotalZesaHours)
+	fmt.Fprintf(&b, "Average uptime: %.1f%%\r\n\r\n", summary.AverageUptime)
+	b.WriteString("The attached workbook lists the totals per site.\r\n")
+	return subject, b.String()
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"testing"
+
+	"fuel-monitor-api/internal/models"
+)
+
+func TestReportRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       models.EmailReportRequest
+		wantStart string
+		wantEnd   string
+		wantError bool
+	}{
+		{"daily", models.EmailReportRequest{Type: "daily", Date: "2024-03-10"}, "2024-03-10", "2024-03-10", false},
+		{"daily with range", models.EmailReportRequest{Type: "daily", StartDate: "2024-03-01", EndDate: "2024-03-05"}, "", "", true},
+		{"daily with start only", models.EmailReportRequest{Type: "daily", Date: "2024-03-10", StartDate: "2024-03-01"}, "", "", true},
+		{"weekly ending on date", models.EmailReportRequest{Type: "weekly", Date: "2024-03-10"}, "2024-03-04", "2024-03-10", false},
+		{"weekly range", models.EmailReportRequest{Type: "weekly", StartDate: "2024-03-01", EndDate: "2024-03-05"}, "2024-03-01", "2024-03-05", false},
+		{"weekly half range", models.EmailReportRequest{Type: "weekly", StartDate: "2024-03-01"}, "", "", true},
+		{"weekly reversed", models.EmailReportRequest{Type: "weekly", StartDate: "2024-03-05", EndDate: "2024-03-01"}, "", "", true},
+		{"weekly too long", models.EmailReportRequest{Type: "weekly", StartDate: "2024-01-01", EndDate: "2024-03-01"}, "", "", true},
+		{"invalid date", models.EmailReportRequest{Type: "daily", Date: "10-03"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, message := reportRange(tt.req)
+			if tt.wantError {
+				if message == "" {
+					t.Errorf("reportRange(%+v) accepted, want an error", tt.req)
+				}
+				return
+			}
+			if message != "" {
+				t.Fatalf("reportRange(%+v) = %q, want no error", tt.req, message)
+			}
+			if got := start.Format("2006-01-02"); got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := end.Format("2006-01-02"); got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
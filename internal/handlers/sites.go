@@ -1,23 +1,36 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"fuel-monitor-api/internal/config"
 	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/logger"
 	"fuel-monitor-api/internal/middleware"
 	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type SitesHandler struct {
-	DB *database.DB
+	DB       *database.DB
+	Config   *config.Config
+	Settings *settings.Store
 }
 
-func NewSitesHandler(db *database.DB) *SitesHandler {
+func NewSitesHandler(db *database.DB, cfg *config.Config, store *settings.Store) *SitesHandler {
 	return &SitesHandler{
-		DB: db,
+		DB:       db,
+		Config:   cfg,
+		Settings: store,
 	}
 }
 
@@ -39,6 +52,60 @@ func (h *SitesHandler) GetSites(c *gin.Context) {
 		return
 	}
 
+	// Clients that opt in get an envelope that distinguishes an empty scope
+	if c.Query("envelope") == "true" {
+		c.JSON(http.StatusOK, models.SitesResponse{
+			Sites:      sites,
+			ScopeEmpty: len(sites) == 0,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, sites)
+}
+
+// SearchSites finds accessible sites whose name, location or device ID contains ?q=,
+// best matches first, returning at most ?limit= sites (default 20, max 100)
+func (h *SitesHandler) SearchSites(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "q is required",
+		})
+		return
+	}
+	if len(query) > 100 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "q must be at most 100 characters",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "limit must be between 1 and 100",
+		})
+		return
+	}
+
+	sites, err := h.DB.SearchSitesForUser(user.ID, user.Role, query, limit)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to search sites for %q: %v", query, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, sites)
 }
 
@@ -55,9 +122,7 @@ func (h *SitesHandler) AssignSitesToUser(c *gin.Context) {
 
 	var req models.AssignSitesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Message: "Invalid site IDs",
-		})
+		respondBindError(c, err, "Invalid site IDs")
 		return
 	}
 
@@ -77,6 +142,14 @@ func (h *SitesHandler) AssignSitesToUser(c *gin.Context) {
 		return
 	}
 
+	// Admins see every site, so assignments would have no effect. Clearing is still allowed.
+	if user.Role == "admin" && len(req.SiteIds) > 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Admins have access to all sites",
+		})
+		return
+	}
+
 	// Assign sites to user
 	err = h.DB.AssignSitesToUser(userID, req.SiteIds)
 	if err != nil {
@@ -112,3 +185,1198 @@ func (h *SitesHandler) GetUserSiteAssignments(c *gin.Context) {
 
 	c.JSON(http.StatusOK, assignments)
 }
+
+// BulkAssignSites assigns the same sites to several users atomically, adding to
+// their own or, with mode "replace", replacing them (admin only)
+func (h *SitesHandler) BulkAssignSites(c *gin.Context) {
+	var req models.BulkAssignSitesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	// Replacing removes assignments, so it must be asked for
+	if req.Mode == "" {
+		req.Mode = "add"
+	}
+	if req.Mode != "replace" && req.Mode != "add" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "mode must be 'add' or 'replace'",
+		})
+		return
+	}
+
+	if len(req.UserIds) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "At least one user ID is required",
+		})
+		return
+	}
+
+	userIDs := uniqueIDs(req.UserIds)
+	siteIDs := uniqueIDs(req.SiteIds)
+
+	// Validate that all users exist before touching any assignments
+	users := make(map[int]*models.User, len(userIDs))
+	for _, userID := range userIDs {
+		user, err := h.DB.GetUserByID(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Message: "Database error",
+			})
+			return
+		}
+
+		if user == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Message: fmt.Sprintf("User %d not found", userID),
+			})
+			return
+		}
+
+		if user.Role == "admin" && len(siteIDs) > 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: fmt.Sprintf("User %d is an admin; admins have access to all sites", userID),
+			})
+			return
+		}
+		users[userID] = user
+	}
+
+	// Validate that all sites exist
+	sites, err := h.DB.GetAllSites()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Database error",
+		})
+		return
+	}
+
+	existingSites := make(map[int]bool, len(sites))
+	for _, site := range sites {
+		existingSites[site.ID] = true
+	}
+
+	for _, siteID := range siteIDs {
+		if !existingSites[siteID] {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Message: fmt.Sprintf("Site %d not found", siteID),
+			})
+			return
+		}
+	}
+
+	added, err := h.DB.BulkAssignSitesToUsers(userIDs, siteIDs, req.Mode == "replace")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to update site assignments",
+		})
+		return
+	}
+
+	results := make([]models.BulkAssignUserResult, 0, len(userIDs))
+	for _, userID := range userIDs {
+		result := models.BulkAssignUserResult{
+			UserID:     userID,
+			Username:   users[userID].Username,
+			AddedSites: added[userID],
+		}
+
+		if assignments, err := h.DB.GetUserSiteAssignments(userID); err == nil {
+			result.AssignedSites = len(assignments)
+		}
+
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, models.BulkAssignSitesResponse{
+		Mode:    req.Mode,
+		Results: results,
+	})
+}
+
+// CloneAssignments copies one user's site assignments to another user, adding to
+// the target's own or, with mode "replace", replacing them (admin only)
+func (h *SitesHandler) CloneAssignments(c *gin.Context) {
+	var req models.CloneAssignmentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	// Replacing removes assignments, so it must be asked for
+	if req.Mode == "" {
+		req.Mode = "add"
+	}
+	if req.Mode != "replace" && req.Mode != "add" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "mode must be 'add' or 'replace'",
+		})
+		return
+	}
+
+	if req.FromUserID == req.ToUserID {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "fromUserId and toUserId must differ",
+		})
+		return
+	}
+
+	// Both users must exist; admins see every site, so assignments mean nothing to them
+	for _, userID := range []int{req.FromUserID, req.ToUserID} {
+		user, err := h.DB.GetUserByID(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Message: "Database error",
+			})
+			return
+		}
+
+		if user == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Message: fmt.Sprintf("User %d not found", userID),
+			})
+			return
+		}
+
+		if user.Role == "admin" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: fmt.Sprintf("User %d is an admin; admins have access to all sites", userID),
+			})
+			return
+		}
+	}
+
+	sourceAssignments, err := h.DB.GetUserSiteAssignments(req.FromUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Database error",
+		})
+		return
+	}
+
+	siteIDs := make([]int, 0, len(sourceAssignments))
+	for _, assignment := range sourceAssignments {
+		siteIDs = append(siteIDs, assignment.SiteID)
+	}
+
+	added, err := h.DB.BulkAssignSitesToUsers([]int{req.ToUserID}, siteIDs, req.Mode == "replace")
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to clone site assignments from user %d to %d: %v", req.FromUserID, req.ToUserID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to update site assignments",
+		})
+		return
+	}
+
+	response := models.CloneAssignmentsResponse{
+		FromUserID:  req.FromUserID,
+		ToUserID:    req.ToUserID,
+		Mode:        req.Mode,
+		SourceSites: len(siteIDs),
+		AddedSites:  added[req.ToUserID],
+	}
+	if assignments, err := h.DB.GetUserSiteAssignments(req.ToUserID); err == nil {
+		response.AssignedSites = len(assignments)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// uniqueIDs returns ids without duplicates, preserving order
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// GetFrozenSensors flags accessible devices whose fuel level has not changed for longer than the frozen window
+func (h *SitesHandler) GetFrozenSensors(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	window := time.Duration(h.Settings.Float(settings.FrozenSensorWindowHours) * float64(time.Hour))
+	if windowParam := c.Query("window"); windowParam != "" {
+		parsed, err := time.ParseDuration(windowParam)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "window must be a positive duration such as 6h or 90m",
+			})
+			return
+		}
+		window = parsed
+	}
+
+	sites, err := h.DB.GetSitesForUser(user.ID, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	// Site types map sensors to the names their devices report them under
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get site types, using default sensor names: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
+	sensors, err := h.DB.GetFrozenFuelSensors(sites, models.SiteSensorNames(sites, siteTypes), window)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get frozen sensors: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to check for frozen sensors",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.FrozenSensorsResponse{
+		Window:  window.String(),
+		Sensors: sensors,
+	})
+}
+
+// GetFuelLevelAt returns a site's most recent fuel level and volume at or before a given time
+func (h *SitesHandler) GetFuelLevelAt(c *gin.Context) {
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, c.Query("time"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "time must be an RFC3339 timestamp",
+		})
+		return
+	}
+
+	fuelLevel, fuelVolume, err := h.DB.GetFuelLevelAt(site.DeviceID, at, h.sensorNames(site))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get fuel level at %s for site %s: %v", at.Format(time.RFC3339), site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get fuel level",
+		})
+		return
+	}
+
+	if fuelLevel == nil && fuelVolume == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "No fuel readings at or before the requested time",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.FuelLevelAtResponse{
+		SiteID:      site.ID,
+		SiteName:    site.Name,
+		DeviceID:    site.DeviceID,
+		RequestedAt: at,
+		FuelLevel:   fuelLevel,
+		FuelVolume:  fuelVolume,
+	})
+}
+
+// accessibleSite resolves the :id route parameter to a site the current user may access.
+// It writes the error response and returns false when the site cannot be used.
+func (h *SitesHandler) accessibleSite(c *gin.Context) (*models.Site, bool) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return nil, false
+	}
+
+	siteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid site ID",
+		})
+		return nil, false
+	}
+
+	site, err := h.DB.GetSiteForUser(siteID, user.ID, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Database error",
+		})
+		return nil, false
+	}
+
+	if site == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Site not found",
+		})
+		return nil, false
+	}
+
+	return site, true
+}
+
+// GetSensorReadings returns a site's raw sensor readings as a time series using cursor pagination.
+//
+// Pages are ordered by reading time, then sensor name. Pass the previous page's
+// nextCursor as ?after= to fetch the next page; readings strictly after it are
+// returned. A plain RFC3339 timestamp also works as ?after=. An empty nextCursor
+// means the end of the data was reached.
+func (h *SitesHandler) GetSensorReadings(c *gin.Context) {
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "500"))
+	if err != nil || limit < 1 || limit > 5000 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "limit must be between 1 and 5000",
+		})
+		return
+	}
+
+	var after *time.Time
+	var afterSensor string
+	if afterParam := c.Query("after"); afterParam != "" {
+		parsed, sensor, err := parseReadingsCursor(afterParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "after must be a nextCursor or an RFC3339 timestamp",
+			})
+			return
+		}
+		after, afterSensor = &parsed, sensor
+	}
+
+	sensors := []string{"fuel_sensor_level"}
+	if sensorParam := c.Query("sensors"); sensorParam != "" {
+		sensors = nil
+		for _, sensor := range strings.Split(sensorParam, ",") {
+			if sensor = strings.TrimSpace(sensor); sensor != "" {
+				sensors = append(sensors, sensor)
+			}
+		}
+	}
+
+	// Readings are stored under the device's names and reported under the API's
+	names := h.sensorNames(site)
+	if afterSensor != "" {
+		afterSensor = names.Device(afterSensor)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	readings, err := h.DB.GetSensorReadingsAfter(site.DeviceID, names.DeviceNames(sensors...), after, afterSensor, limit+1)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sensor readings for site %s: %v", site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sensor readings",
+		})
+		return
+	}
+
+	for _, reading := range readings {
+		reading.SensorName = names.Sensor(reading.SensorName)
+	}
+
+	page := models.SensorReadingsPage{
+		SiteID:   site.ID,
+		DeviceID: site.DeviceID,
+		Sensors:  sensors,
+		Readings: readings,
+	}
+
+	if len(readings) > limit {
+		page.Readings = readings[:limit]
+		page.HasMore = true
+		page.NextCursor = readingsCursor(page.Readings[limit-1])
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// readingsCursor encodes the position of a reading as "<time>,<sensor name>".
+// Sensor names cannot contain commas since ?sensors= is comma-separated.
+func readingsCursor(reading *models.RawSensorReading) string {
+	return reading.Time.Format(time.RFC3339Nano) + "," + reading.SensorName
+}
+
+// parseReadingsCursor decodes a cursor made by readingsCursor. A bare timestamp
+// yields an empty sensor name, meaning strictly after that time.
+func parseReadingsCursor(cursor string) (time.Time, string, error) {
+	timePart, sensor, _ := strings.Cut(cursor, ",")
+	parsed, err := time.Parse(time.RFC3339Nano, timePart)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return parsed, sensor, nil
+}
+
+// GetDeviceSensors lists the sensor names a site's device reports, with ?latest=true adding each sensor's latest reading
+func (h *SitesHandler) GetDeviceSensors(c *gin.Context) {
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	withLatest := c.Query("latest") == "true"
+
+	sensors, err := h.DB.GetDeviceSensors(site.DeviceID, withLatest)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get sensors for site %s: %v", site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sensors",
+		})
+		return
+	}
+
+	names := h.sensorNames(site)
+	for _, sensor := range sensors {
+		sensor.SensorName = names.Sensor(sensor.SensorName)
+	}
+	sort.Slice(sensors, func(i, j int) bool { return sensors[i].SensorName < sensors[j].SensorName })
+
+	c.JSON(http.StatusOK, models.DeviceSensorsResponse{
+		SiteID:   site.ID,
+		DeviceID: site.DeviceID,
+		Sensors:  sensors,
+	})
+}
+
+// GetLatestSensorValue returns the latest raw value and time of the sensor named
+// :name on the site's device
+func (h *SitesHandler) GetLatestSensorValue(c *gin.Context) {
+	sensorName := c.Param("name")
+	if !models.IsKnownSensor(sensorName) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Unknown sensor: " + sensorName,
+		})
+		return
+	}
+
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	reading, err := h.DB.GetLatestSensorReading(site.DeviceID, h.sensorNames(site).Device(sensorName))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get latest %s reading for site %s: %v", sensorName, site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sensor reading",
+		})
+		return
+	}
+	if reading == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "No readings for sensor " + sensorName,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.LatestSensorValue{
+		SiteID:     site.ID,
+		DeviceID:   site.DeviceID,
+		SensorName: sensorName,
+		Value:      reading.Value,
+		Time:       reading.Time,
+	})
+}
+
+// GetRecentSensorReadings returns a sensor's latest ?limit= raw readings (default 50,
+// max 500), newest first, to inspect the data feeding the calculations (admin only)
+func (h *SitesHandler) GetRecentSensorReadings(c *gin.Context) {
+	sensorName := c.Param("name")
+	if !models.IsKnownSensor(sensorName) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Unknown sensor: " + sensorName,
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "limit must be between 1 and 500",
+		})
+		return
+	}
+
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	readings, err := h.DB.GetRecentSensorReadings(site.DeviceID, h.sensorNames(site).Device(sensorName), limit)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get recent %s readings for site %s: %v", sensorName, site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get sensor readings",
+		})
+		return
+	}
+	for i := range readings {
+		readings[i].SensorName = sensorName
+	}
+
+	c.JSON(http.StatusOK, models.RecentSensorReadings{
+		SiteID:     site.ID,
+		DeviceID:   site.DeviceID,
+		SensorName: sensorName,
+		Limit:      limit,
+		Readings:   readings,
+	})
+}
+
+// PatchSite changes only the fields present in the request, e.g. just the
+// location, leaving the others as they are (admin only)
+func (h *SitesHandler) PatchSite(c *gin.Context) {
+	siteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid site ID",
+		})
+		return
+	}
+
+	var req models.PatchSiteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	siteData := &models.UpdateSiteData{
+		Name:     req.Name,
+		Location: req.Location,
+		DeviceID: req.DeviceID,
+		TypeID:   req.TypeID,
+	}
+	if siteData.Empty() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "At least one of name, location, deviceId or typeId is required",
+		})
+		return
+	}
+
+	site, err := h.DB.UpdateSite(siteID, siteData)
+	if errors.Is(err, database.ErrDeviceIDTaken) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Message: "Device ID is already used by another site",
+		})
+		return
+	}
+	if errors.Is(err, database.ErrSiteTypeNotFound) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Site type not found",
+		})
+		return
+	}
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to update site %d: %v", siteID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if site == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Site not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, site)
+}
+
+// SetSiteMaintenance switches a site's maintenance mode, optionally for a time
+// window. Sites in maintenance are not counted as alerts on the dashboard (admin only).
+func (h *SitesHandler) SetSiteMaintenance(c *gin.Context) {
+	siteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid site ID",
+		})
+		return
+	}
+
+	var req models.SiteMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	if *req.Enabled {
+		if req.Start != nil && req.End != nil && !req.End.After(*req.Start) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "end must be after start",
+			})
+			return
+		}
+		if req.End != nil && !req.End.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "end must be in the future",
+			})
+			return
+		}
+	}
+
+	site, err := h.DB.SetSiteMaintenance(siteID, *req.Enabled, req.Start, req.End)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to set maintenance for site %d: %v", siteID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if site == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Site not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, site)
+}
+
+// SetSiteAlerts enables or disables a site's alerting. Sites with alerting disabled
+// keep their real status on the dashboard but are not counted as alerts or
+// notified (admin only).
+func (h *SitesHandler) SetSiteAlerts(c *gin.Context) {
+	siteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid site ID",
+		})
+		return
+	}
+
+	var req models.SiteAlertsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	site, err := h.DB.SetSiteAlertsEnabled(siteID, *req.Enabled)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to set alerting for site %d: %v", siteID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if site == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Site not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, site)
+}
+
+// SetSiteLowFuel sets whether a site's low fuel is judged by percent, by liters
+// remaining or by either (admin only)
+func (h *SitesHandler) SetSiteLowFuel(c *gin.Context) {
+	siteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid site ID",
+		})
+		return
+	}
+
+	var req models.SiteLowFuelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	if req.Mode != models.LowFuelModePercent && req.Liters == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "liters is required for mode " + req.Mode,
+		})
+		return
+	}
+
+	site, err := h.DB.SetSiteLowFuel(siteID, req.Mode, req.Liters)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to set low fuel mode for site %d: %v", siteID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if site == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Site not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, site)
+}
+
+// BulkSetLowFuelThreshold sets the same low fuel threshold (percent) on several
+// sites in one transaction, or clears it when null. Nothing is updated when any site does not exist (admin only).
+func (h *SitesHandler) BulkSetLowFuelThreshold(c *gin.Context) {
+	var req models.BulkLowFuelThresholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	threshold := req.LowFuelThreshold.Value
+	if !req.LowFuelThreshold.Set {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "lowFuelThreshold is required; use null to clear it",
+		})
+		return
+	}
+	if threshold != nil && (*threshold < 0 || *threshold > 100) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "lowFuelThreshold must be between 0 and 100",
+		})
+		return
+	}
+
+	siteIDs := uniqueIDs(req.SiteIds)
+
+	// Validate that all sites exist before touching any of them
+	sites, err := h.DB.GetAllSites()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Database error",
+		})
+		return
+	}
+
+	existingSites := make(map[int]bool, len(sites))
+	for _, site := range sites {
+		existingSites[site.ID] = true
+	}
+
+	missing := []string{}
+	for _, siteID := range siteIDs {
+		if !existingSites[siteID] {
+			missing = append(missing, strconv.Itoa(siteID))
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Sites not found: " + strings.Join(missing, ", "),
+		})
+		return
+	}
+
+	updated, err := h.DB.SetSitesLowFuelThreshold(siteIDs, threshold)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to set low fuel threshold for %d sites: %v", len(siteIDs), err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to update low fuel thresholds",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.BulkLowFuelThresholdResponse{
+		Updated:          updated,
+		LowFuelThreshold: threshold,
+	})
+}
+
+// GetSiteLowFuelThreshold returns a site's own low fuel threshold, null when it
+// has none, and the threshold in effect for it
+func (h *SitesHandler) GetSiteLowFuelThreshold(c *gin.Context) {
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	effective := h.Settings.Float(settings.LowFuelThreshold)
+	if site.LowFuelThreshold != nil {
+		effective = *site.LowFuelThreshold
+	}
+
+	c.JSON(http.StatusOK, models.SiteLowFuelThresholdResponse{
+		SiteID:           site.ID,
+		LowFuelThreshold: site.LowFuelThreshold,
+		Effective:        effective,
+	})
+}
+
+// GetLongRuntimeAlerts flags accessible sites whose generator has been on continuously
+// for longer than ?hours= (default from configuration)
+func (h *SitesHandler) GetLongRuntimeAlerts(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	threshold := h.Config.Sensors.LongRuntimeThreshold
+	if hoursParam := c.Query("hours"); hoursParam != "" {
+		hours, err := strconv.ParseFloat(hoursParam, 64)
+		if err != nil || hours <= 0 || hours > 24*30 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "hours must be a positive number up to 720",
+			})
+			return
+		}
+		threshold = time.Duration(hours * float64(time.Hour))
+	}
+
+	sites, err := h.DB.GetSitesForUser(user.ID, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	// Only sites whose type has a generator and alerting enabled can raise this alert
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		middleware.Log(c).Warnf("Failed to get site types, checking all sites: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+	generatorSites := make([]*models.Site, 0, len(sites))
+	for _, site := range sites {
+		if site.AlertsEnabled && siteTypeFor(site, siteTypes).Expects("generator_state") {
+			generatorSites = append(generatorSites, site)
+		}
+	}
+
+	// Look back twice the threshold so any run at least that long is still caught
+	lookback := 2 * threshold
+	if lookback < 48*time.Hour {
+		lookback = 48 * time.Hour
+	}
+
+	runs, err := h.DB.GetContinuousGeneratorRuns(generatorSites, models.SiteSensorNames(generatorSites, siteTypes), time.Now().Add(-lookback))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get generator runs: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to check generator runtime",
+		})
+		return
+	}
+
+	alerts := []*models.GeneratorRun{}
+	for _, run := range runs {
+		if run.RunningHours >= threshold.Hours() {
+			alerts = append(alerts, run)
+		}
+	}
+
+	// Longest running first
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].RunningHours > alerts[j].RunningHours
+	})
+
+	c.JSON(http.StatusOK, models.LongRuntimeAlertsResponse{
+		ThresholdHours: threshold.Hours(),
+		Sites:          alerts,
+	})
+}
+
+const (
+	// maxVolumeSeriesRange caps the span of a volume series request
+	maxVolumeSeriesRange = 31 * 24 * time.Hour
+	// maxVolumeSeriesBuckets caps the number of points a volume series can return
+	maxVolumeSeriesBuckets = 5000
+)
+
+// GetFuelVolumeSeries returns a site's fuel volume as a time-bucketed series using
+// ?start=&end=&interval=. Each bucket carries the last volume reading within it.
+func (h *SitesHandler) GetFuelVolumeSeries(c *gin.Context) {
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	end := time.Now()
+	if endParam := c.Query("end"); endParam != "" {
+		parsed, err := parseTimeParam(endParam, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "Invalid end. Use RFC3339, DD/MM/YYYY or YYYY-MM-DD",
+			})
+			return
+		}
+		end = parsed
+	}
+
+	start := end.Add(-24 * time.Hour)
+	if startParam := c.Query("start"); startParam != "" {
+		parsed, err := parseTimeParam(startParam, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "Invalid start. Use RFC3339, DD/MM/YYYY or YYYY-MM-DD",
+			})
+			return
+		}
+		start = parsed
+	}
+
+	if !start.Before(end) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "start must be before end",
+		})
+		return
+	}
+	if end.Sub(start) > maxVolumeSeriesRange {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Range cannot exceed 31 days",
+		})
+		return
+	}
+
+	interval, err := time.ParseDuration(c.DefaultQuery("interval", "1h"))
+	if err != nil || interval < time.Minute {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "interval must be a duration of at least 1m, such as 15m or 1h",
+		})
+		return
+	}
+	if end.Sub(start)/interval > maxVolumeSeriesBuckets {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: fmt.Sprintf("Too many buckets; use a larger interval (max %d points)", maxVolumeSeriesBuckets),
+		})
+		return
+	}
+
+	points, err := h.DB.GetFuelVolumeSeries(site.DeviceID, start, end, interval, h.sensorNames(site))
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get volume series for site %s: %v", site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get fuel volume series",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.VolumeSeriesResponse{
+		SiteID:   site.ID,
+		DeviceID: site.DeviceID,
+		Start:    start,
+		End:      end,
+		Interval: interval.String(),
+		Points:   points,
+	})
+}
+
+// parseTimeParam parses an RFC3339 timestamp or a date. A bare date means the
+// start of that day, or the start of the next day when endOfDay is set.
+func parseTimeParam(value string, endOfDay bool) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+
+	date, err := parseDate(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date, nil
+}
+
+// sensorNames returns the sensor names of the site's type; nil (the default
+// names) when the site has no type or the types cannot be read
+func (h *SitesHandler) sensorNames(site *models.Site) models.SensorNames {
+	if site.TypeID == nil {
+		return nil
+	}
+
+	siteTypes, err := h.DB.GetSiteTypes()
+	if err != nil {
+		logger.Warnf("Failed to get site types, using default sensor names: %v", err)
+		return nil
+	}
+	return siteTypeFor(site, siteTypes).Names()
+}
+
+// GetRuntimeForecast estimates how many generator hours a site's current fuel covers,
+// using the liters-per-generator-hour burn rate over the last ?days= (default 14)
+// of cumulative readings, and projects the empty date at the recent daily runtime
+func (h *SitesHandler) GetRuntimeForecast(c *gin.Context) {
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "14"))
+	if err != nil || days < 1 || days > 90 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "days must be between 1 and 90",
+		})
+		return
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -days).Format("2006-01-02")
+	historyDays, fuelConsumed, generatorHours, err := h.DB.GetRecentBurnStats(site.ID, since)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get burn stats for site %s: %v", site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to compute runtime forecast",
+		})
+		return
+	}
+
+	forecast := models.RuntimeForecastResponse{
+		SiteID:         site.ID,
+		SiteName:       site.Name,
+		HistoryDays:    historyDays,
+		FuelConsumed:   roundTo(fuelConsumed, 1),
+		GeneratorHours: roundTo(generatorHours, 2),
+	}
+
+	if reading := h.DB.GetSingleDeviceReading(site.DeviceID, h.sensorNames(site)); reading != nil {
+		if volume, err := strconv.ParseFloat(strings.TrimSpace(reading.FuelVolume), 64); err == nil && volume >= 0 {
+			forecast.CurrentVolume = &volume
+			forecast.VolumeCapturedAt = &reading.CapturedAt
+		}
+	}
+
+	switch {
+	case historyDays == 0:
+		forecast.Message = "No cumulative history in the selected period"
+	case generatorHours <= 0 || fuelConsumed <= 0:
+		forecast.Message = "Generator did not burn fuel in the selected period"
+	case forecast.CurrentVolume == nil:
+		forecast.Message = "No current fuel volume reading"
+	}
+
+	if historyDays > 0 && generatorHours > 0 && fuelConsumed > 0 {
+		burnRate := roundTo(fuelConsumed/generatorHours, 2)
+		dailyRuntime := roundTo(generatorHours/float64(historyDays), 2)
+		forecast.LitersPerGeneratorHour = &burnRate
+		forecast.AverageDailyRuntime = &dailyRuntime
+
+		if forecast.CurrentVolume != nil {
+			remaining := roundTo(*forecast.CurrentVolume/(fuelConsumed/generatorHours), 1)
+			forecast.RemainingGeneratorHours = &remaining
+
+			emptyDate := now.Add(time.Duration(remaining / (generatorHours / float64(historyDays)) * 24 * float64(time.Hour))).Format("2006-01-02")
+			forecast.ProjectedEmptyDate = &emptyDate
+		}
+	}
+
+	c.JSON(http.StatusOK, forecast)
+}
+
+// roundTo rounds a value to the given number of decimal places
+func roundTo(value float64, places int) float64 {
+	multiplier := math.Pow(10, float64(places))
+	return math.Round(value*multiplier) / multiplier
+}
+
+// maxDailyDeltaDays caps the range of the daily deltas endpoint
+const maxDailyDeltaDays = 92
+
+// GetDailyDeltas returns a site's closing fuel level per day with the change since
+// the previous day's closing, flagging drops beyond ?threshold= (percent) on days
+// the generator logged no runtime
+func (h *SitesHandler) GetDailyDeltas(c *gin.Context) {
+	site, ok := h.accessibleSite(c)
+	if !ok {
+		return
+	}
+
+	startDate, endDate, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "endDate must not be before startDate",
+		})
+		return
+	}
+	if endDate.Sub(startDate) >= maxDailyDeltaDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: fmt.Sprintf("Range cannot exceed %d days", maxDailyDeltaDays),
+		})
+		return
+	}
+
+	threshold := h.Config.Sensors.DailyDropThreshold
+	if thresholdParam := c.Query("threshold"); thresholdParam != "" {
+		parsed, err := strconv.ParseFloat(thresholdParam, 64)
+		if err != nil || parsed < 0 || parsed > 100 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "threshold must be a number between 0 and 100",
+			})
+			return
+		}
+		threshold = parsed
+	}
+
+	startDateString := startDate.Format("2006-01-02")
+	endDateString := endDate.Format("2006-01-02")
+
+	// The day before the range supplies the first day's previous closing
+	levels, err := h.DB.GetDailyClosingLevels(site.ID, startDate.AddDate(0, 0, -1).Format("2006-01-02"), endDateString)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get daily closing levels for site %s: %v", site.Name, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Failed to get daily closing readings",
+		})
+		return
+	}
+
+	levelsByDate := make(map[string]*models.DailyClosingLevel, len(levels))
+	for _, level := range levels {
+		levelsByDate[level.Date] = level
+	}
+
+	response := models.DailyDeltasResponse{
+		SiteID:   site.ID,
+		SiteName: site.Name,
+		DateRange: models.DateRange{
+			Start:   startDateString,
+			End:     endDateString,
+			IsRange: startDateString != endDateString,
+		},
+		Threshold: threshold,
+		Days:      []models.DailyDelta{},
+	}
+
+	for day := startDate; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		entry := models.DailyDelta{Date: day.Format("2006-01-02")}
+
+		level, ok := levelsByDate[entry.Date]
+		if ok {
+			fuelLevel := level.FuelLevel
+			capturedAt := level.CapturedAt
+			entry.ClosingFuelLevel = &fuelLevel
+			entry.CapturedAt = &capturedAt
+			entry.GeneratorHours = level.GeneratorHours
+
+			if previous, ok := levelsByDate[day.AddDate(0, 0, -1).Format("2006-01-02")]; ok {
+				delta := roundTo(level.FuelLevel-previous.FuelLevel, 2)
+				entry.Delta = &delta
+				entry.Suspicious = delta < -threshold && level.GeneratorHours != nil && *level.GeneratorHours == 0
+			}
+		}
+
+		if entry.Suspicious {
+			response.SuspiciousDays++
+		}
+		response.Days = append(response.Days, entry)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
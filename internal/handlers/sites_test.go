@@ -0,0 +1,571 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/alerts"
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+	"fuel-monitor-api/internal/watchdog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// answerSites answers the site listing queries with sites
+func answerSites(sites ...*models.Site) fakeQuery {
+	return func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "FROM sites") {
+			return siteRows(sites...)
+		}
+		return nil, nil, fmt.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestGetSitesScopeEmpty(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	site := &models.Site{ID: 3, Name: "Site A", DeviceID: "simbisa-a", IsActive: true, CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name   string
+		target string
+		sites  []*models.Site
+		want   string
+	}{
+		{"empty scope in envelope", "/sites?envelope=true", nil, `{"sites":[],"scopeEmpty":true}`},
+		{"sites in envelope", "/sites?envelope=true", []*models.Site{site}, `"scopeEmpty":false`},
+		{"plain list by default", "/sites", []*models.Site{site}, `[{"id":3,`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = answerSites(tt.sites...)
+			cfg := &config.Config{}
+			handler := NewSitesHandler(db, cfg, settings.NewStore(db, cfg))
+
+			router := gin.New()
+			router.GET("/sites", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 2, Username: "manager", Role: "manager"})
+			}, handler.GetSites)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.want) {
+				t.Errorf("body = %s, want it to contain %s", recorder.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDashboardScopeEmpty(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, fake := newFakeDB(t, 2)
+	fake.answer = answerSites()
+	cfg := &config.Config{}
+	handler := NewDashboardHandler(db, cfg, settings.NewStore(db, cfg), alerts.NewFuelEscalation(time.Hour), watchdog.New())
+
+	router := gin.New()
+	router.GET("/dashboard", func(c *gin.Context) {
+		c.Set("user", models.UserResponse{ID: 2, Username: "manager", Role: "manager"})
+	}, handler.GetDashboard)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	if !strings.Contains(recorder.Body.String(), `"scopeEmpty":true`) {
+		t.Errorf("body = %s, want scopeEmpty true", recorder.Body)
+	}
+}
+
+func TestSetSiteMaintenance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	future := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	later := time.Now().Add(72 * time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		body       string
+		found      bool
+		wantStatus int
+		wantWindow bool
+	}{
+		{"enable with window", `{"enabled": true, "start": "` + future + `", "end": "` + later + `"}`, true, http.StatusOK, true},
+		{"disable clears window", `{"enabled": false, "start": "` + future + `", "end": "` + later + `"}`, true, http.StatusOK, false},
+		{"end before start", `{"enabled": true, "start": "` + later + `", "end": "` + future + `"}`, true, http.StatusBadRequest, false},
+		{"end in the past", `{"enabled": true, "end": "` + past + `"}`, true, http.StatusBadRequest, false},
+		{"missing enabled", `{}`, true, http.StatusBadRequest, false},
+		{"unknown site", `{"enabled": true}`, false, http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			var gotWindow bool
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "UPDATE sites") {
+					return nil, nil, fmt.Errorf("unexpected query: %s", query)
+				}
+				gotWindow = args[2].Value != nil && args[3].Value != nil
+				if !tt.found {
+					return siteRows()
+				}
+				return siteRows(&models.Site{ID: 3, Name: "Site A", DeviceID: "simbisa-a", IsActive: true, MaintenanceMode: args[1].Value.(bool)})
+			}
+			cfg := &config.Config{}
+			handler := NewSitesHandler(db, cfg, settings.NewStore(db, cfg))
+
+			router := gin.New()
+			router.PUT("/sites/:id/maintenance", handler.SetSiteMaintenance)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/sites/3/maintenance", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantStatus == http.StatusBadRequest && len(fake.queries) != 0 {
+				t.Errorf("updated the site for an invalid request: %q", fake.queries)
+			}
+			if gotWindow != tt.wantWindow {
+				t.Errorf("stored a window = %t, want %t", gotWindow, tt.wantWindow)
+			}
+		})
+	}
+}
+
+func TestSearchSites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	site := &models.Site{ID: 3, Name: "Borrowdale", DeviceID: "simbisa-a", IsActive: true}
+
+	tests := []struct {
+		name        string
+		target      string
+		wantStatus  int
+		wantPattern string
+		wantLimit   int64
+		wantBody    string
+	}{
+		{"default limit", "/sites/search?q=borrow", http.StatusOK, "borrow", 20, `[{"id":3,`},
+		{"wildcards matched literally", "/sites/search?q=" + url.QueryEscape(`50%_off\`) + "&limit=5", http.StatusOK, `50\%\_off\\`, 5, `[{"id":3,`},
+		{"missing q", "/sites/search?q=+", http.StatusBadRequest, "", 0, ""},
+		{"q too long", "/sites/search?q=" + strings.Repeat("a", 101), http.StatusBadRequest, "", 0, ""},
+		{"limit too large", "/sites/search?q=a&limit=101", http.StatusBadRequest, "", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			var gotPattern interface{}
+			var gotLimit interface{}
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				gotPattern, gotLimit = args[2].Value, args[4].Value
+				return siteRows(site)
+			}
+			cfg := &config.Config{}
+			handler := NewSitesHandler(db, cfg, settings.NewStore(db, cfg))
+
+			router := gin.New()
+			router.GET("/sites/search", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 2, Username: "manager", Role: "manager"})
+			}, handler.SearchSites)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(fake.queries) != 0 {
+					t.Errorf("searched for an invalid request: %q", fake.queries)
+				}
+				return
+			}
+			if gotPattern != tt.wantPattern || gotLimit != tt.wantLimit {
+				t.Errorf("searched %v with limit %v, want %v with limit %d", gotPattern, gotLimit, tt.wantPattern, tt.wantLimit)
+			}
+			if !strings.HasPrefix(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to start with %s", recorder.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSetSiteAlerts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		body       string
+		found      bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"disable", `{"enabled": false}`, true, http.StatusOK, `"alertsEnabled":false`},
+		{"enable", `{"enabled": true}`, true, http.StatusOK, `"alertsEnabled":true`},
+		{"missing enabled", `{}`, true, http.StatusBadRequest, ""},
+		{"unknown site", `{"enabled": true}`, false, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "SET alerts_enabled") {
+					return nil, nil, fmt.Errorf("unexpected query: %s", query)
+				}
+				if !tt.found {
+					return siteRows()
+				}
+				return siteRows(&models.Site{ID: 3, Name: "Site A", DeviceID: "simbisa-a", IsActive: true, AlertsEnabled: args[1].Value.(bool)})
+			}
+			cfg := &config.Config{}
+			handler := NewSitesHandler(db, cfg, settings.NewStore(db, cfg))
+
+			router := gin.New()
+			router.PUT("/sites/:id/alerts", handler.SetSiteAlerts)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/sites/3/alerts", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", recorder.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCloneAssignments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	users := map[int64]*models.User{
+		1: {ID: 1, Username: "admin", Role: "admin", IsActive: true, CreatedAt: created},
+		2: {ID: 2, Username: "ann", Role: "manager", IsActive: true, CreatedAt: created},
+		3: {ID: 3, Username: "ben", Role: "supervisor", IsActive: true, CreatedAt: created},
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantDeletes int
+		wantInserts int
+		wantBody    string
+	}{
+		{"add by default", `{"fromUserId": 2, "toUserId": 3}`, http.StatusOK, 0, 2,
+			`{"fromUserId":2,"toUserId":3,"mode":"add","sourceSites":2,"addedSites":2,"assignedSites":2}`},
+		{"replace", `{"fromUserId": 2, "toUserId": 3, "mode": "replace"}`, http.StatusOK, 1, 2, `"mode":"replace"`},
+		{"unknown mode", `{"fromUserId": 2, "toUserId": 3, "mode": "merge"}`, http.StatusBadRequest, 0, 0, "mode must be"},
+		{"same user", `{"fromUserId": 2, "toUserId": 2}`, http.StatusBadRequest, 0, 0, "must differ"},
+		{"admin target", `{"fromUserId": 2, "toUserId": 1}`, http.StatusBadRequest, 0, 0, "is an admin"},
+		{"unknown user", `{"fromUserId": 9, "toUserId": 3}`, http.StatusNotFound, 0, 0, "User 9 not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				switch {
+				case strings.Contains(query, "FROM users"):
+					if user, ok := users[args[0].Value.(int64)]; ok {
+						return userRows(user)
+					}
+					return userRows()
+				case strings.Contains(query, "FROM user_site_assignments"):
+					return []string{"site_id", "name", "location"}, [][]driver.Value{
+						{int64(10), "Site A", "Harare"},
+						{int64(11), "Site B", "Bulawayo"},
+					}, nil
+				}
+				return nil, nil, fmt.Errorf("unexpected query: %s", query)
+			}
+			cfg := &config.Config{}
+			handler := NewSitesHandler(db, cfg, settings.NewStore(db, cfg))
+
+			router := gin.New()
+			router.POST("/assignments/clone", handler.CloneAssignments)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/assignments/clone", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", recorder.Body, tt.wantBody)
+			}
+
+			var deletes, inserts int
+			for _, exec := range fake.execs {
+				switch {
+				case strings.HasPrefix(exec, "DELETE FROM user_site_assignments"):
+					deletes++
+				case strings.HasPrefix(exec, "INSERT INTO user_site_assignments"):
+					inserts++
+				}
+			}
+			if deletes != tt.wantDeletes || inserts != tt.wantInserts {
+				t.Errorf("deleted %d times and inserted %d sites, want %d and %d", deletes, inserts, tt.wantDeletes, tt.wantInserts)
+			}
+		})
+	}
+}
+
+func TestParseReadingsCursor(t *testing.T) {
+	at := time.Date(2024, 3, 1, 10, 15, 0, 500, time.UTC)
+
+	tests := []struct {
+		name       string
+		cursor     string
+		wantTime   time.Time
+		wantSensor string
+		wantErr    bool
+	}{
+		{"next cursor", readingsCursor(&models.RawSensorReading{SensorName: "fuel_sensor_level", Time: at}), at, "fuel_sensor_level", false},
+		{"bare timestamp", "2024-03-01T10:15:00Z", at.Truncate(time.Second), "", false},
+		{"offset timestamp", "2024-03-01T12:15:00+02:00", at.Truncate(time.Second), "", false},
+		{"invalid time", "yesterday,fuel_sensor_level", time.Time{}, "", true},
+		{"empty", "", time.Time{}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTime, gotSensor, err := parseReadingsCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !gotTime.Equal(tt.wantTime) {
+				t.Errorf("time = %v, want %v", gotTime, tt.wantTime)
+			}
+			if gotSensor != tt.wantSensor {
+				t.Errorf("sensor = %q, want %q", gotSensor, tt.wantSensor)
+			}
+		})
+	}
+}
+
+func TestBulkSetLowFuelThresholdValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"missing threshold", `{"siteIds": [1]}`, http.StatusBadRequest},
+		{"above 100", `{"siteIds": [1], "lowFuelThreshold": 150}`, http.StatusBadRequest},
+		{"negative", `{"siteIds": [1], "lowFuelThreshold": -1}`, http.StatusBadRequest},
+		{"not a number", `{"siteIds": [1], "lowFuelThreshold": "20"}`, http.StatusBadRequest},
+		{"no sites", `{"siteIds": [], "lowFuelThreshold": null}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Invalid requests are rejected before the database is used
+			router := gin.New()
+			router.PUT("/thresholds", (&SitesHandler{}).BulkSetLowFuelThreshold)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/thresholds", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestBulkLowFuelThresholdRequest(t *testing.T) {
+	twenty := 20.0
+
+	tests := []struct {
+		name    string
+		body    string
+		wantSet bool
+		want    *float64
+	}{
+		{"value", `{"lowFuelThreshold": 20}`, true, &twenty},
+		{"null clears", `{"lowFuelThreshold": null}`, true, nil},
+		{"missing", `{}`, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req models.BulkLowFuelThresholdRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if req.LowFuelThreshold.Set != tt.wantSet {
+				t.Errorf("Set = %v, want %v", req.LowFuelThreshold.Set, tt.wantSet)
+			}
+			if !reflect.DeepEqual(req.LowFuelThreshold.Value, tt.want) {
+				t.Errorf("Value = %v, want %v", req.LowFuelThreshold.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchSite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		wantStatus int
+		wantSet    string
+		wantBody   string
+	}{
+		{"location only", "/sites/3", `{"location": "Borrowdale"}`, http.StatusOK, "SET location = $1",
+			`"name":"Site A","location":"Borrowdale","deviceId":"simbisa-a"`},
+		{"name and type", "/sites/3", `{"name": "Site B", "typeId": 2}`, http.StatusOK, "SET name = $1, type_id = $2", `"name":"Site B"`},
+		{"unknown site", "/sites/99", `{"location": "Borrowdale"}`, http.StatusNotFound, "SET location = $1", "Site not found"},
+		{"no fields", "/sites/3", `{}`, http.StatusBadRequest, "", "At least one of"},
+		{"empty name", "/sites/3", `{"name": ""}`, http.StatusBadRequest, "", ""},
+		{"invalid ID", "/sites/abc", `{"location": "Borrowdale"}`, http.StatusBadRequest, "", "Invalid site ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "UPDATE sites") {
+					return nil, nil, fmt.Errorf("unexpected query: %s", query)
+				}
+				// Site 3 is the only site; the site ID is the last argument
+				if args[len(args)-1].Value != int64(3) {
+					return siteRows()
+				}
+				site := &models.Site{ID: 3, Name: "Site A", Location: "Harare", DeviceID: "simbisa-a", IsActive: true, CreatedAt: created}
+				if strings.Contains(query, "name = $1") {
+					site.Name = args[0].Value.(string)
+				}
+				if strings.Contains(query, "location = $1") {
+					site.Location = args[0].Value.(string)
+				}
+				return siteRows(site)
+			}
+			cfg := &config.Config{}
+			handler := NewSitesHandler(db, cfg, settings.NewStore(db, cfg))
+
+			router := gin.New()
+			router.PATCH("/sites/:id", handler.PatchSite)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPatch, tt.target, strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", recorder.Body, tt.wantBody)
+			}
+
+			if tt.wantSet == "" {
+				if len(fake.queries) != 0 {
+					t.Errorf("queried the database for an invalid update: %q", fake.queries)
+				}
+				return
+			}
+			// Fields left out of the request are not written
+			if len(fake.queries) != 1 || !strings.Contains(fake.queries[0], tt.wantSet+"\n") {
+				t.Errorf("queries = %q, want one update with %q", fake.queries, tt.wantSet)
+			}
+		})
+	}
+}
+
+func TestAssignSitesToAdminRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	users := map[int64]*models.User{
+		1: {ID: 1, Username: "admin", Role: "admin", IsActive: true, CreatedAt: created},
+		2: {ID: 2, Username: "ann", Role: "manager", IsActive: true, CreatedAt: created},
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		body        string
+		wantStatus  int
+		wantBody    string
+		wantDeletes int
+		wantInserts int
+	}{
+		{"sites for an admin", "/assignments/user/1/sites", `{"siteIds": [10, 11]}`, http.StatusBadRequest, "Admins have access to all sites", 0, 0},
+		// Clearing leftover assignments from an admin is still allowed
+		{"clearing an admin", "/assignments/user/1/sites", `{"siteIds": []}`, http.StatusOK, "updated successfully", 1, 0},
+		{"sites for a manager", "/assignments/user/2/sites", `{"siteIds": [10, 11]}`, http.StatusOK, "updated successfully", 1, 1},
+		{"bulk including an admin", "/assignments/bulk", `{"userIds": [2, 1], "siteIds": [10]}`, http.StatusBadRequest, "User 1 is an admin; admins have access to all sites", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "FROM users") {
+					return nil, nil, fmt.Errorf("unexpected query: %s", query)
+				}
+				if user, ok := users[args[0].Value.(int64)]; ok {
+					return userRows(user)
+				}
+				return userRows()
+			}
+			cfg := &config.Config{}
+			handler := NewSitesHandler(db, cfg, settings.NewStore(db, cfg))
+
+			router := gin.New()
+			router.POST("/assignments/user/:userId/sites", handler.AssignSitesToUser)
+			router.POST("/assignments/bulk", handler.BulkAssignSites)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", recorder.Body, tt.wantBody)
+			}
+
+			var deletes, inserts int
+			for _, exec := range fake.execs {
+				switch {
+				case strings.HasPrefix(exec, "DELETE FROM user_site_assignments"):
+					deletes++
+				case strings.HasPrefix(exec, "INSERT INTO user_site_assignments"):
+					inserts++
+				}
+			}
+			if deletes != tt.wantDeletes || inserts != tt.wantInserts {
+				t.Errorf("ran %d deletes and %d inserts, want %d and %d", deletes, inserts, tt.wantDeletes, tt.wantInserts)
+			}
+		})
+	}
+}
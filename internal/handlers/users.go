@@ -1,10 +1,18 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"fuel-monitor-api/internal/config"
 	"fuel-monitor-api/internal/database"
 	"fuel-monitor-api/internal/middleware"
 	"fuel-monitor-api/internal/models"
@@ -14,17 +22,24 @@ import (
 )
 
 type UserHandler struct {
-	DB *database.DB
+	DB     *database.DB
+	Config *config.Config
 }
 
-func NewUserHandler(db *database.DB) *UserHandler {
+func NewUserHandler(db *database.DB, cfg *config.Config) *UserHandler {
 	return &UserHandler{
-		DB: db,
+		DB:     db,
+		Config: cfg,
 	}
 }
 
 // GetUsers retrieves all active users (admin only)
 func (h *UserHandler) GetUsers(c *gin.Context) {
+	page, ok := parsePagination(c, h.Config.Pagination)
+	if !ok {
+		return
+	}
+
 	users, err := h.DB.GetAllUsers()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -39,7 +54,124 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		userResponses[i] = user.ToResponse()
 	}
 
-	c.JSON(http.StatusOK, userResponses)
+	c.JSON(http.StatusOK, paginate(c, userResponses, page))
+}
+
+// ExportUsers streams users as a CSV file, with ?includeInactive=true adding deactivated users (admin only)
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	includeInactive := c.Query("includeInactive") == "true"
+
+	filename := fmt.Sprintf("users-%s.csv", time.Now().Format("2006-01-02"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"username", "email", "role", "full_name", "is_active", "last_login", "created_at"})
+
+	written := 0
+	err := h.DB.EachUser(includeInactive, func(user *models.User) error {
+		lastLogin := ""
+		if user.LastLogin != nil {
+			lastLogin = user.LastLogin.Format(time.RFC3339)
+		}
+
+		if err := writer.Write([]string{
+			user.Username,
+			user.Email,
+			user.Role,
+			user.FullName,
+			strconv.FormatBool(user.IsActive),
+			lastLogin,
+			user.CreatedAt.Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+
+		// Flush regularly so large exports stream instead of buffering
+		written++
+		if written%100 == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+		return writer.Error()
+	})
+
+	writer.Flush()
+	if err != nil {
+		// Headers are already sent, so the truncated file is all the client gets
+		middleware.Log(c).Errorf("User export failed after %d rows: %v", written, err)
+	}
+}
+
+// GetInactiveUsers retrieves active users who have not logged in for a number of days (admin only)
+func (h *UserHandler) GetInactiveUsers(c *gin.Context) {
+	page, ok := parsePagination(c, h.Config.Pagination)
+	if !ok {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "days must be a positive integer",
+		})
+		return
+	}
+
+	now := time.Now()
+	users, err := h.DB.GetInactiveUsers(now.AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	inactiveUsers := make([]models.InactiveUserResponse, len(users))
+	for i, user := range users {
+		inactiveUsers[i] = models.InactiveUserResponse{
+			UserResponse:  user.ToResponse(),
+			NeverLoggedIn: user.LastLogin == nil,
+		}
+
+		if user.LastLogin != nil {
+			daysSince := int(now.Sub(*user.LastLogin).Hours() / 24)
+			inactiveUsers[i].DaysSinceLastLogin = &daysSince
+		}
+	}
+
+	c.JSON(http.StatusOK, paginate(c, inactiveUsers, page))
+}
+
+// GetRoles lists the roles users hold. Roles change rarely, so clients may
+// cache the list for METADATA_CACHE_MAX_AGE.
+func (h *UserHandler) GetRoles(c *gin.Context) {
+	roles, err := h.DB.GetRoles()
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get roles: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	middleware.SetPrivateCache(c, h.Config.Server.MetadataCacheMaxAge)
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetAssignmentCounts lists non-admin users with their assigned site counts,
+// including users with none (admin only)
+func (h *UserHandler) GetAssignmentCounts(c *gin.Context) {
+	counts, err := h.DB.GetAssignmentCounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, counts)
 }
 
 // GetUserByID retrieves a user by ID (admin only)
@@ -71,13 +203,153 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 	c.JSON(http.StatusOK, user.ToResponse())
 }
 
+// GetUserLogins retrieves a page of a user's login history, newest first (admin only)
+func (h *UserHandler) GetUserLogins(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid user ID",
+		})
+		return
+	}
+
+	h.respondLoginHistory(c, userID)
+}
+
+// GetMyLogins retrieves a page of the authenticated user's own login history
+func (h *UserHandler) GetMyLogins(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	h.respondLoginHistory(c, user.ID)
+}
+
+// respondLoginHistory writes one page of login events using ?before=<cursor>&limit=
+func (h *UserHandler) respondLoginHistory(c *gin.Context, userID int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "limit must be between 1 and 500",
+		})
+		return
+	}
+
+	var beforeID int64
+	if before := c.Query("before"); before != "" {
+		beforeID, err = strconv.ParseInt(before, 10, 64)
+		if err != nil || beforeID < 1 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "Invalid cursor",
+			})
+			return
+		}
+	}
+
+	// Fetch one extra row to know whether another page exists
+	events, err := h.DB.GetLoginEvents(userID, beforeID, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	response := models.LoginHistoryResponse{
+		UserID: userID,
+		Events: events,
+	}
+
+	if len(events) > limit {
+		response.Events = events[:limit]
+		response.HasMore = true
+		response.NextCursor = strconv.FormatInt(response.Events[limit-1].ID, 10)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// maxPreferencesSize caps the stored preferences blob
+const maxPreferencesSize = 16 * 1024
+
+// GetMyPreferences returns the authenticated user's preferences blob, or {} when none is stored
+func (h *UserHandler) GetMyPreferences(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	prefs, err := h.DB.GetUserPreferences(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	if prefs == nil {
+		prefs = &models.UserPreferences{Preferences: json.RawMessage("{}")}
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdateMyPreferences replaces the authenticated user's preferences with the request body,
+// which must be well-formed JSON no larger than maxPreferencesSize
+func (h *UserHandler) UpdateMyPreferences(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPreferencesSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Failed to read request body",
+		})
+		return
+	}
+	if len(body) > maxPreferencesSize {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Message: fmt.Sprintf("Preferences cannot exceed %d bytes", maxPreferencesSize),
+		})
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || !json.Valid(body) || bytes.Equal(body, []byte("null")) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Preferences must be well-formed JSON",
+		})
+		return
+	}
+
+	prefs, err := h.DB.SaveUserPreferences(user.ID, json.RawMessage(body))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
 // CreateUser creates a new user (admin only)
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Message: "Invalid data provided",
-		})
+		respondBindError(c, err, "Invalid data provided")
 		return
 	}
 
@@ -103,8 +375,8 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	// Check if username already exists
-	existingUser, err := h.DB.GetUserByUsername(req.Username)
+	// Check if username already exists, ignoring case and including inactive users
+	usernameTaken, err := h.DB.UsernameExists(req.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Message: "Database error",
@@ -112,7 +384,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	if existingUser != nil {
+	if usernameTaken {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Message: "Username already exists",
 		})
@@ -154,6 +426,13 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		IsActive: req.IsActive,
 	})
 
+	if errors.Is(err, database.ErrUsernameTaken) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Username already exists",
+		})
+		return
+	}
+
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Message: "Failed to create user",
@@ -177,9 +456,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Message: "Invalid data provided",
-		})
+		respondBindError(c, err, "Invalid data provided")
 		return
 	}
 
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		roles        []string
+		err          error
+		wantStatus   int
+		wantBody     string
+		wantCacheHdr string
+	}{
+		{"roles in use", []string{"admin", "manager", "user"}, nil, http.StatusOK, `{"roles":["admin","manager","user"]}`, "private, max-age=3600"},
+		{"database error", nil, fmt.Errorf("connection reset"), http.StatusInternalServerError, `{"message":"Internal server error"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "SELECT role FROM users") {
+					return nil, nil, fmt.Errorf("unexpected query: %s", query)
+				}
+				if tt.err != nil {
+					return nil, nil, tt.err
+				}
+				var values [][]driver.Value
+				for _, role := range tt.roles {
+					values = append(values, []driver.Value{role})
+				}
+				return []string{"role"}, values, nil
+			}
+			handler := NewUserHandler(db, &config.Config{Server: config.ServerConfig{MetadataCacheMaxAge: time.Hour}})
+
+			router := gin.New()
+			router.GET("/roles", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 2, Username: "ann", Role: "manager"})
+			}, handler.GetRoles)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/roles", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if got := recorder.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+			// Errors must never be cached
+			if got := recorder.Header().Get("Cache-Control"); got != tt.wantCacheHdr {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheHdr)
+			}
+		})
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	created := time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)
+	lastLogin := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	var includeInactive interface{}
+	db, fake := newFakeDB(t, 1)
+	fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+		if !strings.Contains(query, "FROM users") {
+			return nil, nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		if strings.Contains(query, "password") {
+			return nil, nil, fmt.Errorf("export selects password hashes: %s", query)
+		}
+		includeInactive = args[0].Value
+		return []string{"id", "username", "email", "role", "full_name", "is_active", "last_login", "created_at"}, [][]driver.Value{
+			{int64(1), "admin", "admin@example.com", "admin", "Admin, Head Office", true, lastLogin, created},
+			{int64(2), "ann", "ann@example.com", "manager", "Ann", false, nil, created},
+		}, nil
+	}
+	handler := NewUserHandler(db, &config.Config{})
+
+	router := gin.New()
+	router.GET("/users/export", handler.ExportUsers)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/export?includeInactive=true", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/csv", got)
+	}
+	if includeInactive != true {
+		t.Errorf("includeInactive = %v, want true", includeInactive)
+	}
+
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	want := [][]string{
+		{"username", "email", "role", "full_name", "is_active", "last_login", "created_at"},
+		// The comma in the full name is quoted, not a column break
+		{"admin", "admin@example.com", "admin", "Admin, Head Office", "true", "2024-03-01T09:30:00Z", "2024-01-02T08:00:00Z"},
+		{"ann", "ann@example.com", "manager", "Ann", "false", "", "2024-01-02T08:00:00Z"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV = %q, want %q", records, want)
+	}
+}
+
+func TestUserListsSerializeEmptyAsArray(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Pagination: config.PaginationConfig{DefaultPageSize: 20, MaxPageSize: 100}}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	oneUser := []*models.User{{ID: 1, Username: "admin", Role: "admin", IsActive: true, CreatedAt: created}}
+
+	tests := []struct {
+		name   string
+		target string
+		users  []*models.User
+	}{
+		{"no users", "/users", nil},
+		{"no users paginated", "/users?page=1", nil},
+		{"page past the end", "/users?page=3", oneUser},
+		{"no inactive users", "/users/inactive", nil},
+		{"no inactive users paginated", "/users/inactive?page=1", nil},
+		{"inactive page past the end", "/users/inactive?page=3", oneUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, 1)
+			fake.answer = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				if !strings.Contains(query, "FROM users") {
+					return nil, nil, fmt.Errorf("unexpected query: %s", query)
+				}
+				return userRows(tt.users...)
+			}
+			handler := NewUserHandler(db, cfg)
+
+			router := gin.New()
+			router.GET("/users", handler.GetUsers)
+			router.GET("/users/inactive", handler.GetInactiveUsers)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+			}
+			if got := recorder.Body.String(); got != "[]" {
+				t.Errorf("body = %s, want []", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report validation failures by JSON field name rather than Go field name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// respondBindError writes a 400 response listing each field that failed JSON binding
+func respondBindError(c *gin.Context, err error, message string) {
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Message: message,
+		Errors:  bindFieldErrors(err),
+	})
+}
+
+// bindFieldErrors translates a binding error into per-field reasons
+func bindFieldErrors(err error) []models.FieldError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fieldErrors := make([]models.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fieldErrors = append(fieldErrors, models.FieldError{
+				Field:  fieldPath(fe.Namespace()),
+				Reason: validationReason(fe),
+			})
+		}
+		return fieldErrors
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []models.FieldError{{
+			Field:  typeErr.Field,
+			Reason: fmt.Sprintf("must be %s", typeErr.Type.String()),
+		}}
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return []models.FieldError{{
+			Field:  "body",
+			Reason: "malformed JSON",
+		}}
+	}
+
+	return []models.FieldError{{
+		Field:  "body",
+		Reason: err.Error(),
+	}}
+}
+
+// fieldPath drops the struct name from a validator namespace ("LoginRequest.password" -> "password")
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// validationReason describes a failed validation tag in plain words
+func validationReason(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "required"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	default:
+		if fe.Param() != "" {
+			return fmt.Sprintf("failed %s=%s", fe.Tag(), fe.Param())
+		}
+		return fmt.Sprintf("failed %s", fe.Tag())
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindTarget exercises the validation tags the request models use
+type bindTarget struct {
+	Username string `json:"username" binding:"required"`
+	Age      int    `json:"age" binding:"min=18,max=99"`
+	Role     string `json:"role" binding:"omitempty,oneof=admin user"`
+	Email    string `json:"email" binding:"omitempty,email"`
+}
+
+func TestBindFieldErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		body string
+		want []models.FieldError
+	}{
+		{"missing fields", `{}`, []models.FieldError{
+			{Field: "username", Reason: "required"},
+			{Field: "age", Reason: "must be at least 18"},
+		}},
+		{"too large", `{"username": "a", "age": 120}`, []models.FieldError{{Field: "age", Reason: "must be at most 99"}}},
+		{"not one of", `{"username": "a", "age": 20, "role": "root"}`, []models.FieldError{{Field: "role", Reason: "must be one of: admin user"}}},
+		{"bad email", `{"username": "a", "age": 20, "email": "nope"}`, []models.FieldError{{Field: "email", Reason: "must be a valid email address"}}},
+		{"wrong type", `{"username": "a", "age": "old"}`, []models.FieldError{{Field: "age", Reason: "must be int"}}},
+		{"empty body", ``, []models.FieldError{{Field: "body", Reason: "malformed JSON"}}},
+		{"syntax error", `{"username": a}`, []models.FieldError{{Field: "body", Reason: "malformed JSON"}}},
+		{"truncated", `{"username": "a"`, []models.FieldError{{Field: "body", Reason: "malformed JSON"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := testContext("/bind")
+			c.Request.Body = io.NopCloser(strings.NewReader(tt.body))
+
+			var target bindTarget
+			err := c.ShouldBindJSON(&target)
+			if err == nil {
+				t.Fatal("binding succeeded, want an error")
+			}
+			if got := bindFieldErrors(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bindFieldErrors = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
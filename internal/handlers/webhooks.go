@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/middleware"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	DB     *database.DB
+	Config *config.Config
+	Sender *webhooks.Sender
+}
+
+func NewWebhookHandler(db *database.DB, cfg *config.Config, sender *webhooks.Sender) *WebhookHandler {
+	return &WebhookHandler{
+		DB:     db,
+		Config: cfg,
+		Sender: sender,
+	}
+}
+
+// GetWebhooks lists registered webhooks without their secrets (admin only)
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	hooks, err := h.DB.GetWebhooks(false)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get webhooks: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// CreateWebhook registers a webhook for some or all alert types. The signing
+// secret is returned only in this response (admin only).
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	user, exists := middleware.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err, "Invalid request format")
+		return
+	}
+
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "url must be an absolute http or https URL",
+		})
+		return
+	}
+
+	alertTypes := []string{}
+	for _, alertType := range req.AlertTypes {
+		if !isAlertType(alertType) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Message: "Unknown alert type: " + alertType,
+			})
+			return
+		}
+		alertTypes = append(alertTypes, alertType)
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret, err = generateSecret()
+		if err != nil {
+			middleware.Log(c).Errorf("Failed to generate webhook secret: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Message: "Internal server error",
+			})
+			return
+		}
+	}
+
+	webhook, err := h.DB.CreateWebhook(req.URL, secret, alertTypes, user.ID)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to create webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	middleware.Log(c).Infof("Webhook %d registered by %s for %v", webhook.ID, user.Username, alertTypes)
+
+	c.JSON(http.StatusCreated, models.CreateWebhookResponse{
+		Webhook: webhook,
+		Secret:  webhook.Secret,
+	})
+}
+
+// DeleteWebhook removes a webhook (admin only)
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid webhook ID",
+		})
+		return
+	}
+
+	deleted, err := h.DB.DeleteWebhook(id)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to delete webhook %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Webhook not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// TestWebhook sends a signed "test" payload to a webhook and reports the outcome (admin only)
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Message: "Invalid webhook ID",
+		})
+		return
+	}
+
+	webhook, err := h.DB.GetWebhookByID(id)
+	if err != nil {
+		middleware.Log(c).Errorf("Failed to get webhook %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Message: "Internal server error",
+		})
+		return
+	}
+	if webhook == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Message: "Webhook not found",
+		})
+		return
+	}
+
+	alertType := models.AlertLowFuel
+	if len(webhook.AlertTypes) > 0 {
+		alertType = webhook.AlertTypes[0]
+	}
+
+	payload := models.WebhookPayload{
+		Event:     "test",
+		AlertType: alertType,
+		Priority:  models.PriorityNormal,
+		SiteName:  "Test site",
+		DeviceID:  "test-device",
+		Value:     "test",
+		Timestamp: time.Now(),
+	}
+
+	attempts, statusCode, err := h.Sender.Send(c.Request.Context(), webhook, payload)
+	response := models.WebhookDeliveryResponse{
+		Delivered:  err == nil,
+		Attempts:   attempts,
+		StatusCode: statusCode,
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// isAlertType reports whether alertType is one webhooks can subscribe to
+func isAlertType(alertType string) bool {
+	for _, known := range models.AlertTypes {
+		if known == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSecret returns a random 32-byte hex signing secret
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
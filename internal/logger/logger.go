@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a logging severity. Messages below the configured level are dropped.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int32(l))
+}
+
+var current atomic.Int32
+
+func init() {
+	current.Store(int32(LevelInfo))
+}
+
+// ParseLevel parses "debug", "info", "warn" (or "warning") and "error" in any case
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// SetLevel sets the minimum level that is logged
+func SetLevel(level Level) {
+	current.Store(int32(level))
+}
+
+// Enabled reports whether messages at level are logged
+func Enabled(level Level) bool {
+	return int32(level) >= current.Load()
+}
+
+// Debugf logs step-by-step detail that is only useful when troubleshooting
+func Debugf(format string, args ...interface{}) {
+	output(LevelDebug, format, args...)
+}
+
+// Infof logs normal operational events
+func Infof(format string, args ...interface{}) {
+	output(LevelInfo, format, args...)
+}
+
+// Warnf logs recoverable problems, such as a lookup that fell back to a default
+func Warnf(format string, args ...interface{}) {
+	output(LevelWarn, format, args...)
+}
+
+// Errorf logs failures that affected a request or background job
+func Errorf(format string, args ...interface{}) {
+	output(LevelError, format, args...)
+}
+
+// Entry logs like the package functions, tagging every message with the
+// request it was written for
+type Entry struct {
+	requestID string
+}
+
+// ForRequest returns an Entry tagging messages with requestID; an empty ID
+// leaves them untagged
+func ForRequest(requestID string) Entry {
+	return Entry{requestID: requestID}
+}
+
+// Debugf is Debugf for the entry's request
+func (e Entry) Debugf(format string, args ...interface{}) {
+	e.output(LevelDebug, format, args...)
+}
+
+// Infof is Infof for the entry's request
+func (e Entry) Infof(format string, args ...interface{}) {
+	e.output(LevelInfo, format, args...)
+}
+
+// Warnf is Warnf for the entry's request
+func (e Entry) Warnf(format string, args ...interface{}) {
+	e.output(LevelWarn, format, args...)
+}
+
+// Errorf is Errorf for the entry's request
+func (e Entry) Errorf(format string, args ...interface{}) {
+	e.output(LevelError, format, args...)
+}
+
+func (e Entry) output(level Level, format string, args ...interface{}) {
+	if !Enabled(level) {
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	if e.requestID != "" {
+		message = "[" + e.requestID + "] " + message
+	}
+	// Calldepth 3 reports the caller of Debugf/Infof/... when file flags are set
+	log.Output(3, level.String()+" "+message)
+}
+
+func output(level Level, format string, args ...interface{}) {
+	if !Enabled(level) {
+		return
+	}
+	// Calldepth 3 reports the caller of Debugf/Infof/... when file flags are set
+	log.Output(3, level.String()+" "+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+// captureLog sends the standard logger's output to a buffer for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	flags, out := log.Flags(), log.Writer()
+	log.SetFlags(0)
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.SetFlags(flags)
+		log.SetOutput(out)
+	})
+	return &buf
+}
+
+func TestEntry(t *testing.T) {
+	defer SetLevel(LevelInfo)
+
+	tests := []struct {
+		name      string
+		requestID string
+		level     Level
+		log       func(Entry)
+		want      string
+	}{
+		{"tagged", "abc-123", LevelInfo, func(e Entry) { e.Infof("loaded %d sites", 3) }, "INFO [abc-123] loaded 3 sites\n"},
+		{"untagged", "", LevelInfo, func(e Entry) { e.Warnf("no id") }, "WARN no id\n"},
+		{"below level", "abc-123", LevelWarn, func(e Entry) { e.Debugf("dropped") }, ""},
+		{"error", "abc-123", LevelError, func(e Entry) { e.Errorf("failed: %v", "boom") }, "ERROR [abc-123] failed: boom\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLog(t)
+			SetLevel(tt.level)
+			tt.log(ForRequest(tt.requestID))
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryReportsCaller(t *testing.T) {
+	buf := captureLog(t)
+	log.SetFlags(log.Lshortfile)
+
+	ForRequest("abc").Infof("here")
+	if got := buf.String(); !bytes.HasPrefix([]byte(got), []byte("logger_test.go:")) {
+		t.Errorf("output = %q, want the caller's file", got)
+	}
+}
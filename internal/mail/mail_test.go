@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+)
+
+func TestSendTimesOut(t *testing.T) {
+	// A server that accepts connections but never greets the client
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	sender := NewSender(config.SMTPConfig{Host: host, Port: portNumber, From: "fuel@example.com", Timeout: 100 * time.Millisecond})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sender.Send([]string{"ops@example.com"}, "Report", "body")
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Send succeeded against a silent server")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send did not give up after its timeout")
+	}
+}
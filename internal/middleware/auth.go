@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -21,6 +23,51 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+var errInvalidClaims = errors.New("invalid token claims")
+
+// ParseToken parses and validates a signed JWT and returns its claims
+func ParseToken(tokenString, jwtSecret string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		// Verify signing method
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, jwt.ErrTokenUnverifiable
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return nil, errInvalidClaims
+	}
+
+	return claims, nil
+}
+
+// TokenInvalidReason maps a ParseToken error to a short machine-readable reason
+func TokenInvalidReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return "not_yet_valid"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return "invalid_signature"
+	case errors.Is(err, errInvalidClaims):
+		return "invalid_claims"
+	default:
+		return "invalid"
+	}
+}
+
 // AuthRequired middleware validates JWT token
 func AuthRequired(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -44,27 +91,14 @@ func AuthRequired(jwtSecret string) gin.HandlerFunc {
 		}
 
 		// Parse and validate token
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			// Verify signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		claims, err := ParseToken(tokenString, jwtSecret)
+		if err != nil {
+			message := "Invalid or expired token"
+			if errors.Is(err, errInvalidClaims) {
+				message = "Invalid token claims"
 			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
 			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Message: "Invalid or expired token",
-			})
-			c.Abort()
-			return
-		}
-
-		// Extract claims
-		claims, ok := token.Claims.(*Claims)
-		if !ok {
-			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Message: "Invalid token claims",
+				Message: message,
 			})
 			c.Abort()
 			return
@@ -84,6 +118,63 @@ func AuthRequired(jwtSecret string) gin.HandlerFunc {
 	}
 }
 
+// RequireAPIKeyOrAdmin allows requests carrying the configured X-API-Key, and
+// otherwise requires an admin bearer token whose user is still an active admin
+// according to recheck. An empty apiKey disables API key access.
+func RequireAPIKeyOrAdmin(apiKey, jwtSecret string, recheck *UserRecheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		providedKey := c.GetHeader("X-API-Key")
+		if apiKey != "" && providedKey != "" && subtle.ConstantTimeCompare([]byte(providedKey), []byte(apiKey)) == 1 {
+			c.Next()
+			return
+		}
+
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" || tokenString == c.GetHeader("Authorization") {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Message: "API key or admin access token required",
+			})
+			c.Abort()
+			return
+		}
+
+		claims, err := ParseToken(tokenString, jwtSecret)
+		if err != nil {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Message: "Invalid or expired token",
+			})
+			c.Abort()
+			return
+		}
+
+		if claims.Role != "admin" {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Message: "Insufficient permissions",
+			})
+			c.Abort()
+			return
+		}
+
+		user, err := recheck.CurrentUser(claims.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Message: "Internal server error",
+			})
+			c.Abort()
+			return
+		}
+		if user == nil || user.Role != "admin" {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Message: "Insufficient permissions",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // RequireRole middleware checks if user has required role
 func RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
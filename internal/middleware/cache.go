@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetPrivateCache allows only the caller's own client to cache the response for maxAge.
+// Use it for user-scoped responses; shared proxies must not store them.
+func SetPrivateCache(c *gin.Context, maxAge time.Duration) {
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+}
+
+// SetPublicCache lets clients and shared proxies cache the response for maxAge.
+// Use it only for responses that are the same for every caller.
+func SetPublicCache(c *gin.Context, maxAge time.Duration) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+}
+
+// SetRevalidate lets clients keep the response but forces revalidation (e.g. via ETag) on every use
+func SetRevalidate(c *gin.Context) {
+	c.Header("Cache-Control", "private, no-cache")
+}
+
+// SetNoStore forbids any caching of the response
+func SetNoStore(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+}
+
+// NoStore is a middleware that marks every response in the group as non-cacheable
+func NoStore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		SetNoStore(c)
+		c.Next()
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MethodNotAllowed answers requests whose path exists under other methods with
+// 405 and an Allow header listing those methods. Register it with router.NoMethod
+// after setting router.HandleMethodNotAllowed.
+func MethodNotAllowed(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		methods := allowedMethods(router.Routes(), c.Request.URL.Path)
+		if len(methods) > 0 {
+			c.Header("Allow", strings.Join(methods, ", "))
+		}
+		c.JSON(http.StatusMethodNotAllowed, models.ErrorResponse{
+			Message: "Method " + c.Request.Method + " not allowed",
+		})
+	}
+}
+
+// allowedMethods returns the sorted methods of the routes matching path. Only
+// the most specific matching routes count: a :param route does not make its
+// method allowed on a path that a static route, under any method, names.
+func allowedMethods(routes gin.RoutesInfo, path string) []string {
+	var matching []string
+	for _, route := range routes {
+		if routeMatches(route.Path, path) {
+			matching = append(matching, route.Path)
+		}
+	}
+
+	seen := make(map[string]bool)
+	methods := []string{}
+	for _, route := range routes {
+		if seen[route.Method] || !routeMatches(route.Path, path) || shadowed(route.Path, matching) {
+			continue
+		}
+		seen[route.Method] = true
+		methods = append(methods, route.Method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// shadowed reports whether any of the patterns is more specific than pattern
+func shadowed(pattern string, patterns []string) bool {
+	for _, other := range patterns {
+		if moreSpecific(other, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// moreSpecific reports whether pattern a is more specific than b: at the first
+// segment where they differ in kind, a has a static segment where b has a
+// :param or *wildcard, or a :param where b has a *wildcard
+func moreSpecific(a, b string) bool {
+	aParts := strings.Split(strings.Trim(a, "/"), "/")
+	bParts := strings.Split(strings.Trim(b, "/"), "/")
+
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aKind, bKind := segmentKind(aParts[i]), segmentKind(bParts[i]); aKind != bKind {
+			return aKind > bKind
+		}
+	}
+	return false
+}
+
+// segmentKind ranks route segments from least to most specific
+func segmentKind(part string) int {
+	switch {
+	case strings.HasPrefix(part, "*"):
+		return 0
+	case strings.HasPrefix(part, ":"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// routeMatches reports whether a request path matches a route pattern with
+// :param segments and a trailing *wildcard
+func routeMatches(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
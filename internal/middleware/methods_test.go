@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/api/sites", "/api/sites", true},
+		{"/api/sites", "/api/sites/", true},
+		{"/api/sites", "/api/users", false},
+		{"/api/sites/:id", "/api/sites/7", true},
+		{"/api/sites/:id", "/api/sites", false},
+		{"/api/sites/:id", "/api/sites/7/readings", false},
+		{"/api/sites/:id/readings", "/api/sites/7/readings", true},
+		{"/static/*filepath", "/static/css/app.css", true},
+		{"/static/*filepath", "/other/app.css", false},
+	}
+
+	for _, tt := range tests {
+		if got := routeMatches(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("routeMatches(%q, %q) = %t, want %t", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	routes := gin.RoutesInfo{
+		{Method: http.MethodGet, Path: "/api/sites"},
+		{Method: http.MethodPost, Path: "/api/sites"},
+		{Method: http.MethodGet, Path: "/api/sites/:id"},
+		{Method: http.MethodPut, Path: "/api/sites/:id"},
+		{Method: http.MethodDelete, Path: "/api/sites/:id"},
+		{Method: http.MethodPut, Path: "/api/sites/low-fuel-threshold"},
+		{Method: http.MethodGet, Path: "/files/*filepath"},
+		{Method: http.MethodPost, Path: "/files/:name"},
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/api/sites", []string{"GET", "POST"}},
+		{"/api/sites/7", []string{"DELETE", "GET", "PUT"}},
+		// The static route shadows /api/sites/:id under every method
+		{"/api/sites/low-fuel-threshold", []string{"PUT"}},
+		{"/files/report.csv", []string{"POST"}},
+		{"/files/a/b", []string{"GET"}},
+		{"/api/nope", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := allowedMethods(routes, tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("allowedMethods(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMethodNotAllowedHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.NoMethod(MethodNotAllowed(router))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/sites/:id", ok)
+	router.POST("/api/sites/bulk", ok)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/sites/bulk", nil))
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "POST" {
+		t.Errorf("got %d with Allow %q, want 405 with Allow %q", recorder.Code, recorder.Header().Get("Allow"), "POST")
+	}
+}
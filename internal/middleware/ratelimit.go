@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit is a token bucket holding up to PerMinute requests and refilled at
+// PerMinute requests per minute. A PerMinute of 0 or less means no limit.
+type RateLimit struct {
+	PerMinute int
+}
+
+// Disabled reports whether the limit lets every request through
+func (l RateLimit) Disabled() bool {
+	return l.PerMinute <= 0
+}
+
+// RateLimitStore keeps the token buckets. MemoryRateLimitStore keeps them per
+// process; a shared store (e.g. Redis) can implement it for several instances.
+type RateLimitStore interface {
+	// Take removes a token from key's bucket, reporting whether one was
+	// available and, when not, how long until the next one is
+	Take(key string, limit RateLimit, now time.Time) (bool, time.Duration)
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimitSweepInterval is how often MemoryRateLimitStore drops idle buckets
+const rateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore is an in-memory RateLimitStore. Buckets that have
+// refilled completely are dropped during a periodic sweep on Take, since a
+// new bucket starts full anyway.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	if limit.Disabled() {
+		return true, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		s.sweep(now)
+		s.lastSweep = now
+	}
+
+	capacity := float64(limit.PerMinute)
+	perSecond := capacity / 60
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updated: now}
+		s.buckets[key] = bucket
+	} else if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens = math.Min(capacity, bucket.tokens+elapsed.Seconds()*perSecond)
+		bucket.updated = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets untouched for longer than a full refill can take. It is
+// called with s.mu held.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) >= time.Minute {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RateLimitPerUser limits each authenticated user to limit requests per minute
+// on the routes it guards, or adminLimit for admins. Buckets are keyed by name
+// and user ID, so routes sharing a name share a budget. Rejected requests get
+// 429 with Retry-After in seconds. It must run after AuthRequired.
+func RateLimitPerUser(store RateLimitStore, name string, limit, adminLimit RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetUserFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Message: "Authentication required",
+			})
+			c.Abort()
+			return
+		}
+
+		userLimit := limit
+		if user.Role == "admin" {
+			userLimit = adminLimit
+		}
+
+		allowed, wait := store.Take(fmt.Sprintf("%s:%d", name, user.ID), userLimit, time.Now())
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+				Message: fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
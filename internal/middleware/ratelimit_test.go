@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	perMinute := RateLimit{PerMinute: 2}
+
+	type take struct {
+		after       time.Duration // since start
+		key         string
+		limit       RateLimit
+		wantAllowed bool
+		wantWait    time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{"disabled", []take{
+			{0, "a", RateLimit{}, true, 0},
+			{0, "a", RateLimit{}, true, 0},
+			{0, "a", RateLimit{PerMinute: -1}, true, 0},
+		}},
+		{"burst up to the limit", []take{
+			{0, "a", perMinute, true, 0},
+			{0, "a", perMinute, true, 0},
+			{0, "a", perMinute, false, 30 * time.Second},
+		}},
+		{"refills over time", []take{
+			{0, "a", perMinute, true, 0},
+			{0, "a", perMinute, true, 0},
+			{10 * time.Second, "a", perMinute, false, 20 * time.Second},
+			{30 * time.Second, "a", perMinute, true, 0},
+			{30 * time.Second, "a", perMinute, false, 30 * time.Second},
+		}},
+		{"refill is capped at the limit", []take{
+			{0, "a", perMinute, true, 0},
+			{time.Hour, "a", perMinute, true, 0},
+			{time.Hour, "a", perMinute, true, 0},
+			{time.Hour, "a", perMinute, false, 30 * time.Second},
+		}},
+		{"keys are independent", []take{
+			{0, "a", RateLimit{PerMinute: 1}, true, 0},
+			{0, "a", RateLimit{PerMinute: 1}, false, time.Minute},
+			{0, "b", RateLimit{PerMinute: 1}, true, 0},
+		}},
+		{"swept buckets start full", []take{
+			{0, "a", RateLimit{PerMinute: 1}, true, 0},
+			{2 * time.Minute, "b", RateLimit{PerMinute: 1}, true, 0},
+			{2 * time.Minute, "a", RateLimit{PerMinute: 1}, true, 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryRateLimitStore()
+			for i, take := range tt.takes {
+				allowed, wait := store.Take(take.key, take.limit, start.Add(take.after))
+				if allowed != take.wantAllowed || wait.Round(time.Millisecond) != take.wantWait {
+					t.Errorf("take %d: got %t, wait %v, want %t, wait %v", i, allowed, wait, take.wantAllowed, take.wantWait)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Users get one dashboard request a minute and admins two
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-User"); id != "" {
+			userID, _ := strconv.Atoi(id)
+			c.Set("user", models.UserResponse{ID: userID, Role: c.GetHeader("X-Role")})
+		}
+	})
+	limit := RateLimitPerUser(NewMemoryRateLimitStore(), "dashboard", RateLimit{PerMinute: 1}, RateLimit{PerMinute: 2})
+	router.GET("/dashboard", limit, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name           string
+		user           string
+		role           string
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"first request", "2", "manager", http.StatusOK, ""},
+		{"over the limit", "2", "manager", http.StatusTooManyRequests, "60"},
+		{"another user's budget", "3", "manager", http.StatusOK, ""},
+		{"admin first request", "1", "admin", http.StatusOK, ""},
+		{"admin second request", "1", "admin", http.StatusOK, ""},
+		{"admin over the limit", "1", "admin", http.StatusTooManyRequests, "30"},
+		{"unauthenticated", "", "", http.StatusUnauthorized, ""},
+	}
+
+	// The cases run in order against the same buckets
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+		req.Header.Set("X-User", tt.user)
+		req.Header.Set("X-Role", tt.role)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, recorder.Code, tt.wantStatus)
+		}
+		if got := recorder.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+			t.Errorf("%s: Retry-After = %q, want %q", tt.name, got, tt.wantRetryAfter)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Readiness tracks whether startup initialization has finished
+type Readiness struct {
+	ready atomic.Bool
+}
+
+// SetReady marks startup initialization as complete
+func (r *Readiness) SetReady() {
+	r.ready.Store(true)
+}
+
+// IsReady reports whether startup initialization has completed
+func (r *Readiness) IsReady() bool {
+	return r.ready.Load()
+}
+
+// Handler reports readiness for orchestrators: 200 once ready, 503 before
+func (r *Readiness) Handler(c *gin.Context) {
+	SetNoStore(c)
+
+	status := http.StatusOK
+	state := "ready"
+	if !r.IsReady() {
+		status = http.StatusServiceUnavailable
+		state = "starting"
+	}
+
+	c.JSON(status, gin.H{
+		"status":    state,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// RequireReady rejects requests with 503 until startup initialization has completed
+func (r *Readiness) RequireReady() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.IsReady() {
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Message: "Service is starting up, please retry shortly",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
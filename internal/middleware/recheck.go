@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserLookup loads a user by ID, returning nil when the user does not exist or is inactive
+type UserLookup interface {
+	GetUserByID(id int) (*models.User, error)
+}
+
+type recheckEntry struct {
+	user      *models.User
+	expiresAt time.Time
+}
+
+// UserRecheck re-validates token claims against the user's current account so
+// that demoted or deactivated users lose access before their token expires.
+// Lookups are cached per user for ttl to limit database load.
+type UserRecheck struct {
+	lookup UserLookup
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[int]recheckEntry
+}
+
+// NewUserRecheck creates a UserRecheck backed by lookup
+func NewUserRecheck(lookup UserLookup, ttl time.Duration) *UserRecheck {
+	return &UserRecheck{
+		lookup: lookup,
+		ttl:    ttl,
+		cache:  make(map[int]recheckEntry),
+	}
+}
+
+// Middleware rejects requests whose token belongs to a deactivated user or
+// claims a role the user no longer has. The user's current account replaces
+// the claims in the context. It must run after AuthRequired.
+func (r *UserRecheck) Middleware() gin.HandlerFunc {
+	return r.middleware(false)
+}
+
+// AdminMiddleware is Middleware for tokens claiming the admin role only, so a
+// demoted admin loses admin scope on every route while other tokens cost no
+// lookup. It must run after AuthRequired.
+func (r *UserRecheck) AdminMiddleware() gin.HandlerFunc {
+	return r.middleware(true)
+}
+
+func (r *UserRecheck) middleware(adminClaimsOnly bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimed, ok := GetUserFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Message: "Authentication required",
+			})
+			c.Abort()
+			return
+		}
+
+		if adminClaimsOnly && claimed.Role != "admin" {
+			c.Next()
+			return
+		}
+
+		user, err := r.CurrentUser(claimed.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Message: "Internal server error",
+			})
+			c.Abort()
+			return
+		}
+
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Message: "Account is no longer active",
+			})
+			c.Abort()
+			return
+		}
+
+		if user.Role != claimed.Role {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Message: "Token role is out of date, please log in again",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("user", user.ToResponse())
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user's current account, nil when it no longer exists
+// or is inactive. The cached account is used while fresh, otherwise it is
+// reloaded; missing users are cached too so repeated requests stay cheap.
+func (r *UserRecheck) CurrentUser(id int) (*models.User, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	entry, ok := r.cache[id]
+	r.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.user, nil
+	}
+
+	user, err := r.lookup.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	// Drop stale entries so the cache only holds recently active users
+	for cachedID, cached := range r.cache {
+		if !now.Before(cached.expiresAt) {
+			delete(r.cache, cachedID)
+		}
+	}
+	r.cache[id] = recheckEntry{user: user, expiresAt: now.Add(r.ttl)}
+	r.mu.Unlock()
+
+	return user, nil
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeUsers is a UserLookup over a fixed set of active users, counting lookups
+type fakeUsers struct {
+	users   map[int]*models.User
+	lookups int
+}
+
+func (f *fakeUsers) GetUserByID(id int) (*models.User, error) {
+	f.lookups++
+	return f.users[id], nil
+}
+
+func TestUserRecheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		adminOnly   bool
+		claimedRole string
+		current     *models.User
+		wantStatus  int
+		wantRole    string
+		wantLookups int
+	}{
+		{"unchanged admin", true, "admin", &models.User{ID: 1, Role: "admin"}, http.StatusOK, "admin", 1},
+		{"demoted admin", true, "admin", &models.User{ID: 1, Role: "user"}, http.StatusUnauthorized, "", 1},
+		{"deactivated admin", true, "admin", nil, http.StatusUnauthorized, "", 1},
+		{"user skipped in admin mode", true, "user", nil, http.StatusOK, "user", 0},
+		{"user checked in all mode", false, "user", nil, http.StatusUnauthorized, "", 1},
+		{"unchanged user in all mode", false, "user", &models.User{ID: 1, Role: "user", Email: "new@example.com"}, http.StatusOK, "user", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUsers{users: map[int]*models.User{}}
+			if tt.current != nil {
+				users.users[1] = tt.current
+			}
+			recheck := NewUserRecheck(users, time.Minute)
+			check := recheck.Middleware()
+			if tt.adminOnly {
+				check = recheck.AdminMiddleware()
+			}
+
+			var gotRole string
+			router := gin.New()
+			router.GET("/data", func(c *gin.Context) {
+				c.Set("user", models.UserResponse{ID: 1, Role: tt.claimedRole, Email: "old@example.com"})
+			}, check, func(c *gin.Context) {
+				user, _ := GetUserFromContext(c)
+				gotRole = user.Role
+				if tt.current != nil && user.Email != tt.current.Email && tt.current.Email != "" {
+					t.Errorf("context email = %q, want the current %q", user.Email, tt.current.Email)
+				}
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/data", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if gotRole != tt.wantRole {
+				t.Errorf("handler saw role %q, want %q", gotRole, tt.wantRole)
+			}
+			if users.lookups != tt.wantLookups {
+				t.Errorf("lookups = %d, want %d", users.lookups, tt.wantLookups)
+			}
+		})
+	}
+}
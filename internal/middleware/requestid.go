@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"fuel-monitor-api/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID that correlates a request with the server logs
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds incoming request IDs that are echoed back
+const maxRequestIDLength = 128
+
+// RequestID sets X-Request-Id on every response, echoing a valid incoming ID or
+// generating a UUID. Register it first so 404, 405 and error responses carry it too.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newUUID()
+		}
+
+		c.Set("requestID", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the request's ID, or "" outside the RequestID middleware
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("requestID")
+}
+
+// Log returns a logger tagging each message with the request's ID, so entries
+// written while handling a request can be found from its X-Request-Id
+func Log(c *gin.Context) logger.Entry {
+	return logger.ForRequest(GetRequestID(c))
+}
+
+// RequestLogger logs each request like gin.Logger, adding its request ID
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		requestID, _ := param.Keys["requestID"].(string)
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency.Round(time.Microsecond),
+			param.ClientIP,
+			requestID,
+			param.Method,
+			param.Path,
+			param.ErrorMessage,
+		)
+	})
+}
+
+// validRequestID accepts non-empty IDs of printable ASCII without spaces
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"abc-123", true},
+		{"550e8400-e29b-41d4-a716-446655440000", true},
+		{"~!@#$%^&*()", true},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{"", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+		{"has space", false},
+		{"tab\tid", false},
+		{"line\nbreak", false},
+		{"café", false},
+		{"del\x7f", false},
+	}
+
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %t, want %t", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/data", func(c *gin.Context) {
+		c.String(http.StatusOK, GetRequestID(c))
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		incoming string
+		echoed   bool
+	}{
+		{"valid id echoed", "/data", "req-42", true},
+		{"missing id generated", "/data", "", false},
+		{"invalid id replaced", "/data", "bad id", false},
+		{"unknown route", "/missing", "req-43", true},
+	}
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			got := recorder.Header().Get(RequestIDHeader)
+			if tt.path == "/data" && got != recorder.Body.String() {
+				t.Errorf("header %q differs from the context's ID %q", got, recorder.Body.String())
+			}
+			if tt.echoed && got != tt.incoming {
+				t.Errorf("ID = %q, want %q echoed", got, tt.incoming)
+			}
+			if !tt.echoed && !uuid.MatchString(got) {
+				t.Errorf("ID = %q, want a generated UUID", got)
+			}
+		})
+	}
+}
+
+func TestLogTagsRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	flags, out := log.Flags(), log.Writer()
+	log.SetFlags(0)
+	log.SetOutput(&buf)
+	defer func() {
+		log.SetFlags(flags)
+		log.SetOutput(out)
+	}()
+
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/data", func(c *gin.Context) {
+		Log(c).Infof("handling")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/data", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := strings.TrimSpace(buf.String()); got != "INFO [req-42] handling" {
+		t.Errorf("log = %q, want %q", got, "INFO [req-42] handling")
+	}
+}
@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"fuel-monitor-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutWriteGrace is how long past a request's deadline its response may
+// still take to write, so the timeout response itself reaches the client
+const timeoutWriteGrace = 5 * time.Second
+
+// timeoutStateKey holds the *timeoutState of the outermost Timeout, so a
+// route-level Timeout or NoTimeout can replace a group's
+const timeoutStateKey = "timeoutState"
+
+// timeoutState is the deadline the outermost Timeout enforces. The zero time
+// means no deadline.
+type timeoutState struct {
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (s *timeoutState) set(deadline time.Time) {
+	s.mu.Lock()
+	s.deadline = deadline
+	s.mu.Unlock()
+}
+
+func (s *timeoutState) get() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deadline
+}
+
+// Timeout bounds the routes it guards to d. The rest of the chain runs with a
+// buffered response; if it has not finished after d the client gets 504 right
+// away, the request context is cancelled so queries and handlers using it give
+// up, and whatever the handler still writes is discarded. The connection's
+// write deadline is moved to d (plus a short grace) so a slow client cannot
+// hold it either. A Timeout on a route replaces one set on its group. A d of 0
+// or less disables it.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		deadline := time.Now().Add(d)
+
+		// Nested under another Timeout: move its deadline instead
+		if state, ok := c.Get(timeoutStateKey); ok {
+			state.(*timeoutState).set(deadline)
+			setWriteDeadline(c, deadline.Add(timeoutWriteGrace))
+			c.Next()
+			return
+		}
+
+		state := &timeoutState{deadline: deadline}
+		c.Set(timeoutStateKey, state)
+		setWriteDeadline(c, deadline.Add(timeoutWriteGrace))
+
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		method, path := c.Request.Method, c.Request.URL.Path
+		log := Log(c)
+		original := c.Writer
+		buffered := newTimeoutWriter(original)
+		c.Writer = buffered
+
+		done := make(chan struct{})
+		var panicked interface{}
+		go func() {
+			defer close(done)
+			defer func() { panicked = recover() }()
+			c.Next()
+		}()
+
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired := timer.C
+
+		for {
+			select {
+			case <-done:
+				c.Writer = original
+				if panicked != nil {
+					panic(panicked)
+				}
+				buffered.flushTo(original)
+				return
+
+			case <-expired:
+				deadline := state.get()
+				if deadline.IsZero() {
+					// NoTimeout lifted the deadline
+					expired = nil
+					continue
+				}
+				if remaining := time.Until(deadline); remaining > 0 {
+					timer.Reset(remaining)
+					continue
+				}
+
+				cancel()
+				buffered.discard()
+				log.Warnf("Request %s %s timed out after %v", method, path, d)
+				writeTimeoutResponse(original)
+
+				// The handler may still be using the context; it must not be
+				// reused for another request until the handler returns
+				<-done
+				c.Writer = original
+				if panicked != nil {
+					log.Errorf("Request %s %s panicked after timing out: %v", method, path, panicked)
+				}
+				return
+			}
+		}
+	}
+}
+
+// NoTimeout opts the routes it guards out of any Timeout on their group and of
+// the server's write timeout, for responses such as file exports and history
+// rebuilds that may legitimately take long to produce or send
+func NoTimeout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if state, ok := c.Get(timeoutStateKey); ok {
+			state.(*timeoutState).set(time.Time{})
+		}
+		setWriteDeadline(c, time.Time{})
+		c.Next()
+	}
+}
+
+// writeTimeoutResponse sends the 504 for a request that ran out of time
+func writeTimeoutResponse(w gin.ResponseWriter) {
+	body, _ := json.Marshal(models.ErrorResponse{Message: "Request timed out"})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	// With a length the client has the whole response without waiting for the handler
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusGatewayTimeout)
+	w.Write(body)
+	w.Flush()
+}
+
+// setWriteDeadline sets the write deadline of the request's connection; the
+// zero time clears it
+func setWriteDeadline(c *gin.Context, deadline time.Time) {
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(deadline); err != nil {
+		Log(c).Debugf("Could not set write deadline for %s: %v", c.Request.URL.Path, err)
+	}
+}
+
+// timeoutWriter buffers a response until the handler finishes, so it can be
+// replaced by a timeout response. Once discarded every write is dropped.
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	mu        sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	status    int
+	written   bool
+	discarded bool
+}
+
+func newTimeoutWriter(w gin.ResponseWriter) *timeoutWriter {
+	header := make(http.Header)
+	for key, values := range w.Header() {
+		header[key] = append([]string(nil), values...)
+	}
+	return &timeoutWriter{ResponseWriter: w, header: header, status: http.StatusOK}
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = true
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.discarded {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+// Flush is a no-op: the response is sent once the handler finishes
+func (w *timeoutWriter) Flush() {}
+
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("hijacking is not supported on routes with a timeout")
+}
+
+// discard drops the buffered response and every later write
+func (w *timeoutWriter) discard() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.discarded = true
+	w.body.Reset()
+}
+
+// flushTo writes the buffered response to w
+func (w *timeoutWriter) flushTo(dst gin.ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	header := dst.Header()
+	for key := range header {
+		if _, ok := w.header[key]; !ok {
+			header.Del(key)
+		}
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+
+	dst.WriteHeader(w.status)
+	if w.body.Len() > 0 {
+		dst.Write(w.body.Bytes())
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sleepHandler ignores the request context, like handlers whose queries do not take one
+func sleepHandler(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		time.Sleep(d)
+		c.Header("X-Handler", "done")
+		c.String(http.StatusCreated, "finished")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+	group := router.Group("")
+	group.Use(Timeout(50 * time.Millisecond))
+	group.GET("/fast", sleepHandler(0))
+	group.GET("/slow", sleepHandler(300*time.Millisecond))
+	group.GET("/longer", Timeout(time.Second), sleepHandler(150*time.Millisecond))
+	group.GET("/none", NoTimeout(), sleepHandler(150*time.Millisecond))
+	group.GET("/panic", func(c *gin.Context) { panic("boom") })
+	router.GET("/disabled", Timeout(0), sleepHandler(100*time.Millisecond))
+
+	server := httptest.NewUnstartedServer(router)
+	// The write timeout is shorter than the routes that lift or extend it
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+		wantHeader string
+		maxTime    time.Duration
+	}{
+		{"/fast", http.StatusCreated, "finished", "done", time.Second},
+		{"/slow", http.StatusGatewayTimeout, `{"message":"Request timed out"}`, "", 250 * time.Millisecond},
+		{"/longer", http.StatusCreated, "finished", "done", time.Second},
+		{"/none", http.StatusCreated, "finished", "done", time.Second},
+		{"/panic", http.StatusInternalServerError, "", "", time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			start := time.Now()
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			elapsed := time.Since(start)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if got := resp.Header.Get("X-Handler"); got != tt.wantHeader {
+				t.Errorf("X-Handler = %q, want %q", got, tt.wantHeader)
+			}
+			if elapsed > tt.maxTime {
+				t.Errorf("took %v, want at most %v", elapsed, tt.maxTime)
+			}
+		})
+	}
+}
+
+func TestTimeoutCancelsContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cancelled := make(chan bool, 1)
+	router := gin.New()
+	router.GET("/wait", Timeout(20*time.Millisecond), func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/wait", nil))
+
+	if recorder.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusGatewayTimeout)
+	}
+	if !<-cancelled {
+		t.Error("request context was not cancelled at the deadline")
+	}
+}
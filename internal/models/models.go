@@ -1,6 +1,11 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,7 +29,160 @@ type Site struct {
 	Location  string    `json:"location"`
 	DeviceID  string    `json:"deviceId"`
 	IsActive  bool      `json:"isActive"`
+	TypeID    *int      `json:"typeId"`
 	CreatedAt time.Time `json:"createdAt"`
+
+	// MaintenanceMode marks planned downtime, optionally limited to the
+	// MaintenanceStart/MaintenanceEnd window (either end may be open)
+	MaintenanceMode  bool       `json:"maintenanceMode"`
+	MaintenanceStart *time.Time `json:"maintenanceStart"`
+	MaintenanceEnd   *time.Time `json:"maintenanceEnd"`
+
+	// AlertsEnabled is false for sites that stay visible with their real status
+	// but are left out of alert counts and notifications
+	AlertsEnabled bool `json:"alertsEnabled"`
+
+	// LowFuelMode decides whether low fuel is judged by percent, by the liters
+	// remaining (LowFuelLiters) or by either
+	LowFuelMode   string   `json:"lowFuelMode"`
+	LowFuelLiters *float64 `json:"lowFuelLiters"`
+	// LowFuelThreshold overrides the low_fuel_threshold setting (percent) for the site
+	LowFuelThreshold *float64 `json:"lowFuelThreshold"`
+}
+
+// Low fuel modes
+const (
+	LowFuelModePercent = "percent"
+	LowFuelModeLiters  = "liters"
+	LowFuelModeEither  = "either"
+)
+
+// InMaintenance reports whether the site is in maintenance at the given time
+func (s *Site) InMaintenance(now time.Time) bool {
+	if !s.MaintenanceMode {
+		return false
+	}
+	if s.MaintenanceStart != nil && now.Before(*s.MaintenanceStart) {
+		return false
+	}
+	if s.MaintenanceEnd != nil && !now.Before(*s.MaintenanceEnd) {
+		return false
+	}
+	return true
+}
+
+// DefaultExpectedSensors are the sensors expected at sites without a site type
+var DefaultExpectedSensors = []string{"fuel_sensor_level", "fuel_sensor_volume", "generator_state", "zesa_state"}
+
+// KnownSensors are the sensor names the API reads from sensor_readings
+var KnownSensors = []string{"fuel_sensor_level", "fuel_sensor_volume", "fuel_sensor_temp", "fuel_sensor_temperature", "generator_state", "zesa_state"}
+
+// IsKnownSensor reports whether name is one of KnownSensors or a configured temperature sensor
+func IsKnownSensor(name string) bool {
+	if IsTemperatureSensor(name) {
+		return true
+	}
+	for _, sensor := range KnownSensors {
+		if sensor == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SiteType represents a class of site and the sensors it is expected to report
+type SiteType struct {
+	ID              int      `json:"id"`
+	Name            string   `json:"name"`
+	ExpectedSensors []string `json:"expectedSensors"`
+	// SensorNames maps sensors to the names this type's devices report them under
+	SensorNames SensorNames `json:"sensorNames"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+// SensorNames maps the API's sensor names (KnownSensors) to the names a device
+// fleet reports them under, e.g. {"generator_state": "gen_state"}. Unmapped
+// sensors are reported under their own name.
+type SensorNames map[string]string
+
+// Device returns the name devices report the sensor under
+func (n SensorNames) Device(sensorName string) string {
+	if deviceName := n[sensorName]; deviceName != "" {
+		return deviceName
+	}
+	return sensorName
+}
+
+// Sensor returns the API's name for a sensor reported as deviceName
+func (n SensorNames) Sensor(deviceName string) string {
+	for sensorName, name := range n {
+		if name == deviceName {
+			return sensorName
+		}
+	}
+	return deviceName
+}
+
+// DeviceNames returns the device names of the given sensors
+func (n SensorNames) DeviceNames(sensorNames ...string) []string {
+	deviceNames := make([]string, len(sensorNames))
+	for i, sensorName := range sensorNames {
+		deviceNames[i] = n.Device(sensorName)
+	}
+	return deviceNames
+}
+
+// Names returns the type's sensor name mapping. A nil SiteType maps no sensors.
+func (t *SiteType) Names() SensorNames {
+	if t == nil {
+		return nil
+	}
+	return t.SensorNames
+}
+
+// SiteSensorNames returns the sensor names of each site's type by device ID,
+// keyed in lowercase. Sites without a known type map no sensors.
+func SiteSensorNames(sites []*Site, siteTypes map[int]*SiteType) map[string]SensorNames {
+	names := make(map[string]SensorNames, len(sites))
+	for _, site := range sites {
+		if site.TypeID == nil {
+			continue
+		}
+		names[strings.ToLower(site.DeviceID)] = siteTypes[*site.TypeID].Names()
+	}
+	return names
+}
+
+// SiteExpectedSensors returns the sensors each site's type expects by device ID,
+// keyed in lowercase. Sites without a known type expect DefaultExpectedSensors.
+func SiteExpectedSensors(sites []*Site, siteTypes map[int]*SiteType) map[string][]string {
+	expected := make(map[string][]string, len(sites))
+	for _, site := range sites {
+		sensors := DefaultExpectedSensors
+		if site.TypeID != nil {
+			if siteType := siteTypes[*site.TypeID]; siteType != nil {
+				sensors = siteType.ExpectedSensors
+			}
+		}
+		expected[strings.ToLower(site.DeviceID)] = sensors
+	}
+	return expected
+}
+
+// Expects reports whether sites of this type are expected to report the sensor.
+// A nil SiteType expects DefaultExpectedSensors.
+func (t *SiteType) Expects(sensorName string) bool {
+	sensors := DefaultExpectedSensors
+	if t != nil {
+		sensors = t.ExpectedSensors
+	}
+
+	for _, sensor := range sensors {
+		if sensor == sensorName {
+			return true
+		}
+	}
+	return false
 }
 
 // UserSiteAssignment represents a user-site assignment in the system
@@ -42,17 +200,176 @@ type UserSiteAssignmentResponse struct {
 	SiteLocation string `json:"siteLocation"`
 }
 
+// UserAssignmentCount represents how many active sites a non-admin user is assigned
+type UserAssignmentCount struct {
+	UserID    int    `json:"userId"`
+	Username  string `json:"username"`
+	FullName  string `json:"fullName"`
+	Role      string `json:"role"`
+	SiteCount int    `json:"siteCount"`
+}
+
+// SiteMaintenanceRequest represents a request to switch a site's maintenance mode.
+// Start and End optionally bound the maintenance window.
+type SiteMaintenanceRequest struct {
+	Enabled *bool      `json:"enabled" binding:"required"`
+	Start   *time.Time `json:"start"`
+	End     *time.Time `json:"end"`
+}
+
+// SiteLowFuelRequest represents a request to set how a site's low fuel is judged.
+// Liters is required unless Mode is percent.
+type SiteLowFuelRequest struct {
+	Mode   string   `json:"mode" binding:"required,oneof=percent liters either"`
+	Liters *float64 `json:"liters" binding:"omitempty,gt=0"`
+}
+
+// NullableFloat is a JSON number that tells an explicit null (Set with a nil
+// Value) apart from a missing field (not Set)
+type NullableFloat struct {
+	Set   bool
+	Value *float64
+}
+
+func (f *NullableFloat) UnmarshalJSON(data []byte) error {
+	f.Set = true
+	if string(data) == "null" {
+		f.Value = nil
+		return nil
+	}
+	return json.Unmarshal(data, &f.Value)
+}
+
+// BulkLowFuelThresholdRequest represents a request to set the same low fuel
+// threshold (percent) on several sites. A null threshold clears the sites' own
+// thresholds so the low_fuel_threshold setting applies again.
+type BulkLowFuelThresholdRequest struct {
+	SiteIds          []int         `json:"siteIds" binding:"required,min=1"`
+	LowFuelThreshold NullableFloat `json:"lowFuelThreshold"`
+}
+
+// BulkLowFuelThresholdResponse represents the outcome of a bulk threshold update
+type BulkLowFuelThresholdResponse struct {
+	Updated          int      `json:"updated"`
+	LowFuelThreshold *float64 `json:"lowFuelThreshold"` // null when cleared
+}
+
+// SiteLowFuelThresholdResponse represents a site's own low fuel threshold and
+// the threshold in effect for it
+type SiteLowFuelThresholdResponse struct {
+	SiteID           int      `json:"siteId"`
+	LowFuelThreshold *float64 `json:"lowFuelThreshold"` // null when the site has none
+	Effective        float64  `json:"effectiveLowFuelThreshold"`
+}
+
+// PatchSiteRequest represents a partial site update. Only the fields present
+// are changed; pointers tell an omitted field from an empty one.
+type PatchSiteRequest struct {
+	Name     *string `json:"name" binding:"omitempty,min=1,max=255"`
+	Location *string `json:"location" binding:"omitempty,max=255"`
+	DeviceID *string `json:"deviceId" binding:"omitempty,min=1,max=255"`
+	TypeID   *int    `json:"typeId" binding:"omitempty,min=1"`
+}
+
+// UpdateSiteData represents data for a partial site update in database. Nil
+// fields are left unchanged.
+type UpdateSiteData struct {
+	Name     *string
+	Location *string
+	DeviceID *string
+	TypeID   *int
+}
+
+// Empty reports whether the update changes no field
+func (d *UpdateSiteData) Empty() bool {
+	return d.Name == nil && d.Location == nil && d.DeviceID == nil && d.TypeID == nil
+}
+
+// SiteAlertsRequest represents a request to enable or disable a site's alerting
+type SiteAlertsRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
 // AssignSitesRequest represents request to assign sites to user
 type AssignSitesRequest struct {
 	SiteIds []int `json:"siteIds" binding:"required"`
 }
 
+// SitesResponse represents the enveloped sites list with the caller's scope
+type SitesResponse struct {
+	Sites      []*Site `json:"sites"`
+	ScopeEmpty bool    `json:"scopeEmpty"` // true when the user has no sites assigned
+}
+
+// BulkAssignSitesRequest represents request to assign the same sites to several users
+type BulkAssignSitesRequest struct {
+	UserIds []int  `json:"userIds" binding:"required"`
+	SiteIds []int  `json:"siteIds" binding:"required"`
+	Mode    string `json:"mode"` // "add" (default) or "replace"
+}
+
+// BulkAssignUserResult represents the outcome of a bulk assignment for one user
+type BulkAssignUserResult struct {
+	UserID        int    `json:"userId"`
+	Username      string `json:"username"`
+	AssignedSites int    `json:"assignedSites"`
+	AddedSites    int    `json:"addedSites"` // sites the user did not already have
+}
+
+// BulkAssignSitesResponse represents the result of a bulk assignment
+type BulkAssignSitesResponse struct {
+	Mode    string                 `json:"mode"`
+	Results []BulkAssignUserResult `json:"results"`
+}
+
+// CloneAssignmentsRequest represents a request to copy one user's site assignments to another
+type CloneAssignmentsRequest struct {
+	FromUserID int    `json:"fromUserId" binding:"required"`
+	ToUserID   int    `json:"toUserId" binding:"required"`
+	Mode       string `json:"mode"` // "add" (default) or "replace"
+}
+
+// CloneAssignmentsResponse represents the result of cloning site assignments
+type CloneAssignmentsResponse struct {
+	FromUserID    int    `json:"fromUserId"`
+	ToUserID      int    `json:"toUserId"`
+	Mode          string `json:"mode"`
+	SourceSites   int    `json:"sourceSites"`
+	AddedSites    int    `json:"addedSites"`
+	AssignedSites int    `json:"assignedSites"`
+}
+
+// RenameDeviceRequest represents a request to move a relabeled device's site to its new device ID
+type RenameDeviceRequest struct {
+	OldDeviceID string `json:"oldDeviceId" binding:"required"`
+	NewDeviceID string `json:"newDeviceId" binding:"required"`
+	// RewriteReadings also moves the device's historical sensor readings, which
+	// can take a while on a large history
+	RewriteReadings bool `json:"rewriteReadings"`
+}
+
+// RenameDeviceResponse reports the rows moved to the new device ID
+type RenameDeviceResponse struct {
+	SiteID             int    `json:"siteId"`
+	OldDeviceID        string `json:"oldDeviceId"`
+	NewDeviceID        string `json:"newDeviceId"`
+	CumulativeReadings int64  `json:"cumulativeReadings"`
+	ClosingReadings    int64  `json:"closingReadings"`
+	CumulativeErrors   int64  `json:"cumulativeErrors"`
+	ReadingsRewritten  bool   `json:"readingsRewritten"`
+	SensorReadings     int64  `json:"sensorReadings"`
+}
+
 // Dashboard models
 type DashboardData struct {
 	Sites          []*SiteWithReadings `json:"sites"`
 	SystemStatus   SystemStatus        `json:"systemStatus"`
 	RecentActivity []ActivityItem      `json:"recentActivity"`
 	ViewMode       string              `json:"viewMode"`
+	ScopeEmpty     bool                `json:"scopeEmpty"` // true when the user has no sites assigned
+	// Partial is set when the soft deadline passed before every site was read;
+	// the sites not read are listed with alertStatus "timed_out"
+	Partial bool `json:"partial"`
 }
 
 type SiteWithReadings struct {
@@ -61,7 +378,10 @@ type SiteWithReadings struct {
 	GeneratorOnline     bool           `json:"generatorOnline"`
 	ZesaOnline          bool           `json:"zesaOnline"`
 	FuelLevelPercentage float64        `json:"fuelLevelPercentage"`
-	AlertStatus         string         `json:"alertStatus"` // "normal", "low_fuel", "generator_off"
+	AlertStatus         string         `json:"alertStatus"` // "normal", "low_fuel", "critical_fuel", "generator_off", "maintenance", "timed_out"
+	ExpectedSensors     []string       `json:"expectedSensors"`
+	// Acknowledged is set when an operator has acknowledged the current alert status
+	Acknowledged bool `json:"acknowledged"`
 }
 
 type SensorReading struct {
@@ -75,15 +395,57 @@ type SensorReading struct {
 	ZesaState      string    `json:"zesaState"`
 	CapturedAt     time.Time `json:"capturedAt"`
 	CreatedAt      time.Time `json:"createdAt"`
+
+	// Typed values parsed once at read time by ParseValues
+	FuelLevelFloat   float64 `json:"-"`
+	FuelLevelParsed  bool    `json:"-"`
+	FuelVolumeFloat  float64 `json:"-"`
+	FuelVolumeParsed bool    `json:"-"`
+	GeneratorOn      bool    `json:"-"`
+	ZesaOn           bool    `json:"-"`
+}
+
+// ParseValues populates the typed fields from the raw string values so
+// downstream code does not need to re-parse them
+func (r *SensorReading) ParseValues() {
+	r.FuelLevelFloat, r.FuelLevelParsed = 0, false
+	if level, err := strconv.ParseFloat(strings.TrimSpace(r.FuelLevel), 64); err == nil {
+		r.FuelLevelFloat = level
+		r.FuelLevelParsed = true
+	}
+	r.FuelVolumeFloat, r.FuelVolumeParsed = 0, false
+	if volume, err := strconv.ParseFloat(strings.TrimSpace(r.FuelVolume), 64); err == nil {
+		r.FuelVolumeFloat = volume
+		r.FuelVolumeParsed = true
+	}
+
+	r.GeneratorOn = ParseState(r.GeneratorState)
+	r.ZesaOn = ParseState(r.ZesaState)
+}
+
+// FuelLevelPercentage returns the parsed fuel level clamped to 0–100, or 0 when it did not parse
+func (r *SensorReading) FuelLevelPercentage() float64 {
+	if !r.FuelLevelParsed {
+		return 0
+	}
+	return math.Max(0, math.Min(100, r.FuelLevelFloat))
 }
 
 type SystemStatus struct {
-	SitesOnline       int `json:"sitesOnline"`
-	TotalSites        int `json:"totalSites"`
-	LowFuelAlerts     int `json:"lowFuelAlerts"`
-	GeneratorsRunning int `json:"generatorsRunning"`
-	ZesaRunning       int `json:"zesaRunning"`
-	OfflineSites      int `json:"offlineSites"`
+	SitesOnline   int `json:"sitesOnline"`
+	TotalSites    int `json:"totalSites"`
+	LowFuelAlerts int `json:"lowFuelAlerts"`
+	// CriticalFuelAlerts counts sites escalated to critical_fuel; they are not
+	// also counted in LowFuelAlerts
+	CriticalFuelAlerts int `json:"criticalFuelAlerts"`
+	GeneratorsRunning  int `json:"generatorsRunning"`
+	ZesaRunning        int `json:"zesaRunning"`
+	OfflineSites       int `json:"offlineSites"`
+	MaintenanceSites   int `json:"maintenanceSites"`
+	// AlertsDisabledSites counts sites left out of the alert counts
+	AlertsDisabledSites int `json:"alertsDisabledSites"`
+	// TimedOutSites counts sites not read before the dashboard's soft deadline
+	TimedOutSites int `json:"timedOutSites"`
 }
 
 type ActivityItem struct {
@@ -115,6 +477,13 @@ type UserResponse struct {
 	CreatedAt time.Time  `json:"createdAt"`
 }
 
+// InactiveUserResponse represents a user who has not logged in within a threshold
+type InactiveUserResponse struct {
+	UserResponse
+	NeverLoggedIn      bool `json:"neverLoggedIn"`
+	DaysSinceLastLogin *int `json:"daysSinceLastLogin"` // null when the user never logged in
+}
+
 // LoginRequest represents login request data
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -129,7 +498,14 @@ type LoginResponse struct {
 
 // ErrorResponse represents error response data
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
 }
 
 // HealthResponse represents health check response
@@ -153,6 +529,28 @@ type TokenInfo struct {
 	Role     string `json:"role"`
 }
 
+// IntrospectRequest represents a request to introspect one token or a batch of tokens
+type IntrospectRequest struct {
+	Token  string   `json:"token"`
+	Tokens []string `json:"tokens"`
+}
+
+// IntrospectionResult represents the introspection result for a single token
+type IntrospectionResult struct {
+	Active    bool       `json:"active"`
+	UserID    int        `json:"userId,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	IssuedAt  *time.Time `json:"issuedAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// IntrospectBatchResponse represents introspection results for a batch of tokens
+type IntrospectBatchResponse struct {
+	Results []IntrospectionResult `json:"results"`
+}
+
 // CreateUserRequest represents create user request data
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -224,6 +622,14 @@ type CumulativeReadingsResponse struct {
 	Summary     CumulativeSummary      `json:"summary"`
 }
 
+// StoredCumulativeReadingsResponse lists the stored cumulative readings for a date.
+// Readings holds []*CumulativeReading, or []LegacyCumulativeReading when the
+// legacy format is requested.
+type StoredCumulativeReadingsResponse struct {
+	Date     string      `json:"date"`
+	Readings interface{} `json:"readings"`
+}
+
 type UserInfo struct {
 	Username string `json:"username"`
 	Role     string `json:"role"`
@@ -240,15 +646,22 @@ type CumulativeSiteResult struct {
 	GeneratorHours      float64   `json:"generatorHours"`
 	ZesaHours           float64   `json:"zesaHours"`
 	OfflineHours        float64   `json:"offlineHours"`
-	Status              string    `json:"status"` // "CREATED", "UPDATED", "ERROR"
+	GeneratorStarts     int       `json:"generatorStarts"`
+	Status              string    `json:"status"` // "CREATED", "UPDATED", "PARTIAL" (not saved), "ERROR"
 	Error               string    `json:"error,omitempty"`
+	FuelError           string    `json:"fuelError,omitempty"`
+	PowerError          string    `json:"powerError,omitempty"`
+	MetricsInconsistent bool      `json:"metricsInconsistent"`   // fuel level and volume disagree
+	CalcVersion         int       `json:"calcVersion,omitempty"` // set once saved
 	CalculatedAt        time.Time `json:"calculatedAt"`
 }
 
 type CumulativeSummary struct {
 	TotalSites          int     `json:"totalSites"`
 	ProcessedSites      int     `json:"processedSites"`
+	PartialSites        int     `json:"partialSites"`
 	ErrorSites          int     `json:"errorSites"`
+	InconsistentSites   int     `json:"inconsistentSites"`
 	TotalFuelConsumed   float64 `json:"totalFuelConsumed"`
 	TotalFuelTopped     float64 `json:"totalFuelTopped"`
 	TotalGeneratorHours float64 `json:"totalGeneratorHours"`
@@ -257,7 +670,31 @@ type CumulativeSummary struct {
 }
 
 // Database models
+
+// CumulativeReading represents a stored cumulative_readings row. The metric
+// columns are stored as text but are scanned into float64 so they serialize
+// as JSON numbers, matching the computed CumulativeSiteResult shape.
 type CumulativeReading struct {
+	ID                    int       `json:"id"`
+	SiteID                int       `json:"siteId"`
+	DeviceID              string    `json:"deviceId"`
+	Date                  string    `json:"date"`
+	TotalFuelConsumed     float64   `json:"totalFuelConsumed"`
+	TotalFuelTopped       float64   `json:"totalFuelTopped"`
+	FuelConsumedPercent   float64   `json:"fuelConsumedPercent"`
+	FuelToppedPercent     float64   `json:"fuelToppedPercent"`
+	TotalGeneratorRuntime float64   `json:"totalGeneratorRuntime"`
+	TotalZesaRuntime      float64   `json:"totalZesaRuntime"`
+	TotalOfflineTime      float64   `json:"totalOfflineTime"`
+	GeneratorStarts       int       `json:"generatorStarts"`
+	CalcVersion           int       `json:"calcVersion"`
+	CalculatedAt          time.Time `json:"calculatedAt"`
+	CreatedAt             time.Time `json:"createdAt"`
+}
+
+// LegacyCumulativeReading is the string-typed shape of CumulativeReading
+// served to clients that still parse the metric fields themselves
+type LegacyCumulativeReading struct {
 	ID                    int       `json:"id"`
 	SiteID                int       `json:"siteId"`
 	DeviceID              string    `json:"deviceId"`
@@ -269,22 +706,58 @@ type CumulativeReading struct {
 	TotalGeneratorRuntime string    `json:"totalGeneratorRuntime"`
 	TotalZesaRuntime      string    `json:"totalZesaRuntime"`
 	TotalOfflineTime      string    `json:"totalOfflineTime"`
+	CalcVersion           int       `json:"calcVersion"`
 	CalculatedAt          time.Time `json:"calculatedAt"`
 	CreatedAt             time.Time `json:"createdAt"`
 }
 
+// ToLegacy converts CumulativeReading to its string-typed representation
+func (r *CumulativeReading) ToLegacy() LegacyCumulativeReading {
+	return LegacyCumulativeReading{
+		ID:                    r.ID,
+		SiteID:                r.SiteID,
+		DeviceID:              r.DeviceID,
+		Date:                  r.Date,
+		TotalFuelConsumed:     fmt.Sprintf("%.2f", r.TotalFuelConsumed),
+		TotalFuelTopped:       fmt.Sprintf("%.2f", r.TotalFuelTopped),
+		FuelConsumedPercent:   fmt.Sprintf("%.2f", r.FuelConsumedPercent),
+		FuelToppedPercent:     fmt.Sprintf("%.2f", r.FuelToppedPercent),
+		TotalGeneratorRuntime: fmt.Sprintf("%.2f", r.TotalGeneratorRuntime),
+		TotalZesaRuntime:      fmt.Sprintf("%.2f", r.TotalZesaRuntime),
+		TotalOfflineTime:      fmt.Sprintf("%.2f", r.TotalOfflineTime),
+		CalcVersion:           r.CalcVersion,
+		CalculatedAt:          r.CalculatedAt,
+		CreatedAt:             r.CreatedAt,
+	}
+}
+
+// CumulativeCalcVersion identifies the logic that computes cumulative readings
+// and is stored on every row saved. Bump it whenever a change to the fuel or
+// power calculations (noise filtering, gap handling, interval merging, ...)
+// alters the values produced, so rows computed earlier can be found and
+// recomputed. Rows saved before versioning have version 0.
+//
+// Version 2 merges overlapping generator and zesa runtime when deriving
+// offline time instead of adding them.
+const CumulativeCalcVersion = 2
+
 // Calculation result models
 type FuelMetrics struct {
 	TotalFuelConsumed   float64
 	TotalFuelTopped     float64
 	FuelConsumedPercent float64
 	FuelToppedPercent   float64
+	// MetricsInconsistent is set when the day's fuel level and volume moved in
+	// opposite directions, pointing at a faulty sensor; the totals are kept as computed
+	MetricsInconsistent bool
 }
 
 type PowerMetrics struct {
 	TotalGeneratorRuntime float64
 	TotalZesaRuntime      float64
 	TotalOfflineTime      float64
+	// GeneratorStarts counts the generator's OFF→ON transitions during the day
+	GeneratorStarts int
 }
 
 // CumulativeReadingsRangeResponse represents the response for date range queries
@@ -295,16 +768,19 @@ type CumulativeReadingsRangeResponse struct {
 
 // CumulativeSiteRangeResult represents aggregated data for a single site over a date range
 type CumulativeSiteRangeResult struct {
-	SiteID              int       `json:"siteId"`
-	SiteName            string    `json:"siteName"`
-	DeviceID            string    `json:"deviceId"`
-	TotalFuelConsumed   float64   `json:"totalFuelConsumed"`
-	TotalFuelTopped     float64   `json:"totalFuelTopped"`
-	TotalGeneratorHours float64   `json:"totalGeneratorHours"`
-	TotalZesaHours      float64   `json:"totalZesaHours"`
-	TotalOfflineHours   float64   `json:"totalOfflineHours"`
-	ReadingDays         int       `json:"readingDays"`
-	DateRange           DateRange `json:"dateRange"`
+	SiteID              int     `json:"siteId"`
+	SiteName            string  `json:"siteName"`
+	DeviceID            string  `json:"deviceId"`
+	TotalFuelConsumed   float64 `json:"totalFuelConsumed"`
+	TotalFuelTopped     float64 `json:"totalFuelTopped"`
+	TotalGeneratorHours float64 `json:"totalGeneratorHours"`
+	TotalZesaHours      float64 `json:"totalZesaHours"`
+	TotalOfflineHours   float64 `json:"totalOfflineHours"`
+	UptimePercent       float64 `json:"uptimePercent"`
+	ReadingDays         int     `json:"readingDays"`
+	// OutdatedDays counts the days computed before CumulativeCalcVersion
+	OutdatedDays int       `json:"outdatedDays"`
+	DateRange    DateRange `json:"dateRange"`
 }
 
 // CumulativeRangeSummary represents summary statistics for a date range
@@ -317,6 +793,7 @@ type CumulativeRangeSummary struct {
 	TotalZesaHours      float64   `json:"totalZesaHours"`
 	TotalOfflineHours   float64   `json:"totalOfflineHours"`
 	AverageFuelPerSite  float64   `json:"averageFuelPerSite"`
+	AverageUptime       float64   `json:"averageUptimePercent"`
 	DaysIncluded        int       `json:"daysIncluded"`
 }
 
@@ -326,3 +803,893 @@ type DateRange struct {
 	End     string `json:"end"`
 	IsRange bool   `json:"isRange,omitempty"`
 }
+
+// RebuildClosingRequest represents a request to regenerate daily closing readings
+type RebuildClosingRequest struct {
+	Date    string `json:"date"`
+	SiteIds []int  `json:"siteIds"`
+	// Cutoff is an optional "HH:MM" time replacing the configured cutoff for this rebuild
+	Cutoff string `json:"cutoff"`
+}
+
+// RebuildClosingResponse represents the result of a daily closing rebuild
+type RebuildClosingResponse struct {
+	Date        string                     `json:"date"`
+	Cutoff      string                     `json:"cutoff"`
+	ProcessedAt string                     `json:"processedAt"`
+	Sites       []RebuildClosingSiteResult `json:"sites"`
+	Summary     RebuildClosingSummary      `json:"summary"`
+}
+
+// RebuildClosingSiteResult represents the rebuilt closing snapshot for a single site
+type RebuildClosingSiteResult struct {
+	SiteID      int        `json:"siteId"`
+	SiteName    string     `json:"siteName"`
+	DeviceID    string     `json:"deviceId"`
+	FuelLevel   string     `json:"fuelLevel,omitempty"`
+	FuelVolume  string     `json:"fuelVolume,omitempty"`
+	Temperature *string    `json:"temperature,omitempty"`
+	CapturedAt  *time.Time `json:"capturedAt,omitempty"`
+	Status      string     `json:"status"` // "WRITTEN", "NO_DATA", "ERROR"
+	Error       string     `json:"error,omitempty"`
+}
+
+// RebuildClosingSummary represents summary statistics for a daily closing rebuild
+type RebuildClosingSummary struct {
+	TotalSites  int `json:"totalSites"`
+	RowsWritten int `json:"rowsWritten"`
+	NoDataSites int `json:"noDataSites"`
+	ErrorSites  int `json:"errorSites"`
+}
+
+// LeaderboardResponse represents the top consumers for a single day
+type LeaderboardResponse struct {
+	Date    string             `json:"date"`
+	OrderBy string             `json:"orderBy"`
+	Limit   int                `json:"limit"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// OfflineRankingResponse ranks sites by offline hours over a date range
+type OfflineRankingResponse struct {
+	DateRange   DateRange             `json:"dateRange"`
+	DaysInRange int                   `json:"daysInRange"`
+	Limit       int                   `json:"limit"`
+	TotalSites  int                   `json:"totalSites"`
+	Entries     []OfflineRankingEntry `json:"entries"`
+}
+
+// OfflineRankingEntry is a site's offline time over the range. ReadingDays
+// tells sites with few stored days apart from sites that were offline.
+type OfflineRankingEntry struct {
+	Rank               int       `json:"rank"`
+	SiteID             int       `json:"siteId"`
+	SiteName           string    `json:"siteName"`
+	DeviceID           string    `json:"deviceId"`
+	OfflineHours       float64   `json:"offlineHours"`
+	OfflineHoursPerDay float64   `json:"offlineHoursPerDay"`
+	UptimePercent      float64   `json:"uptimePercent"`
+	ReadingDays        int       `json:"readingDays"`
+	DateRange          DateRange `json:"dateRange"`
+}
+
+// EfficiencyRankingResponse ranks sites by liters consumed per generator hour
+// over a date range. ExcludedSites counts sites with stored readings whose
+// generator ran less than MinGeneratorHours.
+type EfficiencyRankingResponse struct {
+	DateRange         DateRange                `json:"dateRange"`
+	MinGeneratorHours float64                  `json:"minGeneratorHours"`
+	Limit             int                      `json:"limit"`
+	TotalSites        int                      `json:"totalSites"`
+	ExcludedSites     int                      `json:"excludedSites"`
+	Entries           []EfficiencyRankingEntry `json:"entries"`
+}
+
+// EfficiencyRankingEntry is a site's consumption per generator hour over the
+// range, with the totals it is computed from
+type EfficiencyRankingEntry struct {
+	Rank           int       `json:"rank"`
+	SiteID         int       `json:"siteId"`
+	SiteName       string    `json:"siteName"`
+	DeviceID       string    `json:"deviceId"`
+	FuelConsumed   float64   `json:"fuelConsumed"`
+	GeneratorHours float64   `json:"generatorHours"`
+	LitersPerHour  float64   `json:"litersPerHour"`
+	ReadingDays    int       `json:"readingDays"`
+	DateRange      DateRange `json:"dateRange"`
+}
+
+// LeaderboardEntry represents a single ranked site on the leaderboard
+type LeaderboardEntry struct {
+	Rank           int       `json:"rank"`
+	SiteID         int       `json:"siteId"`
+	SiteName       string    `json:"siteName"`
+	DeviceID       string    `json:"deviceId"`
+	FuelConsumed   float64   `json:"fuelConsumed"`
+	FuelTopped     float64   `json:"fuelTopped"`
+	GeneratorHours float64   `json:"generatorHours"`
+	ZesaHours      float64   `json:"zesaHours"`
+	OfflineHours   float64   `json:"offlineHours"`
+	CalcVersion    int       `json:"calcVersion"`
+	CalculatedAt   time.Time `json:"calculatedAt"`
+}
+
+// DBStatsResponse represents the current database connection pool statistics
+type DBStatsResponse struct {
+	MaxOpenConnections int         `json:"maxOpenConnections"`
+	OpenConnections    int         `json:"openConnections"`
+	InUse              int         `json:"inUse"`
+	Idle               int         `json:"idle"`
+	WaitCount          int64       `json:"waitCount"`
+	WaitDuration       string      `json:"waitDuration"`
+	WaitDurationMs     int64       `json:"waitDurationMs"`
+	MaxIdleClosed      int64       `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64       `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64       `json:"maxLifetimeClosed"`
+	Workers            WorkerStats `json:"workers"`
+	Timestamp          string      `json:"timestamp"`
+}
+
+// DataDiagnostics summarizes the sensor data flow: StaleSites are active sites
+// with no reading within StaleAfter, OrphanDevices report readings but have no
+// site. LatestReading is null when sensor_readings is empty.
+type DataDiagnostics struct {
+	TotalSites       int        `json:"totalSites"`
+	ActiveSites      int        `json:"activeSites"`
+	ReportingDevices int        `json:"reportingDevices"`
+	StaleSites       int        `json:"staleSites"`
+	OrphanDevices    int        `json:"orphanDevices"`
+	LatestReading    *time.Time `json:"latestReading"`
+	StaleAfter       string     `json:"staleAfter"`
+	Timestamp        string     `json:"timestamp"`
+}
+
+// WorkerStats reports the dashboard and cumulative worker pools. The oldest
+// request fields are empty while no request is in flight.
+type WorkerStats struct {
+	ActiveWorkers      int64  `json:"activeWorkers"`
+	InFlightRequests   int    `json:"inFlightRequests"`
+	OldestRequestKind  string `json:"oldestRequestKind,omitempty"`
+	OldestRequestAge   string `json:"oldestRequestAge,omitempty"`
+	OldestRequestAgeMs int64  `json:"oldestRequestAgeMs"`
+}
+
+// ConfigResponse is the effective configuration of the running service: the
+// environment values and defaults with runtime settings applied. Credentials
+// (database and SSH users and passwords, the JWT secret and API keys) are never
+// included; durations are Go duration strings.
+type ConfigResponse struct {
+	Server     ServerConfigInfo     `json:"server"`
+	Database   DatabaseConfigInfo   `json:"database"`
+	SSH        SSHConfigInfo        `json:"ssh"`
+	Auth       AuthConfigInfo       `json:"auth"`
+	Sites      SitesConfigInfo      `json:"sites"`
+	Closing    ClosingConfigInfo    `json:"closing"`
+	Cumulative CumulativeConfigInfo `json:"cumulative"`
+	Sensors    SensorsConfigInfo    `json:"sensors"`
+	Dashboard  DashboardConfigInfo  `json:"dashboard"`
+	Features   FeaturesConfigInfo   `json:"features"`
+	Audit      AuditConfigInfo      `json:"audit"`
+	Webhooks   WebhooksConfigInfo   `json:"webhooks"`
+	Pagination PaginationConfigInfo `json:"pagination"`
+	SMTP       SMTPConfigInfo       `json:"smtp"`
+	RateLimit  RateLimitConfigInfo  `json:"rateLimit"`
+	Timestamp  string               `json:"timestamp"`
+}
+
+type ServerConfigInfo struct {
+	Port           int    `json:"port"`
+	Environment    string `json:"environment"`
+	BasePath       string `json:"basePath"`
+	LogLevel       string `json:"logLevel"`
+	Timezone       string `json:"timezone"`
+	WriteTimeout   string `json:"writeTimeout"`
+	RequestTimeout string `json:"requestTimeout"`
+}
+
+type DatabaseConfigInfo struct {
+	Host               string `json:"host"`
+	Port               int    `json:"port"`
+	Name               string `json:"name"`
+	MaxOpenConns       int    `json:"maxOpenConns"`
+	MaxIdleConns       int    `json:"maxIdleConns"`
+	ConnMaxLifetime    string `json:"connMaxLifetime"`
+	ConnMaxIdleTime    string `json:"connMaxIdleTime"`
+	ConnectAttempts    int    `json:"connectAttempts"`
+	ConnectBackoff     string `json:"connectBackoff"`
+	SlowQueryThreshold string `json:"slowQueryThreshold"`
+}
+
+type SSHConfigInfo struct {
+	Host           string `json:"host"`
+	RemoteBindHost string `json:"remoteBindHost"`
+	RemoteBindPort int    `json:"remoteBindPort"`
+	LocalPort      int    `json:"localPort"`
+}
+
+// AuthConfigInfo reports whether an introspection API key is set, never the key
+type AuthConfigInfo struct {
+	TokenExpiresIn             string `json:"tokenExpiresIn"`
+	RecheckUser                string `json:"recheckUser"`
+	RecheckTTL                 string `json:"recheckTtl"`
+	IntrospectionKeyConfigured bool   `json:"introspectionKeyConfigured"`
+	ReportDisabledAccounts     bool   `json:"reportDisabledAccounts"`
+}
+
+type SitesConfigInfo struct {
+	DeviceIDPrefix   string `json:"deviceIdPrefix"`
+	NameStyle        string `json:"nameStyle"`
+	LocationTemplate string `json:"locationTemplate"`
+}
+
+type ClosingConfigInfo struct {
+	Cutoff string `json:"cutoff"`
+}
+
+type CumulativeConfigInfo struct {
+	RangeGroupedQuery           bool    `json:"rangeGroupedQuery"`
+	RangeBatchSize              int     `json:"rangeBatchSize"`
+	RangeMaxRows                int     `json:"rangeMaxRows"`
+	RangeCacheMaxAge            string  `json:"rangeCacheMaxAge"`
+	NoGeneratorNoiseThreshold   float64 `json:"noGeneratorNoiseThreshold"`
+	TransactionalBatches        bool    `json:"transactionalBatches"`
+	ScheduleEnabled             bool    `json:"scheduleEnabled"`
+	ScheduleTime                string  `json:"scheduleTime"`
+	ScheduleBackfillDays        int     `json:"scheduleBackfillDays"`
+	EfficiencyMinGeneratorHours float64 `json:"efficiencyMinGeneratorHours"`
+}
+
+type SensorsConfigInfo struct {
+	FrozenWindow         string   `json:"frozenWindow"`
+	OnStateValues        []string `json:"onStateValues"`
+	TemperatureSensors   []string `json:"temperatureSensors"`
+	LongRuntimeThreshold string   `json:"longRuntimeThreshold"`
+	DailyDropThreshold   float64  `json:"dailyDropThreshold"`
+}
+
+type DashboardConfigInfo struct {
+	RealtimeWorkers       int     `json:"realtimeWorkers"`
+	ClosingWorkers        int     `json:"closingWorkers"`
+	CollectTimeout        string  `json:"collectTimeout"`
+	SoftDeadline          string  `json:"softDeadline"`
+	RequestTimeout        string  `json:"requestTimeout"`
+	LowFuelThreshold      float64 `json:"lowFuelThreshold"`
+	CriticalFuelThreshold float64 `json:"criticalFuelThreshold"`
+	CriticalFuelDuration  string  `json:"criticalFuelDuration"`
+	ActivityLimit         int     `json:"activityLimit"`
+	ActivityWindow        string  `json:"activityWindow"`
+}
+
+type FeaturesConfigInfo struct {
+	Introspection bool `json:"introspection"`
+	Leaderboard   bool `json:"leaderboard"`
+	SensorQuality bool `json:"sensorQuality"`
+	RawReadings   bool `json:"rawReadings"`
+	AdminTools    bool `json:"adminTools"`
+	Alerting      bool `json:"alerting"`
+}
+
+type AuditConfigInfo struct {
+	LogFailedLogins bool `json:"logFailedLogins"`
+}
+
+type PaginationConfigInfo struct {
+	DefaultPageSize int `json:"defaultPageSize"`
+	MaxPageSize     int `json:"maxPageSize"`
+}
+
+type SMTPConfigInfo struct {
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	From    string `json:"from"`
+	Timeout string `json:"timeout"`
+}
+
+type RateLimitConfigInfo struct {
+	Dashboard       int     `json:"dashboard"`
+	Cumulative      int     `json:"cumulative"`
+	AdminMultiplier float64 `json:"adminMultiplier"`
+}
+
+type WebhooksConfigInfo struct {
+	PollInterval string `json:"pollInterval"`
+	Timeout      string `json:"timeout"`
+	MaxAttempts  int    `json:"maxAttempts"`
+	RetryBackoff string `json:"retryBackoff"`
+}
+
+// CumulativeStatusResponse represents the processing status of every accessible site for a date
+type CumulativeStatusResponse struct {
+	Date string `json:"date"`
+	// CalcVersion is the current CumulativeCalcVersion
+	CalcVersion int                     `json:"calcVersion"`
+	Sites       []*CumulativeSiteStatus `json:"sites"`
+	Summary     CumulativeStatusSummary `json:"summary"`
+}
+
+// CumulativeSiteStatus represents whether a site was processed for a date
+type CumulativeSiteStatus struct {
+	SiteID       int        `json:"siteId"`
+	SiteName     string     `json:"siteName"`
+	DeviceID     string     `json:"deviceId"`
+	Processed    bool       `json:"processed"`
+	CalculatedAt *time.Time `json:"calculatedAt"`
+	CalcVersion  *int       `json:"calcVersion"`
+	// Outdated is set when the stored reading predates CumulativeCalcVersion
+	Outdated  bool       `json:"outdated"`
+	HasError  bool       `json:"hasError"`
+	Error     string     `json:"error,omitempty"`
+	ErroredAt *time.Time `json:"erroredAt,omitempty"`
+}
+
+// CumulativeStatusSummary represents processing status counts for a date
+type CumulativeStatusSummary struct {
+	TotalSites     int `json:"totalSites"`
+	ProcessedSites int `json:"processedSites"`
+	ErrorSites     int `json:"errorSites"`
+	PendingSites   int `json:"pendingSites"`
+	OutdatedSites  int `json:"outdatedSites"`
+}
+
+// FrozenSensor represents a sensor whose value has not changed for longer than the frozen window
+type FrozenSensor struct {
+	SiteID      int       `json:"siteId"`
+	SiteName    string    `json:"siteName"`
+	DeviceID    string    `json:"deviceId"`
+	SensorName  string    `json:"sensorName"`
+	Value       string    `json:"value"`
+	FrozenSince time.Time `json:"frozenSince"`
+	LastSeen    time.Time `json:"lastSeen"`
+	FrozenHours float64   `json:"frozenHours"`
+}
+
+// FrozenSensorsResponse represents the frozen sensor check result
+type FrozenSensorsResponse struct {
+	Window  string          `json:"window"`
+	Sensors []*FrozenSensor `json:"sensors"`
+}
+
+// CumulativeByDateResponse represents stored cumulative readings for selected sites on a date
+type CumulativeByDateResponse struct {
+	Date  string                  `json:"date"`
+	Sites []CumulativeByDateEntry `json:"sites"`
+}
+
+// CumulativeByDateEntry represents a requested site and its stored reading, if any.
+// Reading is a *CumulativeReading, or a LegacyCumulativeReading when the legacy
+// string format is requested.
+type CumulativeByDateEntry struct {
+	SiteID   int         `json:"siteId"`
+	SiteName string      `json:"siteName"`
+	DeviceID string      `json:"deviceId"`
+	HasData  bool        `json:"hasData"`
+	Reading  interface{} `json:"reading"`
+}
+
+// TimedValue represents a raw sensor value and the time it was captured
+type TimedValue struct {
+	Value string    `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// FuelLevelAtResponse represents a site's fuel readings as of a point in time
+type FuelLevelAtResponse struct {
+	SiteID      int         `json:"siteId"`
+	SiteName    string      `json:"siteName"`
+	DeviceID    string      `json:"deviceId"`
+	RequestedAt time.Time   `json:"requestedAt"`
+	FuelLevel   *TimedValue `json:"fuelLevel"`
+	FuelVolume  *TimedValue `json:"fuelVolume"`
+}
+
+// RawSensorReading represents a single row from sensor_readings
+type RawSensorReading struct {
+	SensorName string    `json:"sensorName"`
+	Value      string    `json:"value"`
+	Time       time.Time `json:"time"`
+}
+
+// LatestSensorValue represents the latest raw value of one sensor on a site's device
+type LatestSensorValue struct {
+	SiteID     int       `json:"siteId"`
+	DeviceID   string    `json:"deviceId"`
+	SensorName string    `json:"sensorName"`
+	Value      string    `json:"value"`
+	Time       time.Time `json:"time"`
+}
+
+// RecentSensorReadings represents the latest raw readings of one sensor on a site's device, newest first
+type RecentSensorReadings struct {
+	SiteID     int                `json:"siteId"`
+	DeviceID   string             `json:"deviceId"`
+	SensorName string             `json:"sensorName"`
+	Limit      int                `json:"limit"`
+	Readings   []RawSensorReading `json:"readings"`
+}
+
+// SensorReadingsPage represents one cursor-paginated page of raw sensor readings
+type SensorReadingsPage struct {
+	SiteID     int                 `json:"siteId"`
+	DeviceID   string              `json:"deviceId"`
+	Sensors    []string            `json:"sensors"`
+	Readings   []*RawSensorReading `json:"readings"`
+	NextCursor string              `json:"nextCursor"` // empty when there is no more data
+	HasMore    bool                `json:"hasMore"`
+}
+
+// Setting represents a runtime-tunable setting and its effective value
+type Setting struct {
+	Key          string  `json:"key"`
+	Description  string  `json:"description"`
+	Value        float64 `json:"value"`
+	DefaultValue float64 `json:"defaultValue"`
+	Min          float64 `json:"min"`
+	Max          float64 `json:"max"`
+	Integer      bool    `json:"integer"`
+	Source       string  `json:"source"` // "database" or "default"
+}
+
+// SettingsResponse represents the list of runtime settings
+type SettingsResponse struct {
+	Settings []Setting `json:"settings"`
+	Warning  string    `json:"warning,omitempty"`
+}
+
+// UpdateSettingsRequest maps setting keys to new values; null resets a setting to its default
+type UpdateSettingsRequest struct {
+	Settings map[string]*float64 `json:"settings" binding:"required"`
+}
+
+// DeviceSensor represents a sensor a device reports, optionally with its latest reading
+type DeviceSensor struct {
+	SensorName  string     `json:"sensorName"`
+	LatestValue *string    `json:"latestValue,omitempty"`
+	LatestTime  *time.Time `json:"latestTime,omitempty"`
+}
+
+// DeviceSensorsResponse represents the sensors available for a site's device
+type DeviceSensorsResponse struct {
+	SiteID   int             `json:"siteId"`
+	DeviceID string          `json:"deviceId"`
+	Sensors  []*DeviceSensor `json:"sensors"`
+}
+
+// LoginEvent represents a single login attempt
+type LoginEvent struct {
+	ID        int64     `json:"id"`
+	UserID    *int      `json:"userId"`
+	Username  string    `json:"username"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"` // why a failed attempt was rejected
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LoginHistoryResponse represents one page of a user's login history
+type LoginHistoryResponse struct {
+	UserID     int           `json:"userId"`
+	Events     []*LoginEvent `json:"events"`
+	NextCursor string        `json:"nextCursor"` // pass as ?before= for older events; empty at the end
+	HasMore    bool          `json:"hasMore"`
+}
+
+// GeneratorRun represents a generator that is currently running continuously
+type GeneratorRun struct {
+	SiteID            int       `json:"siteId"`
+	SiteName          string    `json:"siteName"`
+	DeviceID          string    `json:"deviceId"`
+	RunningSince      time.Time `json:"runningSince"`
+	LastSeen          time.Time `json:"lastSeen"`
+	RunningHours      float64   `json:"runningHours"`
+	StartBeforeWindow bool      `json:"startBeforeWindow"` // the run started before the lookback window; runningSince is a lower bound
+}
+
+// LongRuntimeAlertsResponse represents generators running continuously beyond a threshold
+type LongRuntimeAlertsResponse struct {
+	ThresholdHours float64         `json:"thresholdHours"`
+	Sites          []*GeneratorRun `json:"sites"`
+}
+
+// VolumePoint represents the last fuel volume reading within a time bucket
+type VolumePoint struct {
+	Bucket      time.Time `json:"bucket"`
+	Volume      float64   `json:"volume"`
+	ReadingTime time.Time `json:"readingTime"`
+}
+
+// VolumeSeriesResponse represents a site's bucketed fuel volume series
+type VolumeSeriesResponse struct {
+	SiteID   int            `json:"siteId"`
+	DeviceID string         `json:"deviceId"`
+	Start    time.Time      `json:"start"`
+	End      time.Time      `json:"end"`
+	Interval string         `json:"interval"`
+	Points   []*VolumePoint `json:"points"`
+}
+
+// StateTransition represents a detected change in a device's generator, zesa or fuel state
+type StateTransition struct {
+	DeviceID   string    `json:"deviceId"`
+	SensorName string    `json:"sensorName"`
+	On         bool      `json:"on"`        // new state for generator_state/zesa_state
+	FuelLevel  float64   `json:"fuelLevel"` // level reached for fuel_sensor_level low fuel crossings
+	Time       time.Time `json:"time"`
+	ReadingID  int64     `json:"-"` // the sensor reading that made the transition
+}
+
+// TransitionCursor is a position in the (time, reading ID) order transitions
+// are paged through
+type TransitionCursor struct {
+	Time      time.Time
+	ReadingID int64
+}
+
+// Cursor returns the position just at this transition
+func (t *StateTransition) Cursor() TransitionCursor {
+	return TransitionCursor{Time: t.Time, ReadingID: t.ReadingID}
+}
+
+// RuntimeForecastResponse represents how long a site's remaining fuel is expected to last.
+// Forecast fields are nil when there is not enough history (or no burn) to estimate them.
+type RuntimeForecastResponse struct {
+	SiteID                  int        `json:"siteId"`
+	SiteName                string     `json:"siteName"`
+	HistoryDays             int        `json:"historyDays"`
+	FuelConsumed            float64    `json:"fuelConsumed"`
+	GeneratorHours          float64    `json:"generatorHours"`
+	LitersPerGeneratorHour  *float64   `json:"litersPerGeneratorHour"`
+	AverageDailyRuntime     *float64   `json:"averageDailyRuntime"`
+	CurrentVolume           *float64   `json:"currentVolume"`
+	VolumeCapturedAt        *time.Time `json:"volumeCapturedAt"`
+	RemainingGeneratorHours *float64   `json:"remainingGeneratorHours"`
+	ProjectedEmptyDate      *string    `json:"projectedEmptyDate"`
+	Message                 string     `json:"message,omitempty"`
+}
+
+// UserPreferences represents a user's free-form preferences blob, stored and returned verbatim
+type UserPreferences struct {
+	Preferences json.RawMessage `json:"preferences"`
+	UpdatedAt   *time.Time      `json:"updatedAt"`
+}
+
+// LocationTotals represents cumulative totals for all sites sharing a location
+type LocationTotals struct {
+	Location            string  `json:"location"`
+	SiteCount           int     `json:"siteCount"`
+	ReadingDays         int     `json:"readingDays"`
+	TotalFuelConsumed   float64 `json:"totalFuelConsumed"`
+	TotalFuelTopped     float64 `json:"totalFuelTopped"`
+	TotalGeneratorHours float64 `json:"totalGeneratorHours"`
+	TotalZesaHours      float64 `json:"totalZesaHours"`
+	TotalOfflineHours   float64 `json:"totalOfflineHours"`
+}
+
+// CumulativeByLocationResponse represents cumulative totals grouped by location
+type CumulativeByLocationResponse struct {
+	DateRange DateRange         `json:"dateRange"`
+	Locations []*LocationTotals `json:"locations"`
+}
+
+// CumulativeMatrixRow represents one site's daily values aligned to the matrix date axis.
+// Days without a stored reading are null.
+type CumulativeMatrixRow struct {
+	SiteID   int        `json:"siteId"`
+	SiteName string     `json:"siteName"`
+	Location string     `json:"location"`
+	Values   []*float64 `json:"values"`
+}
+
+// CumulativeMatrixResponse represents a sites-by-days matrix of one cumulative metric
+type CumulativeMatrixResponse struct {
+	Metric string                `json:"metric"`
+	Dates  []string              `json:"dates"`
+	Sites  []CumulativeMatrixRow `json:"sites"`
+}
+
+// CumulativeAvailableDatesResponse represents the days of a range with stored cumulative readings
+type CumulativeAvailableDatesResponse struct {
+	StartDate string   `json:"startDate"`
+	EndDate   string   `json:"endDate"`
+	Dates     []string `json:"dates"`
+}
+
+// OutdatedCumulativeResponse lists the stored cumulative readings in a range
+// computed before the current calculation version. Dates and SiteIDs are the
+// distinct days and sites involved, ready to be recomputed.
+type OutdatedCumulativeResponse struct {
+	DateRange   DateRange                    `json:"dateRange"`
+	CalcVersion int                          `json:"calcVersion"`
+	Total       int                          `json:"total"`
+	Dates       []string                     `json:"dates"`
+	SiteIDs     []int                        `json:"siteIds"`
+	Readings    []*OutdatedCumulativeReading `json:"readings"`
+}
+
+// OutdatedCumulativeReading is a site's stored day computed under an older calculation version
+type OutdatedCumulativeReading struct {
+	SiteID       int       `json:"siteId"`
+	SiteName     string    `json:"siteName"`
+	DeviceID     string    `json:"deviceId"`
+	Date         string    `json:"date"`
+	CalcVersion  int       `json:"calcVersion"`
+	CalculatedAt time.Time `json:"calculatedAt"`
+}
+
+// EmailReportRequest represents a request to email a consumption report. Daily
+// reports cover Date; weekly reports cover StartDate to EndDate, or the 7 days
+// ending on Date. Date defaults to yesterday. Only admins may set Recipients.
+type EmailReportRequest struct {
+	Type       string   `json:"type" binding:"required,oneof=daily weekly"`
+	Date       string   `json:"date"`
+	StartDate  string   `json:"startDate"`
+	EndDate    string   `json:"endDate"`
+	Recipients []string `json:"recipients" binding:"omitempty,max=20,dive,email"`
+}
+
+// EmailReportResponse acknowledges a report queued for email delivery
+type EmailReportResponse struct {
+	Status     string   `json:"status"` // "queued"
+	Type       string   `json:"type"`
+	StartDate  string   `json:"startDate"`
+	EndDate    string   `json:"endDate"`
+	Sites      int      `json:"sites"`
+	Recipients []string `json:"recipients"`
+}
+
+// CumulativeRebuildDay represents one recomputed day of a site history rebuild
+type CumulativeRebuildDay struct {
+	Date           string  `json:"date"`
+	Status         string  `json:"status"` // "CREATED", "UPDATED", "PARTIAL", "ERROR"; dry runs report "WOULD_CREATE" or "WOULD_UPDATE"
+	FuelConsumed   float64 `json:"fuelConsumed"`
+	FuelTopped     float64 `json:"fuelTopped"`
+	GeneratorHours float64 `json:"generatorHours"`
+	ZesaHours      float64 `json:"zesaHours"`
+	OfflineHours   float64 `json:"offlineHours"`
+	Error          string  `json:"error,omitempty"`
+
+	// MetricsInconsistent flags fuel metrics whose level and volume disagree
+	MetricsInconsistent bool `json:"metricsInconsistent"`
+}
+
+// CumulativeRebuildSummary represents summary counts for a site history rebuild
+type CumulativeRebuildSummary struct {
+	DaysProcessed int `json:"daysProcessed"`
+	Created       int `json:"created"`
+	Updated       int `json:"updated"`
+	Partial       int `json:"partial"`
+	Errors        int `json:"errors"`
+}
+
+// SiteCumulativeRebuildResponse represents the result of rebuilding a site's cumulative history
+type SiteCumulativeRebuildResponse struct {
+	SiteID    int                      `json:"siteId"`
+	SiteName  string                   `json:"siteName"`
+	DeviceID  string                   `json:"deviceId"`
+	StartDate string                   `json:"startDate"`
+	EndDate   string                   `json:"endDate"`
+	DryRun    bool                     `json:"dryRun"`
+	Days      []CumulativeRebuildDay   `json:"days"`
+	Summary   CumulativeRebuildSummary `json:"summary"`
+}
+
+// DailyClosingLevel represents a site's closing fuel level for one day together
+// with that day's stored generator runtime (nil when not calculated)
+type DailyClosingLevel struct {
+	Date           string
+	FuelLevel      float64
+	CapturedAt     time.Time
+	GeneratorHours *float64
+}
+
+// DailyDelta represents one day's closing fuel level and its change since the
+// previous day's closing. Suspicious marks a drop beyond the threshold on a day
+// the generator logged no runtime.
+type DailyDelta struct {
+	Date             string     `json:"date"`
+	ClosingFuelLevel *float64   `json:"closingFuelLevel"`
+	CapturedAt       *time.Time `json:"capturedAt"`
+	Delta            *float64   `json:"delta"`
+	GeneratorHours   *float64   `json:"generatorHours"`
+	Suspicious       bool       `json:"suspicious"`
+}
+
+// DailyDeltasResponse represents day-over-day closing fuel changes for a site
+type DailyDeltasResponse struct {
+	SiteID         int          `json:"siteId"`
+	SiteName       string       `json:"siteName"`
+	DateRange      DateRange    `json:"dateRange"`
+	Threshold      float64      `json:"threshold"`
+	SuspiciousDays int          `json:"suspiciousDays"`
+	Days           []DailyDelta `json:"days"`
+}
+
+// Alert types delivered to webhooks
+const (
+	AlertGeneratorOn  = "generator_on"
+	AlertGeneratorOff = "generator_off"
+	AlertZesaOn       = "zesa_on"
+	AlertZesaOff      = "zesa_off"
+	AlertLowFuel      = "low_fuel"
+	AlertCriticalFuel = "critical_fuel"
+)
+
+// AlertTypes lists every alert type a webhook can subscribe to
+var AlertTypes = []string{AlertGeneratorOn, AlertGeneratorOff, AlertZesaOn, AlertZesaOff, AlertLowFuel, AlertCriticalFuel}
+
+// AlertOffline marks a site whose device reports no fuel level. It is shown as an
+// active alert but not delivered to webhooks.
+const AlertOffline = "offline"
+
+// AlertTimedOut marks a dashboard site whose reading was not fetched before the
+// soft deadline; its real status is unknown
+const AlertTimedOut = "timed_out"
+
+// AcknowledgeableAlerts lists the alert types an operator can acknowledge
+var AcknowledgeableAlerts = []string{AlertLowFuel, AlertCriticalFuel, AlertGeneratorOff, AlertOffline}
+
+// AlertAcknowledgement records an operator handling a site's alert. It stays
+// active, suppressing webhook re-notification of that alert, until the alert
+// clears; ClearedAt is then set and a recurrence alerts again.
+type AlertAcknowledgement struct {
+	ID             int        `json:"id"`
+	SiteID         int        `json:"siteId"`
+	AlertType      string     `json:"alertType"`
+	Note           string     `json:"note"`
+	AcknowledgedBy *int       `json:"acknowledgedBy"`
+	Username       string     `json:"username"`
+	AcknowledgedAt time.Time  `json:"acknowledgedAt"`
+	ClearedAt      *time.Time `json:"clearedAt"`
+}
+
+// AcknowledgeAlertRequest represents a request to acknowledge a site's current alert
+type AcknowledgeAlertRequest struct {
+	SiteID    int    `json:"siteId" binding:"required"`
+	AlertType string `json:"alertType" binding:"required,oneof=low_fuel critical_fuel generator_off offline"`
+	Note      string `json:"note" binding:"max=500"`
+}
+
+// BulkAcknowledgeAlertsRequest represents a request to acknowledge the same alert
+// at several sites. Without SiteIDs every accessible site in the alert is acknowledged.
+type BulkAcknowledgeAlertsRequest struct {
+	SiteIDs   []int  `json:"siteIds" binding:"omitempty,max=1000,dive,gt=0"`
+	AlertType string `json:"alertType" binding:"required,oneof=low_fuel critical_fuel generator_off offline"`
+	Note      string `json:"note" binding:"max=500"`
+}
+
+// BulkAcknowledgeAlertsResponse reports the outcome of a bulk acknowledgement.
+// NotAlerting and NotFound list requested sites that are not in the alert or
+// not accessible; both stay empty when no sites were listed.
+type BulkAcknowledgeAlertsResponse struct {
+	AlertType           string `json:"alertType"`
+	Acknowledged        int    `json:"acknowledged"`
+	AlreadyAcknowledged int    `json:"alreadyAcknowledged"`
+	SiteIDs             []int  `json:"siteIds"`
+	NotAlerting         []int  `json:"notAlerting"`
+	NotFound            []int  `json:"notFound"`
+	Unavailable         []int  `json:"unavailable"` // listed sites whose reading could not be queried
+}
+
+// AlertPreviewSite is a site that would be low on fuel under a proposed threshold
+type AlertPreviewSite struct {
+	SiteID              int     `json:"siteId"`
+	SiteName            string  `json:"siteName"`
+	DeviceID            string  `json:"deviceId"`
+	FuelLevelPercentage float64 `json:"fuelLevelPercentage"`
+	CurrentStatus       string  `json:"currentStatus"`
+	// NewlyAlerting is set when the site is not low on fuel today
+	NewlyAlerting bool `json:"newlyAlerting"`
+	// SiteThreshold is set when the site's own threshold applies instead of the proposed one
+	SiteThreshold bool `json:"siteThreshold"`
+}
+
+// AlertPreviewResponse lists the sites that would be low on fuel under a
+// proposed global threshold, compared with the current one
+type AlertPreviewResponse struct {
+	Threshold        float64            `json:"threshold"`
+	CurrentThreshold float64            `json:"currentThreshold"`
+	Sites            []AlertPreviewSite `json:"sites"`
+	LowFuel          int                `json:"lowFuel"`
+	NewlyAlerting    int                `json:"newlyAlerting"`
+	NoLongerAlerting int                `json:"noLongerAlerting"`
+}
+
+// ActiveAlert represents a site's current alert and whether it was acknowledged
+type ActiveAlert struct {
+	SiteID              int                   `json:"siteId"`
+	SiteName            string                `json:"siteName"`
+	DeviceID            string                `json:"deviceId"`
+	AlertType           string                `json:"alertType"`
+	FuelLevelPercentage *float64              `json:"fuelLevelPercentage"`
+	Acknowledged        bool                  `json:"acknowledged"`
+	Acknowledgement     *AlertAcknowledgement `json:"acknowledgement,omitempty"`
+}
+
+// ActiveAlertsResponse represents the current alerts of the accessible sites
+type ActiveAlertsResponse struct {
+	Alerts       []ActiveAlert `json:"alerts"`
+	Total        int           `json:"total"`
+	Acknowledged int           `json:"acknowledged"`
+}
+
+// Webhook payload priorities
+const (
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// AlertType returns the webhook alert type for a state transition
+func (t *StateTransition) AlertType() string {
+	switch t.SensorName {
+	case "generator_state":
+		if t.On {
+			return AlertGeneratorOn
+		}
+		return AlertGeneratorOff
+	case "zesa_state":
+		if t.On {
+			return AlertZesaOn
+		}
+		return AlertZesaOff
+	default:
+		return AlertLowFuel
+	}
+}
+
+// Webhook represents an outgoing alert webhook. An empty AlertTypes subscribes to every type.
+type Webhook struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	AlertTypes []string  `json:"alertTypes"`
+	IsActive   bool      `json:"isActive"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Secret     string    `json:"-"`
+}
+
+// Matches reports whether the webhook is subscribed to the alert type
+func (w *Webhook) Matches(alertType string) bool {
+	if len(w.AlertTypes) == 0 {
+		return true
+	}
+	for _, subscribed := range w.AlertTypes {
+		if subscribed == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookRequest represents a request to register a webhook. A secret is
+// generated when none is given.
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required,url"`
+	AlertTypes []string `json:"alertTypes"`
+	Secret     string   `json:"secret"`
+}
+
+// CreateWebhookResponse represents a newly registered webhook. The signing
+// secret is only ever returned here.
+type CreateWebhookResponse struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookPayload represents the JSON body POSTed to webhooks
+type WebhookPayload struct {
+	Event     string    `json:"event"` // "alert", or "test" for test-fire
+	AlertType string    `json:"alertType"`
+	Priority  string    `json:"priority"` // "high" for critical_fuel, otherwise "normal"
+	SiteID    int       `json:"siteId"`
+	SiteName  string    `json:"siteName"`
+	DeviceID  string    `json:"deviceId"`
+	Value     string    `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebhookDeliveryResponse represents the outcome of a test-fire
+type WebhookDeliveryResponse struct {
+	Delivered  bool   `json:"delivered"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
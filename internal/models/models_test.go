@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCumulativeReadingJSON(t *testing.T) {
+	at := time.Date(2024, 3, 2, 1, 0, 0, 0, time.UTC)
+	reading := &CumulativeReading{
+		ID: 7, SiteID: 3, DeviceID: "simbisa-a", Date: "2024-03-01",
+		TotalFuelConsumed: 120.456, TotalFuelTopped: 0, FuelConsumedPercent: 12.5, FuelToppedPercent: 0,
+		TotalGeneratorRuntime: 3.333, TotalZesaRuntime: 20.666, TotalOfflineTime: 0.001,
+		GeneratorStarts: 2, CalcVersion: 3, CalculatedAt: at, CreatedAt: at,
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"numbers", reading, `{"id":7,"siteId":3,"deviceId":"simbisa-a","date":"2024-03-01",` +
+			`"totalFuelConsumed":120.456,"totalFuelTopped":0,"fuelConsumedPercent":12.5,"fuelToppedPercent":0,` +
+			`"totalGeneratorRuntime":3.333,"totalZesaRuntime":20.666,"totalOfflineTime":0.001,"generatorStarts":2,"calcVersion":3,` +
+			`"calculatedAt":"2024-03-02T01:00:00Z","createdAt":"2024-03-02T01:00:00Z"}`},
+		{"legacy strings", reading.ToLegacy(), `{"id":7,"siteId":3,"deviceId":"simbisa-a","date":"2024-03-01",` +
+			`"totalFuelConsumed":"120.46","totalFuelTopped":"0.00","fuelConsumedPercent":"12.50","fuelToppedPercent":"0.00",` +
+			`"totalGeneratorRuntime":"3.33","totalZesaRuntime":"20.67","totalOfflineTime":"0.00","calcVersion":3,` +
+			`"calculatedAt":"2024-03-02T01:00:00Z","createdAt":"2024-03-02T01:00:00Z"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("JSON = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSiteInMaintenance(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(hours int) *time.Time {
+		t := now.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+
+	tests := []struct {
+		name string
+		site Site
+		want bool
+	}{
+		{"off", Site{}, false},
+		{"off with window", Site{MaintenanceStart: at(-1), MaintenanceEnd: at(1)}, false},
+		{"open ended", Site{MaintenanceMode: true}, true},
+		{"inside window", Site{MaintenanceMode: true, MaintenanceStart: at(-1), MaintenanceEnd: at(1)}, true},
+		{"starts now", Site{MaintenanceMode: true, MaintenanceStart: at(0)}, true},
+		{"not started", Site{MaintenanceMode: true, MaintenanceStart: at(1)}, false},
+		{"ends now", Site{MaintenanceMode: true, MaintenanceEnd: at(0)}, false},
+		{"ended", Site{MaintenanceMode: true, MaintenanceStart: at(-3), MaintenanceEnd: at(-1)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.site.InMaintenance(now); got != tt.want {
+				t.Errorf("InMaintenance = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+	"sync"
+)
+
+// defaultOnStateValues are the raw sensor values that mean a generator or zesa is on
+var defaultOnStateValues = []string{"1", "1.0", "on", "true"}
+
+var (
+	onStateMu     sync.RWMutex
+	onStateValues = normalizeStateValues(defaultOnStateValues)
+)
+
+// SetOnStateValues replaces the set of values treated as "on". Values are
+// compared case-insensitively after trimming; an empty list restores the defaults.
+func SetOnStateValues(values []string) {
+	normalized := normalizeStateValues(values)
+	if len(normalized) == 0 {
+		normalized = normalizeStateValues(defaultOnStateValues)
+	}
+
+	onStateMu.Lock()
+	onStateValues = normalized
+	onStateMu.Unlock()
+}
+
+// OnStateValues returns the normalized values treated as "on"
+func OnStateValues() []string {
+	onStateMu.RLock()
+	defer onStateMu.RUnlock()
+
+	values := make([]string, len(onStateValues))
+	copy(values, onStateValues)
+	return values
+}
+
+// ParseState reports whether a raw state value represents "on". It is the single
+// definition of "on" shared by the dashboard and the cumulative calculations.
+func ParseState(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	onStateMu.RLock()
+	defer onStateMu.RUnlock()
+
+	for _, on := range onStateValues {
+		if value == on {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeStateValues(values []string) []string {
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != "" {
+			normalized = append(normalized, value)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestParseState(t *testing.T) {
+	defer SetOnStateValues(nil)
+
+	tests := []struct {
+		name     string
+		onValues []string
+		value    string
+		want     bool
+	}{
+		{"default one", nil, "1", true},
+		{"default one point zero", nil, "1.0", true},
+		{"default on any case", nil, " ON ", true},
+		{"default true", nil, "True", true},
+		{"default zero", nil, "0", false},
+		{"default off", nil, "off", false},
+		{"empty", nil, "", false},
+		{"configured running", []string{"Running", " yes "}, "running", true},
+		{"configured yes", []string{"Running", " yes "}, "YES", true},
+		{"configured replaces defaults", []string{"Running", " yes "}, "1", false},
+		{"blank list restores defaults", []string{" ", ""}, "on", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetOnStateValues(tt.onValues)
+			if got := ParseState(tt.value); got != tt.want {
+				t.Errorf("ParseState(%q) = %t, want %t", tt.value, got, tt.want)
+			}
+		})
+	}
+}
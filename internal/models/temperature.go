@@ -0,0 +1,119 @@
+package models
+
+import (
+	"strings"
+	"sync"
+)
+
+// defaultTemperatureSensors are the names devices report the fuel temperature
+// under, most preferred first
+var defaultTemperatureSensors = []string{"fuel_sensor_temp", "fuel_sensor_temperature"}
+
+var (
+	temperatureMu      sync.RWMutex
+	temperatureSensors = normalizeSensorNames(defaultTemperatureSensors)
+)
+
+// SetTemperatureSensors replaces the ordered preference list of temperature
+// sensor names. When a device reports several of them, the earliest listed one
+// is used. An empty list restores the defaults.
+func SetTemperatureSensors(names []string) {
+	normalized := normalizeSensorNames(names)
+	if len(normalized) == 0 {
+		normalized = normalizeSensorNames(defaultTemperatureSensors)
+	}
+
+	temperatureMu.Lock()
+	temperatureSensors = normalized
+	temperatureMu.Unlock()
+}
+
+// TemperatureSensors returns the temperature sensor names, most preferred first
+func TemperatureSensors() []string {
+	temperatureMu.RLock()
+	defer temperatureMu.RUnlock()
+
+	names := make([]string, len(temperatureSensors))
+	copy(names, temperatureSensors)
+	return names
+}
+
+// IsTemperatureSensor reports whether name is one of TemperatureSensors
+func IsTemperatureSensor(name string) bool {
+	return temperatureRank(name) >= 0
+}
+
+// ReadingSensors are the sensors a SensorReading is assembled from: KnownSensors
+// with the configured temperature sensors in place of the default ones
+func ReadingSensors() []string {
+	sensors := []string{}
+	for _, sensor := range KnownSensors {
+		if !isDefaultTemperatureSensor(sensor) {
+			sensors = append(sensors, sensor)
+		}
+	}
+	return append(sensors, TemperatureSensors()...)
+}
+
+// TemperatureResolver picks the most preferred temperature among the sensor
+// values offered to it, so a device reporting several temperature sensors
+// resolves to the same one everywhere. The zero value is ready to use.
+type TemperatureResolver struct {
+	rank  int
+	value *string
+}
+
+// Offer considers a value reported under sensorName and reports whether
+// sensorName is a temperature sensor
+func (r *TemperatureResolver) Offer(sensorName, value string) bool {
+	rank := temperatureRank(sensorName)
+	if rank < 0 {
+		return false
+	}
+	if r.value == nil || rank < r.rank {
+		r.rank = rank
+		r.value = &value
+	}
+	return true
+}
+
+// Value returns the preferred temperature offered, or nil when none was
+func (r *TemperatureResolver) Value() *string {
+	return r.value
+}
+
+// temperatureRank returns name's position in TemperatureSensors, or -1
+func temperatureRank(name string) int {
+	temperatureMu.RLock()
+	defer temperatureMu.RUnlock()
+
+	for i, sensor := range temperatureSensors {
+		if sensor == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func isDefaultTemperatureSensor(name string) bool {
+	for _, sensor := range defaultTemperatureSensors {
+		if sensor == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeSensorNames trims names and drops empty and repeated ones, keeping order
+func normalizeSensorNames(names []string) []string {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized
+}
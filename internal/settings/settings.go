@@ -0,0 +1,277 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/logger"
+	"fuel-monitor-api/internal/models"
+)
+
+// Keys of the runtime-tunable settings
+const (
+	NoGeneratorNoiseThreshold = "no_generator_noise_threshold"
+	LowFuelThreshold          = "low_fuel_threshold"
+	CriticalFuelThreshold     = "critical_fuel_threshold"
+	FrozenSensorWindowHours   = "frozen_sensor_window_hours"
+	DashboardRealtimeWorkers  = "dashboard_realtime_workers"
+	DashboardClosingWorkers   = "dashboard_closing_workers"
+)
+
+// definition describes a setting, its allowed range and its env/default fallback
+type definition struct {
+	Key         string
+	Description string
+	Min         float64
+	Max         float64
+	Integer     bool
+	Default     func(cfg *config.Config) float64
+}
+
+var definitions = []definition{
+	{
+		Key:         NoGeneratorNoiseThreshold,
+		Description: "Fuel change (percent) treated as noise at sites without a generator; 0 disables the filter",
+		Min:         0,
+		Max:         20,
+		Default:     func(cfg *config.Config) float64 { return cfg.Cumulative.NoGeneratorNoiseThreshold },
+	},
+	{
+		Key:         LowFuelThreshold,
+		Description: "Fuel level (percent) at or below which a site is flagged low_fuel",
+		Min:         0,
+		Max:         100,
+		Default:     func(cfg *config.Config) float64 { return cfg.Dashboard.LowFuelThreshold },
+	},
+	{
+		Key:         CriticalFuelThreshold,
+		Description: "Fuel level (percent) a site must stay at or below for the critical duration to escalate to critical_fuel",
+		Min:         0,
+		Max:         100,
+		Default:     func(cfg *config.Config) float64 { return cfg.Dashboard.CriticalFuelThreshold },
+	},
+	{
+		Key:         FrozenSensorWindowHours,
+		Description: "Hours a fuel level must stay identical before the sensor is flagged as frozen",
+		Min:         1,
+		Max:         168,
+		Default:     func(cfg *config.Config) float64 { return cfg.Sensors.FrozenWindow.Hours() },
+	},
+	{
+		Key:         DashboardRealtimeWorkers,
+		Description: "Concurrent per-site queries for the realtime dashboard",
+		Min:         1,
+		Max:         100,
+		Integer:     true,
+		Default:     func(cfg *config.Config) float64 { return float64(cfg.Dashboard.RealtimeWorkers) },
+	},
+	{
+		Key:         DashboardClosingWorkers,
+		Description: "Concurrent per-site queries for the daily closing dashboard",
+		Min:         1,
+		Max:         100,
+		Integer:     true,
+		Default:     func(cfg *config.Config) float64 { return float64(cfg.Dashboard.ClosingWorkers) },
+	},
+}
+
+// Store caches the settings stored in the database and falls back to the
+// env/default configuration for settings that have not been overridden
+type Store struct {
+	db  *database.DB
+	cfg *config.Config
+
+	mu        sync.RWMutex
+	overrides map[string]float64
+}
+
+// NewStore creates a settings store. Call Refresh to load stored overrides.
+func NewStore(db *database.DB, cfg *config.Config) *Store {
+	return &Store{
+		db:        db,
+		cfg:       cfg,
+		overrides: make(map[string]float64),
+	}
+}
+
+// Refresh reloads the stored overrides into the cache. Unknown keys and
+// values that no longer pass validation are ignored.
+func (s *Store) Refresh() error {
+	stored, err := s.db.GetSettings()
+	if err != nil {
+		return err
+	}
+
+	overrides := make(map[string]float64)
+	for key, raw := range stored {
+		def, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err == nil {
+			err = def.validate(value)
+		}
+		if err != nil {
+			logger.Warnf("Ignoring stored setting %s=%q: %v", key, raw, err)
+			continue
+		}
+		overrides[key] = value
+	}
+
+	s.mu.Lock()
+	s.overrides = overrides
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Float returns the effective value of a setting
+func (s *Store) Float(key string) float64 {
+	s.mu.RLock()
+	value, ok := s.overrides[key]
+	s.mu.RUnlock()
+	if ok {
+		return value
+	}
+
+	if def, ok := lookup(key); ok {
+		return def.Default(s.cfg)
+	}
+	return 0
+}
+
+// Int returns the effective value of an integer setting
+func (s *Store) Int(key string) int {
+	return int(s.Float(key))
+}
+
+// List returns every setting with its effective value and where it came from
+func (s *Store) List() []models.Setting {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Setting, 0, len(definitions))
+	for _, def := range definitions {
+		setting := models.Setting{
+			Key:          def.Key,
+			Description:  def.Description,
+			Value:        def.Default(s.cfg),
+			DefaultValue: def.Default(s.cfg),
+			Min:          def.Min,
+			Max:          def.Max,
+			Integer:      def.Integer,
+			Source:       "default",
+		}
+		if value, ok := s.overrides[def.Key]; ok {
+			setting.Value = value
+			setting.Source = "database"
+		}
+		result = append(result, setting)
+	}
+	return result
+}
+
+// Update validates and stores the given values, then refreshes the cache.
+// A nil value removes the override so the env/default value applies again.
+// Nothing is written when any value is invalid. When the values are stored but
+// the cache cannot be refreshed they are applied to the cache directly and a
+// refresh error is returned; see IsRefreshError.
+func (s *Store) Update(values map[string]*float64, updatedBy int) error {
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		def, ok := lookup(key)
+		if !ok {
+			return validationError(fmt.Sprintf("unknown setting %q", key))
+		}
+		if value != nil {
+			if err := def.validate(*value); err != nil {
+				return validationError(fmt.Sprintf("%s %v", key, err))
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	updates := make(map[string]*string, len(values))
+	for _, key := range keys {
+		if values[key] == nil {
+			updates[key] = nil
+			continue
+		}
+		raw := strconv.FormatFloat(*values[key], 'f', -1, 64)
+		updates[key] = &raw
+	}
+
+	if err := s.db.SaveSettings(updates, updatedBy); err != nil {
+		return err
+	}
+
+	if err := s.Refresh(); err != nil {
+		s.mu.Lock()
+		for key, value := range values {
+			if value == nil {
+				delete(s.overrides, key)
+			} else {
+				s.overrides[key] = *value
+			}
+		}
+		s.mu.Unlock()
+		return refreshError{err: err}
+	}
+
+	return nil
+}
+
+// IsValidationError reports whether err came from validating a setting rather than storing it
+func IsValidationError(err error) bool {
+	var target validationError
+	return errors.As(err, &target)
+}
+
+type validationError string
+
+func (e validationError) Error() string { return string(e) }
+
+// IsRefreshError reports whether err means the settings were stored but the
+// cache could not be reloaded from the database
+func IsRefreshError(err error) bool {
+	var target refreshError
+	return errors.As(err, &target)
+}
+
+type refreshError struct {
+	err error
+}
+
+func (e refreshError) Error() string { return "settings saved but not reloaded: " + e.err.Error() }
+
+func (e refreshError) Unwrap() error { return e.err }
+
+func lookup(key string) (definition, bool) {
+	for _, def := range definitions {
+		if def.Key == key {
+			return def, true
+		}
+	}
+	return definition{}, false
+}
+
+func (d definition) validate(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return validationError("must be a finite number")
+	}
+	if value < d.Min || value > d.Max {
+		return validationError(fmt.Sprintf("must be between %g and %g", d.Min, d.Max))
+	}
+	if d.Integer && value != math.Trunc(value) {
+		return validationError("must be a whole number")
+	}
+	return nil
+}
@@ -3,11 +3,11 @@ package ssh
 import (
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"time"
 
 	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/logger"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -24,28 +24,38 @@ func SetupTunnel(cfg *config.Config) (*ssh.Client, int, error) {
 	}
 
 	// Connect to SSH server
-	log.Printf("Connecting to SSH server: %s:22", cfg.SSH.Host)
+	logger.Infof("Connecting to SSH server: %s:22", cfg.SSH.Host)
 	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:22", cfg.SSH.Host), sshConfig)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to connect to SSH server: %w", err)
 	}
 
-	// Find available local port
-	localPort, err := findAvailablePort()
-	if err != nil {
-		sshClient.Close()
-		return nil, 0, fmt.Errorf("failed to find available port: %w", err)
+	// Use the pinned local port if configured, otherwise find an available one
+	localPort := cfg.SSH.LocalPort
+	if localPort == 0 {
+		localPort, err = findAvailablePort()
+		if err != nil {
+			sshClient.Close()
+			return nil, 0, fmt.Errorf("failed to find available port: %w", err)
+		}
 	}
 
 	// Start local listener
 	localListener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
 	if err != nil {
 		sshClient.Close()
+		if cfg.SSH.LocalPort != 0 {
+			return nil, 0, fmt.Errorf("failed to bind pinned SSH_LOCAL_PORT %d (is it already in use?): %w", localPort, err)
+		}
 		return nil, 0, fmt.Errorf("failed to start local listener: %w", err)
 	}
 
-	log.Printf("SSH tunnel established: local port %d -> %s:%d",
-		localPort, cfg.SSH.RemoteBindHost, cfg.SSH.RemoteBindPort)
+	portMode := "random"
+	if cfg.SSH.LocalPort != 0 {
+		portMode = "pinned"
+	}
+	logger.Infof("SSH tunnel established: local port %d (%s) -> %s:%d",
+		localPort, portMode, cfg.SSH.RemoteBindHost, cfg.SSH.RemoteBindPort)
 
 	// Handle tunnel connections
 	go func() {
@@ -53,7 +63,7 @@ func SetupTunnel(cfg *config.Config) (*ssh.Client, int, error) {
 		for {
 			localConn, err := localListener.Accept()
 			if err != nil {
-				log.Printf("Failed to accept local connection: %v", err)
+				logger.Warnf("Failed to accept local connection: %v", err)
 				continue
 			}
 
@@ -71,7 +81,7 @@ func handleTunnelConnection(sshClient *ssh.Client, localConn net.Conn, remoteHos
 	remoteAddr := fmt.Sprintf("%s:%d", remoteHost, remotePort)
 	remoteConn, err := sshClient.Dial("tcp", remoteAddr)
 	if err != nil {
-		log.Printf("Failed to dial remote address %s: %v", remoteAddr, err)
+		logger.Errorf("Failed to dial remote address %s: %v", remoteAddr, err)
 		return
 	}
 	defer remoteConn.Close()
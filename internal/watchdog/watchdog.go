@@ -0,0 +1,90 @@
+package watchdog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the tracked workers and requests
+type Stats struct {
+	ActiveWorkers    int64
+	InFlightRequests int
+	// OldestKind and OldestAge describe the longest running request; both are
+	// zero when no request is in flight
+	OldestKind string
+	OldestAge  time.Duration
+}
+
+// request is one in-flight request
+type request struct {
+	kind    string
+	started time.Time
+}
+
+// Watchdog counts running worker goroutines and in-flight requests so a stuck
+// worker pool shows up as a growing worker count and an ageing oldest request
+type Watchdog struct {
+	workers atomic.Int64
+
+	mu       sync.Mutex
+	nextID   uint64
+	requests map[uint64]request
+}
+
+// New creates an empty watchdog
+func New() *Watchdog {
+	return &Watchdog{
+		requests: make(map[uint64]request),
+	}
+}
+
+// WorkerStarted records a running worker goroutine; pair it with WorkerFinished
+func (w *Watchdog) WorkerStarted() {
+	w.workers.Add(1)
+}
+
+// WorkerFinished records that a worker goroutine has returned
+func (w *Watchdog) WorkerFinished() {
+	w.workers.Add(-1)
+}
+
+// RequestStarted records an in-flight request of the given kind and returns the
+// function that marks it finished
+func (w *Watchdog) RequestStarted(kind string) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.nextID++
+	id := w.nextID
+	w.requests[id] = request{kind: kind, started: time.Now()}
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.requests, id)
+	}
+}
+
+// Stats returns the current worker and request counts and the oldest request
+func (w *Watchdog) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := Stats{
+		ActiveWorkers:    w.workers.Load(),
+		InFlightRequests: len(w.requests),
+	}
+
+	var oldest time.Time
+	for _, req := range w.requests {
+		if oldest.IsZero() || req.started.Before(oldest) {
+			oldest = req.started
+			stats.OldestKind = req.kind
+		}
+	}
+	if !oldest.IsZero() {
+		stats.OldestAge = time.Since(oldest)
+	}
+	return stats
+}
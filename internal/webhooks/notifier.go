@@ -0,0 +1,295 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fuel-monitor-api/internal/alerts"
+	"fuel-monitor-api/internal/database"
+	"fuel-monitor-api/internal/logger"
+	"fuel-monitor-api/internal/models"
+	"fuel-monitor-api/internal/settings"
+)
+
+// transitionsPerPage is how many state transitions a poll loads and delivers at a time
+const transitionsPerPage = 1000
+
+// Notifier polls for new state transitions and delivers each one to the
+// active webhooks subscribed to its alert type. Each poll also feeds the sites'
+// latest fuel levels to the low fuel escalation and delivers sites that have
+// just become critical as high priority critical_fuel alerts. Alerts an operator
+// has acknowledged are not delivered until they clear and recur.
+type Notifier struct {
+	db         *database.DB
+	settings   *settings.Store
+	sender     *Sender
+	escalation *alerts.FuelEscalation
+	interval   time.Duration
+}
+
+// NewNotifier creates a Notifier that polls every interval
+func NewNotifier(db *database.DB, settingsStore *settings.Store, sender *Sender, escalation *alerts.FuelEscalation, interval time.Duration) *Notifier {
+	return &Notifier{
+		db:         db,
+		settings:   settingsStore,
+		sender:     sender,
+		escalation: escalation,
+		interval:   interval,
+	}
+}
+
+// Run polls until ctx is cancelled. Transitions are delivered once, oldest
+// first: each poll pages forward from where the previous one stopped, even when
+// it failed part way, so readings that arrive with older timestamps than that
+// are not alerted on.
+func (n *Notifier) Run(ctx context.Context) {
+	if n.interval <= 0 {
+		logger.Infof("Webhook delivery disabled")
+		return
+	}
+
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	cursor := models.TransitionCursor{Time: time.Now()}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		if cursor, err = n.poll(ctx, cursor); err != nil {
+			logger.Errorf("Webhook poll failed: %v", err)
+		}
+	}
+}
+
+// poll delivers the transitions after the cursor, oldest first, then the sites
+// escalated to critical fuel. It returns the cursor past the last transition
+// delivered, which has moved even when an error is returned.
+func (n *Notifier) poll(ctx context.Context, cursor models.TransitionCursor) (models.TransitionCursor, error) {
+	started := time.Now()
+
+	webhooks, err := n.db.GetWebhooks(true)
+	if err != nil {
+		return cursor, err
+	}
+
+	sites, err := n.db.GetAllSites()
+	if err != nil {
+		return cursor, err
+	}
+
+	// Site types map sensors to the names their devices report them under
+	siteTypes, err := n.db.GetSiteTypes()
+	if err != nil {
+		logger.Warnf("Failed to get site types, using default sensor names: %v", err)
+		siteTypes = map[int]*models.SiteType{}
+	}
+
+	// Escalation and acknowledgements are tracked even without webhooks so the
+	// dashboard stays current
+	current := n.observeFuelLevels(ctx, sites, siteTypes)
+	escalations := n.escalation.Escalations()
+	acked, err := n.acknowledged(current)
+	if err != nil {
+		return cursor, err
+	}
+	if len(webhooks) == 0 {
+		// Nothing is delivered, so later webhooks start from now
+		return models.TransitionCursor{Time: started}, nil
+	}
+
+	sitesByDevice := make(map[string]*models.Site, len(sites))
+	sitesByID := make(map[int]*models.Site, len(sites))
+	deviceIDs := make([]string, 0, len(sites))
+	for _, site := range sites {
+		sitesByDevice[strings.ToLower(site.DeviceID)] = site
+		sitesByID[site.ID] = site
+		deviceIDs = append(deviceIDs, site.DeviceID)
+	}
+
+	names := models.SiteSensorNames(sites, siteTypes)
+	expected := models.SiteExpectedSensors(sites, siteTypes)
+	lowFuelThreshold := n.settings.Float(settings.LowFuelThreshold)
+	now := time.Now()
+	for ctx.Err() == nil {
+		transitions, err := n.db.GetTransitionsAfter(deviceIDs, names, expected, cursor, lowFuelThreshold, transitionsPerPage)
+		if err != nil {
+			return cursor, err
+		}
+
+		payloads := make([]models.WebhookPayload, 0, len(transitions))
+		for _, transition := range transitions {
+			site, ok := sitesByDevice[transition.DeviceID]
+			if !ok || !site.AlertsEnabled || site.InMaintenance(now) || acked[site.ID][transition.AlertType()] {
+				continue
+			}
+
+			payloads = append(payloads, models.WebhookPayload{
+				Event:     "alert",
+				AlertType: transition.AlertType(),
+				Priority:  models.PriorityNormal,
+				SiteID:    site.ID,
+				SiteName:  site.Name,
+				DeviceID:  site.DeviceID,
+				Value:     transitionValue(transition),
+				Timestamp: transition.Time,
+			})
+		}
+		n.deliver(ctx, webhooks, payloads)
+
+		if len(transitions) > 0 {
+			cursor = transitions[len(transitions)-1].Cursor()
+		}
+		if len(transitions) < transitionsPerPage {
+			break
+		}
+	}
+
+	// An escalation stays pending, and is offered again next poll, until it is
+	// delivered to at least one webhook or acknowledged
+	payloads := make([]models.WebhookPayload, 0, len(escalations))
+	for _, escalation := range escalations {
+		site, ok := sitesByID[escalation.SiteID]
+		if ok && acked[site.ID][models.AlertCriticalFuel] {
+			n.escalation.MarkNotified(site.ID)
+			continue
+		}
+		if !ok || !site.AlertsEnabled || site.InMaintenance(now) {
+			continue
+		}
+
+		payloads = append(payloads, models.WebhookPayload{
+			Event:     "alert",
+			AlertType: models.AlertCriticalFuel,
+			Priority:  models.PriorityHigh,
+			SiteID:    site.ID,
+			SiteName:  site.Name,
+			DeviceID:  site.DeviceID,
+			Value:     fmt.Sprintf("%.1f%%", escalation.Level),
+			Timestamp: escalation.At,
+		})
+	}
+	for i, delivered := range n.deliver(ctx, webhooks, payloads) {
+		if delivered {
+			n.escalation.MarkNotified(payloads[i].SiteID)
+		}
+	}
+
+	return cursor, nil
+}
+
+// observeFuelLevels feeds each site's latest fuel level to the escalation and
+// returns the current alert status of the sites not in maintenance by site ID.
+// Sites whose reading could not be queried are left out.
+func (n *Notifier) observeFuelLevels(ctx context.Context, sites []*models.Site, siteTypes map[int]*models.SiteType) map[int]string {
+	lowFuelThreshold := n.settings.Float(settings.LowFuelThreshold)
+	criticalFuelThreshold := n.settings.Float(settings.CriticalFuelThreshold)
+	current := make(map[int]string, len(sites))
+	for _, site := range sites {
+		if ctx.Err() != nil {
+			return current
+		}
+		var siteType *models.SiteType
+		if site.TypeID != nil {
+			siteType = siteTypes[*site.TypeID]
+		}
+		reading, err := n.db.GetLatestDeviceReading(site.DeviceID, siteType.Names())
+		if err != nil {
+			// Unknown rather than offline, so its acknowledgement is kept
+			logger.Warnf("Failed to get reading for site %s: %v", site.Name, err)
+			continue
+		}
+		critical := reading != nil && reading.FuelLevelParsed &&
+			n.escalation.Observe(site.ID, reading.FuelLevelFloat, criticalFuelThreshold, reading.CapturedAt)
+
+		now := time.Now()
+		if !site.InMaintenance(now) {
+			current[site.ID] = alerts.CurrentStatus(site, reading, siteType, lowFuelThreshold, critical, now)
+		}
+	}
+	return current
+}
+
+// acknowledged clears the acknowledgements of alerts that are no longer current
+// and returns the remaining acknowledged alert types by site ID
+func (n *Notifier) acknowledged(current map[int]string) (map[int]map[string]bool, error) {
+	cleared, err := n.db.ClearResolvedAlertAcknowledgements(current)
+	if err != nil {
+		return nil, err
+	}
+	if cleared > 0 {
+		logger.Infof("Cleared %d alert acknowledgement(s) of resolved alerts", cleared)
+	}
+
+	acks, err := n.db.GetActiveAlertAcknowledgements()
+	if err != nil {
+		return nil, err
+	}
+
+	acked := make(map[int]map[string]bool, len(acks))
+	for _, ack := range acks {
+		if acked[ack.SiteID] == nil {
+			acked[ack.SiteID] = make(map[string]bool)
+		}
+		acked[ack.SiteID][ack.AlertType] = true
+	}
+	return acked, nil
+}
+
+// deliver sends each payload to the webhooks subscribed to its alert type.
+// Every webhook is sent its payloads in order from its own goroutine, so a slow
+// or unreachable endpoint delays only its own deliveries; each request is
+// bounded by WEBHOOK_TIMEOUT. It returns once every webhook is done, reporting
+// for each payload whether at least one webhook accepted it.
+func (n *Notifier) deliver(ctx context.Context, webhooks []*models.Webhook, payloads []models.WebhookPayload) []bool {
+	delivered := make([]bool, len(payloads))
+	if len(payloads) == 0 {
+		return delivered
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, webhook := range webhooks {
+		wg.Add(1)
+		go func(webhook *models.Webhook) {
+			defer wg.Done()
+			for i, payload := range payloads {
+				if ctx.Err() != nil {
+					return
+				}
+				if !webhook.Matches(payload.AlertType) {
+					continue
+				}
+				attempts, _, err := n.sender.Send(ctx, webhook, payload)
+				if err != nil {
+					logger.Warnf("Webhook %d: %s alert for site %s not delivered after %d attempt(s): %v", webhook.ID, payload.AlertType, payload.SiteName, attempts, err)
+					continue
+				}
+				mu.Lock()
+				delivered[i] = true
+				mu.Unlock()
+			}
+		}(webhook)
+	}
+	wg.Wait()
+
+	return delivered
+}
+
+// transitionValue formats the new state or fuel level the way dashboard activity does
+func transitionValue(transition *models.StateTransition) string {
+	if transition.SensorName == "fuel_sensor_level" {
+		return fmt.Sprintf("%.1f%%", transition.FuelLevel)
+	}
+	if transition.On {
+		return "ON"
+	}
+	return "OFF"
+}
@@ -0,0 +1,92 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+)
+
+// recordingEndpoint is a webhook receiver that records the site IDs it was sent, in order
+type recordingEndpoint struct {
+	mu       sync.Mutex
+	siteIDs  []int
+	finished time.Time
+}
+
+func (e *recordingEndpoint) handler(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		var payload models.WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		e.mu.Lock()
+		e.siteIDs = append(e.siteIDs, payload.SiteID)
+		e.finished = time.Now()
+		e.mu.Unlock()
+	}
+}
+
+func TestNotifierDeliver(t *testing.T) {
+	const slowDelay = 100 * time.Millisecond
+
+	fast, slow, dead := &recordingEndpoint{}, &recordingEndpoint{}, &recordingEndpoint{}
+	fastServer := httptest.NewServer(fast.handler(0))
+	defer fastServer.Close()
+	slowServer := httptest.NewServer(slow.handler(slowDelay))
+	defer slowServer.Close()
+	// Answers after the request timeout, so every delivery to it fails
+	deadServer := httptest.NewServer(dead.handler(time.Second))
+	defer deadServer.Close()
+
+	n := &Notifier{sender: NewSender(config.WebhooksConfig{Timeout: 300 * time.Millisecond, MaxAttempts: 1})}
+	webhooks := []*models.Webhook{
+		{ID: 1, URL: deadServer.URL},
+		{ID: 2, URL: slowServer.URL},
+		{ID: 3, URL: fastServer.URL},
+		{ID: 4, URL: fastServer.URL + "/generator", AlertTypes: []string{models.AlertGeneratorOff}},
+	}
+	payloads := []models.WebhookPayload{
+		{AlertType: models.AlertLowFuel, SiteID: 1},
+		{AlertType: models.AlertLowFuel, SiteID: 2},
+		{AlertType: models.AlertLowFuel, SiteID: 3},
+	}
+
+	start := time.Now()
+	n.deliver(context.Background(), webhooks, payloads)
+	elapsed := time.Since(start)
+
+	want := []int{1, 2, 3}
+	for name, endpoint := range map[string]*recordingEndpoint{"fast": fast, "slow": slow} {
+		endpoint.mu.Lock()
+		if len(endpoint.siteIDs) != len(want) {
+			t.Errorf("%s endpoint got sites %v, want %v", name, endpoint.siteIDs, want)
+		} else {
+			for i := range want {
+				if endpoint.siteIDs[i] != want[i] {
+					t.Errorf("%s endpoint got sites %v, want %v in order", name, endpoint.siteIDs, want)
+					break
+				}
+			}
+		}
+		endpoint.mu.Unlock()
+	}
+
+	// The fast endpoint is not held up behind the slow or dead ones
+	fast.mu.Lock()
+	if fastDone := fast.finished.Sub(start); fastDone > slowDelay {
+		t.Errorf("fast endpoint finished after %v, want under %v", fastDone, slowDelay)
+	}
+	fast.mu.Unlock()
+
+	// Dead endpoint: three timed-out requests of 300ms, run alongside the others
+	if elapsed > 1500*time.Millisecond {
+		t.Errorf("deliver took %v, want the endpoints delivered concurrently", elapsed)
+	}
+}
@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, keyed with
+// the webhook's secret and prefixed with "sha256="
+const SignatureHeader = "X-Webhook-Signature"
+
+// Sign returns the SignatureHeader value for body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender POSTs signed payloads to webhooks, retrying failed deliveries
+type Sender struct {
+	client       *http.Client
+	maxAttempts  int
+	retryBackoff time.Duration
+}
+
+// NewSender creates a Sender from the webhook configuration
+func NewSender(cfg config.WebhooksConfig) *Sender {
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return &Sender{
+		client:       &http.Client{Timeout: cfg.Timeout},
+		maxAttempts:  maxAttempts,
+		retryBackoff: cfg.RetryBackoff,
+	}
+}
+
+// Send delivers payload to the webhook. Network errors, 429 and 5xx responses
+// are retried with doubling backoff; other non-2xx responses fail immediately.
+// It returns the number of attempts made and the last response status (0 when
+// no response was received).
+func (s *Sender) Send(ctx context.Context, webhook *models.Webhook, payload models.WebhookPayload) (attempts, statusCode int, err error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	signature := Sign(webhook.Secret, body)
+
+	backoff := s.retryBackoff
+	for attempts = 1; attempts <= s.maxAttempts; attempts++ {
+		var retry bool
+		statusCode, retry, err = s.post(ctx, webhook.URL, body, signature)
+		if err == nil || !retry || attempts == s.maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempts, statusCode, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return attempts, statusCode, err
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying
+func (s *Sender) post(ctx context.Context, url string, body []byte, signature string) (statusCode int, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "fuel-monitor-api-webhooks")
+	req.Header.Set(SignatureHeader, signature)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, true, fmt.Errorf("webhook request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return resp.StatusCode, retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
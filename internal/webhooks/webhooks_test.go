@@ -0,0 +1,92 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"fuel-monitor-api/internal/config"
+	"fuel-monitor-api/internal/models"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		want   string
+	}{
+		// RFC 4231 test case 2
+		{"rfc 4231", "Jefe", "what do ya want for nothing?", "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{"empty", "", "", "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if Sign("secret-a", []byte("{}")) == Sign("secret-b", []byte("{}")) {
+		t.Error("signatures with different secrets are equal")
+	}
+}
+
+func TestSenderSend(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // response for each attempt; the last one repeats
+		wantAttempts int
+		wantStatus   int
+		wantErr      bool
+	}{
+		{"delivered", []int{http.StatusNoContent}, 1, http.StatusNoContent, false},
+		{"retried until delivered", []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK}, 3, http.StatusOK, false},
+		{"client error not retried", []int{http.StatusBadRequest}, 1, http.StatusBadRequest, true},
+		{"gives up after max attempts", []int{http.StatusServiceUnavailable}, 3, http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls int
+			var badSignatures int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if r.Header.Get(SignatureHeader) != Sign("hook-secret", body) {
+					badSignatures++
+				}
+				status := tt.statuses[len(tt.statuses)-1]
+				if calls < len(tt.statuses) {
+					status = tt.statuses[calls]
+				}
+				calls++
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			sender := NewSender(config.WebhooksConfig{Timeout: time.Second, MaxAttempts: 3, RetryBackoff: time.Millisecond})
+			webhook := &models.Webhook{ID: 1, URL: server.URL, Secret: "hook-secret"}
+			attempts, status, err := sender.Send(context.Background(), webhook, models.WebhookPayload{Event: "test", AlertType: "low_fuel"})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts || status != tt.wantStatus {
+				t.Errorf("Send = %d attempts, status %d, want %d attempts, status %d", attempts, status, tt.wantAttempts, tt.wantStatus)
+			}
+			if calls != tt.wantAttempts || badSignatures != 0 {
+				t.Errorf("server saw %d requests (%d badly signed), want %d signed requests", calls, badSignatures, tt.wantAttempts)
+			}
+		})
+	}
+}